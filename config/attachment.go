@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+var (
+	AttachmentStorageDir   string   // 本地附件存储目录
+	AttachmentMaxSize      int64    // 单个附件大小上限（字节）
+	AttachmentAllowedTypes []string // 允许上传的MIME类型
+	AttachmentThumbSizes   []int    // 图片缩略图的最长边，按从小到大排列
+)
+
+// LoadAttachment 加载附件相关配置
+func LoadAttachment(file *ini.File) {
+	section := file.Section("attachment")
+	AttachmentStorageDir = section.Key("StorageDir").MustString("./uploads")
+	AttachmentMaxSize = section.Key("MaxSize").MustInt64(10 << 20)
+
+	AttachmentAllowedTypes = nil
+	types := section.Key("AllowedTypes").MustString("image/jpeg,image/png,image/gif,application/pdf,text/plain")
+	for _, contentType := range strings.Split(types, ",") {
+		if contentType = strings.TrimSpace(contentType); contentType != "" {
+			AttachmentAllowedTypes = append(AttachmentAllowedTypes, contentType)
+		}
+	}
+
+	AttachmentThumbSizes = nil
+	for _, size := range strings.Split(section.Key("ThumbnailSizes").MustString("200,800"), ",") {
+		if parsed, err := strconv.Atoi(strings.TrimSpace(size)); err == nil && parsed > 0 {
+			AttachmentThumbSizes = append(AttachmentThumbSizes, parsed)
+		}
+	}
+	sort.Ints(AttachmentThumbSizes)
+}
+
+// PrintAttachmentConfig 打印附件配置
+func PrintAttachmentConfig() {
+	fmt.Println("\n=== 附件配置 ===")
+	fmt.Printf("存储目录: %s\n", AttachmentStorageDir)
+	fmt.Printf("大小上限: %d 字节\n", AttachmentMaxSize)
+	fmt.Printf("允许类型: %s\n", strings.Join(AttachmentAllowedTypes, ","))
+	fmt.Printf("缩略图尺寸: %v\n", AttachmentThumbSizes)
+}
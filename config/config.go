@@ -25,6 +25,11 @@ func Init() {
 	LoadMysqlData(file)
 	LoadMongoDB(file)
 	LoadRedisData(file)
+	LoadJWT(file)
+	LoadMessage(file)
+	LoadWebSocket(file)
+	LoadAttachment(file)
+	LoadGroup(file)
 
 	fmt.Println("配置文件加载完成!")
 
@@ -53,4 +58,9 @@ func PrintConfig() {
 	PrintMySQLConfig()
 	PrintMongoDBConfig()
 	PrintRedisConfig()
+	PrintJWTConfig()
+	PrintMessageConfig()
+	PrintWebSocketConfig()
+	PrintAttachmentConfig()
+	PrintGroupConfig()
 }
@@ -0,0 +1,22 @@
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/ini.v1"
+)
+
+var (
+	GroupMaxMembers int // 每个群的成员上限
+)
+
+// LoadGroup 加载群组相关配置
+func LoadGroup(file *ini.File) {
+	GroupMaxMembers = file.Section("group").Key("MaxMembers").MustInt(500)
+}
+
+// PrintGroupConfig 打印群组配置
+func PrintGroupConfig() {
+	fmt.Println("\n=== 群组配置 ===")
+	fmt.Printf("成员上限: %d\n", GroupMaxMembers)
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+var (
+	JwtSecret        string
+	JwtIssuer        string
+	JwtAccessExpire  time.Duration
+	JwtRefreshExpire time.Duration
+)
+
+// LoadJWT 加载JWT配置数据
+func LoadJWT(file *ini.File) {
+	JwtSecret = file.Section("jwt").Key("JwtSecret").String()
+	JwtIssuer = file.Section("jwt").Key("JwtIssuer").MustString("go_chat")
+	JwtAccessExpire = file.Section("jwt").Key("AccessExpire").MustDuration(2 * time.Hour)
+	JwtRefreshExpire = file.Section("jwt").Key("RefreshExpire").MustDuration(7 * 24 * time.Hour)
+}
+
+// PrintJWTConfig 打印JWT配置
+func PrintJWTConfig() {
+	fmt.Println("\n=== JWT配置 ===")
+	fmt.Printf("签发者: %s\n", JwtIssuer)
+	fmt.Printf("访问令牌有效期: %s\n", JwtAccessExpire)
+	fmt.Printf("刷新令牌有效期: %s\n", JwtRefreshExpire)
+}
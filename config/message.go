@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+var (
+	MessageDedupWindow    time.Duration // 客户端消息ID去重窗口
+	MessageAckTimeout     time.Duration // 等待客户端确认的超时时间，超时后重传
+	MessageInflightWindow int           // 每个连接未确认消息的上限
+	MessageMaxRetransmits int           // 最大重传次数，超过后断开连接
+	MessageEditWindow     time.Duration // 消息发出后允许编辑的时间
+	MessageRecallWindow   time.Duration // 消息发出后允许撤回的时间
+	MessageMaxReactions   int           // 每个用户对同一条消息最多使用的不同表情数
+)
+
+// LoadMessage 加载消息相关配置
+func LoadMessage(file *ini.File) {
+	MessageDedupWindow = file.Section("message").Key("DedupWindow").MustDuration(10 * time.Minute)
+	MessageAckTimeout = file.Section("message").Key("AckTimeout").MustDuration(15 * time.Second)
+	MessageInflightWindow = file.Section("message").Key("InflightWindow").MustInt(100)
+	MessageMaxRetransmits = file.Section("message").Key("MaxRetransmits").MustInt(3)
+	MessageEditWindow = file.Section("message").Key("EditWindow").MustDuration(15 * time.Minute)
+	MessageRecallWindow = file.Section("message").Key("RecallWindow").MustDuration(2 * time.Minute)
+	MessageMaxReactions = file.Section("message").Key("MaxReactions").MustInt(3)
+}
+
+// PrintMessageConfig 打印消息配置
+func PrintMessageConfig() {
+	fmt.Println("\n=== 消息配置 ===")
+	fmt.Printf("去重窗口: %s\n", MessageDedupWindow)
+	fmt.Printf("确认超时: %s\n", MessageAckTimeout)
+	fmt.Printf("在途窗口: %d\n", MessageInflightWindow)
+	fmt.Printf("最大重传次数: %d\n", MessageMaxRetransmits)
+	fmt.Printf("编辑时限: %s\n", MessageEditWindow)
+	fmt.Printf("撤回时限: %s\n", MessageRecallWindow)
+	fmt.Printf("每人表情回应上限: %d\n", MessageMaxReactions)
+}
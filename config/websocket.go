@@ -0,0 +1,26 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+var (
+	WebSocketResumeGrace  time.Duration // 断线后保留会话的宽限期，期间可凭恢复令牌续接
+	WebSocketResumeBuffer int           // 宽限期内缓存的推送消息上限，续接时与离线消息一起补发，需小于客户端发送缓冲区
+)
+
+// LoadWebSocket 加载WebSocket相关配置
+func LoadWebSocket(file *ini.File) {
+	WebSocketResumeGrace = file.Section("websocket").Key("ResumeGrace").MustDuration(30 * time.Second)
+	WebSocketResumeBuffer = file.Section("websocket").Key("ResumeBuffer").MustInt(100)
+}
+
+// PrintWebSocketConfig 打印WebSocket配置
+func PrintWebSocketConfig() {
+	fmt.Println("\n=== WebSocket配置 ===")
+	fmt.Printf("断线宽限期: %s\n", WebSocketResumeGrace)
+	fmt.Printf("宽限期缓存上限: %d\n", WebSocketResumeBuffer)
+}
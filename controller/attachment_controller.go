@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"go_chat/config"
+	"go_chat/middleware"
+	"go_chat/service"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+var attachmentService = service.NewAttachmentService()
+
+// UploadAttachment 上传附件
+// @Summary 上传附件
+// @Description 以multipart/form-data上传文件（字段名file），大小和类型受配置限制，返回的附件ID可在私聊消息的attachments中引用
+// @Tags 附件
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param file formData file true "附件文件"
+// @Success 200 {object} Response "上传成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 413 {object} Response "附件大小超过限制"
+// @Failure 415 {object} Response "不支持的附件类型"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/attachments [post]
+func UploadAttachment(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	// 为multipart头部预留1MB
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, config.AttachmentMaxSize+1<<20)
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		if strings.Contains(err.Error(), "request body too large") {
+			Error(c, http.StatusRequestEntityTooLarge, "附件大小超过限制")
+			return
+		}
+		Error(c, http.StatusBadRequest, "请选择要上传的文件")
+		return
+	}
+	defer file.Close()
+
+	attachment, err := attachmentService.Upload(userID, header.Filename, header.Size, file)
+	if err != nil {
+		logrus.Error("上传附件失败:", err)
+		respondAttachmentError(c, err)
+		return
+	}
+
+	Success(c, service.NewAttachmentResponse(attachment), "上传附件成功")
+}
+
+// DownloadAttachment 下载附件
+// @Summary 下载附件
+// @Description 只有上传者和收到引用该附件消息的会话参与者可以下载
+// @Tags 附件
+// @Produce octet-stream
+// @Security BearerAuth
+// @Param id path string true "附件ID"
+// @Success 200 {file} file "附件内容"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 404 {object} Response "附件不存在"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/attachments/{id} [get]
+func DownloadAttachment(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	attachment, reader, err := attachmentService.Open(userID, c.Param("id"))
+	if err != nil {
+		respondAttachmentError(c, err)
+		return
+	}
+	defer reader.Close()
+
+	// 图片直接展示，其他类型作为下载
+	disposition := "attachment"
+	if strings.HasPrefix(attachment.ContentType, "image/") {
+		disposition = "inline"
+	}
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, reader, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}),
+		"X-Content-Type-Options": "nosniff",
+		"Cache-Control":          "private, max-age=86400",
+	})
+}
+
+// DownloadThumbnail 下载图片附件的缩略图
+// @Summary 下载缩略图
+// @Description 获取图片附件指定尺寸的缩略图，权限与下载附件相同
+// @Tags 附件
+// @Produce image/jpeg,image/png
+// @Security BearerAuth
+// @Param id path string true "附件ID"
+// @Param size path int true "缩略图尺寸"
+// @Success 200 {file} file "缩略图内容"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 404 {object} Response "附件或缩略图不存在"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/attachments/{id}/thumbnails/{size} [get]
+func DownloadThumbnail(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	size, err := strconv.Atoi(c.Param("size"))
+	if err != nil || size <= 0 {
+		Error(c, http.StatusBadRequest, "缩略图尺寸格式错误")
+		return
+	}
+
+	thumbnail, reader, err := attachmentService.OpenThumbnail(userID, c.Param("id"), size)
+	if err != nil {
+		respondAttachmentError(c, err)
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusOK, -1, thumbnail.ContentType, reader, map[string]string{
+		"X-Content-Type-Options": "nosniff",
+		"Cache-Control":          "private, max-age=86400",
+	})
+}
+
+// respondAttachmentError 将附件操作的错误映射为HTTP状态码
+func respondAttachmentError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "附件不存在", "缩略图不存在":
+		Error(c, http.StatusNotFound, err.Error())
+	case "附件大小超过限制":
+		Error(c, http.StatusRequestEntityTooLarge, err.Error())
+	case "不支持的附件类型":
+		Error(c, http.StatusUnsupportedMediaType, err.Error())
+	case "附件ID格式错误", "附件不能为空", "图片解析失败", "图片尺寸超过限制":
+		Error(c, http.StatusBadRequest, err.Error())
+	default:
+		Error(c, http.StatusInternalServerError, err.Error())
+	}
+}
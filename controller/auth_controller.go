@@ -1,15 +1,26 @@
 package controller
 
 import (
+	"go_chat/middleware"
 	"go_chat/service"
+	"go_chat/websocket"
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
-var authService = service.NewAuthService()
+var (
+	authService = service.NewAuthService()
+
+	// wsHub WebSocket连接池，用于退出登录等场景下强制断开连接
+	wsHub *websocket.Hub
+)
+
+// SetHub 设置控制器使用的WebSocket连接池
+func SetHub(hub *websocket.Hub) {
+	wsHub = hub
+}
 
 // Response 统一响应结构
 type Response struct {
@@ -94,6 +105,10 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// 记录登录设备信息
+	req.UserAgent = c.Request.UserAgent()
+	req.IP = c.ClientIP()
+
 	// 调用服务层处理登录逻辑
 	user, err := authService.Login(req)
 	if err != nil {
@@ -109,35 +124,113 @@ func Login(c *gin.Context) {
 	Success(c, user, "登录成功")
 }
 
-// GetProfile 获取用户信息
+// Refresh 刷新令牌
+// @Summary 刷新令牌
+// @Description 使用刷新令牌换取新的访问令牌和刷新令牌，旧的刷新令牌立即失效
+// @Tags 认证
+// @Accept json
+// @Produce json
+// @Param refresh body service.RefreshRequest true "刷新令牌"
+// @Success 200 {object} Response "刷新成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "刷新令牌无效"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Failure 503 {object} Response "Redis不可用，无法轮换刷新令牌"
+// @Router /api/v1/auth/refresh [post]
+func Refresh(c *gin.Context) {
+	var req service.RefreshRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logrus.Error("参数绑定失败:", err)
+		Error(c, http.StatusBadRequest, "请求参数格式错误: "+err.Error())
+		return
+	}
+
+	result, err := authService.Refresh(req)
+	if err != nil {
+		logrus.Error("刷新令牌失败:", err)
+		switch err.Error() {
+		case "数据库连接不可用", "令牌签发失败":
+			Error(c, http.StatusInternalServerError, err.Error())
+		case "Redis连接不可用", "令牌校验失败":
+			Error(c, http.StatusServiceUnavailable, err.Error())
+		default:
+			Error(c, http.StatusUnauthorized, err.Error())
+		}
+		return
+	}
+
+	Success(c, result, "刷新令牌成功")
+}
+
+// Logout 退出登录
+// @Summary 退出登录
+// @Description 吊销当前访问令牌及同一会话的刷新令牌，并断开该会话的WebSocket连接
+// @Tags 认证
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param logout body service.LogoutRequest false "刷新令牌"
+// @Success 200 {object} Response "退出成功"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/auth/logout [post]
+func Logout(c *gin.Context) {
+	claims, ok := middleware.CurrentClaims(c)
+	if !ok {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	// 请求体可选
+	var req service.LogoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			Error(c, http.StatusBadRequest, "请求参数格式错误: "+err.Error())
+			return
+		}
+	}
+
+	if err := authService.Logout(claims, req); err != nil {
+		logrus.Error("退出登录失败:", err)
+		if err.Error() == "刷新令牌与当前会话不匹配" {
+			Error(c, http.StatusBadRequest, err.Error())
+		} else {
+			Error(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	// 断开该会话的WebSocket连接
+	if wsHub != nil {
+		wsHub.DisconnectSession(claims.SessionID, "已退出登录")
+	}
+
+	Success(c, nil, "退出登录成功")
+}
+
+// GetProfile 获取当前用户信息
 // @Summary 获取用户信息
-// @Description 根据用户ID获取用户详细信息
+// @Description 获取当前登录用户的详细信息，用户身份由访问令牌确定
 // @Tags 用户
 // @Accept json
 // @Produce json
-// @Param id query string true "用户ID"
+// @Security BearerAuth
 // @Success 200 {object} Response "获取成功"
-// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
 // @Failure 404 {object} Response "用户不存在"
 // @Failure 500 {object} Response "服务器内部错误"
 // @Router /api/v1/user/profile [get]
 func GetProfile(c *gin.Context) {
-	// 从查询参数获取用户ID
-	userIDStr := c.Query("id")
-	if userIDStr == "" {
-		Error(c, http.StatusBadRequest, "用户ID不能为空")
-		return
-	}
-
-	// 转换用户ID
-	userID, err := strconv.ParseUint(userIDStr, 10, 32)
-	if err != nil {
-		Error(c, http.StatusBadRequest, "用户ID格式错误")
+	// 从上下文获取当前用户ID，不接受调用方传入的ID
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
 		return
 	}
 
 	// 调用服务层获取用户信息
-	user, err := authService.GetUserByID(uint(userID))
+	user, err := authService.GetUserByID(userID)
 	if err != nil {
 		logrus.Error("获取用户信息失败:", err)
 		if err.Error() == "用户不存在" {
@@ -150,3 +243,22 @@ func GetProfile(c *gin.Context) {
 
 	Success(c, user, "获取用户信息成功")
 }
+
+// GetMe 获取当前登录用户
+// @Summary 获取当前用户
+// @Description 返回认证中间件写入上下文的当前用户
+// @Tags 用户
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} Response "获取成功"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Router /api/v1/user/me [get]
+func GetMe(c *gin.Context) {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	Success(c, user, "获取当前用户成功")
+}
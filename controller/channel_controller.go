@@ -0,0 +1,455 @@
+package controller
+
+import (
+	"go_chat/middleware"
+	"go_chat/model"
+	"go_chat/service"
+	"go_chat/websocket"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const maxChannelSyncLimit = 100 // 按序号读取频道消息时单次最多返回的条数
+
+var channelService = service.NewChannelService()
+
+// ListChannels 搜索频道
+// @Summary 频道列表
+// @Description 按名称或简介搜索频道，不传q时按订阅人数返回
+// @Tags 频道
+// @Produce json
+// @Security BearerAuth
+// @Param q query string false "搜索关键字"
+// @Param limit query int false "返回条数，默认20，最大100"
+// @Success 200 {object} Response "获取成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/channels [get]
+func ListChannels(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	var limit int
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 {
+			Error(c, http.StatusBadRequest, "limit格式错误")
+			return
+		}
+		limit = parsed
+	}
+
+	channels, err := channelService.ListChannels(userID, c.Query("q"), limit)
+	if err != nil {
+		logrus.Error("获取频道列表失败:", err)
+		Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	Success(c, gin.H{
+		"channels": channels,
+		"count":    len(channels),
+	}, "获取频道列表成功")
+}
+
+// CreateChannel 创建频道
+// @Summary 创建频道
+// @Description 创建广播频道，当前用户成为频道所有者并自动订阅
+// @Tags 频道
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param channel body service.CreateChannelRequest true "频道信息"
+// @Success 200 {object} Response "创建成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/channels [post]
+func CreateChannel(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	var req service.CreateChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, http.StatusBadRequest, "请求参数格式错误: "+err.Error())
+		return
+	}
+
+	channel, err := channelService.CreateChannel(userID, req)
+	if err != nil {
+		logrus.Error("创建频道失败:", err)
+		respondChannelError(c, err)
+		return
+	}
+
+	if wsHub != nil {
+		wsHub.SubscribeChannel(userID, channel.ID)
+	}
+	Success(c, channel, "创建频道成功")
+}
+
+// ListChannelSubscriptions 获取已订阅的频道
+// @Summary 已订阅频道
+// @Description 获取当前用户订阅的频道，包含各频道的最新序号、已读位置和未读数
+// @Tags 频道
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} Response "获取成功"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/channels/subscriptions [get]
+func ListChannelSubscriptions(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	channels, err := channelService.ListSubscriptions(userID)
+	if err != nil {
+		logrus.Error("获取已订阅频道失败:", err)
+		Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	Success(c, gin.H{
+		"channels": channels,
+		"count":    len(channels),
+	}, "获取已订阅频道成功")
+}
+
+// GetChannel 获取频道详情
+// @Summary 频道详情
+// @Description 获取频道信息和订阅人数，未订阅也可以查看
+// @Tags 频道
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "频道ID"
+// @Success 200 {object} Response "获取成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 404 {object} Response "频道不存在"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/channels/{id} [get]
+func GetChannel(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	channelID, ok := parsePathID(c, "id", "频道ID格式错误")
+	if !ok {
+		return
+	}
+
+	channel, err := channelService.GetChannelDetail(userID, channelID)
+	if err != nil {
+		respondChannelError(c, err)
+		return
+	}
+	Success(c, channel, "获取频道详情成功")
+}
+
+// SubscribeChannel 订阅频道
+// @Summary 订阅频道
+// @Description 订阅频道后在线时实时接收频道消息，已订阅时直接返回成功
+// @Tags 频道
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "频道ID"
+// @Success 200 {object} Response "订阅成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 404 {object} Response "频道不存在"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/channels/{id}/subscribe [post]
+func SubscribeChannel(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	channelID, ok := parsePathID(c, "id", "频道ID格式错误")
+	if !ok {
+		return
+	}
+
+	subscribed, err := channelService.Subscribe(userID, channelID)
+	if err != nil {
+		logrus.Error("订阅频道失败:", err)
+		respondChannelError(c, err)
+		return
+	}
+
+	if wsHub != nil {
+		wsHub.SubscribeChannel(userID, channelID)
+	}
+	Success(c, gin.H{"subscribed": subscribed}, "订阅频道成功")
+}
+
+// UnsubscribeChannel 取消订阅频道
+// @Summary 取消订阅频道
+// @Description 取消订阅后不再接收频道消息，频道所有者不能取消订阅
+// @Tags 频道
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "频道ID"
+// @Success 200 {object} Response "取消订阅成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "频道所有者不能取消订阅"
+// @Failure 404 {object} Response "频道不存在或未订阅"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/channels/{id}/unsubscribe [post]
+func UnsubscribeChannel(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	channelID, ok := parsePathID(c, "id", "频道ID格式错误")
+	if !ok {
+		return
+	}
+
+	if err := channelService.Unsubscribe(userID, channelID); err != nil {
+		logrus.Error("取消订阅频道失败:", err)
+		respondChannelError(c, err)
+		return
+	}
+
+	if wsHub != nil {
+		wsHub.UnsubscribeChannel(userID, channelID)
+	}
+	Success(c, nil, "取消订阅成功")
+}
+
+// AddChannelPublisher 指定频道发布者
+// @Summary 指定发布者
+// @Description 频道所有者将已订阅的用户设为发布者，发布者可以在频道中发送消息
+// @Tags 频道
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "频道ID"
+// @Param user_id path int true "用户ID"
+// @Success 200 {object} Response "设置成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "权限不足"
+// @Failure 404 {object} Response "频道不存在或用户未订阅"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/channels/{id}/publishers/{user_id} [put]
+func AddChannelPublisher(c *gin.Context) {
+	setChannelPublisher(c, true)
+}
+
+// RemoveChannelPublisher 取消频道发布者
+// @Summary 取消发布者
+// @Description 频道所有者取消用户的发布权限，用户仍保持订阅
+// @Tags 频道
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "频道ID"
+// @Param user_id path int true "用户ID"
+// @Success 200 {object} Response "取消成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "权限不足"
+// @Failure 404 {object} Response "频道不存在或用户未订阅"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/channels/{id}/publishers/{user_id} [delete]
+func RemoveChannelPublisher(c *gin.Context) {
+	setChannelPublisher(c, false)
+}
+
+// setChannelPublisher 设置或取消频道发布者
+func setChannelPublisher(c *gin.Context, publisher bool) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	channelID, ok := parsePathID(c, "id", "频道ID格式错误")
+	if !ok {
+		return
+	}
+	targetID, ok := parseMemberID(c)
+	if !ok {
+		return
+	}
+
+	if err := channelService.SetPublisher(userID, channelID, targetID, publisher); err != nil {
+		logrus.Error("设置频道发布者失败:", err)
+		respondChannelError(c, err)
+		return
+	}
+
+	role := model.ChannelRoleSubscriber
+	if publisher {
+		role = model.ChannelRolePublisher
+	}
+	Success(c, gin.H{"user_id": targetID, "role": role}, "设置发布者成功")
+}
+
+// GetChannelMessages 获取频道消息
+// @Summary 频道消息
+// @Description 只有订阅者可以读取。传after_seq时按序号升序返回该序号之后的消息，用于从已读位置补读；否则按时间倒序分页
+// @Tags 频道
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "频道ID"
+// @Param before query string false "分页游标，取上一页返回的next_cursor"
+// @Param after_seq query int false "起始序号，不包含该序号"
+// @Param limit query int false "每页条数，默认20，最大100"
+// @Success 200 {object} Response "获取成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 404 {object} Response "频道不存在或未订阅"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/channels/{id}/messages [get]
+func GetChannelMessages(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	channelID, ok := parsePathID(c, "id", "频道ID格式错误")
+	if !ok {
+		return
+	}
+
+	var limit int64
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || parsed <= 0 {
+			Error(c, http.StatusBadRequest, "limit格式错误")
+			return
+		}
+		limit = parsed
+	}
+
+	if _, err := channelService.GetChannel(channelID); err != nil {
+		respondChannelError(c, err)
+		return
+	}
+	if _, err := channelService.GetSubscriber(channelID, userID); err != nil {
+		respondChannelError(c, err)
+		return
+	}
+
+	sessionID := model.ChannelSessionID(channelID)
+	if afterSeqStr := c.Query("after_seq"); afterSeqStr != "" {
+		afterSeq, err := strconv.ParseInt(afterSeqStr, 10, 64)
+		if err != nil || afterSeq < 0 {
+			Error(c, http.StatusBadRequest, "after_seq格式错误")
+			return
+		}
+		if limit == 0 || limit > maxChannelSyncLimit {
+			limit = maxChannelSyncLimit
+		}
+
+		page, err := messageService.ListAfterSeq(sessionID, userID, afterSeq, limit)
+		if err != nil {
+			logrus.Error("获取频道消息失败:", err)
+			Error(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		Success(c, gin.H{
+			"messages": websocket.NewMessagesFromStored(page.Messages),
+			"has_more": page.Scanned == limit,
+		}, "获取频道消息成功")
+		return
+	}
+
+	page, err := messageService.ListHistory(sessionID, userID, c.Query("before"), limit)
+	if err != nil {
+		logrus.Error("获取频道消息失败:", err)
+		if err.Error() == "游标格式错误" {
+			Error(c, http.StatusBadRequest, err.Error())
+		} else {
+			Error(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	Success(c, gin.H{
+		"messages":    websocket.NewMessagesFromStored(page.Messages),
+		"next_cursor": page.NextCursor,
+		"has_more":    page.NextCursor != "",
+	}, "获取频道消息成功")
+}
+
+// MarkChannelRead 更新频道已读位置
+// @Summary 更新频道已读位置
+// @Description 将已读位置推进到指定序号，已读位置只会前进，超过最新序号时按最新序号记录
+// @Tags 频道
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "频道ID"
+// @Param read body service.UpdateReadSeqRequest true "已读序号"
+// @Success 200 {object} Response "更新成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 404 {object} Response "频道不存在或未订阅"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/channels/{id}/read [post]
+func MarkChannelRead(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	channelID, ok := parsePathID(c, "id", "频道ID格式错误")
+	if !ok {
+		return
+	}
+
+	var req service.UpdateReadSeqRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, http.StatusBadRequest, "请求参数格式错误: "+err.Error())
+		return
+	}
+
+	lastReadSeq, err := channelService.UpdateReadSeq(userID, channelID, req.Seq)
+	if err != nil {
+		logrus.Error("更新频道已读位置失败:", err)
+		respondChannelError(c, err)
+		return
+	}
+	Success(c, gin.H{
+		"channel_id":    channelID,
+		"last_read_seq": lastReadSeq,
+	}, "更新已读位置成功")
+}
+
+// respondChannelError 根据频道服务错误返回对应的HTTP状态码
+func respondChannelError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "频道不存在", "未订阅该频道", "该用户未订阅频道":
+		Error(c, http.StatusNotFound, err.Error())
+	case "权限不足", "没有发布权限", "频道所有者不能取消订阅", "不能修改频道所有者的角色":
+		Error(c, http.StatusForbidden, err.Error())
+	case "频道名称不能为空", "频道名称过长", "频道简介过长", "已读序号无效":
+		Error(c, http.StatusBadRequest, err.Error())
+	default:
+		Error(c, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,207 @@
+package controller
+
+import (
+	"go_chat/middleware"
+	"go_chat/model"
+	"go_chat/service"
+	"go_chat/websocket"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	messageService      = service.NewMessageService()
+	conversationService = service.NewConversationService()
+)
+
+// ListConversations 获取当前用户的会话列表
+// @Summary 会话列表
+// @Description 按最后消息时间倒序分页获取当前用户参与的会话，包含对方用户信息、最后一条可见消息预览和未读数
+// @Tags 会话
+// @Produce json
+// @Security BearerAuth
+// @Param before query string false "分页游标，取上一页返回的next_cursor"
+// @Param limit query int false "每页条数，默认20，最大100"
+// @Success 200 {object} Response "获取成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/conversations [get]
+func ListConversations(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	var limit int64
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || parsed <= 0 {
+			Error(c, http.StatusBadRequest, "limit格式错误")
+			return
+		}
+		limit = parsed
+	}
+
+	page, err := conversationService.ListConversations(userID, c.Query("before"), limit)
+	if err != nil {
+		logrus.Error("获取会话列表失败:", err)
+		if err.Error() == "游标格式错误" {
+			Error(c, http.StatusBadRequest, err.Error())
+		} else {
+			Error(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	Success(c, gin.H{
+		"conversations": page.Conversations,
+		"count":         len(page.Conversations),
+		"next_cursor":   page.NextCursor,
+		"has_more":      page.NextCursor != "",
+	}, "获取会话列表成功")
+}
+
+// MarkConversationRead 将与指定用户的会话标记为已读
+// @Summary 会话标记已读
+// @Description 记录已读位置并扣除该位置之前消息的未读数，同时向对方发送已读回执；未指定位置时视为全部已读
+// @Tags 会话
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param peer_id path int true "对方用户ID"
+// @Param read body websocket.ReadReceiptData false "已读位置(message_id或timestamp)"
+// @Success 200 {object} Response "标记成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 404 {object} Response "用户不存在"
+// @Router /api/v1/conversations/{peer_id}/read [post]
+func MarkConversationRead(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	peerID, ok := parsePeerID(c, userID)
+	if !ok {
+		return
+	}
+
+	// 请求体可选
+	var watermark websocket.ReadReceiptData
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&watermark); err != nil {
+			Error(c, http.StatusBadRequest, "请求参数格式错误: "+err.Error())
+			return
+		}
+	}
+
+	var receipt *websocket.ReadReceiptData
+	var err error
+	if wsHub != nil {
+		receipt, err = wsHub.MarkRead(userID, peerID, watermark)
+	} else {
+		// 没有WebSocket服务时只记录已读位置，不发送回执
+		receipt, err = websocket.ApplyRead(userID, peerID, watermark)
+	}
+	if err != nil {
+		logrus.Error("标记已读失败:", err)
+		switch err.Error() {
+		case "消息不存在":
+			Error(c, http.StatusNotFound, err.Error())
+		case "消息ID格式错误", "消息不属于该会话":
+			Error(c, http.StatusBadRequest, err.Error())
+		default:
+			Error(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	Success(c, receipt, "标记已读成功")
+}
+
+// GetConversationMessages 获取与指定用户的私聊历史消息
+// @Summary 私聊历史消息
+// @Description 按时间倒序分页获取当前用户与指定用户之间的历史消息，仅会话双方可以读取
+// @Tags 会话
+// @Produce json
+// @Security BearerAuth
+// @Param peer_id path int true "对方用户ID"
+// @Param before query string false "分页游标，取上一页返回的next_cursor"
+// @Param limit query int false "每页条数，默认20，最大100"
+// @Success 200 {object} Response "获取成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 404 {object} Response "用户不存在"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/conversations/{peer_id}/messages [get]
+func GetConversationMessages(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	peerID, ok := parsePeerID(c, userID)
+	if !ok {
+		return
+	}
+
+	var limit int64
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || parsed <= 0 {
+			Error(c, http.StatusBadRequest, "limit格式错误")
+			return
+		}
+		limit = parsed
+	}
+
+	// 会话ID由当前用户和对方共同确定，只能读取自己参与的会话
+	sessionID := model.PrivateSessionID(userID, peerID)
+	page, err := messageService.ListHistory(sessionID, userID, c.Query("before"), limit)
+	if err != nil {
+		logrus.Error("获取历史消息失败:", err)
+		if err.Error() == "游标格式错误" {
+			Error(c, http.StatusBadRequest, err.Error())
+		} else {
+			Error(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	Success(c, gin.H{
+		"messages":    websocket.NewMessagesFromStored(page.Messages),
+		"next_cursor": page.NextCursor,
+		"has_more":    page.NextCursor != "",
+	}, "获取历史消息成功")
+}
+
+// parsePeerID 解析路径中的对方用户ID并校验对方存在
+func parsePeerID(c *gin.Context, userID uint) (uint, bool) {
+	peerID, err := strconv.ParseUint(c.Param("peer_id"), 10, 32)
+	if err != nil || peerID == 0 {
+		Error(c, http.StatusBadRequest, "用户ID格式错误")
+		return 0, false
+	}
+
+	if uint(peerID) == userID {
+		Error(c, http.StatusBadRequest, "不能查看与自己的会话")
+		return 0, false
+	}
+
+	if _, err := authService.GetUserByID(uint(peerID)); err != nil {
+		if err.Error() == "用户不存在" {
+			Error(c, http.StatusNotFound, err.Error())
+		} else {
+			Error(c, http.StatusInternalServerError, err.Error())
+		}
+		return 0, false
+	}
+	return uint(peerID), true
+}
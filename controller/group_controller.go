@@ -0,0 +1,477 @@
+package controller
+
+import (
+	"go_chat/middleware"
+	"go_chat/model"
+	"go_chat/service"
+	"go_chat/websocket"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+var groupService = service.NewGroupService()
+
+// CreateGroup 创建群组
+// @Summary 创建群组
+// @Description 创建群组，当前用户成为群主，member_ids中的用户作为初始成员加入
+// @Tags 群组
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param group body service.CreateGroupRequest true "群组信息"
+// @Success 200 {object} Response "创建成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 404 {object} Response "用户不存在"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/groups [post]
+func CreateGroup(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	var req service.CreateGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, http.StatusBadRequest, "请求参数格式错误: "+err.Error())
+		return
+	}
+
+	group, err := groupService.CreateGroup(userID, req)
+	if err != nil {
+		logrus.Error("创建群组失败:", err)
+		respondGroupError(c, err)
+		return
+	}
+
+	if wsHub != nil {
+		wsHub.NotifyGroup(websocket.GroupEventData{
+			GroupID:    group.ID,
+			Event:      websocket.GroupEventCreated,
+			OperatorID: userID,
+			Name:       group.Name,
+		})
+	}
+	Success(c, group, "创建群组成功")
+}
+
+// ListGroups 获取当前用户加入的群组
+// @Summary 群组列表
+// @Description 获取当前用户加入的所有群组及自己在群中的角色
+// @Tags 群组
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} Response "获取成功"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/groups [get]
+func ListGroups(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	groups, err := groupService.ListGroups(userID)
+	if err != nil {
+		logrus.Error("获取群组列表失败:", err)
+		Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	Success(c, gin.H{
+		"groups": groups,
+		"count":  len(groups),
+	}, "获取群组列表成功")
+}
+
+// GetGroup 获取群组详情
+// @Summary 群组详情
+// @Description 获取群组信息和成员列表，只有群成员可以查看
+// @Tags 群组
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "群组ID"
+// @Success 200 {object} Response "获取成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "不是群成员"
+// @Failure 404 {object} Response "群组不存在"
+// @Router /api/v1/groups/{id} [get]
+func GetGroup(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	groupID, ok := parseGroupID(c)
+	if !ok {
+		return
+	}
+
+	group, members, err := groupService.GetGroupDetail(userID, groupID)
+	if err != nil {
+		respondGroupError(c, err)
+		return
+	}
+
+	Success(c, gin.H{
+		"group":   group,
+		"members": members,
+	}, "获取群组详情成功")
+}
+
+// RenameGroup 修改群名称
+// @Summary 修改群名称
+// @Description 群主或管理员修改群名称，并通知在线的群成员
+// @Tags 群组
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "群组ID"
+// @Param group body service.RenameGroupRequest true "新的群名称"
+// @Success 200 {object} Response "修改成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "权限不足"
+// @Failure 404 {object} Response "群组不存在"
+// @Router /api/v1/groups/{id} [put]
+func RenameGroup(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	groupID, ok := parseGroupID(c)
+	if !ok {
+		return
+	}
+
+	var req service.RenameGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, http.StatusBadRequest, "请求参数格式错误: "+err.Error())
+		return
+	}
+
+	group, err := groupService.RenameGroup(userID, groupID, req.Name)
+	if err != nil {
+		logrus.Error("修改群名称失败:", err)
+		respondGroupError(c, err)
+		return
+	}
+
+	if wsHub != nil {
+		wsHub.NotifyGroup(websocket.GroupEventData{
+			GroupID:    groupID,
+			Event:      websocket.GroupEventRenamed,
+			OperatorID: userID,
+			Name:       group.Name,
+		})
+	}
+	Success(c, group, "修改群名称成功")
+}
+
+// AddGroupMembers 添加群成员
+// @Summary 添加群成员
+// @Description 群主或管理员添加成员，已在群中的用户会被忽略
+// @Tags 群组
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "群组ID"
+// @Param members body service.AddMembersRequest true "要添加的用户ID"
+// @Success 200 {object} Response "添加成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "权限不足"
+// @Failure 404 {object} Response "群组或用户不存在"
+// @Router /api/v1/groups/{id}/members [post]
+func AddGroupMembers(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	groupID, ok := parseGroupID(c)
+	if !ok {
+		return
+	}
+
+	var req service.AddMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, http.StatusBadRequest, "请求参数格式错误: "+err.Error())
+		return
+	}
+
+	added, err := groupService.AddMembers(userID, groupID, req.UserIDs)
+	if err != nil {
+		logrus.Error("添加群成员失败:", err)
+		respondGroupError(c, err)
+		return
+	}
+
+	if wsHub != nil && len(added) > 0 {
+		wsHub.NotifyGroup(websocket.GroupEventData{
+			GroupID:    groupID,
+			Event:      websocket.GroupEventMembersAdded,
+			OperatorID: userID,
+			UserIDs:    added,
+		})
+	}
+	Success(c, gin.H{"added": added}, "添加群成员成功")
+}
+
+// RemoveGroupMember 移除群成员
+// @Summary 移除群成员
+// @Description 群主可以移除管理员和成员，管理员只能移除普通成员
+// @Tags 群组
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "群组ID"
+// @Param user_id path int true "成员用户ID"
+// @Success 200 {object} Response "移除成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "权限不足"
+// @Failure 404 {object} Response "群组或成员不存在"
+// @Router /api/v1/groups/{id}/members/{user_id} [delete]
+func RemoveGroupMember(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	groupID, ok := parseGroupID(c)
+	if !ok {
+		return
+	}
+	memberID, ok := parseMemberID(c)
+	if !ok {
+		return
+	}
+
+	if err := groupService.RemoveMember(userID, groupID, memberID); err != nil {
+		logrus.Error("移除群成员失败:", err)
+		respondGroupError(c, err)
+		return
+	}
+
+	if wsHub != nil {
+		// 被移除的成员同样收到通知
+		wsHub.NotifyGroup(websocket.GroupEventData{
+			GroupID:    groupID,
+			Event:      websocket.GroupEventMemberRemoved,
+			OperatorID: userID,
+			UserIDs:    []uint{memberID},
+		}, memberID)
+	}
+	Success(c, nil, "移除群成员成功")
+}
+
+// SetGroupMemberRole 设置群成员角色
+// @Summary 设置成员角色
+// @Description 群主将成员设置为管理员或普通成员
+// @Tags 群组
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "群组ID"
+// @Param user_id path int true "成员用户ID"
+// @Param role body service.SetMemberRoleRequest true "角色(admin/member)"
+// @Success 200 {object} Response "设置成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "权限不足"
+// @Failure 404 {object} Response "群组或成员不存在"
+// @Router /api/v1/groups/{id}/members/{user_id}/role [put]
+func SetGroupMemberRole(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	groupID, ok := parseGroupID(c)
+	if !ok {
+		return
+	}
+	memberID, ok := parseMemberID(c)
+	if !ok {
+		return
+	}
+
+	var req service.SetMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, http.StatusBadRequest, "请求参数格式错误: "+err.Error())
+		return
+	}
+
+	if err := groupService.SetMemberRole(userID, groupID, memberID, req.Role); err != nil {
+		logrus.Error("设置成员角色失败:", err)
+		respondGroupError(c, err)
+		return
+	}
+
+	if wsHub != nil {
+		wsHub.NotifyGroup(websocket.GroupEventData{
+			GroupID:    groupID,
+			Event:      websocket.GroupEventRoleChanged,
+			OperatorID: userID,
+			UserIDs:    []uint{memberID},
+			Role:       req.Role,
+		})
+	}
+	Success(c, nil, "设置成员角色成功")
+}
+
+// LeaveGroup 退出群组
+// @Summary 退出群组
+// @Description 当前用户退出群组；群主退出时群主转让给最早加入的管理员或成员，最后一名成员退出后群组解散
+// @Tags 群组
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "群组ID"
+// @Success 200 {object} Response "退出成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "不是群成员"
+// @Failure 404 {object} Response "群组不存在"
+// @Router /api/v1/groups/{id}/leave [post]
+func LeaveGroup(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	groupID, ok := parseGroupID(c)
+	if !ok {
+		return
+	}
+
+	newOwnerID, err := groupService.LeaveGroup(userID, groupID)
+	if err != nil {
+		logrus.Error("退出群组失败:", err)
+		respondGroupError(c, err)
+		return
+	}
+
+	if wsHub != nil {
+		wsHub.NotifyGroup(websocket.GroupEventData{
+			GroupID:    groupID,
+			Event:      websocket.GroupEventMemberLeft,
+			OperatorID: userID,
+			UserIDs:    []uint{userID},
+			NewOwnerID: newOwnerID,
+		})
+	}
+	Success(c, gin.H{"new_owner_id": newOwnerID}, "退出群组成功")
+}
+
+// GetGroupMessages 获取群聊历史消息
+// @Summary 群聊历史消息
+// @Description 按时间倒序分页获取群聊历史消息，只有群成员可以读取
+// @Tags 群组
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "群组ID"
+// @Param before query string false "分页游标，取上一页返回的next_cursor"
+// @Param limit query int false "每页条数，默认20，最大100"
+// @Success 200 {object} Response "获取成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "不是群成员"
+// @Failure 404 {object} Response "群组不存在"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/groups/{id}/messages [get]
+func GetGroupMessages(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	groupID, ok := parseGroupID(c)
+	if !ok {
+		return
+	}
+
+	var limit int64
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || parsed <= 0 {
+			Error(c, http.StatusBadRequest, "limit格式错误")
+			return
+		}
+		limit = parsed
+	}
+
+	if _, err := groupService.GetGroup(groupID); err != nil {
+		respondGroupError(c, err)
+		return
+	}
+	if _, err := groupService.GetMember(groupID, userID); err != nil {
+		respondGroupError(c, err)
+		return
+	}
+
+	page, err := messageService.ListHistory(model.GroupSessionID(groupID), userID, c.Query("before"), limit)
+	if err != nil {
+		logrus.Error("获取群聊历史消息失败:", err)
+		if err.Error() == "游标格式错误" {
+			Error(c, http.StatusBadRequest, err.Error())
+		} else {
+			Error(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	Success(c, gin.H{
+		"messages":    websocket.NewMessagesFromStored(page.Messages),
+		"next_cursor": page.NextCursor,
+		"has_more":    page.NextCursor != "",
+	}, "获取群聊历史消息成功")
+}
+
+// parseGroupID 解析路径中的群组ID
+func parseGroupID(c *gin.Context) (uint, bool) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || groupID == 0 {
+		Error(c, http.StatusBadRequest, "群组ID格式错误")
+		return 0, false
+	}
+	return uint(groupID), true
+}
+
+// parseMemberID 解析路径中的成员用户ID
+func parseMemberID(c *gin.Context) (uint, bool) {
+	memberID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil || memberID == 0 {
+		Error(c, http.StatusBadRequest, "用户ID格式错误")
+		return 0, false
+	}
+	return uint(memberID), true
+}
+
+// respondGroupError 将群组操作的错误映射为HTTP状态码
+func respondGroupError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "群组不存在", "用户不存在", "该用户不是群成员":
+		Error(c, http.StatusNotFound, err.Error())
+	case "不是群成员", "权限不足", "已被禁止加入该群组":
+		Error(c, http.StatusForbidden, err.Error())
+	case "群名称不能为空", "群名称过长", "群成员数量超过限制", "角色无效",
+		"不能移除自己，请使用退出群组", "不能修改自己的角色":
+		Error(c, http.StatusBadRequest, err.Error())
+	default:
+		Error(c, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,300 @@
+package controller
+
+import (
+	"go_chat/middleware"
+	"go_chat/model"
+	"go_chat/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// CreateGroupInvite 创建群邀请链接
+// @Summary 创建邀请链接
+// @Description 群主或管理员创建邀请链接，可设置有效期、最大使用次数以及是否需要审批
+// @Tags 群组邀请
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "群组ID"
+// @Param invite body service.CreateInviteRequest true "邀请链接设置"
+// @Success 200 {object} Response "创建成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "权限不足"
+// @Failure 404 {object} Response "群组不存在"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/groups/{id}/invites [post]
+func CreateGroupInvite(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	groupID, ok := parseGroupID(c)
+	if !ok {
+		return
+	}
+
+	var req service.CreateInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, http.StatusBadRequest, "请求参数格式错误: "+err.Error())
+		return
+	}
+
+	invite, err := groupService.CreateInvite(userID, groupID, req)
+	if err != nil {
+		logrus.Error("创建邀请链接失败:", err)
+		respondInviteError(c, err)
+		return
+	}
+	Success(c, invite, "创建邀请链接成功")
+}
+
+// ListGroupInvites 获取群邀请链接列表
+// @Summary 邀请链接列表
+// @Description 群主或管理员查看群组的全部邀请链接，包括已过期、已用完和已撤销的链接
+// @Tags 群组邀请
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "群组ID"
+// @Success 200 {object} Response "获取成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "权限不足"
+// @Failure 404 {object} Response "群组不存在"
+// @Router /api/v1/groups/{id}/invites [get]
+func ListGroupInvites(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	groupID, ok := parseGroupID(c)
+	if !ok {
+		return
+	}
+
+	invites, err := groupService.ListInvites(userID, groupID)
+	if err != nil {
+		respondInviteError(c, err)
+		return
+	}
+
+	Success(c, gin.H{
+		"invites": invites,
+		"count":   len(invites),
+	}, "获取邀请链接成功")
+}
+
+// RevokeGroupInvite 撤销群邀请链接
+// @Summary 撤销邀请链接
+// @Description 群主或管理员撤销邀请链接，撤销后链接不能再使用
+// @Tags 群组邀请
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "群组ID"
+// @Param invite_id path int true "邀请链接ID"
+// @Success 200 {object} Response "撤销成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "权限不足"
+// @Failure 404 {object} Response "邀请链接不存在"
+// @Router /api/v1/groups/{id}/invites/{invite_id} [delete]
+func RevokeGroupInvite(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	groupID, ok := parseGroupID(c)
+	if !ok {
+		return
+	}
+	inviteID, ok := parsePathID(c, "invite_id", "邀请链接ID格式错误")
+	if !ok {
+		return
+	}
+
+	if err := groupService.RevokeInvite(userID, groupID, inviteID); err != nil {
+		logrus.Error("撤销邀请链接失败:", err)
+		respondInviteError(c, err)
+		return
+	}
+	Success(c, nil, "撤销邀请链接成功")
+}
+
+// RedeemInvite 使用邀请链接
+// @Summary 使用邀请链接
+// @Description 通过邀请链接加入群组，加入后群内会收到一条系统入群消息；需要审批的链接会提交入群申请
+// @Tags 群组邀请
+// @Produce json
+// @Security BearerAuth
+// @Param token path string true "邀请链接令牌"
+// @Success 200 {object} Response "status为joined、pending或already_member"
+// @Failure 400 {object} Response "群成员数量超过限制"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "邀请链接已过期或使用次数已达上限"
+// @Failure 404 {object} Response "邀请链接无效"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/invites/{token}/redeem [post]
+func RedeemInvite(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	result, err := groupService.RedeemInvite(userID, c.Param("token"))
+	if err != nil {
+		logrus.Error("使用邀请链接失败:", err)
+		respondInviteError(c, err)
+		return
+	}
+
+	if wsHub != nil && result.Status == service.RedeemStatusJoined {
+		wsHub.AnnounceGroupJoin(result.GroupID, userID, userID)
+	}
+	Success(c, result, "使用邀请链接成功")
+}
+
+// ListJoinRequests 获取入群申请列表
+// @Summary 入群申请列表
+// @Description 群主或管理员查看通过需要审批的邀请链接提交的入群申请
+// @Tags 群组邀请
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "群组ID"
+// @Param status query string false "申请状态：pending（默认）、approved或rejected"
+// @Success 200 {object} Response "获取成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "权限不足"
+// @Failure 404 {object} Response "群组不存在"
+// @Router /api/v1/groups/{id}/join-requests [get]
+func ListJoinRequests(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	groupID, ok := parseGroupID(c)
+	if !ok {
+		return
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", model.JoinRequestPending, model.JoinRequestApproved, model.JoinRequestRejected:
+	default:
+		Error(c, http.StatusBadRequest, "申请状态无效")
+		return
+	}
+
+	requests, err := groupService.ListJoinRequests(userID, groupID, status)
+	if err != nil {
+		respondInviteError(c, err)
+		return
+	}
+
+	Success(c, gin.H{
+		"requests": requests,
+		"count":    len(requests),
+	}, "获取入群申请成功")
+}
+
+// ApproveJoinRequest 通过入群申请
+// @Summary 通过入群申请
+// @Description 群主或管理员通过入群申请，申请人加入群组，群内会收到一条系统入群消息
+// @Tags 群组邀请
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "群组ID"
+// @Param request_id path int true "入群申请ID"
+// @Success 200 {object} Response "审批成功"
+// @Failure 400 {object} Response "入群申请已处理"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "权限不足"
+// @Failure 404 {object} Response "入群申请不存在"
+// @Router /api/v1/groups/{id}/join-requests/{request_id}/approve [post]
+func ApproveJoinRequest(c *gin.Context) {
+	reviewJoinRequest(c, true)
+}
+
+// RejectJoinRequest 拒绝入群申请
+// @Summary 拒绝入群申请
+// @Description 群主或管理员拒绝入群申请
+// @Tags 群组邀请
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "群组ID"
+// @Param request_id path int true "入群申请ID"
+// @Success 200 {object} Response "审批成功"
+// @Failure 400 {object} Response "入群申请已处理"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "权限不足"
+// @Failure 404 {object} Response "入群申请不存在"
+// @Router /api/v1/groups/{id}/join-requests/{request_id}/reject [post]
+func RejectJoinRequest(c *gin.Context) {
+	reviewJoinRequest(c, false)
+}
+
+// reviewJoinRequest 审批入群申请，通过后推送系统入群消息
+func reviewJoinRequest(c *gin.Context, approve bool) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	groupID, ok := parseGroupID(c)
+	if !ok {
+		return
+	}
+	requestID, ok := parsePathID(c, "request_id", "入群申请ID格式错误")
+	if !ok {
+		return
+	}
+
+	applicantID, joined, err := groupService.ReviewJoinRequest(userID, groupID, requestID, approve)
+	if err != nil {
+		logrus.Error("审批入群申请失败:", err)
+		respondInviteError(c, err)
+		return
+	}
+
+	if wsHub != nil && joined {
+		wsHub.AnnounceGroupJoin(groupID, applicantID, userID)
+	}
+	Success(c, gin.H{"user_id": applicantID, "joined": joined}, "审批入群申请成功")
+}
+
+// parsePathID 解析路径中的数字ID
+func parsePathID(c *gin.Context, name, message string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil || id == 0 {
+		Error(c, http.StatusBadRequest, message)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// respondInviteError 根据邀请链接和入群申请相关错误返回对应的HTTP状态码
+func respondInviteError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "邀请链接无效", "邀请链接不存在", "入群申请不存在":
+		Error(c, http.StatusNotFound, err.Error())
+	case "邀请链接已过期", "邀请链接使用次数已达上限":
+		Error(c, http.StatusForbidden, err.Error())
+	case "有效期无效", "使用次数无效", "入群申请已处理":
+		Error(c, http.StatusBadRequest, err.Error())
+	default:
+		respondGroupError(c, err)
+	}
+}
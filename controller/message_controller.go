@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"go_chat/middleware"
+	"go_chat/model"
+	"go_chat/service"
+	"go_chat/websocket"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// EditMessage 编辑自己发送的私聊消息
+// @Summary 编辑消息
+// @Description 发送者在编辑时限内修改消息内容，编辑前的内容保留在编辑历史中，新内容实时推送给会话双方
+// @Tags 消息
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "消息ID"
+// @Param edit body service.EditMessageRequest true "新的消息内容"
+// @Success 200 {object} Response "编辑成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "无权编辑或已超过编辑时限"
+// @Failure 404 {object} Response "消息不存在"
+// @Failure 409 {object} Response "消息已被修改"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/messages/{id} [put]
+func EditMessage(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	var req service.EditMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, http.StatusBadRequest, "请求参数格式错误: "+err.Error())
+		return
+	}
+
+	var (
+		edited *websocket.Message
+		err    error
+	)
+	if wsHub != nil {
+		edited, err = wsHub.EditMessage(userID, c.Param("id"), req.Content)
+	} else {
+		var stored *model.ChatMessage
+		if stored, err = messageService.EditMessage(userID, c.Param("id"), req.Content); err == nil {
+			edited = websocket.NewMessageFromStored(stored)
+		}
+	}
+	if err != nil {
+		logrus.Error("编辑消息失败:", err)
+		respondMessageError(c, err)
+		return
+	}
+
+	Success(c, edited, "编辑消息成功")
+}
+
+// RecallMessage 撤回自己发送的私聊消息
+// @Summary 撤回消息
+// @Description 发送者在撤回时限内撤回消息，会话双方的记录中只保留撤回标记，并实时通知对方
+// @Tags 消息
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "消息ID"
+// @Success 200 {object} Response "撤回成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "无权撤回或已超过撤回时限"
+// @Failure 404 {object} Response "消息不存在"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/messages/{id}/recall [post]
+func RecallMessage(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	var (
+		recalled *websocket.Message
+		err      error
+	)
+	if wsHub != nil {
+		recalled, err = wsHub.RecallMessage(userID, c.Param("id"))
+	} else {
+		var stored *model.ChatMessage
+		if stored, err = messageService.RecallMessage(userID, c.Param("id")); err == nil {
+			recalled = websocket.NewMessageFromStored(stored)
+		}
+	}
+	if err != nil {
+		logrus.Error("撤回消息失败:", err)
+		respondMessageError(c, err)
+		return
+	}
+
+	Success(c, recalled, "撤回消息成功")
+}
+
+// DeleteMessage 从自己的记录中删除消息
+// @Summary 删除消息
+// @Description 会话任一方将消息从自己的历史记录和离线补发中删除，不影响对方
+// @Tags 消息
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "消息ID"
+// @Success 200 {object} Response "删除成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 404 {object} Response "消息不存在"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/messages/{id} [delete]
+func DeleteMessage(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	if err := messageService.HideMessage(userID, c.Param("id")); err != nil {
+		logrus.Error("删除消息失败:", err)
+		respondMessageError(c, err)
+		return
+	}
+
+	Success(c, nil, "删除消息成功")
+}
+
+// respondMessageError 将消息操作的错误映射为HTTP状态码
+func respondMessageError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "消息不存在":
+		Error(c, http.StatusNotFound, err.Error())
+	case "只能编辑自己发送的消息", "已超过可编辑时间", "只能撤回自己发送的消息", "已超过可撤回时间":
+		Error(c, http.StatusForbidden, err.Error())
+	case "消息已被修改，请重试", "消息已撤回":
+		Error(c, http.StatusConflict, err.Error())
+	case "消息ID格式错误", "消息内容不能为空", "消息内容过长", "消息内容未变化":
+		Error(c, http.StatusBadRequest, err.Error())
+	default:
+		Error(c, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,305 @@
+package controller
+
+import (
+	"go_chat/middleware"
+	"go_chat/service"
+	"go_chat/websocket"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// MuteGroupMember 禁言群成员
+// @Summary 禁言群成员
+// @Description 群主或管理员禁言角色比自己低的成员，禁言期间发送群聊消息会收到错误码4032
+// @Tags 群组管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "群组ID"
+// @Param user_id path int true "成员用户ID"
+// @Param mute body service.MuteMemberRequest true "禁言秒数，最长30天"
+// @Success 200 {object} Response "禁言成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "权限不足"
+// @Failure 404 {object} Response "群组或成员不存在"
+// @Router /api/v1/groups/{id}/members/{user_id}/mute [put]
+func MuteGroupMember(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	groupID, ok := parseGroupID(c)
+	if !ok {
+		return
+	}
+	memberID, ok := parseMemberID(c)
+	if !ok {
+		return
+	}
+
+	var req service.MuteMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, http.StatusBadRequest, "请求参数格式错误: "+err.Error())
+		return
+	}
+
+	until, err := groupService.MuteMember(userID, groupID, memberID, req.Duration)
+	if err != nil {
+		logrus.Error("禁言群成员失败:", err)
+		respondModerationError(c, err)
+		return
+	}
+
+	if wsHub != nil {
+		wsHub.NotifyGroup(websocket.GroupEventData{
+			GroupID:    groupID,
+			Event:      websocket.GroupEventMemberMuted,
+			OperatorID: userID,
+			UserIDs:    []uint{memberID},
+			MutedUntil: until,
+		})
+	}
+	Success(c, gin.H{"muted_until": until}, "禁言成功")
+}
+
+// UnmuteGroupMember 解除群成员禁言
+// @Summary 解除禁言
+// @Description 群主或管理员解除角色比自己低的成员的禁言
+// @Tags 群组管理
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "群组ID"
+// @Param user_id path int true "成员用户ID"
+// @Success 200 {object} Response "解除成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "权限不足"
+// @Failure 404 {object} Response "群组或成员不存在"
+// @Router /api/v1/groups/{id}/members/{user_id}/mute [delete]
+func UnmuteGroupMember(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	groupID, ok := parseGroupID(c)
+	if !ok {
+		return
+	}
+	memberID, ok := parseMemberID(c)
+	if !ok {
+		return
+	}
+
+	if err := groupService.UnmuteMember(userID, groupID, memberID); err != nil {
+		logrus.Error("解除禁言失败:", err)
+		respondModerationError(c, err)
+		return
+	}
+
+	if wsHub != nil {
+		wsHub.NotifyGroup(websocket.GroupEventData{
+			GroupID:    groupID,
+			Event:      websocket.GroupEventMemberUnmuted,
+			OperatorID: userID,
+			UserIDs:    []uint{memberID},
+		})
+	}
+	Success(c, nil, "解除禁言成功")
+}
+
+// SetGroupSlowMode 设置慢速模式
+// @Summary 设置慢速模式
+// @Description 群主或管理员设置普通成员两次发言的最小间隔，0表示关闭；间隔内再次发言会收到错误码4291
+// @Tags 群组管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "群组ID"
+// @Param slow_mode body service.SetSlowModeRequest true "间隔秒数，最长3600"
+// @Success 200 {object} Response "设置成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "权限不足"
+// @Failure 404 {object} Response "群组不存在"
+// @Router /api/v1/groups/{id}/slow-mode [put]
+func SetGroupSlowMode(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	groupID, ok := parseGroupID(c)
+	if !ok {
+		return
+	}
+
+	var req service.SetSlowModeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, http.StatusBadRequest, "请求参数格式错误: "+err.Error())
+		return
+	}
+
+	if err := groupService.SetSlowMode(userID, groupID, req.Interval); err != nil {
+		logrus.Error("设置慢速模式失败:", err)
+		respondModerationError(c, err)
+		return
+	}
+
+	if wsHub != nil {
+		wsHub.NotifyGroup(websocket.GroupEventData{
+			GroupID:    groupID,
+			Event:      websocket.GroupEventSlowMode,
+			OperatorID: userID,
+			SlowMode:   &req.Interval,
+		})
+	}
+	Success(c, gin.H{"slow_mode": req.Interval}, "设置慢速模式成功")
+}
+
+// BanGroupMember 封禁用户
+// @Summary 封禁用户
+// @Description 群主或管理员封禁用户：已在群中的成员被移出，之后不能通过添加成员、公开房间或邀请链接再次加入
+// @Tags 群组管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "群组ID"
+// @Param ban body service.BanMemberRequest true "封禁的用户和原因"
+// @Success 200 {object} Response "封禁成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "权限不足"
+// @Failure 404 {object} Response "群组或用户不存在"
+// @Router /api/v1/groups/{id}/bans [post]
+func BanGroupMember(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	groupID, ok := parseGroupID(c)
+	if !ok {
+		return
+	}
+
+	var req service.BanMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, http.StatusBadRequest, "请求参数格式错误: "+err.Error())
+		return
+	}
+
+	removed, err := groupService.BanMember(userID, groupID, req)
+	if err != nil {
+		logrus.Error("封禁用户失败:", err)
+		respondModerationError(c, err)
+		return
+	}
+
+	if wsHub != nil && removed {
+		// 被封禁的成员同样收到通知
+		wsHub.NotifyGroup(websocket.GroupEventData{
+			GroupID:    groupID,
+			Event:      websocket.GroupEventMemberBanned,
+			OperatorID: userID,
+			UserIDs:    []uint{req.UserID},
+			Reason:     req.Reason,
+		}, req.UserID)
+	}
+	Success(c, gin.H{"removed": removed}, "封禁成功")
+}
+
+// UnbanGroupMember 解除封禁
+// @Summary 解除封禁
+// @Description 群主或管理员解除封禁，解除后用户可以重新加入，但不会自动回到群中
+// @Tags 群组管理
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "群组ID"
+// @Param user_id path int true "用户ID"
+// @Success 200 {object} Response "解除成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "权限不足"
+// @Failure 404 {object} Response "该用户未被封禁"
+// @Router /api/v1/groups/{id}/bans/{user_id} [delete]
+func UnbanGroupMember(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	groupID, ok := parseGroupID(c)
+	if !ok {
+		return
+	}
+	memberID, ok := parseMemberID(c)
+	if !ok {
+		return
+	}
+
+	if err := groupService.UnbanMember(userID, groupID, memberID); err != nil {
+		logrus.Error("解除封禁失败:", err)
+		respondModerationError(c, err)
+		return
+	}
+	Success(c, nil, "解除封禁成功")
+}
+
+// ListGroupBans 获取封禁列表
+// @Summary 封禁列表
+// @Description 群主或管理员查看群组的封禁用户
+// @Tags 群组管理
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "群组ID"
+// @Success 200 {object} Response "获取成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "权限不足"
+// @Failure 404 {object} Response "群组不存在"
+// @Router /api/v1/groups/{id}/bans [get]
+func ListGroupBans(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	groupID, ok := parseGroupID(c)
+	if !ok {
+		return
+	}
+
+	bans, err := groupService.ListBans(userID, groupID)
+	if err != nil {
+		respondModerationError(c, err)
+		return
+	}
+
+	Success(c, gin.H{
+		"bans":  bans,
+		"count": len(bans),
+	}, "获取封禁列表成功")
+}
+
+// respondModerationError 根据群组管理相关错误返回对应的HTTP状态码
+func respondModerationError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "该用户未被封禁":
+		Error(c, http.StatusNotFound, err.Error())
+	case "禁言时长无效", "慢速模式间隔无效", "封禁原因过长", "不能封禁自己", "不能对自己执行该操作":
+		Error(c, http.StatusBadRequest, err.Error())
+	default:
+		respondGroupError(c, err)
+	}
+}
@@ -0,0 +1,239 @@
+package controller
+
+import (
+	"go_chat/middleware"
+	"go_chat/service"
+	"go_chat/websocket"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ListRooms 搜索公开房间
+// @Summary 公开房间列表
+// @Description 按名称或主题搜索公开房间，不传q时返回最新创建的房间
+// @Tags 房间
+// @Produce json
+// @Security BearerAuth
+// @Param q query string false "搜索关键字"
+// @Param limit query int false "返回条数，默认20，最大100"
+// @Success 200 {object} Response "获取成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/rooms [get]
+func ListRooms(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	var limit int
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 {
+			Error(c, http.StatusBadRequest, "limit格式错误")
+			return
+		}
+		limit = parsed
+	}
+
+	rooms, err := groupService.ListRooms(userID, c.Query("q"), limit)
+	if err != nil {
+		logrus.Error("获取房间列表失败:", err)
+		Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	Success(c, gin.H{
+		"rooms": rooms,
+		"count": len(rooms),
+	}, "获取房间列表成功")
+}
+
+// CreateRoom 创建公开房间
+// @Summary 创建公开房间
+// @Description 创建任何人都可以搜索和加入的公开房间，当前用户成为群主
+// @Tags 房间
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param room body service.CreateRoomRequest true "房间信息"
+// @Success 200 {object} Response "创建成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/rooms [post]
+func CreateRoom(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	var req service.CreateRoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, http.StatusBadRequest, "请求参数格式错误: "+err.Error())
+		return
+	}
+
+	room, err := groupService.CreateRoom(userID, req)
+	if err != nil {
+		logrus.Error("创建房间失败:", err)
+		respondRoomError(c, err)
+		return
+	}
+
+	if wsHub != nil {
+		wsHub.JoinRoom(userID, room.ID, false)
+	}
+	Success(c, room, "创建房间成功")
+}
+
+// JoinRoom 加入公开房间
+// @Summary 加入公开房间
+// @Description 加入公开房间，房间内的在线成员会收到join消息；已加入时直接返回成功
+// @Tags 房间
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "房间ID"
+// @Success 200 {object} Response "加入成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 403 {object} Response "群成员数量超过限制或已被禁止加入"
+// @Failure 404 {object} Response "房间不存在"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/rooms/{id}/join [post]
+func JoinRoom(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	roomID, ok := parseRoomID(c)
+	if !ok {
+		return
+	}
+
+	joined, err := groupService.JoinRoom(userID, roomID)
+	if err != nil {
+		logrus.Error("加入房间失败:", err)
+		respondRoomError(c, err)
+		return
+	}
+
+	if wsHub != nil {
+		wsHub.JoinRoom(userID, roomID, joined)
+	}
+	Success(c, gin.H{"joined": joined}, "加入房间成功")
+}
+
+// LeaveRoom 退出公开房间
+// @Summary 退出公开房间
+// @Description 退出公开房间，房间内的在线成员会收到leave消息；群主退出时自动转让，最后一名成员退出后房间解散
+// @Tags 房间
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "房间ID"
+// @Success 200 {object} Response "退出成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 404 {object} Response "房间不存在或不是房间成员"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/rooms/{id}/leave [post]
+func LeaveRoom(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	roomID, ok := parseRoomID(c)
+	if !ok {
+		return
+	}
+
+	newOwnerID, err := groupService.LeaveRoom(userID, roomID)
+	if err != nil {
+		logrus.Error("退出房间失败:", err)
+		respondRoomError(c, err)
+		return
+	}
+
+	if wsHub != nil {
+		wsHub.LeaveRoom(userID, roomID, newOwnerID)
+	}
+	Success(c, gin.H{"new_owner_id": newOwnerID}, "退出房间成功")
+}
+
+// GetRoomPresence 获取房间在线成员
+// @Summary 房间在线成员
+// @Description 获取公开房间中当前在线的成员，未加入房间也可以查看
+// @Tags 房间
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "房间ID"
+// @Success 200 {object} Response "获取成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 404 {object} Response "房间不存在"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/rooms/{id}/presence [get]
+func GetRoomPresence(c *gin.Context) {
+	if middleware.CurrentUserID(c) == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	roomID, ok := parseRoomID(c)
+	if !ok {
+		return
+	}
+
+	users := []websocket.OnlineUser{}
+	if wsHub != nil {
+		online, err := wsHub.RoomOnlineUsers(roomID)
+		if err != nil {
+			respondRoomError(c, err)
+			return
+		}
+		users = online
+	} else if _, err := groupService.GetRoom(roomID); err != nil {
+		respondRoomError(c, err)
+		return
+	}
+
+	Success(c, gin.H{
+		"room_id": roomID,
+		"users":   users,
+		"count":   len(users),
+	}, "获取房间在线成员成功")
+}
+
+// parseRoomID 解析路径中的房间ID
+func parseRoomID(c *gin.Context) (uint, bool) {
+	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || roomID == 0 {
+		Error(c, http.StatusBadRequest, "房间ID格式错误")
+		return 0, false
+	}
+	return uint(roomID), true
+}
+
+// respondRoomError 根据房间服务错误返回对应的HTTP状态码
+func respondRoomError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "房间不存在", "不是群成员":
+		Error(c, http.StatusNotFound, err.Error())
+	case "群成员数量超过限制", "已被禁止加入该群组":
+		Error(c, http.StatusForbidden, err.Error())
+	case "群名称不能为空", "群名称过长", "房间主题过长":
+		Error(c, http.StatusBadRequest, err.Error())
+	default:
+		Error(c, http.StatusInternalServerError, err.Error())
+	}
+}
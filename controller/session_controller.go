@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"go_chat/middleware"
+	"go_chat/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+var sessionService = service.NewSessionService()
+
+// ListSessions 获取当前用户的登录会话列表
+// @Summary 登录设备列表
+// @Description 获取当前用户所有有效的登录会话（设备）
+// @Tags 用户
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} Response "获取成功"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/user/sessions [get]
+func ListSessions(c *gin.Context) {
+	claims, ok := middleware.CurrentClaims(c)
+	if !ok {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	sessions, err := sessionService.ListSessions(claims.UserID, claims.SessionID)
+	if err != nil {
+		logrus.Error("获取会话列表失败:", err)
+		Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	Success(c, gin.H{
+		"sessions": sessions,
+		"count":    len(sessions),
+	}, "获取会话列表成功")
+}
+
+// RevokeSession 注销指定的登录会话
+// @Summary 远程退出设备
+// @Description 注销当前用户的指定会话，并断开该会话的WebSocket连接
+// @Tags 用户
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "会话ID"
+// @Success 200 {object} Response "注销成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未登录或令牌无效"
+// @Failure 404 {object} Response "会话不存在"
+// @Failure 500 {object} Response "服务器内部错误"
+// @Router /api/v1/user/sessions/{id} [delete]
+func RevokeSession(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if userID == 0 {
+		Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		Error(c, http.StatusBadRequest, "会话ID格式错误")
+		return
+	}
+
+	sessionID, err := sessionService.RevokeSession(userID, uint(id))
+	if err != nil {
+		logrus.Error("注销会话失败:", err)
+		if err.Error() == "会话不存在" {
+			Error(c, http.StatusNotFound, err.Error())
+		} else {
+			Error(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	// 断开该会话的WebSocket连接
+	if wsHub != nil {
+		wsHub.DisconnectSession(sessionID, "会话已在其他设备上被注销")
+	}
+
+	Success(c, nil, "注销会话成功")
+}
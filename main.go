@@ -6,6 +6,7 @@ import (
 	"go_chat/global"
 	"go_chat/model"
 	"go_chat/router"
+	"go_chat/service"
 
 	"github.com/sirupsen/logrus"
 )
@@ -21,6 +22,9 @@ func main() {
 	mongoClient := global.GetMongoDBClient()
 	if mongoClient != nil {
 		fmt.Println("MongoDB: 已连接")
+
+		// 创建消息集合索引
+		service.EnsureMessageIndexes()
 	} else {
 		fmt.Println("MongoDB: 未连接")
 	}
@@ -73,7 +77,7 @@ func startServer() {
 	fmt.Printf("API文档地址: http://localhost:%s/api/v1\n", port)
 	fmt.Printf("注册接口: POST http://localhost:%s/api/v1/auth/register\n", port)
 	fmt.Printf("登录接口: POST http://localhost:%s/api/v1/auth/login\n", port)
-	fmt.Printf("用户信息: GET http://localhost:%s/api/v1/user/profile?id=1\n", port)
+	fmt.Printf("当前用户: GET http://localhost:%s/api/v1/user/me (需携带 Authorization: Bearer <token>)\n", port)
 
 	// 启动服务器
 	if err := r.Run(":" + port); err != nil {
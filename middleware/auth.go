@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"go_chat/service"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// 上下文键
+	ContextUserIDKey = "user_id"
+	ContextUserKey   = "user"
+	ContextClaimsKey = "claims"
+)
+
+var authService = service.NewAuthService()
+
+// AuthRequired 校验Bearer访问令牌，并将当前用户写入gin上下文
+func AuthRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c)
+		if token == "" {
+			abortUnauthorized(c, "缺少访问令牌")
+			return
+		}
+
+		claims, user, err := authService.Authenticate(token)
+		if err != nil {
+			logrus.Warnf("访问令牌校验失败: %v", err)
+			if err.Error() == "数据库连接不可用" {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"code":    http.StatusInternalServerError,
+					"message": err.Error(),
+				})
+				return
+			}
+			abortUnauthorized(c, err.Error())
+			return
+		}
+
+		c.Set(ContextUserIDKey, user.ID)
+		c.Set(ContextUserKey, user)
+		c.Set(ContextClaimsKey, claims)
+		c.Next()
+	}
+}
+
+// CurrentUser 获取当前登录用户
+func CurrentUser(c *gin.Context) (*service.UserResponse, bool) {
+	value, exists := c.Get(ContextUserKey)
+	if !exists {
+		return nil, false
+	}
+	user, ok := value.(*service.UserResponse)
+	return user, ok
+}
+
+// CurrentUserID 获取当前登录用户ID，未登录时返回0
+func CurrentUserID(c *gin.Context) uint {
+	return c.GetUint(ContextUserIDKey)
+}
+
+// CurrentClaims 获取当前访问令牌的声明
+func CurrentClaims(c *gin.Context) (*service.Claims, bool) {
+	value, exists := c.Get(ContextClaimsKey)
+	if !exists {
+		return nil, false
+	}
+	claims, ok := value.(*service.Claims)
+	return claims, ok
+}
+
+// bearerToken 从Authorization请求头读取Bearer令牌
+func bearerToken(c *gin.Context) string {
+	auth := c.GetHeader("Authorization")
+	parts := strings.SplitN(auth, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
+
+// abortUnauthorized 终止请求并返回401
+func abortUnauthorized(c *gin.Context, message string) {
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+		"code":    http.StatusUnauthorized,
+		"message": message,
+	})
+}
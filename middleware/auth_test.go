@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"encoding/json"
+	"go_chat/config"
+	"go_chat/global"
+	"go_chat/model"
+	"go_chat/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+// setupAuth 使用内存SQLite替代MySQL并设置测试用的JWT配置，测试结束后恢复
+func setupAuth(t *testing.T) *gorm.DB {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	// 每个测试使用独立的共享缓存内存库，连接池中的连接看到同一份数据
+	db, err := gorm.Open("sqlite3", "file:"+strings.ReplaceAll(t.Name(), "/", "_")+"?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	db.SingularTable(true)
+	if err := db.AutoMigrate(&model.User{}, &model.UserSession{}).Error; err != nil {
+		t.Fatalf("测试数据库迁移失败: %v", err)
+	}
+
+	previous := global.MySQLClient
+	secret, issuer := config.JwtSecret, config.JwtIssuer
+	accessExpire, refreshExpire := config.JwtAccessExpire, config.JwtRefreshExpire
+	t.Cleanup(func() {
+		global.MySQLClient = previous
+		config.JwtSecret, config.JwtIssuer = secret, issuer
+		config.JwtAccessExpire, config.JwtRefreshExpire = accessExpire, refreshExpire
+		db.Close()
+	})
+	global.MySQLClient = db
+	config.JwtSecret = "test-secret"
+	config.JwtIssuer = "go_chat"
+	config.JwtAccessExpire = time.Hour
+	config.JwtRefreshExpire = 24 * time.Hour
+	return db
+}
+
+// performAuth 经过AuthRequired发起请求，返回响应和处理函数看到的当前用户ID
+func performAuth(authorization string) (*httptest.ResponseRecorder, uint) {
+	var userID uint
+	router := gin.New()
+	router.GET("/me", AuthRequired(), func(c *gin.Context) {
+		userID = CurrentUserID(c)
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/me", nil)
+	if authorization != "" {
+		r.Header.Set("Authorization", authorization)
+	}
+	router.ServeHTTP(w, r)
+	return w, userID
+}
+
+func TestAuthRequired(t *testing.T) {
+	db := setupAuth(t)
+	active := model.User{UserName: "alice", Status: model.Active}
+	disabled := model.User{UserName: "bob", Status: "disabled"}
+	db.Create(&active)
+	db.Create(&disabled)
+
+	token := func(userID uint) string {
+		sessionID := service.NewSessionID()
+		tokens, err := service.GenerateTokenPair(userID, "", sessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := service.NewSessionService().CreateSession(userID, sessionID, service.LoginRequest{}, tokens.RefreshExpiresAt); err != nil {
+			t.Fatal(err)
+		}
+		return tokens.AccessToken
+	}
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, service.Claims{
+		UserID:    active.ID,
+		TokenType: service.TokenTypeAccess,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "go_chat",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}).SignedString([]byte("other-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+		wantMessage   string
+		wantUserID    uint
+	}{
+		{"缺少认证头", "", http.StatusUnauthorized, "缺少访问令牌", 0},
+		{"不是Bearer认证", "Basic " + token(active.ID), http.StatusUnauthorized, "缺少访问令牌", 0},
+		{"Bearer后没有令牌", "Bearer", http.StatusUnauthorized, "缺少访问令牌", 0},
+		{"令牌格式错误", "Bearer not-a-jwt", http.StatusUnauthorized, "令牌无效或已过期", 0},
+		{"签名错误", "Bearer " + forged, http.StatusUnauthorized, "令牌无效或已过期", 0},
+		{"用户不存在", "Bearer " + token(999), http.StatusUnauthorized, "用户不存在", 0},
+		{"用户已禁用", "Bearer " + token(disabled.ID), http.StatusUnauthorized, "账户已被禁用", 0},
+		{"令牌有效", "Bearer " + token(active.ID), http.StatusOK, "", active.ID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, userID := performAuth(tt.authorization)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if userID != tt.wantUserID {
+				t.Errorf("当前用户ID = %d, want %d", userID, tt.wantUserID)
+			}
+			if tt.wantMessage == "" {
+				return
+			}
+			var body struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("响应解析失败: %v", err)
+			}
+			if body.Code != tt.wantStatus || body.Message != tt.wantMessage {
+				t.Errorf("响应 = %+v, want %d %s", body, tt.wantStatus, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestAuthRequiredWithoutDatabase(t *testing.T) {
+	setupAuth(t)
+	tokens, err := service.GenerateTokenPair(1, "alice", service.NewSessionID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	global.MySQLClient = nil
+
+	if w, _ := performAuth("Bearer " + tokens.AccessToken); w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redactedValue 访问日志中替换敏感查询参数的值
+const redactedValue = "REDACTED"
+
+// sensitiveQueryParams 访问日志中需要隐藏的查询参数：WebSocket连接可以通过查询参数携带访问令牌和恢复令牌
+var sensitiveQueryParams = map[string]bool{
+	"token":        true,
+	"resume_token": true,
+}
+
+// AccessLogger 请求访问日志，格式与gin默认日志一致，记录前隐藏查询参数中的令牌
+func AccessLogger() gin.HandlerFunc {
+	return gin.LoggerWithConfig(gin.LoggerConfig{Formatter: accessLogFormatter})
+}
+
+// accessLogFormatter 按gin默认格式输出访问日志，路径中的敏感查询参数已隐藏
+func accessLogFormatter(param gin.LogFormatterParams) string {
+	var statusColor, methodColor, resetColor string
+	if param.IsOutputColor() {
+		statusColor = param.StatusCodeColor()
+		methodColor = param.MethodColor()
+		resetColor = param.ResetColor()
+	}
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+
+	return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, param.StatusCode, resetColor,
+		param.Latency,
+		param.ClientIP,
+		methodColor, param.Method, resetColor,
+		redactQuery(param.Path),
+		param.ErrorMessage,
+	)
+}
+
+// redactQuery 隐藏请求路径中敏感查询参数的值，其余参数保持原样和原有顺序
+func redactQuery(path string) string {
+	base, rawQuery, found := strings.Cut(path, "?")
+	if !found || rawQuery == "" {
+		return path
+	}
+
+	pairs := strings.Split(rawQuery, "&")
+	for i, pair := range pairs {
+		key, _, _ := strings.Cut(pair, "=")
+		if name, err := url.QueryUnescape(key); err == nil && sensitiveQueryParams[name] {
+			pairs[i] = key + "=" + redactedValue
+		}
+	}
+	return base + "?" + strings.Join(pairs, "&")
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRedactQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"没有查询参数", "/ws", "/ws"},
+		{"空查询参数", "/ws?", "/ws?"},
+		{"访问令牌", "/ws?token=secret", "/ws?token=REDACTED"},
+		{"恢复令牌和其他参数", "/ws?resume_token=abc&limit=20", "/ws?resume_token=REDACTED&limit=20"},
+		{"重复参数都隐藏", "/ws?token=a&token=b", "/ws?token=REDACTED&token=REDACTED"},
+		{"编码的参数名", "/ws?%74oken=secret", "/ws?%74oken=REDACTED"},
+		{"没有值的参数", "/ws?token", "/ws?token=REDACTED"},
+		{"名称相近的参数不隐藏", "/api?tokens=1&access=2", "/api?tokens=1&access=2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactQuery(tt.path); got != tt.want {
+				t.Errorf("redactQuery(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccessLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var logs bytes.Buffer
+	var token string
+	router := gin.New()
+	router.Use(gin.LoggerWithConfig(gin.LoggerConfig{Formatter: accessLogFormatter, Output: &logs}))
+	router.GET("/ws", func(c *gin.Context) {
+		token = c.Query("token")
+		c.Status(http.StatusUnauthorized)
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ws?token=secret-token", nil))
+
+	// 只隐藏日志中的令牌，处理函数仍能读取
+	if token != "secret-token" {
+		t.Errorf("处理函数读取的令牌 = %q, want secret-token", token)
+	}
+	if line := logs.String(); strings.Contains(line, "secret-token") || !strings.Contains(line, "/ws?token=REDACTED") || !strings.Contains(line, "401") {
+		t.Errorf("访问日志 = %q, want 隐藏令牌", line)
+	}
+}
@@ -0,0 +1,56 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Attachment MongoDB中存储的附件元数据，文件内容保存在BlobStore中
+type Attachment struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	OwnerID     uint               `bson:"owner_id"`              // 上传者
+	FileName    string             `bson:"file_name"`             // 原始文件名
+	ContentType string             `bson:"content_type"`          // 按文件内容识别的MIME类型
+	Size        int64              `bson:"size"`                  // 文件大小（字节）
+	StorageKey  string             `bson:"storage_key"`           // BlobStore中的存储键
+	Width       int                `bson:"width,omitempty"`       // 图片宽度
+	Height      int                `bson:"height,omitempty"`      // 图片高度
+	Thumbnails  []Thumbnail        `bson:"thumbnails,omitempty"`  // 图片缩略图，按尺寸从小到大排列
+	SharedWith  []uint             `bson:"shared_with,omitempty"` // 通过消息获得下载权限的用户
+	Groups      []uint             `bson:"groups,omitempty"`      // 引用该附件的群组，当前群成员可以下载
+	Channels    []uint             `bson:"channels,omitempty"`    // 引用该附件的频道，订阅者均可下载
+	CreatedAt   time.Time          `bson:"created_at"`
+}
+
+// Thumbnail 图片附件的缩略图
+type Thumbnail struct {
+	Size        int    `bson:"size"` // 生成时指定的最长边
+	Width       int    `bson:"width"`
+	Height      int    `bson:"height"`
+	ContentType string `bson:"content_type"`
+	StorageKey  string `bson:"storage_key"`
+}
+
+// FindThumbnail 根据尺寸查找缩略图
+func (attachment *Attachment) FindThumbnail(size int) *Thumbnail {
+	for i := range attachment.Thumbnails {
+		if attachment.Thumbnails[i].Size == size {
+			return &attachment.Thumbnails[i]
+		}
+	}
+	return nil
+}
+
+// CanAccess 上传者和收到引用该附件消息的用户可以下载
+func (attachment *Attachment) CanAccess(userID uint) bool {
+	if attachment.OwnerID == userID {
+		return true
+	}
+	for _, sharedWith := range attachment.SharedWith {
+		if sharedWith == userID {
+			return true
+		}
+	}
+	return false
+}
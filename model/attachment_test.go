@@ -0,0 +1,20 @@
+package model
+
+import "testing"
+
+func TestAttachmentCanAccess(t *testing.T) {
+	attachment := Attachment{OwnerID: 1, SharedWith: []uint{2}}
+	tests := []struct {
+		userID uint
+		want   bool
+	}{
+		{1, true},
+		{2, true},
+		{3, false},
+	}
+	for _, tt := range tests {
+		if got := attachment.CanAccess(tt.userID); got != tt.want {
+			t.Errorf("CanAccess(%d) = %v, want %v", tt.userID, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 频道订阅者角色
+const (
+	ChannelRoleOwner      = "owner"      // 频道所有者，可以指定发布者
+	ChannelRolePublisher  = "publisher"  // 发布者，可以发布消息
+	ChannelRoleSubscriber = "subscriber" // 普通订阅者，只能接收消息
+)
+
+// Channel 广播频道，只有所有者和发布者可以发布消息
+type Channel struct {
+	gorm.Model
+	Name            string `gorm:"size:100"`
+	Description     string `gorm:"size:500"`
+	CreatorID       uint   `gorm:"index"`
+	SubscriberCount int    // 订阅者数量，随订阅和取消订阅更新
+}
+
+// ChannelSubscriber 频道订阅关系，取消订阅后直接删除记录
+type ChannelSubscriber struct {
+	ID          uint   `gorm:"primary_key"`
+	ChannelID   uint   `gorm:"unique_index:idx_channel_subscriber"`
+	UserID      uint   `gorm:"unique_index:idx_channel_subscriber;index"`
+	Role        string `gorm:"size:20"`
+	LastReadSeq int64  // 已读到的频道消息序号
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// CanPublish 是否可以在频道中发布消息
+func (subscriber *ChannelSubscriber) CanPublish() bool {
+	return subscriber.Role == ChannelRoleOwner || subscriber.Role == ChannelRolePublisher
+}
+
+// ChannelSessionID 生成频道会话ID
+func ChannelSessionID(channelID uint) string {
+	return fmt.Sprintf("channel_%d", channelID)
+}
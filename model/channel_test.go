@@ -0,0 +1,25 @@
+package model
+
+import "testing"
+
+func TestChannelSubscriberCanPublish(t *testing.T) {
+	tests := []struct {
+		role string
+		want bool
+	}{
+		{ChannelRoleOwner, true},
+		{ChannelRolePublisher, true},
+		{ChannelRoleSubscriber, false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		subscriber := &ChannelSubscriber{Role: tt.role}
+		if got := subscriber.CanPublish(); got != tt.want {
+			t.Errorf("CanPublish(%q) = %v, want %v", tt.role, got, tt.want)
+		}
+	}
+
+	if got := ChannelSessionID(7); got != "channel_7" {
+		t.Errorf("ChannelSessionID(7) = %s, want channel_7", got)
+	}
+}
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Conversation MongoDB中存储的会话摘要，每次写入消息时更新
+type Conversation struct {
+	SessionID     string                  `bson:"_id"`
+	Participants  []uint                  `bson:"participants"`             // 会话参与者
+	LastSeq       int64                   `bson:"last_seq"`                 // 最近分配的消息序号
+	LastMessage   LastMessage             `bson:"last_message"`             // 最后一条消息
+	ReadPositions map[string]ReadPosition `bson:"read_positions,omitempty"` // 各参与者的已读位置，键为用户ID
+	UpdatedAt     time.Time               `bson:"updated_at"`
+}
+
+// ReadPosition 用户在会话中的已读位置
+type ReadPosition struct {
+	MessageID primitive.ObjectID `bson:"message_id,omitempty"`
+	Timestamp time.Time          `bson:"timestamp"` // 已读到的消息时间，早于等于该时间的消息均视为已读
+	UpdatedAt time.Time          `bson:"updated_at"`
+}
+
+// ReadReceipt 待投递的已读回执，发送者离线时暂存，每个会话只保留最新的一条
+type ReadReceipt struct {
+	ID        string             `bson:"_id"` // 会话ID:阅读者ID
+	SessionID string             `bson:"session_id"`
+	ReaderID  uint               `bson:"reader_id"`
+	ToUserID  uint               `bson:"to_user_id"` // 回执接收者，即消息发送者
+	MessageID primitive.ObjectID `bson:"message_id,omitempty"`
+	Timestamp time.Time          `bson:"timestamp"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+// LastMessage 会话最后一条消息摘要
+type LastMessage struct {
+	MessageID  primitive.ObjectID `bson:"message_id"`
+	FromUserID uint               `bson:"from_user_id"`
+	Content    string             `bson:"content"`
+	Recalled   bool               `bson:"recalled,omitempty"`   // 最后一条消息已被撤回
+	HiddenFor  []uint             `bson:"hidden_for,omitempty"` // 已删除这条消息的用户
+	Timestamp  time.Time          `bson:"timestamp"`
+}
+
+// HiddenFrom 最后一条消息是否已被指定用户删除
+func (last *LastMessage) HiddenFrom(userID uint) bool {
+	for _, hiddenFor := range last.HiddenFor {
+		if hiddenFor == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// Peer 获取会话中除指定用户之外的另一方
+func (conversation *Conversation) Peer(userID uint) uint {
+	for _, participant := range conversation.Participants {
+		if participant != userID {
+			return participant
+		}
+	}
+	return 0
+}
@@ -0,0 +1,26 @@
+package model
+
+import "testing"
+
+func TestConversationPeer(t *testing.T) {
+	tests := []struct {
+		name         string
+		participants []uint
+		userID       uint
+		want         uint
+	}{
+		{"自己在前", []uint{1, 2}, 1, 2},
+		{"自己在后", []uint{1, 2}, 2, 1},
+		{"不是参与者", []uint{1, 2}, 3, 1},
+		{"没有参与者", nil, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conversation := &Conversation{Participants: tt.participants}
+			if got := conversation.Peer(tt.userID); got != tt.want {
+				t.Errorf("Peer(%d) = %d, want %d", tt.userID, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 群成员角色
+const (
+	GroupRoleOwner  = "owner"  // 群主，每个群只有一个
+	GroupRoleAdmin  = "admin"  // 管理员
+	GroupRoleMember = "member" // 普通成员
+)
+
+// groupRoleRank 角色权限高低
+var groupRoleRank = map[string]int{
+	GroupRoleMember: 0,
+	GroupRoleAdmin:  1,
+	GroupRoleOwner:  2,
+}
+
+// Group 群组。公开的群组即公开房间，任何人都可以搜索和加入；非公开的群组只能由管理员添加成员
+type Group struct {
+	gorm.Model
+	Name      string `gorm:"size:100"`
+	Topic     string `gorm:"size:200"` // 房间主题
+	Public    bool   `gorm:"index"`
+	CreatorID uint   `gorm:"index"`
+	SlowMode  int    // 慢速模式间隔秒数，普通成员两次发言之间至少间隔这么久，0表示关闭
+}
+
+// GroupMember 群成员，退群后直接删除记录
+type GroupMember struct {
+	ID         uint       `gorm:"primary_key"`
+	GroupID    uint       `gorm:"unique_index:idx_group_member"`
+	UserID     uint       `gorm:"unique_index:idx_group_member;index"`
+	Role       string     `gorm:"size:20"`
+	MutedUntil *time.Time // 禁言截止时间，为空表示未被禁言
+	CreatedAt  time.Time  // 入群时间，入群前的消息不会作为离线消息补发
+	UpdatedAt  time.Time
+}
+
+// GroupBan 群封禁记录，被封禁的用户不能再通过任何方式加入群组，解除封禁后删除记录
+type GroupBan struct {
+	ID         uint   `gorm:"primary_key"`
+	GroupID    uint   `gorm:"unique_index:idx_group_ban"`
+	UserID     uint   `gorm:"unique_index:idx_group_ban"`
+	OperatorID uint   // 执行封禁的群主或管理员
+	Reason     string `gorm:"size:200"`
+	CreatedAt  time.Time
+}
+
+// IsManager 是否为群主或管理员
+func (member *GroupMember) IsManager() bool {
+	return groupRoleRank[member.Role] >= groupRoleRank[GroupRoleAdmin]
+}
+
+// IsMuted 成员当前是否处于禁言中
+func (member *GroupMember) IsMuted() bool {
+	return member.MutedUntil != nil && time.Now().Before(*member.MutedUntil)
+}
+
+// Outranks 角色是否高于另一成员，只能管理角色比自己低的成员
+func (member *GroupMember) Outranks(other *GroupMember) bool {
+	return groupRoleRank[member.Role] > groupRoleRank[other.Role]
+}
+
+// GroupSessionID 生成群聊会话ID
+func GroupSessionID(groupID uint) string {
+	return fmt.Sprintf("group_%d", groupID)
+}
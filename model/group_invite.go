@@ -0,0 +1,48 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 入群申请状态
+const (
+	JoinRequestPending  = "pending"  // 等待审批
+	JoinRequestApproved = "approved" // 已通过
+	JoinRequestRejected = "rejected" // 已拒绝
+)
+
+// GroupInvite 群邀请链接
+type GroupInvite struct {
+	gorm.Model
+	Token           string `gorm:"size:64;unique_index"`
+	GroupID         uint   `gorm:"index"`
+	CreatorID       uint
+	ExpiresAt       *time.Time // 为空表示永不过期
+	MaxUses         int        // 最大使用次数，0表示不限
+	Uses            int        // 已使用次数
+	RequireApproval bool       // 通过链接申请后需要群主或管理员审批
+	RevokedAt       *time.Time
+}
+
+// IsExpired 邀请链接是否已过期
+func (invite *GroupInvite) IsExpired() bool {
+	return invite.ExpiresAt != nil && !time.Now().Before(*invite.ExpiresAt)
+}
+
+// IsExhausted 邀请链接使用次数是否已达上限
+func (invite *GroupInvite) IsExhausted() bool {
+	return invite.MaxUses > 0 && invite.Uses >= invite.MaxUses
+}
+
+// GroupJoinRequest 通过需要审批的邀请链接提交的入群申请
+type GroupJoinRequest struct {
+	gorm.Model
+	GroupID    uint `gorm:"index"`
+	UserID     uint `gorm:"index"`
+	InviteID   uint
+	Status     string `gorm:"size:20;index"`
+	ReviewerID uint
+	ReviewedAt *time.Time
+}
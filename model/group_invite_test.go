@@ -0,0 +1,30 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGroupInviteValidity(t *testing.T) {
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Minute)
+	tests := []struct {
+		name          string
+		invite        GroupInvite
+		wantExpired   bool
+		wantExhausted bool
+	}{
+		{"永不过期且不限次数", GroupInvite{Uses: 100}, false, false},
+		{"尚未过期", GroupInvite{ExpiresAt: &future}, false, false},
+		{"已过期", GroupInvite{ExpiresAt: &past}, true, false},
+		{"次数未用完", GroupInvite{MaxUses: 2, Uses: 1}, false, false},
+		{"次数已用完", GroupInvite{MaxUses: 2, Uses: 2}, false, true},
+	}
+	for _, tt := range tests {
+		if got := tt.invite.IsExpired(); got != tt.wantExpired {
+			t.Errorf("%s: IsExpired() = %v, want %v", tt.name, got, tt.wantExpired)
+		}
+		if got := tt.invite.IsExhausted(); got != tt.wantExhausted {
+			t.Errorf("%s: IsExhausted() = %v, want %v", tt.name, got, tt.wantExhausted)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGroupMemberRoles(t *testing.T) {
+	owner := &GroupMember{Role: GroupRoleOwner}
+	admin := &GroupMember{Role: GroupRoleAdmin}
+	member := &GroupMember{Role: GroupRoleMember}
+
+	if !owner.IsManager() || !admin.IsManager() || member.IsManager() {
+		t.Error("群主和管理员才是管理者")
+	}
+	tests := []struct {
+		name          string
+		member, other *GroupMember
+		want          bool
+	}{
+		{"群主高于管理员", owner, admin, true},
+		{"管理员高于普通成员", admin, member, true},
+		{"同级不能管理", admin, &GroupMember{Role: GroupRoleAdmin}, false},
+		{"普通成员不能管理群主", member, owner, false},
+	}
+	for _, tt := range tests {
+		if got := tt.member.Outranks(tt.other); got != tt.want {
+			t.Errorf("%s: Outranks() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if got := GroupSessionID(42); got != "group_42" {
+		t.Errorf("GroupSessionID(42) = %s, want group_42", got)
+	}
+}
+
+func TestGroupMemberIsMuted(t *testing.T) {
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Minute)
+	tests := []struct {
+		name       string
+		mutedUntil *time.Time
+		want       bool
+	}{
+		{"未禁言", nil, false},
+		{"禁言已结束", &past, false},
+		{"禁言中", &future, true},
+	}
+	for _, tt := range tests {
+		member := GroupMember{MutedUntil: tt.mutedUntil}
+		if got := member.IsMuted(); got != tt.want {
+			t.Errorf("%s: IsMuted() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -24,6 +24,30 @@ func Migration() {
 	}
 
 	logrus.Info("✅ User表迁移完成")
+
+	// 自动迁移UserSession表
+	if err := db.AutoMigrate(&UserSession{}).Error; err != nil {
+		logrus.Errorf("UserSession表迁移失败: %v", err)
+		return
+	}
+
+	logrus.Info("✅ UserSession表迁移完成")
+
+	// 自动迁移群组相关表
+	if err := db.AutoMigrate(&Group{}, &GroupMember{}, &GroupInvite{}, &GroupJoinRequest{}, &GroupBan{}).Error; err != nil {
+		logrus.Errorf("Group表迁移失败: %v", err)
+		return
+	}
+
+	logrus.Info("✅ Group表迁移完成")
+
+	// 自动迁移频道相关表
+	if err := db.AutoMigrate(&Channel{}, &ChannelSubscriber{}).Error; err != nil {
+		logrus.Errorf("Channel表迁移失败: %v", err)
+		return
+	}
+
+	logrus.Info("✅ Channel表迁移完成")
 	logrus.Info("🎉 数据库迁移全部完成")
 }
 
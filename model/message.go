@@ -0,0 +1,93 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ChatMessage MongoDB中存储的聊天消息
+type ChatMessage struct {
+	ID          primitive.ObjectID   `bson:"_id,omitempty"`
+	SessionID   string               `bson:"session_id"`              // 会话ID，私聊双方共享
+	GroupID     uint                 `bson:"group_id,omitempty"`      // 群聊消息所属的群组，私聊消息为0
+	ChannelID   uint                 `bson:"channel_id,omitempty"`    // 频道消息所属的频道
+	ClientMsgID string               `bson:"client_msg_id,omitempty"` // 客户端生成的幂等键
+	Seq         int64                `bson:"seq"`                     // 会话内单调递增的序号
+	Type        string               `bson:"type"`
+	FromUserID  uint                 `bson:"from_user_id"`
+	ToUserID    uint                 `bson:"to_user_id"`
+	Content     string               `bson:"content"`
+	ReplyTo     primitive.ObjectID   `bson:"reply_to,omitempty"`    // 引用的消息ID
+	Attachments []primitive.ObjectID `bson:"attachments,omitempty"` // 附件ID
+	Timestamp   time.Time            `bson:"timestamp"`
+	IsOffline   bool                 `bson:"is_offline"`             // 接收者是否离线（需上线后补发）
+	Status      string               `bson:"status"`                 // 投递状态
+	DeliveredAt *time.Time           `bson:"delivered_at,omitempty"` // 接收者确认收到的时间
+	ReadAt      *time.Time           `bson:"read_at,omitempty"`      // 接收者已读时间
+	Edited      bool                 `bson:"edited,omitempty"`       // 是否被编辑过
+	EditedAt    *time.Time           `bson:"edited_at,omitempty"`    // 最近一次编辑时间
+	EditHistory []MessageVersion     `bson:"edit_history,omitempty"` // 编辑前的历史版本，按时间先后排列
+	Recalled    bool                 `bson:"recalled,omitempty"`     // 是否已被发送者撤回，撤回后内容清空
+	RecalledAt  *time.Time           `bson:"recalled_at,omitempty"`  // 撤回时间
+	HiddenFor   []uint               `bson:"hidden_for,omitempty"`   // 已从自己的记录中删除该消息的用户
+	DeliveredTo []uint               `bson:"delivered_to,omitempty"` // 已确认收到群聊消息的成员
+	Reactions   map[string][]uint    `bson:"reactions,omitempty"`    // 表情回应，表情到用户ID集合
+	Skipped     bool                 `bson:"skipped,omitempty"`      // 占位记录：该序号已分配但消息写入失败
+	CreatedAt   time.Time            `bson:"created_at"`
+	UpdatedAt   time.Time            `bson:"updated_at"`
+}
+
+// MessageVersion 消息被编辑前的版本
+type MessageVersion struct {
+	Content    string    `bson:"content"`
+	Timestamp  time.Time `bson:"timestamp"`   // 该版本的生效时间
+	ReplacedAt time.Time `bson:"replaced_at"` // 被新版本替换的时间
+}
+
+// 消息投递状态，只能按 sent -> stored -> delivered -> read 的顺序前进
+const (
+	MessageStatusSent      = "sent"      // 已保存，等待投递
+	MessageStatusStored    = "stored"    // 接收者离线或投递未完成，等待补发
+	MessageStatusDelivered = "delivered" // 接收者已确认收到
+	MessageStatusRead      = "read"      // 接收者已读
+	MessageStatusFailed    = "failed"    // 投递失败
+)
+
+// messageStatusRank 状态先后顺序
+var messageStatusRank = map[string]int{
+	MessageStatusSent:      0,
+	MessageStatusStored:    1,
+	MessageStatusDelivered: 2,
+	MessageStatusRead:      3,
+}
+
+// PreviousMessageStatuses 返回可以转换到指定状态的前置状态
+func PreviousMessageStatuses(status string) []string {
+	// 失败只能发生在送达之前
+	if status == MessageStatusFailed {
+		return []string{MessageStatusSent, MessageStatusStored}
+	}
+
+	rank, ok := messageStatusRank[status]
+	if !ok {
+		return nil
+	}
+
+	var previous []string
+	for candidate, candidateRank := range messageStatusRank {
+		if candidateRank < rank {
+			previous = append(previous, candidate)
+		}
+	}
+	return previous
+}
+
+// PrivateSessionID 生成私聊会话ID，与双方顺序无关
+func PrivateSessionID(userA, userB uint) string {
+	if userA > userB {
+		userA, userB = userB, userA
+	}
+	return fmt.Sprintf("private_%d_%d", userA, userB)
+}
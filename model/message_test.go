@@ -0,0 +1,71 @@
+package model
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPrivateSessionID(t *testing.T) {
+	tests := []struct {
+		userA, userB uint
+		want         string
+	}{
+		{1, 2, "private_1_2"},
+		{2, 1, "private_1_2"},
+		{7, 7, "private_7_7"},
+		{10, 9, "private_9_10"},
+	}
+
+	for _, tt := range tests {
+		if got := PrivateSessionID(tt.userA, tt.userB); got != tt.want {
+			t.Errorf("PrivateSessionID(%d, %d) = %q, want %q", tt.userA, tt.userB, got, tt.want)
+		}
+	}
+}
+
+func TestPreviousMessageStatuses(t *testing.T) {
+	tests := []struct {
+		status string
+		want   []string
+	}{
+		{MessageStatusSent, nil},
+		{MessageStatusStored, []string{MessageStatusSent}},
+		{MessageStatusDelivered, []string{MessageStatusSent, MessageStatusStored}},
+		{MessageStatusRead, []string{MessageStatusDelivered, MessageStatusSent, MessageStatusStored}},
+		{MessageStatusFailed, []string{MessageStatusSent, MessageStatusStored}},
+		{"unknown", nil},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			// 前置状态来自map遍历，按集合比较
+			got := slices.Clone(PreviousMessageStatuses(tt.status))
+			slices.Sort(got)
+			want := slices.Clone(tt.want)
+			slices.Sort(want)
+			if !slices.Equal(got, want) {
+				t.Errorf("PreviousMessageStatuses(%q) = %v, want %v", tt.status, got, want)
+			}
+		})
+	}
+}
+
+func TestPreviousMessageStatusesNeverRegress(t *testing.T) {
+	// 已读和已送达不能回退，失败不能覆盖已送达的消息
+	for _, status := range []string{MessageStatusSent, MessageStatusStored, MessageStatusDelivered, MessageStatusRead, MessageStatusFailed} {
+		previous := PreviousMessageStatuses(status)
+		if slices.Contains(previous, status) {
+			t.Errorf("PreviousMessageStatuses(%q) 包含自身: %v", status, previous)
+		}
+		if slices.Contains(previous, MessageStatusRead) {
+			t.Errorf("PreviousMessageStatuses(%q) 允许从已读转换: %v", status, previous)
+		}
+		if slices.Contains(previous, MessageStatusFailed) {
+			t.Errorf("PreviousMessageStatuses(%q) 允许从失败转换: %v", status, previous)
+		}
+	}
+	if slices.Contains(PreviousMessageStatuses(MessageStatusFailed), MessageStatusDelivered) {
+		t.Error("已送达的消息不能再标记为失败")
+	}
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// UserSession 用户登录会话（每次登录一条记录，对应一台设备）
+type UserSession struct {
+	gorm.Model
+	SessionID  string `gorm:"size:64;unique_index"` // 会话ID，与令牌中的sid一致
+	UserID     uint   `gorm:"index"`
+	DeviceName string `gorm:"size:100"`
+	UserAgent  string `gorm:"size:500"`
+	IP         string `gorm:"size:64"`
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+}
+
+// IsActive 会话是否仍然有效
+func (session *UserSession) IsActive() bool {
+	return session.RevokedAt == nil && time.Now().Before(session.ExpiresAt)
+}
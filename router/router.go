@@ -2,6 +2,7 @@ package router
 
 import (
 	"go_chat/controller"
+	"go_chat/middleware"
 	"go_chat/websocket"
 
 	"github.com/gin-gonic/gin"
@@ -9,10 +10,10 @@ import (
 
 // InitRouter 初始化路由
 func InitRouter() *gin.Engine {
-	r := gin.Default()
+	r := gin.New()
 
-	// 设置中间件
-	r.Use(gin.Logger())
+	// 设置中间件，访问日志中隐藏查询参数携带的令牌
+	r.Use(middleware.AccessLogger())
 	r.Use(gin.Recovery())
 
 	// 静态文件服务
@@ -25,6 +26,7 @@ func InitRouter() *gin.Engine {
 
 	// 创建WebSocket处理器
 	wsHandler := websocket.NewHandler(hub)
+	controller.SetHub(hub)
 
 	// WebSocket路由
 	r.GET("/ws", wsHandler.HandleWebSocket)
@@ -37,17 +39,111 @@ func InitRouter() *gin.Engine {
 		{
 			auth.POST("/register", controller.Register)
 			auth.POST("/login", controller.Login)
+			auth.POST("/refresh", controller.Refresh)
+			auth.POST("/logout", middleware.AuthRequired(), controller.Logout)
 		}
 
 		// 用户相关路由 (需要认证)
 		user := v1.Group("/user")
+		user.Use(middleware.AuthRequired())
 		{
-			// user.Use(middleware.AuthRequired()) // 后续可以添加认证中间件
+			user.GET("/me", controller.GetMe)
 			user.GET("/profile", controller.GetProfile)
+			user.GET("/sessions", controller.ListSessions)
+			user.DELETE("/sessions/:id", controller.RevokeSession)
 		}
 
-		// WebSocket相关路由
+		// 会话相关路由 (需要认证)
+		conversations := v1.Group("/conversations")
+		conversations.Use(middleware.AuthRequired())
+		{
+			conversations.GET("", controller.ListConversations)
+			conversations.GET("/:peer_id/messages", controller.GetConversationMessages)
+			conversations.POST("/:peer_id/read", controller.MarkConversationRead)
+		}
+
+		// 消息相关路由 (需要认证)
+		messages := v1.Group("/messages")
+		messages.Use(middleware.AuthRequired())
+		{
+			messages.PUT("/:id", controller.EditMessage)
+			messages.DELETE("/:id", controller.DeleteMessage)
+			messages.POST("/:id/recall", controller.RecallMessage)
+		}
+
+		// 附件相关路由 (需要认证)
+		attachments := v1.Group("/attachments")
+		attachments.Use(middleware.AuthRequired())
+		{
+			attachments.POST("", controller.UploadAttachment)
+			attachments.GET("/:id", controller.DownloadAttachment)
+			attachments.GET("/:id/thumbnails/:size", controller.DownloadThumbnail)
+		}
+
+		// 群组相关路由 (需要认证)
+		groups := v1.Group("/groups")
+		groups.Use(middleware.AuthRequired())
+		{
+			groups.POST("", controller.CreateGroup)
+			groups.GET("", controller.ListGroups)
+			groups.GET("/:id", controller.GetGroup)
+			groups.PUT("/:id", controller.RenameGroup)
+			groups.POST("/:id/leave", controller.LeaveGroup)
+			groups.GET("/:id/messages", controller.GetGroupMessages)
+			groups.POST("/:id/members", controller.AddGroupMembers)
+			groups.DELETE("/:id/members/:user_id", controller.RemoveGroupMember)
+			groups.PUT("/:id/members/:user_id/role", controller.SetGroupMemberRole)
+			groups.PUT("/:id/members/:user_id/mute", controller.MuteGroupMember)
+			groups.DELETE("/:id/members/:user_id/mute", controller.UnmuteGroupMember)
+			groups.PUT("/:id/slow-mode", controller.SetGroupSlowMode)
+			groups.GET("/:id/bans", controller.ListGroupBans)
+			groups.POST("/:id/bans", controller.BanGroupMember)
+			groups.DELETE("/:id/bans/:user_id", controller.UnbanGroupMember)
+			groups.POST("/:id/invites", controller.CreateGroupInvite)
+			groups.GET("/:id/invites", controller.ListGroupInvites)
+			groups.DELETE("/:id/invites/:invite_id", controller.RevokeGroupInvite)
+			groups.GET("/:id/join-requests", controller.ListJoinRequests)
+			groups.POST("/:id/join-requests/:request_id/approve", controller.ApproveJoinRequest)
+			groups.POST("/:id/join-requests/:request_id/reject", controller.RejectJoinRequest)
+		}
+
+		// 邀请链接相关路由 (需要认证)
+		invites := v1.Group("/invites")
+		invites.Use(middleware.AuthRequired())
+		{
+			invites.POST("/:token/redeem", controller.RedeemInvite)
+		}
+
+		// 公开房间相关路由 (需要认证)
+		rooms := v1.Group("/rooms")
+		rooms.Use(middleware.AuthRequired())
+		{
+			rooms.GET("", controller.ListRooms)
+			rooms.POST("", controller.CreateRoom)
+			rooms.POST("/:id/join", controller.JoinRoom)
+			rooms.POST("/:id/leave", controller.LeaveRoom)
+			rooms.GET("/:id/presence", controller.GetRoomPresence)
+		}
+
+		// 频道相关路由 (需要认证)
+		channels := v1.Group("/channels")
+		channels.Use(middleware.AuthRequired())
+		{
+			channels.GET("", controller.ListChannels)
+			channels.POST("", controller.CreateChannel)
+			channels.GET("/subscriptions", controller.ListChannelSubscriptions)
+			channels.GET("/:id", controller.GetChannel)
+			channels.POST("/:id/subscribe", controller.SubscribeChannel)
+			channels.POST("/:id/unsubscribe", controller.UnsubscribeChannel)
+			channels.PUT("/:id/publishers/:user_id", controller.AddChannelPublisher)
+			channels.DELETE("/:id/publishers/:user_id", controller.RemoveChannelPublisher)
+			channels.GET("/:id/messages", controller.GetChannelMessages)
+			channels.POST("/:id/read", controller.MarkChannelRead)
+		}
+
+		// WebSocket相关路由 (需要认证)
 		ws := v1.Group("/ws")
+		ws.Use(middleware.AuthRequired())
 		{
 			ws.GET("/online-users", wsHandler.GetOnlineUsers)
 		}
@@ -0,0 +1,436 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go_chat/config"
+	"go_chat/global"
+	"go_chat/model"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	attachmentCollection  = "attachments" // 附件元数据集合名
+	maxMessageAttachments = 9             // 单条消息最多引用的附件数
+	sniffLength           = 512           // 识别文件类型读取的字节数
+)
+
+type AttachmentService struct{}
+
+// AttachmentResponse 附件信息
+type AttachmentResponse struct {
+	ID          string              `json:"id"`
+	FileName    string              `json:"file_name"`
+	ContentType string              `json:"content_type"`
+	Size        int64               `json:"size"`
+	URL         string              `json:"url"` // 下载地址，需携带访问令牌
+	Width       int                 `json:"width,omitempty"`
+	Height      int                 `json:"height,omitempty"`
+	Thumbnails  []ThumbnailResponse `json:"thumbnails,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+}
+
+// ThumbnailResponse 图片缩略图信息
+type ThumbnailResponse struct {
+	Size   int    `json:"size"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	URL    string `json:"url"`
+}
+
+// NewAttachmentService 创建附件服务实例
+func NewAttachmentService() *AttachmentService {
+	return &AttachmentService{}
+}
+
+// NewAttachmentResponse 转换附件信息
+func NewAttachmentResponse(attachment *model.Attachment) *AttachmentResponse {
+	response := &AttachmentResponse{
+		ID:          attachment.ID.Hex(),
+		FileName:    attachment.FileName,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		URL:         "/api/v1/attachments/" + attachment.ID.Hex(),
+		Width:       attachment.Width,
+		Height:      attachment.Height,
+		CreatedAt:   attachment.CreatedAt,
+	}
+	for _, thumbnail := range attachment.Thumbnails {
+		response.Thumbnails = append(response.Thumbnails, ThumbnailResponse{
+			Size:   thumbnail.Size,
+			Width:  thumbnail.Width,
+			Height: thumbnail.Height,
+			URL:    fmt.Sprintf("%s/thumbnails/%d", response.URL, thumbnail.Size),
+		})
+	}
+	return response
+}
+
+// Upload 保存上传的附件，文件类型按内容识别，不信任客户端声明的类型
+func (s *AttachmentService) Upload(ownerID uint, fileName string, size int64, reader io.Reader) (*model.Attachment, error) {
+	if size > config.AttachmentMaxSize {
+		return nil, errors.New("附件大小超过限制")
+	}
+
+	collection, err := attachmentsCollection()
+	if err != nil {
+		return nil, err
+	}
+
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(reader, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, errors.New("附件读取失败")
+	}
+	head = head[:n]
+	if n == 0 {
+		return nil, errors.New("附件不能为空")
+	}
+
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if !allowedAttachmentType(contentType) {
+		return nil, errors.New("不支持的附件类型")
+	}
+
+	attachment := &model.Attachment{
+		ID:          primitive.NewObjectID(),
+		OwnerID:     ownerID,
+		FileName:    filepath.Base(fileName),
+		ContentType: contentType,
+		CreatedAt:   time.Now(),
+	}
+	attachment.StorageKey = fmt.Sprintf("%d/%s", ownerID, attachment.ID.Hex())
+
+	// 实际写入的大小同样受限，防止声明的大小与内容不符
+	content := io.LimitReader(io.MultiReader(bytes.NewReader(head), reader), config.AttachmentMaxSize+1)
+	if isProcessableImage(contentType) {
+		err = s.storeImage(attachment, content)
+	} else {
+		err = s.storeFile(attachment, content)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	if _, err := collection.InsertOne(ctx, attachment); err != nil {
+		logrus.Errorf("附件信息保存失败: %v", err)
+		deleteAttachmentBlobs(attachment)
+		return nil, errors.New("附件保存失败")
+	}
+	return attachment, nil
+}
+
+// storeFile 将附件内容原样写入存储
+func (s *AttachmentService) storeFile(attachment *model.Attachment, content io.Reader) error {
+	store := getBlobStore()
+	written, err := store.Put(attachment.StorageKey, content)
+	if err != nil {
+		logrus.Errorf("附件写入失败: %v", err)
+		return errors.New("附件保存失败")
+	}
+	if written > config.AttachmentMaxSize {
+		store.Delete(attachment.StorageKey)
+		return errors.New("附件大小超过限制")
+	}
+	attachment.Size = written
+	return nil
+}
+
+// storeImage 解码图片，去除元数据后保存原图并生成缩略图
+func (s *AttachmentService) storeImage(attachment *model.Attachment, content io.Reader) error {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return errors.New("附件读取失败")
+	}
+	if int64(len(data)) > config.AttachmentMaxSize {
+		return errors.New("附件大小超过限制")
+	}
+
+	img, err := decodeImage(data)
+	if err != nil {
+		return err
+	}
+	data, orientation, err := stripImageMetadata(attachment.ContentType, data)
+	if err != nil {
+		return err
+	}
+	// 原图保留方向标记，解码后的图片按方向旋转，使缩略图和记录的尺寸与显示效果一致
+	img = applyOrientation(img, orientation)
+	thumbnails, err := makeThumbnails(img, attachment.ContentType, config.AttachmentThumbSizes)
+	if err != nil {
+		return err
+	}
+
+	store := getBlobStore()
+	if _, err := store.Put(attachment.StorageKey, bytes.NewReader(data)); err != nil {
+		logrus.Errorf("附件写入失败: %v", err)
+		return errors.New("附件保存失败")
+	}
+	attachment.Size = int64(len(data))
+	attachment.Width = img.Bounds().Dx()
+	attachment.Height = img.Bounds().Dy()
+
+	for _, thumbnail := range thumbnails {
+		key := fmt.Sprintf("%s_thumb_%d", attachment.StorageKey, thumbnail.size)
+		if _, err := store.Put(key, bytes.NewReader(thumbnail.data)); err != nil {
+			logrus.Errorf("缩略图写入失败: %v", err)
+			deleteAttachmentBlobs(attachment)
+			return errors.New("附件保存失败")
+		}
+		attachment.Thumbnails = append(attachment.Thumbnails, model.Thumbnail{
+			Size:        thumbnail.size,
+			Width:       thumbnail.width,
+			Height:      thumbnail.height,
+			ContentType: thumbnail.contentType,
+			StorageKey:  key,
+		})
+	}
+	return nil
+}
+
+// GetAttachment 根据附件ID获取附件信息
+func (s *AttachmentService) GetAttachment(attachmentID string) (*model.Attachment, error) {
+	collection, err := attachmentsCollection()
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := primitive.ObjectIDFromHex(attachmentID)
+	if err != nil {
+		return nil, errors.New("附件ID格式错误")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	var attachment model.Attachment
+	if err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&attachment); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("附件不存在")
+		}
+		logrus.Errorf("查询附件失败: %v", err)
+		return nil, errors.New("查询附件失败")
+	}
+	return &attachment, nil
+}
+
+// Open 打开用户有权下载的附件，无权访问时同样返回不存在
+func (s *AttachmentService) Open(userID uint, attachmentID string) (*model.Attachment, io.ReadCloser, error) {
+	attachment, err := s.GetAttachment(attachmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !s.canAccess(attachment, userID) {
+		return nil, nil, errors.New("附件不存在")
+	}
+
+	reader, err := getBlobStore().Open(attachment.StorageKey)
+	if err != nil {
+		logrus.Errorf("附件读取失败: %v", err)
+		return nil, nil, errors.New("附件读取失败")
+	}
+	return attachment, reader, nil
+}
+
+// OpenThumbnail 打开用户有权下载的附件的缩略图
+func (s *AttachmentService) OpenThumbnail(userID uint, attachmentID string, size int) (*model.Thumbnail, io.ReadCloser, error) {
+	attachment, err := s.GetAttachment(attachmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !s.canAccess(attachment, userID) {
+		return nil, nil, errors.New("附件不存在")
+	}
+
+	thumbnail := attachment.FindThumbnail(size)
+	if thumbnail == nil {
+		return nil, nil, errors.New("缩略图不存在")
+	}
+
+	reader, err := getBlobStore().Open(thumbnail.StorageKey)
+	if err != nil {
+		logrus.Errorf("缩略图读取失败: %v", err)
+		return nil, nil, errors.New("附件读取失败")
+	}
+	return thumbnail, reader, nil
+}
+
+// GetAttachable 获取用户可以在消息中引用的附件，只能引用自己有权访问的附件，按请求顺序返回
+func (s *AttachmentService) GetAttachable(userID uint, attachmentIDs []string) ([]model.Attachment, error) {
+	if len(attachmentIDs) > maxMessageAttachments {
+		return nil, errors.New("附件数量超过限制")
+	}
+
+	attachments := make([]model.Attachment, 0, len(attachmentIDs))
+	seen := make(map[string]bool, len(attachmentIDs))
+	for _, attachmentID := range attachmentIDs {
+		if seen[attachmentID] {
+			continue
+		}
+		seen[attachmentID] = true
+
+		attachment, err := s.GetAttachment(attachmentID)
+		if err != nil {
+			return nil, err
+		}
+		if !s.canAccess(attachment, userID) {
+			return nil, errors.New("附件不存在")
+		}
+		attachments = append(attachments, *attachment)
+	}
+	return attachments, nil
+}
+
+// ShareWith 授予用户下载附件的权限，在引用附件的消息保存后调用
+func (s *AttachmentService) ShareWith(attachmentIDs []primitive.ObjectID, userIDs ...uint) error {
+	if len(attachmentIDs) == 0 || len(userIDs) == 0 {
+		return nil
+	}
+
+	collection, err := attachmentsCollection()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	filter := bson.M{"_id": bson.M{"$in": attachmentIDs}}
+	update := bson.M{"$addToSet": bson.M{"shared_with": bson.M{"$each": userIDs}}}
+	if _, err := collection.UpdateMany(ctx, filter, update); err != nil {
+		logrus.Errorf("附件授权失败: %v", err)
+		return errors.New("附件授权失败")
+	}
+	return nil
+}
+
+// ShareWithGroup 授予群成员下载附件的权限，只记录群组ID，下载时按当前成员身份校验，
+// 之后入群的成员可以下载，被移出或封禁的成员不能再下载
+func (s *AttachmentService) ShareWithGroup(attachmentIDs []primitive.ObjectID, groupID uint) error {
+	return s.shareWithScope(attachmentIDs, "groups", groupID)
+}
+
+// ShareWithChannel 授予频道订阅者下载附件的权限，只记录频道ID，不按订阅者逐个授权
+func (s *AttachmentService) ShareWithChannel(attachmentIDs []primitive.ObjectID, channelID uint) error {
+	return s.shareWithScope(attachmentIDs, "channels", channelID)
+}
+
+// shareWithScope 在附件上记录引用它的群组或频道
+func (s *AttachmentService) shareWithScope(attachmentIDs []primitive.ObjectID, field string, scopeID uint) error {
+	if len(attachmentIDs) == 0 {
+		return nil
+	}
+
+	collection, err := attachmentsCollection()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	filter := bson.M{"_id": bson.M{"$in": attachmentIDs}}
+	update := bson.M{"$addToSet": bson.M{field: scopeID}}
+	if _, err := collection.UpdateMany(ctx, filter, update); err != nil {
+		logrus.Errorf("附件授权失败: %v", err)
+		return errors.New("附件授权失败")
+	}
+	return nil
+}
+
+// canAccess 用户是否可以下载附件：上传者、收到引用消息的用户，以及引用该附件的群组的当前成员和频道的订阅者
+func (s *AttachmentService) canAccess(attachment *model.Attachment, userID uint) bool {
+	if attachment.CanAccess(userID) {
+		return true
+	}
+	for _, groupID := range attachment.Groups {
+		if groupService.IsMember(groupID, userID) {
+			return true
+		}
+	}
+	for _, channelID := range attachment.Channels {
+		if channelService.IsSubscriber(channelID, userID) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadAttachments 批量加载消息引用的附件信息，键为附件ID；查询失败时返回空结果
+func (s *AttachmentService) LoadAttachments(messages []model.ChatMessage) map[primitive.ObjectID]*AttachmentResponse {
+	result := make(map[primitive.ObjectID]*AttachmentResponse)
+
+	var ids []primitive.ObjectID
+	for i := range messages {
+		ids = append(ids, messages[i].Attachments...)
+	}
+	if len(ids) == 0 {
+		return result
+	}
+
+	collection, err := attachmentsCollection()
+	if err != nil {
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		logrus.Warnf("查询消息附件失败: %v", err)
+		return result
+	}
+
+	var attachments []model.Attachment
+	if err := cursor.All(ctx, &attachments); err != nil {
+		logrus.Warnf("读取消息附件失败: %v", err)
+		return result
+	}
+	for i := range attachments {
+		result[attachments[i].ID] = NewAttachmentResponse(&attachments[i])
+	}
+	return result
+}
+
+// deleteAttachmentBlobs 删除附件原文件及其缩略图
+func deleteAttachmentBlobs(attachment *model.Attachment) {
+	store := getBlobStore()
+	store.Delete(attachment.StorageKey)
+	for _, thumbnail := range attachment.Thumbnails {
+		store.Delete(thumbnail.StorageKey)
+	}
+}
+
+// allowedAttachmentType 检查MIME类型是否在允许列表中
+func allowedAttachmentType(contentType string) bool {
+	for _, allowed := range config.AttachmentAllowedTypes {
+		if allowed == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+// attachmentsCollection 获取附件集合
+func attachmentsCollection() (*mongo.Collection, error) {
+	client := global.GetMongoDBClient()
+	if client == nil {
+		return nil, errors.New("消息存储不可用")
+	}
+	return client.Database(config.MongoDBName).Collection(attachmentCollection), nil
+}
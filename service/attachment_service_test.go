@@ -0,0 +1,215 @@
+package service
+
+import (
+	"bytes"
+	"go_chat/config"
+	"go_chat/model"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// setupAttachments 使用临时目录存储附件并设置测试用的附件配置，测试结束后恢复
+func setupAttachments(t *testing.T) *LocalBlobStore {
+	t.Helper()
+	previousStore := blobStore
+	maxSize, allowed := config.AttachmentMaxSize, config.AttachmentAllowedTypes
+	t.Cleanup(func() {
+		blobStore = previousStore
+		config.AttachmentMaxSize, config.AttachmentAllowedTypes = maxSize, allowed
+	})
+	store := NewLocalBlobStore(t.TempDir())
+	SetBlobStore(store)
+	config.AttachmentMaxSize = 1024
+	config.AttachmentAllowedTypes = []string{"text/plain", "image/png"}
+	return store
+}
+
+func TestUpload(t *testing.T) {
+	store := setupAttachments(t)
+	mt := newMockMongo(t)
+
+	mt.Run("保存附件", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		attachment, err := NewAttachmentService().Upload(1, "../notes.txt", 5, strings.NewReader("hello"))
+		if err != nil {
+			mt.Fatalf("Upload() error = %v", err)
+		}
+		if attachment.FileName != "notes.txt" || attachment.ContentType != "text/plain" || attachment.Size != 5 {
+			mt.Errorf("attachment = %+v", attachment)
+		}
+		reader, err := store.Open(attachment.StorageKey)
+		if err != nil {
+			mt.Fatalf("附件内容未写入: %v", err)
+		}
+		content, _ := io.ReadAll(reader)
+		reader.Close()
+		if string(content) != "hello" {
+			mt.Errorf("附件内容 = %q, want hello", content)
+		}
+	})
+
+	mt.Run("元数据保存失败时删除内容", func(mt *mtest.T) {
+		useMockMongo(mt)
+		store := setupAttachments(mt.T)
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 1, Message: "insert failed"}))
+
+		if _, err := NewAttachmentService().Upload(1, "notes.txt", 5, strings.NewReader("hello")); err == nil || err.Error() != "附件保存失败" {
+			mt.Fatalf("Upload() error = %v, want 附件保存失败", err)
+		}
+		if entries, _ := os.ReadDir(filepath.Join(store.Root, "1")); len(entries) != 0 {
+			mt.Errorf("残留附件内容 %d 个, want 0", len(entries))
+		}
+	})
+
+	rejects := []struct {
+		name    string
+		size    int64
+		content []byte
+		wantErr string
+	}{
+		{"声明大小超限", 2048, []byte("hello"), "附件大小超过限制"},
+		{"实际大小超限", 5, bytes.Repeat([]byte("a"), 2048), "附件大小超过限制"},
+		{"空文件", 0, nil, "附件不能为空"},
+		{"不支持的类型", 5, []byte("%PDF-1.4 fake"), "不支持的附件类型"},
+	}
+	for _, tt := range rejects {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			useMockMongo(mt)
+			if _, err := NewAttachmentService().Upload(1, "file", tt.size, bytes.NewReader(tt.content)); err == nil || err.Error() != tt.wantErr {
+				mt.Errorf("Upload() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetAttachable(t *testing.T) {
+	mt := newMockMongo(t)
+	own := model.Attachment{ID: primitive.NewObjectID(), OwnerID: 1, CreatedAt: time.Now()}
+	shared := model.Attachment{ID: primitive.NewObjectID(), OwnerID: 2, SharedWith: []uint{1}, CreatedAt: time.Now()}
+	others := model.Attachment{ID: primitive.NewObjectID(), OwnerID: 3, CreatedAt: time.Now()}
+	found := func(mt *mtest.T, attachment model.Attachment) bson.D {
+		return mtest.CreateCursorResponse(0, "go_chat_test.attachments", mtest.FirstBatch, toBSON(mt, attachment))
+	}
+
+	mt.Run("按请求顺序返回并去重", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(found(mt, shared), found(mt, own))
+
+		attachments, err := NewAttachmentService().GetAttachable(1, []string{shared.ID.Hex(), own.ID.Hex(), shared.ID.Hex()})
+		if err != nil || len(attachments) != 2 || attachments[0].ID != shared.ID || attachments[1].ID != own.ID {
+			mt.Errorf("GetAttachable() = %+v, %v", attachments, err)
+		}
+	})
+
+	mt.Run("无权引用他人的附件", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(found(mt, others))
+
+		if _, err := NewAttachmentService().GetAttachable(1, []string{others.ID.Hex()}); err == nil || err.Error() != "附件不存在" {
+			mt.Errorf("GetAttachable() error = %v, want 附件不存在", err)
+		}
+	})
+
+	t.Run("附件数量超限", func(t *testing.T) {
+		ids := make([]string, maxMessageAttachments+1)
+		if _, err := NewAttachmentService().GetAttachable(1, ids); err == nil || err.Error() != "附件数量超过限制" {
+			t.Errorf("GetAttachable() error = %v, want 附件数量超过限制", err)
+		}
+	})
+}
+
+func TestUploadImage(t *testing.T) {
+	store := setupAttachments(t)
+	previous := config.AttachmentThumbSizes
+	t.Cleanup(func() { config.AttachmentThumbSizes = previous })
+	config.AttachmentMaxSize = 1 << 20
+	config.AttachmentThumbSizes = []int{10}
+	mt := newMockMongo(t)
+
+	mt.Run("去除元数据并生成缩略图", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		var encoded bytes.Buffer
+		if err := png.Encode(&encoded, testImage(strings.Repeat("a", 40)+strings.Repeat("/"+strings.Repeat("a", 40), 19))); err != nil {
+			mt.Fatal(err)
+		}
+		// 在IHDR块之后插入包含拍摄位置的文本块
+		const headerLength = 8 + 12 + 13
+		data := append([]byte{}, encoded.Bytes()[:headerLength]...)
+		data = appendPNGChunk(data, "tEXt", []byte("GPS\x00secret-location"))
+		data = append(data, encoded.Bytes()[headerLength:]...)
+
+		attachment, err := NewAttachmentService().Upload(1, "photo.png", int64(len(data)), bytes.NewReader(data))
+		if err != nil {
+			mt.Fatalf("Upload() error = %v", err)
+		}
+		if attachment.Width != 40 || attachment.Height != 20 || attachment.Size != int64(encoded.Len()) {
+			mt.Errorf("attachment = %dx%d %d 字节", attachment.Width, attachment.Height, attachment.Size)
+		}
+		reader, err := store.Open(attachment.StorageKey)
+		if err != nil {
+			mt.Fatal(err)
+		}
+		content, _ := io.ReadAll(reader)
+		reader.Close()
+		if bytes.Contains(content, []byte("secret-location")) {
+			mt.Error("保存的原图仍包含元数据")
+		}
+
+		thumbnail := attachment.FindThumbnail(10)
+		if thumbnail == nil || thumbnail.Width != 10 || thumbnail.Height != 5 {
+			mt.Fatalf("缩略图 = %+v, want 10x5", thumbnail)
+		}
+		if _, err := store.Open(thumbnail.StorageKey); err != nil {
+			mt.Errorf("缩略图未写入: %v", err)
+		}
+		if response := NewAttachmentResponse(attachment); len(response.Thumbnails) != 1 || response.Thumbnails[0].URL != response.URL+"/thumbnails/10" {
+			mt.Errorf("缩略图地址 = %+v", response.Thumbnails)
+		}
+	})
+}
+
+func TestAttachmentGroupAccess(t *testing.T) {
+	users := setupGroups(t, 4)
+	group, err := groupService.CreateGroup(users[0], CreateGroupRequest{Name: "项目组", MemberIDs: []uint{users[1], users[2]}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attachment := &model.Attachment{OwnerID: users[0], Groups: []uint{group.ID}}
+
+	// 按当前成员身份授权：入群的成员可以下载，被移出后不能再下载
+	if _, err := groupService.AddMembers(users[0], group.ID, []uint{users[3]}); err != nil {
+		t.Fatal(err)
+	}
+	if err := groupService.RemoveMember(users[0], group.ID, users[2]); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		userID uint
+		want   bool
+	}{
+		{"上传者", users[0], true},
+		{"群成员", users[1], true},
+		{"之后入群的成员", users[3], true},
+		{"已被移出的成员", users[2], false},
+		{"非群成员", 999, false},
+	}
+	for _, tt := range tests {
+		if got := NewAttachmentService().canAccess(attachment, tt.userID); got != tt.want {
+			t.Errorf("%s: canAccess() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -10,6 +10,8 @@ import (
 
 type AuthService struct{}
 
+var authService = NewAuthService()
+
 // RegisterRequest 注册请求结构
 type RegisterRequest struct {
 	UserName string `json:"username" binding:"required,min=3,max=20"`
@@ -20,8 +22,13 @@ type RegisterRequest struct {
 
 // LoginRequest 登录请求结构
 type LoginRequest struct {
-	UserName string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	UserName   string `json:"username" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+	DeviceName string `json:"device_name" binding:"max=100"` // 设备名称，如"我的笔记本"
+
+	// 以下字段由控制器根据请求填充
+	UserAgent string `json:"-"`
+	IP        string `json:"-"`
 }
 
 // UserResponse 用户响应结构
@@ -34,6 +41,22 @@ type UserResponse struct {
 	Status   string `json:"status"`
 }
 
+// RefreshRequest 刷新令牌请求结构
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LogoutRequest 退出登录请求结构
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// LoginResponse 登录响应结构
+type LoginResponse struct {
+	TokenPair
+	User *UserResponse `json:"user"`
+}
+
 // NewAuthService 创建认证服务实例
 func NewAuthService() *AuthService {
 	return &AuthService{}
@@ -90,8 +113,8 @@ func (s *AuthService) Register(req RegisterRequest) (*UserResponse, error) {
 	}, nil
 }
 
-// Login 用户登录
-func (s *AuthService) Login(req LoginRequest) (*UserResponse, error) {
+// Login 用户登录，校验成功后签发访问令牌
+func (s *AuthService) Login(req LoginRequest) (*LoginResponse, error) {
 	db := global.GetMySQLClient()
 	if db == nil {
 		return nil, errors.New("数据库连接不可用")
@@ -113,16 +136,32 @@ func (s *AuthService) Login(req LoginRequest) (*UserResponse, error) {
 		return nil, errors.New("账户已被禁用")
 	}
 
+	// 为本次登录签发令牌
+	sessionID := NewSessionID()
+	tokens, err := GenerateTokenPair(user.ID, user.UserName, sessionID)
+	if err != nil {
+		logrus.Error("令牌签发失败:", err)
+		return nil, errors.New("令牌签发失败")
+	}
+
+	// 记录登录会话
+	if err := sessionService.CreateSession(user.ID, sessionID, req, tokens.RefreshExpiresAt); err != nil {
+		return nil, err
+	}
+
 	logrus.Info("用户登录成功:", user.UserName)
 
-	// 返回用户信息
-	return &UserResponse{
-		ID:       user.ID,
-		UserName: user.UserName,
-		Email:    user.Email,
-		Phone:    user.Phone,
-		Avatar:   user.Avatar,
-		Status:   user.Status,
+	// 返回令牌和用户信息
+	return &LoginResponse{
+		TokenPair: *tokens,
+		User: &UserResponse{
+			ID:       user.ID,
+			UserName: user.UserName,
+			Email:    user.Email,
+			Phone:    user.Phone,
+			Avatar:   user.Avatar,
+			Status:   user.Status,
+		},
 	}, nil
 }
 
@@ -147,3 +186,140 @@ func (s *AuthService) GetUserByID(userID uint) (*UserResponse, error) {
 		Status:   user.Status,
 	}, nil
 }
+
+// GetUsersByIDs 批量获取用户信息，返回用户ID到用户信息的映射
+func (s *AuthService) GetUsersByIDs(userIDs []uint) (map[uint]*UserResponse, error) {
+	result := make(map[uint]*UserResponse, len(userIDs))
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+
+	db := global.GetMySQLClient()
+	if db == nil {
+		return nil, errors.New("数据库连接不可用")
+	}
+
+	var users []model.User
+	if err := db.Where("id IN (?)", userIDs).Find(&users).Error; err != nil {
+		logrus.Error("批量查询用户失败:", err)
+		return nil, errors.New("查询用户失败")
+	}
+
+	for _, user := range users {
+		result[user.ID] = &UserResponse{
+			ID:       user.ID,
+			UserName: user.UserName,
+			Email:    user.Email,
+			Phone:    user.Phone,
+			Avatar:   user.Avatar,
+			Status:   user.Status,
+		}
+	}
+	return result, nil
+}
+
+// Authenticate 校验访问令牌并加载对应的用户信息
+func (s *AuthService) Authenticate(tokenString string) (*Claims, *UserResponse, error) {
+	claims, err := ParseAccessToken(tokenString)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 检查令牌是否已被吊销
+	if IsTokenRevoked(claims.ID) {
+		return nil, nil, errors.New("令牌已失效")
+	}
+
+	// 检查登录会话是否已被注销
+	if err := sessionService.TouchSession(claims.SessionID); err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.GetUserByID(claims.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 检查用户状态
+	if user.Status != model.Active {
+		return nil, nil, errors.New("账户已被禁用")
+	}
+
+	return claims, user, nil
+}
+
+// Refresh 使用刷新令牌换取新的令牌，旧的刷新令牌随即吊销
+func (s *AuthService) Refresh(req RefreshRequest) (*LoginResponse, error) {
+	claims, err := ParseRefreshToken(req.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.GetUserByID(claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Status != model.Active {
+		return nil, errors.New("账户已被禁用")
+	}
+
+	// 轮换刷新令牌：签发前原子地占用旧令牌，并发重放同一刷新令牌时只有一个请求成功
+	if err := ConsumeToken(claims); err != nil {
+		logrus.Warnf("用户 %d 刷新令牌占用失败: %v", claims.UserID, err)
+		return nil, err
+	}
+
+	tokens, err := GenerateTokenPair(user.ID, user.UserName, claims.SessionID)
+	if err != nil {
+		logrus.Error("令牌签发失败:", err)
+		return nil, errors.New("令牌签发失败")
+	}
+
+	// 延长会话有效期，已注销的会话不能刷新
+	if err := sessionService.ExtendSession(claims.SessionID, tokens.RefreshExpiresAt); err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("用户 %d 刷新令牌成功", user.ID)
+
+	return &LoginResponse{
+		TokenPair: *tokens,
+		User:      user,
+	}, nil
+}
+
+// Logout 退出登录，注销当前会话并吊销访问令牌以及同一会话的刷新令牌
+func (s *AuthService) Logout(claims *Claims, req LogoutRequest) error {
+	sessionErr := sessionService.RevokeSessionByID(claims.SessionID)
+	if sessionErr != nil {
+		logrus.Warnf("会话注销失败: %v", sessionErr)
+	}
+
+	// 会话已注销时令牌即已失效，Redis吊销失败不影响退出
+	if err := RevokeToken(claims); err != nil {
+		if sessionErr != nil {
+			return err
+		}
+		logrus.Warnf("访问令牌吊销失败: %v", err)
+	}
+
+	if req.RefreshToken == "" {
+		return nil
+	}
+
+	refreshClaims, err := ParseRefreshToken(req.RefreshToken)
+	if err != nil {
+		// 刷新令牌已过期或无效，不影响退出登录
+		return nil
+	}
+
+	if refreshClaims.UserID != claims.UserID || refreshClaims.SessionID != claims.SessionID {
+		return errors.New("刷新令牌与当前会话不匹配")
+	}
+
+	if err := RevokeToken(refreshClaims); err != nil && sessionErr != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"go_chat/global"
+	"go_chat/model"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// setupAuthService 准备刷新令牌测试所需的用户、Redis和JWT配置
+func setupAuthService(t *testing.T) *model.User {
+	t.Helper()
+	setupJWT(t)
+	setupRedis(t)
+	db := setupMySQL(t, &model.User{}, &model.UserSession{})
+
+	user := &model.User{UserName: "alice", Status: model.Active}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// loginTokens 为用户创建登录会话并签发令牌
+func loginTokens(t *testing.T, userID uint, sessionID string) *TokenPair {
+	t.Helper()
+	tokens, err := GenerateTokenPair(userID, "", sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sessionService.CreateSession(userID, sessionID, LoginRequest{}, tokens.RefreshExpiresAt); err != nil {
+		t.Fatal(err)
+	}
+	return tokens
+}
+
+func TestRefresh(t *testing.T) {
+	user := setupAuthService(t)
+	auth := NewAuthService()
+	tokens := loginTokens(t, user.ID, "session-1")
+
+	refreshed, err := auth.Refresh(RefreshRequest{RefreshToken: tokens.RefreshToken})
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if refreshed.RefreshToken == tokens.RefreshToken || refreshed.User.ID != user.ID {
+		t.Errorf("Refresh() = %+v, want 新的令牌对", refreshed)
+	}
+	claims, err := ParseRefreshToken(refreshed.RefreshToken)
+	if err != nil {
+		t.Fatalf("新刷新令牌无效: %v", err)
+	}
+	if claims.SessionID != "session-1" {
+		t.Errorf("新刷新令牌的会话 = %s, want session-1", claims.SessionID)
+	}
+
+	// 刷新令牌轮换后旧令牌不能再次使用
+	if _, err := auth.Refresh(RefreshRequest{RefreshToken: tokens.RefreshToken}); err == nil || err.Error() != "令牌已失效" {
+		t.Errorf("重复使用刷新令牌 error = %v, want 令牌已失效", err)
+	}
+	if _, err := auth.Refresh(RefreshRequest{RefreshToken: refreshed.RefreshToken}); err != nil {
+		t.Errorf("使用新刷新令牌 error = %v", err)
+	}
+}
+
+func TestRefreshRejects(t *testing.T) {
+	user := setupAuthService(t)
+	auth := NewAuthService()
+	disabled := &model.User{UserName: "bob", Status: "disabled"}
+	global.MySQLClient.Create(disabled)
+
+	var sessions int
+	pair := func(userID uint) *TokenPair {
+		sessions++
+		return loginTokens(t, userID, "session-"+strconv.Itoa(sessions))
+	}
+	expired := testClaims(time.Now().Add(-48 * time.Hour))
+	expired.TokenType = TokenTypeRefresh
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{"访问令牌", pair(user.ID).AccessToken, "令牌类型错误"},
+		{"已过期", signClaims(t, jwt.SigningMethodHS256, []byte(testJwtSecret), expired), "令牌无效或已过期"},
+		{"用户不存在", pair(999).RefreshToken, "用户不存在"},
+		{"用户已禁用", pair(disabled.ID).RefreshToken, "账户已被禁用"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := auth.Refresh(RefreshRequest{RefreshToken: tt.token}); err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Refresh() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRefreshSingleUse(t *testing.T) {
+	user := setupAuthService(t)
+	auth := NewAuthService()
+	tokens := loginTokens(t, user.ID, "session-1")
+
+	// 并发重放同一刷新令牌时只有一个请求能换到新令牌
+	const attempts = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := auth.Refresh(RefreshRequest{RefreshToken: tokens.RefreshToken})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case err.Error() != "令牌已失效":
+			t.Errorf("重放刷新令牌 error = %v, want 令牌已失效", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("成功刷新 %d 次, want 1", succeeded)
+	}
+}
+
+func TestRefreshWithoutRedis(t *testing.T) {
+	user := setupAuthService(t)
+	tokens := loginTokens(t, user.ID, "session-1")
+	global.RedisClient = nil
+
+	// 无法记录旧令牌已被使用时不能签发新令牌
+	if _, err := NewAuthService().Refresh(RefreshRequest{RefreshToken: tokens.RefreshToken}); err == nil || err.Error() != "Redis连接不可用" {
+		t.Errorf("Refresh() error = %v, want Redis连接不可用", err)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	user := setupAuthService(t)
+	auth := NewAuthService()
+	tokens := loginTokens(t, user.ID, "session-1")
+	other := loginTokens(t, user.ID, "session-2")
+	access, err := ParseAccessToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := auth.Logout(access, LogoutRequest{RefreshToken: other.RefreshToken}); err == nil || err.Error() != "刷新令牌与当前会话不匹配" {
+		t.Errorf("Logout(其他会话的刷新令牌) error = %v, want 刷新令牌与当前会话不匹配", err)
+	}
+
+	if err := auth.Logout(access, LogoutRequest{RefreshToken: tokens.RefreshToken}); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+	if _, _, err := auth.Authenticate(tokens.AccessToken); err == nil || err.Error() != "令牌已失效" {
+		t.Errorf("退出后访问令牌 error = %v, want 令牌已失效", err)
+	}
+	if _, err := auth.Refresh(RefreshRequest{RefreshToken: tokens.RefreshToken}); err == nil || err.Error() != "令牌已失效" {
+		t.Errorf("退出后刷新令牌 error = %v, want 令牌已失效", err)
+	}
+	if _, _, err := auth.Authenticate(other.AccessToken); err != nil {
+		t.Errorf("其他会话的访问令牌 error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"errors"
+	"go_chat/config"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// BlobStore 附件内容存储，可替换为对象存储等实现
+type BlobStore interface {
+	// Put 写入内容，返回写入的字节数
+	Put(key string, reader io.Reader) (int64, error)
+	// Open 读取内容，调用方负责关闭
+	Open(key string) (io.ReadCloser, error)
+	// Delete 删除内容，不存在时不返回错误
+	Delete(key string) error
+}
+
+var (
+	blobStore     BlobStore
+	blobStoreOnce sync.Once
+)
+
+// SetBlobStore 替换附件存储实现，需在服务启动前调用
+func SetBlobStore(store BlobStore) {
+	blobStore = store
+}
+
+// getBlobStore 获取附件存储，未设置时使用配置的本地目录
+func getBlobStore() BlobStore {
+	blobStoreOnce.Do(func() {
+		if blobStore == nil {
+			blobStore = NewLocalBlobStore(config.AttachmentStorageDir)
+		}
+	})
+	return blobStore
+}
+
+// LocalBlobStore 基于本地文件系统的附件存储
+type LocalBlobStore struct {
+	Root string
+}
+
+// NewLocalBlobStore 创建本地文件系统存储
+func NewLocalBlobStore(root string) *LocalBlobStore {
+	return &LocalBlobStore{Root: root}
+}
+
+// Put 写入临时文件后重命名，避免读取到写了一半的文件
+func (s *LocalBlobStore) Put(key string, reader io.Reader) (int64, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, reader)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return written, os.Rename(tmp.Name(), path)
+}
+
+// Open 打开存储的文件
+func (s *LocalBlobStore) Open(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Delete 删除存储的文件
+func (s *LocalBlobStore) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// path 将存储键转换为根目录下的文件路径，拒绝越出根目录的键
+func (s *LocalBlobStore) path(key string) (string, error) {
+	cleaned := filepath.Clean("/" + key)
+	if key == "" || cleaned == "/" || strings.Contains(key, "..") {
+		return "", errors.New("存储键无效")
+	}
+	return filepath.Join(s.Root, cleaned), nil
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalBlobStore(t *testing.T) {
+	root := t.TempDir()
+	store := NewLocalBlobStore(root)
+
+	written, err := store.Put("1/abc", strings.NewReader("hello"))
+	if err != nil || written != 5 {
+		t.Fatalf("Put() = %d, %v, want 5", written, err)
+	}
+	reader, err := store.Open("1/abc")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	content, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(content) != "hello" {
+		t.Errorf("读取内容 = %q, want hello", content)
+	}
+
+	// 写入完成后不应残留临时文件
+	entries, _ := os.ReadDir(filepath.Join(root, "1"))
+	if len(entries) != 1 {
+		t.Errorf("存储目录文件数 = %d, want 1", len(entries))
+	}
+
+	if err := store.Delete("1/abc"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := store.Delete("1/abc"); err != nil {
+		t.Errorf("删除不存在的文件 error = %v, want nil", err)
+	}
+	if _, err := store.Open("1/abc"); err == nil {
+		t.Error("删除后 Open() error = nil")
+	}
+}
+
+func TestLocalBlobStoreRejectsInvalidKeys(t *testing.T) {
+	store := NewLocalBlobStore(t.TempDir())
+	for _, key := range []string{"", "/", "../escape", "1/../../escape"} {
+		if _, err := store.Put(key, strings.NewReader("x")); err == nil || err.Error() != "存储键无效" {
+			t.Errorf("Put(%q) error = %v, want 存储键无效", key, err)
+		}
+	}
+}
@@ -0,0 +1,417 @@
+package service
+
+import (
+	"errors"
+	"go_chat/global"
+	"go_chat/model"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jinzhu/gorm"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	maxChannelNameLength        = 50  // 频道名称最大字符数
+	maxChannelDescriptionLength = 500 // 频道简介最大字符数
+)
+
+type ChannelService struct{}
+
+var channelService = NewChannelService()
+
+// CreateChannelRequest 创建频道请求
+type CreateChannelRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+// UpdateReadSeqRequest 更新频道已读位置请求
+type UpdateReadSeqRequest struct {
+	Seq int64 `json:"seq" binding:"required"` // 已读到的消息序号
+}
+
+// ChannelResponse 频道信息
+type ChannelResponse struct {
+	ID              uint      `json:"id"`
+	SessionID       string    `json:"session_id"`
+	Name            string    `json:"name"`
+	Description     string    `json:"description,omitempty"`
+	CreatorID       uint      `json:"creator_id"`
+	SubscriberCount int       `json:"subscriber_count"`
+	Subscribed      bool      `json:"subscribed"`
+	Role            string    `json:"role,omitempty"` // 当前用户在频道中的角色
+	LastSeq         int64     `json:"last_seq"`       // 最新消息序号
+	LastReadSeq     int64     `json:"last_read_seq"`  // 当前用户已读到的序号
+	UnreadCount     int64     `json:"unread_count"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// NewChannelService 创建频道服务实例
+func NewChannelService() *ChannelService {
+	return &ChannelService{}
+}
+
+// CreateChannel 创建频道，创建者成为频道所有者并自动订阅
+func (s *ChannelService) CreateChannel(ownerID uint, req CreateChannelRequest) (*ChannelResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("频道名称不能为空")
+	}
+	if utf8.RuneCountInString(name) > maxChannelNameLength {
+		return nil, errors.New("频道名称过长")
+	}
+	description := strings.TrimSpace(req.Description)
+	if utf8.RuneCountInString(description) > maxChannelDescriptionLength {
+		return nil, errors.New("频道简介过长")
+	}
+
+	db := global.GetMySQLClient()
+	if db == nil {
+		return nil, errors.New("数据库连接不可用")
+	}
+
+	channel := model.Channel{Name: name, Description: description, CreatorID: ownerID, SubscriberCount: 1}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&channel).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.ChannelSubscriber{ChannelID: channel.ID, UserID: ownerID, Role: model.ChannelRoleOwner}).Error
+	})
+	if err != nil {
+		logrus.Error("频道创建失败:", err)
+		return nil, errors.New("频道创建失败")
+	}
+
+	logrus.Infof("用户 %d 创建了频道 %d", ownerID, channel.ID)
+	subscriber := &model.ChannelSubscriber{Role: model.ChannelRoleOwner}
+	return newChannelResponse(&channel, subscriber, 0), nil
+}
+
+// ListChannels 按名称或简介搜索频道，keyword为空时返回订阅者最多的频道
+func (s *ChannelService) ListChannels(userID uint, keyword string, limit int) ([]ChannelResponse, error) {
+	db := global.GetMySQLClient()
+	if db == nil {
+		return nil, errors.New("数据库连接不可用")
+	}
+
+	if limit <= 0 || limit > maxRoomLimit {
+		limit = defaultRoomLimit
+	}
+
+	query := db.Model(&model.Channel{})
+	if keyword = strings.TrimSpace(keyword); keyword != "" {
+		pattern := "%" + escapeLike(keyword) + "%"
+		query = query.Where("name LIKE ? OR description LIKE ?", pattern, pattern)
+	}
+
+	var channels []model.Channel
+	if err := query.Order("subscriber_count DESC, id DESC").Limit(limit).Find(&channels).Error; err != nil {
+		logrus.Error("查询频道列表失败:", err)
+		return nil, errors.New("查询频道失败")
+	}
+	return s.withSubscriptions(userID, channels)
+}
+
+// ListSubscriptions 获取用户订阅的频道及各频道的未读数
+func (s *ChannelService) ListSubscriptions(userID uint) ([]ChannelResponse, error) {
+	db := global.GetMySQLClient()
+	if db == nil {
+		return nil, errors.New("数据库连接不可用")
+	}
+
+	channelIDs, err := s.ListSubscribedChannelIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(channelIDs) == 0 {
+		return []ChannelResponse{}, nil
+	}
+
+	var channels []model.Channel
+	if err := db.Where("id IN (?)", channelIDs).Order("id").Find(&channels).Error; err != nil {
+		logrus.Error("查询频道列表失败:", err)
+		return nil, errors.New("查询频道失败")
+	}
+	return s.withSubscriptions(userID, channels)
+}
+
+// GetChannelDetail 获取频道信息，未订阅也可以查看
+func (s *ChannelService) GetChannelDetail(userID, channelID uint) (*ChannelResponse, error) {
+	channel, err := s.GetChannel(channelID)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.withSubscriptions(userID, []model.Channel{*channel})
+	if err != nil {
+		return nil, err
+	}
+	return &result[0], nil
+}
+
+// Subscribe 订阅频道，已订阅时返回false
+func (s *ChannelService) Subscribe(userID, channelID uint) (bool, error) {
+	if _, err := s.GetChannel(channelID); err != nil {
+		return false, err
+	}
+	if s.IsSubscriber(channelID, userID) {
+		return false, nil
+	}
+
+	db := global.GetMySQLClient()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		subscriber := model.ChannelSubscriber{ChannelID: channelID, UserID: userID, Role: model.ChannelRoleSubscriber}
+		if err := tx.Create(&subscriber).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.Channel{}).Where("id = ?", channelID).
+			UpdateColumn("subscriber_count", gorm.Expr("subscriber_count + 1")).Error
+	})
+	if err != nil {
+		// 并发订阅时唯一索引冲突，视为已订阅
+		if s.IsSubscriber(channelID, userID) {
+			return false, nil
+		}
+		logrus.Error("订阅频道失败:", err)
+		return false, errors.New("订阅频道失败")
+	}
+	return true, nil
+}
+
+// Unsubscribe 取消订阅频道，频道所有者不能取消订阅
+func (s *ChannelService) Unsubscribe(userID, channelID uint) error {
+	if _, err := s.GetChannel(channelID); err != nil {
+		return err
+	}
+	subscriber, err := s.GetSubscriber(channelID, userID)
+	if err != nil {
+		return err
+	}
+	if subscriber.Role == model.ChannelRoleOwner {
+		return errors.New("频道所有者不能取消订阅")
+	}
+
+	db := global.GetMySQLClient()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(subscriber)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// 已被并发取消，不重复扣减订阅数
+			return nil
+		}
+		return tx.Model(&model.Channel{}).Where("id = ? AND subscriber_count > 0", channelID).
+			UpdateColumn("subscriber_count", gorm.Expr("subscriber_count - 1")).Error
+	})
+	if err != nil {
+		logrus.Error("取消订阅失败:", err)
+		return errors.New("取消订阅失败")
+	}
+	return nil
+}
+
+// SetPublisher 频道所有者指定或取消发布者，被指定的用户需要已订阅频道
+func (s *ChannelService) SetPublisher(operatorID, channelID, userID uint, publisher bool) error {
+	if _, err := s.GetChannel(channelID); err != nil {
+		return err
+	}
+	operator, err := s.GetSubscriber(channelID, operatorID)
+	if err != nil {
+		return err
+	}
+	if operator.Role != model.ChannelRoleOwner {
+		return errors.New("权限不足")
+	}
+	if operatorID == userID {
+		return errors.New("不能修改频道所有者的角色")
+	}
+	target, err := s.GetSubscriber(channelID, userID)
+	if err != nil {
+		if err.Error() == "未订阅该频道" {
+			return errors.New("该用户未订阅频道")
+		}
+		return err
+	}
+
+	role := model.ChannelRoleSubscriber
+	if publisher {
+		role = model.ChannelRolePublisher
+	}
+
+	db := global.GetMySQLClient()
+	if err := db.Model(target).Update("role", role).Error; err != nil {
+		logrus.Error("设置发布者失败:", err)
+		return errors.New("设置发布者失败")
+	}
+	return nil
+}
+
+// CheckPublish 校验用户可以在频道中发布消息
+func (s *ChannelService) CheckPublish(channelID, userID uint) error {
+	if _, err := s.GetChannel(channelID); err != nil {
+		return err
+	}
+	subscriber, err := s.GetSubscriber(channelID, userID)
+	if err != nil {
+		if err.Error() == "未订阅该频道" {
+			return errors.New("没有发布权限")
+		}
+		return err
+	}
+	if !subscriber.CanPublish() {
+		return errors.New("没有发布权限")
+	}
+	return nil
+}
+
+// UpdateReadSeq 更新订阅者的已读位置，只能前进且不会超过频道最新序号，返回更新后的已读位置
+func (s *ChannelService) UpdateReadSeq(userID, channelID uint, seq int64) (int64, error) {
+	if seq <= 0 {
+		return 0, errors.New("已读序号无效")
+	}
+	if _, err := s.GetChannel(channelID); err != nil {
+		return 0, err
+	}
+	subscriber, err := s.GetSubscriber(channelID, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	sessionID := model.ChannelSessionID(channelID)
+	lastSeqs, err := conversationService.GetSessionLastSeqs([]string{sessionID})
+	if err != nil {
+		return 0, err
+	}
+	seq = min(seq, lastSeqs[sessionID])
+	if seq <= subscriber.LastReadSeq {
+		return subscriber.LastReadSeq, nil
+	}
+
+	db := global.GetMySQLClient()
+	err = db.Model(&model.ChannelSubscriber{}).
+		Where("id = ? AND last_read_seq < ?", subscriber.ID, seq).
+		UpdateColumn("last_read_seq", seq).Error
+	if err != nil {
+		logrus.Error("更新频道已读位置失败:", err)
+		return 0, errors.New("更新已读位置失败")
+	}
+	return seq, nil
+}
+
+// GetChannel 根据ID获取频道
+func (s *ChannelService) GetChannel(channelID uint) (*model.Channel, error) {
+	db := global.GetMySQLClient()
+	if db == nil {
+		return nil, errors.New("数据库连接不可用")
+	}
+
+	var channel model.Channel
+	if err := db.First(&channel, channelID).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, errors.New("频道不存在")
+		}
+		logrus.Error("查询频道失败:", err)
+		return nil, errors.New("查询频道失败")
+	}
+	return &channel, nil
+}
+
+// GetSubscriber 获取用户的频道订阅记录，未订阅时返回错误
+func (s *ChannelService) GetSubscriber(channelID, userID uint) (*model.ChannelSubscriber, error) {
+	db := global.GetMySQLClient()
+	if db == nil {
+		return nil, errors.New("数据库连接不可用")
+	}
+
+	var subscriber model.ChannelSubscriber
+	err := db.Where("channel_id = ? AND user_id = ?", channelID, userID).First(&subscriber).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, errors.New("未订阅该频道")
+		}
+		logrus.Error("查询频道订阅失败:", err)
+		return nil, errors.New("查询频道订阅失败")
+	}
+	return &subscriber, nil
+}
+
+// IsSubscriber 用户是否订阅了频道，查询失败时视为未订阅
+func (s *ChannelService) IsSubscriber(channelID, userID uint) bool {
+	_, err := s.GetSubscriber(channelID, userID)
+	return err == nil
+}
+
+// ListSubscribedChannelIDs 获取用户订阅的全部频道ID
+func (s *ChannelService) ListSubscribedChannelIDs(userID uint) ([]uint, error) {
+	db := global.GetMySQLClient()
+	if db == nil {
+		return nil, errors.New("数据库连接不可用")
+	}
+
+	var channelIDs []uint
+	if err := db.Model(&model.ChannelSubscriber{}).Where("user_id = ?", userID).Pluck("channel_id", &channelIDs).Error; err != nil {
+		logrus.Error("查询用户频道失败:", err)
+		return nil, errors.New("查询频道失败")
+	}
+	return channelIDs, nil
+}
+
+// withSubscriptions 为频道补充当前用户的订阅信息、最新序号和未读数
+func (s *ChannelService) withSubscriptions(userID uint, channels []model.Channel) ([]ChannelResponse, error) {
+	if len(channels) == 0 {
+		return []ChannelResponse{}, nil
+	}
+
+	channelIDs := make([]uint, 0, len(channels))
+	sessionIDs := make([]string, 0, len(channels))
+	for _, channel := range channels {
+		channelIDs = append(channelIDs, channel.ID)
+		sessionIDs = append(sessionIDs, model.ChannelSessionID(channel.ID))
+	}
+
+	db := global.GetMySQLClient()
+	var subscriptions []model.ChannelSubscriber
+	if err := db.Where("user_id = ? AND channel_id IN (?)", userID, channelIDs).Find(&subscriptions).Error; err != nil {
+		logrus.Error("查询频道订阅失败:", err)
+		return nil, errors.New("查询频道失败")
+	}
+	subscribed := make(map[uint]*model.ChannelSubscriber, len(subscriptions))
+	for i := range subscriptions {
+		subscribed[subscriptions[i].ChannelID] = &subscriptions[i]
+	}
+
+	lastSeqs, err := conversationService.GetSessionLastSeqs(sessionIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]ChannelResponse, 0, len(channels))
+	for i := range channels {
+		channel := &channels[i]
+		result = append(result, *newChannelResponse(channel, subscribed[channel.ID], lastSeqs[model.ChannelSessionID(channel.ID)]))
+	}
+	return result, nil
+}
+
+// newChannelResponse 转换频道信息，subscriber为空表示当前用户未订阅
+func newChannelResponse(channel *model.Channel, subscriber *model.ChannelSubscriber, lastSeq int64) *ChannelResponse {
+	response := &ChannelResponse{
+		ID:              channel.ID,
+		SessionID:       model.ChannelSessionID(channel.ID),
+		Name:            channel.Name,
+		Description:     channel.Description,
+		CreatorID:       channel.CreatorID,
+		SubscriberCount: channel.SubscriberCount,
+		LastSeq:         lastSeq,
+		CreatedAt:       channel.CreatedAt,
+	}
+	if subscriber != nil {
+		response.Subscribed = true
+		response.Role = subscriber.Role
+		response.LastReadSeq = subscriber.LastReadSeq
+		response.UnreadCount = max(lastSeq-subscriber.LastReadSeq, 0)
+	}
+	return response
+}
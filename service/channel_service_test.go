@@ -0,0 +1,207 @@
+package service
+
+import (
+	"go_chat/model"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// setupChannels 准备频道测试所需的数据库，创建由第一个用户拥有的频道并返回用户ID和频道ID
+func setupChannels(t *testing.T, userCount int) ([]uint, uint) {
+	t.Helper()
+	db := setupMySQL(t, &model.User{}, &model.Channel{}, &model.ChannelSubscriber{})
+
+	userIDs := make([]uint, 0, userCount)
+	for i := 0; i < userCount; i++ {
+		user := model.User{UserName: "user" + string(rune('a'+i)), Status: model.Active}
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+		userIDs = append(userIDs, user.ID)
+	}
+
+	channel, err := channelService.CreateChannel(userIDs[0], CreateChannelRequest{Name: " 公告 ", Description: "每日公告"})
+	if err != nil {
+		t.Fatalf("CreateChannel() error = %v", err)
+	}
+	if channel.Name != "公告" || channel.SubscriberCount != 1 || channel.Role != model.ChannelRoleOwner || channel.SessionID != model.ChannelSessionID(channel.ID) {
+		t.Fatalf("CreateChannel() = %+v", channel)
+	}
+	return userIDs, channel.ID
+}
+
+// subscriberCount 查询频道记录的订阅者数量
+func subscriberCount(t *testing.T, channelID uint) int {
+	t.Helper()
+	channel, err := channelService.GetChannel(channelID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return channel.SubscriberCount
+}
+
+func TestCreateChannelRejects(t *testing.T) {
+	setupMySQL(t, &model.Channel{}, &model.ChannelSubscriber{})
+
+	tests := []struct {
+		name    string
+		req     CreateChannelRequest
+		wantErr string
+	}{
+		{"名称为空", CreateChannelRequest{Name: "  "}, "频道名称不能为空"},
+		{"名称过长", CreateChannelRequest{Name: strings.Repeat("频", maxChannelNameLength+1)}, "频道名称过长"},
+		{"简介过长", CreateChannelRequest{Name: "公告", Description: strings.Repeat("频", maxChannelDescriptionLength+1)}, "频道简介过长"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := channelService.CreateChannel(1, tt.req); err == nil || err.Error() != tt.wantErr {
+				t.Errorf("CreateChannel() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSubscribeChannel(t *testing.T) {
+	users, channelID := setupChannels(t, 3)
+
+	for _, userID := range users[1:] {
+		if subscribed, err := channelService.Subscribe(userID, channelID); err != nil || !subscribed {
+			t.Fatalf("Subscribe(%d) = %v, %v, want true", userID, subscribed, err)
+		}
+	}
+	// 重复订阅不增加订阅数
+	if subscribed, err := channelService.Subscribe(users[1], channelID); err != nil || subscribed {
+		t.Errorf("重复订阅 = %v, %v, want false", subscribed, err)
+	}
+	if got := subscriberCount(t, channelID); got != 3 {
+		t.Errorf("订阅数 = %d, want 3", got)
+	}
+	if _, err := channelService.Subscribe(users[1], channelID+1); err == nil || err.Error() != "频道不存在" {
+		t.Errorf("订阅不存在的频道 error = %v, want 频道不存在", err)
+	}
+
+	if err := channelService.Unsubscribe(users[1], channelID); err != nil {
+		t.Fatalf("Unsubscribe() error = %v", err)
+	}
+	if got := subscriberCount(t, channelID); got != 2 || channelService.IsSubscriber(channelID, users[1]) {
+		t.Errorf("取消订阅后订阅数 = %d, want 2", got)
+	}
+	if err := channelService.Unsubscribe(users[1], channelID); err == nil || err.Error() != "未订阅该频道" {
+		t.Errorf("重复取消订阅 error = %v, want 未订阅该频道", err)
+	}
+	if err := channelService.Unsubscribe(users[0], channelID); err == nil || err.Error() != "频道所有者不能取消订阅" {
+		t.Errorf("所有者取消订阅 error = %v, want 频道所有者不能取消订阅", err)
+	}
+
+	subscriptions, err := channelService.ListSubscribedChannelIDs(users[2])
+	if err != nil || len(subscriptions) != 1 || subscriptions[0] != channelID {
+		t.Errorf("ListSubscribedChannelIDs() = %v, %v, want [%d]", subscriptions, err, channelID)
+	}
+}
+
+func TestSetPublisher(t *testing.T) {
+	users, channelID := setupChannels(t, 4)
+	channelService.Subscribe(users[1], channelID)
+	channelService.Subscribe(users[2], channelID)
+
+	if err := channelService.CheckPublish(channelID, users[0]); err != nil {
+		t.Errorf("所有者发布 error = %v", err)
+	}
+	if err := channelService.CheckPublish(channelID, users[1]); err == nil || err.Error() != "没有发布权限" {
+		t.Errorf("订阅者发布 error = %v, want 没有发布权限", err)
+	}
+
+	if err := channelService.SetPublisher(users[0], channelID, users[1], true); err != nil {
+		t.Fatalf("SetPublisher() error = %v", err)
+	}
+	if err := channelService.CheckPublish(channelID, users[1]); err != nil {
+		t.Errorf("发布者发布 error = %v", err)
+	}
+	if err := channelService.SetPublisher(users[0], channelID, users[1], false); err != nil {
+		t.Fatalf("SetPublisher() error = %v", err)
+	}
+	if err := channelService.CheckPublish(channelID, users[1]); err == nil || err.Error() != "没有发布权限" {
+		t.Errorf("取消发布者后发布 error = %v, want 没有发布权限", err)
+	}
+
+	rejects := []struct {
+		name             string
+		operator, target uint
+		wantErr          string
+	}{
+		{"发布者不能指定发布者", users[2], users[1], "权限不足"},
+		{"未订阅的操作者", users[3], users[1], "未订阅该频道"},
+		{"修改所有者", users[0], users[0], "不能修改频道所有者的角色"},
+		{"目标未订阅", users[0], users[3], "该用户未订阅频道"},
+	}
+	for _, tt := range rejects {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := channelService.SetPublisher(tt.operator, channelID, tt.target, true); err == nil || err.Error() != tt.wantErr {
+				t.Errorf("SetPublisher() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := channelService.CheckPublish(channelID, users[3]); err == nil || err.Error() != "没有发布权限" {
+		t.Errorf("未订阅者发布 error = %v, want 没有发布权限", err)
+	}
+	if err := channelService.CheckPublish(channelID+1, users[0]); err == nil || err.Error() != "频道不存在" {
+		t.Errorf("不存在的频道 error = %v, want 频道不存在", err)
+	}
+}
+
+func TestUpdateReadSeq(t *testing.T) {
+	mt := newMockMongo(t)
+
+	mt.Run("已读位置只前进且不超过最新序号", func(mt *mtest.T) {
+		useMockMongo(mt)
+		users, channelID := setupChannels(mt.T, 2)
+		channelService.Subscribe(users[1], channelID)
+		lastSeq := func() {
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, "go_chat_test.conversations", mtest.FirstBatch,
+				toBSON(mt, model.Conversation{SessionID: model.ChannelSessionID(channelID), LastSeq: 10})))
+		}
+
+		tests := []struct {
+			name string
+			seq  int64
+			want int64
+		}{
+			{"前进到指定序号", 5, 5},
+			{"不会后退", 3, 5},
+			{"超过最新序号时截断", 20, 10},
+		}
+		for _, tt := range tests {
+			lastSeq()
+			got, err := channelService.UpdateReadSeq(users[1], channelID, tt.seq)
+			if err != nil || got != tt.want {
+				mt.Errorf("%s: UpdateReadSeq(%d) = %d, %v, want %d", tt.name, tt.seq, got, err, tt.want)
+			}
+		}
+
+		subscriber, err := channelService.GetSubscriber(channelID, users[1])
+		if err != nil || subscriber.LastReadSeq != 10 {
+			mt.Errorf("已读位置 = %+v, %v, want 10", subscriber, err)
+		}
+
+		lastSeq()
+		detail, err := channelService.GetChannelDetail(users[0], channelID)
+		if err != nil || detail.LastSeq != 10 || detail.UnreadCount != 10 || detail.SubscriberCount != 2 {
+			mt.Errorf("GetChannelDetail() = %+v, %v, want 所有者未读 10", detail, err)
+		}
+	})
+
+	mt.Run("拒绝无效请求", func(mt *mtest.T) {
+		useMockMongo(mt)
+		users, channelID := setupChannels(mt.T, 2)
+
+		if _, err := channelService.UpdateReadSeq(users[0], channelID, 0); err == nil || err.Error() != "已读序号无效" {
+			mt.Errorf("序号为0 error = %v, want 已读序号无效", err)
+		}
+		if _, err := channelService.UpdateReadSeq(users[1], channelID, 1); err == nil || err.Error() != "未订阅该频道" {
+			mt.Errorf("未订阅 error = %v, want 未订阅该频道", err)
+		}
+	})
+}
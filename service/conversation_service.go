@@ -0,0 +1,591 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"go_chat/config"
+	"go_chat/global"
+	"go_chat/model"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	conversationCollection = "conversations"   // 会话集合名
+	readReceiptCollection  = "read_receipts"   // 待投递已读回执集合名
+	unreadKeyPrefix        = "go_chat:unread:" // 未读数哈希键前缀，字段为对方用户ID
+	previewLength          = 50                // 最后一条消息预览长度
+
+	defaultConversationLimit = 20  // 会话列表默认每页条数
+	maxConversationLimit     = 100 // 会话列表每页最大条数
+)
+
+type ConversationService struct{}
+
+// ConversationResponse 会话列表项
+type ConversationResponse struct {
+	SessionID   string               `json:"session_id"`
+	LastSeq     int64                `json:"last_seq"`
+	Peer        *UserResponse        `json:"peer"`
+	LastMessage *LastMessageResponse `json:"last_message"`
+	Timestamp   time.Time            `json:"timestamp"`
+	UnreadCount int64                `json:"unread_count"`
+}
+
+// ConversationPage 会话列表分页结果
+type ConversationPage struct {
+	Conversations []ConversationResponse
+	NextCursor    string // 下一页游标，为空表示没有更多
+}
+
+// LastMessageResponse 最后一条消息预览
+type LastMessageResponse struct {
+	ID         string    `json:"id"`
+	FromUserID uint      `json:"from_user_id"`
+	Preview    string    `json:"preview"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// NewConversationService 创建会话服务实例
+func NewConversationService() *ConversationService {
+	return &ConversationService{}
+}
+
+var conversationService = NewConversationService()
+
+// TouchConversation 写入消息后更新会话摘要
+func (s *ConversationService) TouchConversation(message *model.ChatMessage) error {
+	collection, err := conversationsCollection()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	participants := sortedParticipants(message.FromUserID, message.ToUserID)
+
+	// 只有更新的消息才覆盖最后一条消息
+	filter := bson.M{
+		"_id": message.SessionID,
+		"$or": bson.A{
+			bson.M{"last_message.timestamp": bson.M{"$lte": message.Timestamp}},
+			bson.M{"last_message": bson.M{"$exists": false}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"participants": participants,
+			"last_message": model.LastMessage{
+				MessageID:  message.ID,
+				FromUserID: message.FromUserID,
+				Content:    message.Content,
+				Timestamp:  message.Timestamp,
+			},
+			"updated_at": time.Now(),
+		},
+	}
+
+	_, err = collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		logrus.Errorf("会话摘要更新失败: %v", err)
+		return errors.New("会话摘要更新失败")
+	}
+	return nil
+}
+
+// RefreshLastMessage 消息被编辑或撤回后，如果它是会话的最后一条消息则同步更新预览
+func (s *ConversationService) RefreshLastMessage(message *model.ChatMessage) error {
+	collection, err := conversationsCollection()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	filter := bson.M{"_id": message.SessionID, "last_message.message_id": message.ID}
+	update := bson.M{"$set": bson.M{
+		"last_message.content":  message.Content,
+		"last_message.recalled": message.Recalled,
+	}}
+	if _, err := collection.UpdateOne(ctx, filter, update); err != nil {
+		logrus.Errorf("会话摘要更新失败: %v", err)
+		return errors.New("会话摘要更新失败")
+	}
+	return nil
+}
+
+// NextSeq 为会话分配下一个消息序号，多节点并发写入时序号仍然单调递增。
+// 群聊会话不记录参与者，participants为空
+func (s *ConversationService) NextSeq(sessionID string, participants []uint) (int64, error) {
+	collection, err := conversationsCollection()
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	update := bson.M{"$inc": bson.M{"last_seq": 1}}
+	if len(participants) > 0 {
+		update["$setOnInsert"] = bson.M{"participants": participants}
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var conversation model.Conversation
+	if err := collection.FindOneAndUpdate(ctx, bson.M{"_id": sessionID}, update, opts).Decode(&conversation); err != nil {
+		logrus.Errorf("会话 %s 序号分配失败: %v", sessionID, err)
+		return 0, errors.New("消息序号分配失败")
+	}
+	return conversation.LastSeq, nil
+}
+
+// RollbackSeq 归还刚分配但未使用的序号，只有该序号仍是会话最新序号时才能归还，返回是否归还成功。
+// 会话的第一条消息写入失败时直接删除尚无消息的会话记录
+func (s *ConversationService) RollbackSeq(sessionID string, seq int64) bool {
+	collection, err := conversationsCollection()
+	if err != nil {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	if seq == 1 {
+		filter := bson.M{"_id": sessionID, "last_seq": 1, "last_message": bson.M{"$exists": false}}
+		result, err := collection.DeleteOne(ctx, filter)
+		if err != nil {
+			logrus.Errorf("会话 %s 序号归还失败: %v", sessionID, err)
+			return false
+		}
+		return result.DeletedCount > 0
+	}
+
+	filter := bson.M{"_id": sessionID, "last_seq": seq}
+	result, err := collection.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"last_seq": -1}})
+	if err != nil {
+		logrus.Errorf("会话 %s 序号归还失败: %v", sessionID, err)
+		return false
+	}
+	return result.ModifiedCount > 0
+}
+
+// GetLastSeqs 获取用户参与的所有会话的最新序号，键为会话ID
+func (s *ConversationService) GetLastSeqs(userID uint) (map[string]int64, error) {
+	collection, err := conversationsCollection()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	opts := options.Find().SetProjection(bson.M{"last_seq": 1})
+	cursor, err := collection.Find(ctx, bson.M{"participants": userID}, opts)
+	if err != nil {
+		logrus.Errorf("查询会话序号失败: %v", err)
+		return nil, errors.New("查询会话失败")
+	}
+
+	var conversations []model.Conversation
+	if err := cursor.All(ctx, &conversations); err != nil {
+		logrus.Errorf("读取会话序号失败: %v", err)
+		return nil, errors.New("查询会话失败")
+	}
+
+	result := make(map[string]int64, len(conversations))
+	for _, conversation := range conversations {
+		result[conversation.SessionID] = conversation.LastSeq
+	}
+	return result, nil
+}
+
+// GetSessionLastSeqs 获取指定会话的最新序号，键为会话ID，没有消息的会话不在结果中
+func (s *ConversationService) GetSessionLastSeqs(sessionIDs []string) (map[string]int64, error) {
+	result := make(map[string]int64, len(sessionIDs))
+	if len(sessionIDs) == 0 {
+		return result, nil
+	}
+
+	collection, err := conversationsCollection()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	opts := options.Find().SetProjection(bson.M{"last_seq": 1})
+	cursor, err := collection.Find(ctx, bson.M{"_id": bson.M{"$in": sessionIDs}}, opts)
+	if err != nil {
+		logrus.Errorf("查询会话序号失败: %v", err)
+		return nil, errors.New("查询会话失败")
+	}
+
+	var conversations []model.Conversation
+	if err := cursor.All(ctx, &conversations); err != nil {
+		logrus.Errorf("读取会话序号失败: %v", err)
+		return nil, errors.New("查询会话失败")
+	}
+
+	for _, conversation := range conversations {
+		result[conversation.SessionID] = conversation.LastSeq
+	}
+	return result, nil
+}
+
+// ListConversations 按最后消息时间倒序分页获取用户参与的会话，before为上一页返回的游标。
+// 最后一条消息已撤回或被用户删除时，预览改为用户可见的上一条消息
+func (s *ConversationService) ListConversations(userID uint, before string, limit int64) (*ConversationPage, error) {
+	collection, err := conversationsCollection()
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 || limit > maxConversationLimit {
+		limit = defaultConversationLimit
+	}
+
+	filter := bson.M{"participants": userID}
+	if before != "" {
+		timestamp, sessionID, err := decodeConversationCursor(before)
+		if err != nil {
+			return nil, err
+		}
+		filter["$or"] = bson.A{
+			bson.M{"last_message.timestamp": bson.M{"$lt": timestamp}},
+			bson.M{"last_message.timestamp": timestamp, "_id": bson.M{"$lt": sessionID}},
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	// 多取一条用于判断是否还有下一页
+	opts := options.Find().
+		SetSort(bson.D{{Key: "last_message.timestamp", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(limit + 1)
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		logrus.Errorf("查询会话列表失败: %v", err)
+		return nil, errors.New("查询会话列表失败")
+	}
+
+	var conversations []model.Conversation
+	if err := cursor.All(ctx, &conversations); err != nil {
+		logrus.Errorf("读取会话列表失败: %v", err)
+		return nil, errors.New("查询会话列表失败")
+	}
+
+	page := &ConversationPage{}
+	if int64(len(conversations)) > limit {
+		conversations = conversations[:limit]
+		last := conversations[limit-1]
+		page.NextCursor = encodeConversationCursor(last.LastMessage.Timestamp, last.SessionID)
+	}
+
+	// 批量加载对方用户信息和未读数
+	peerIDs := make([]uint, 0, len(conversations))
+	for i := range conversations {
+		peerIDs = append(peerIDs, conversations[i].Peer(userID))
+	}
+	peers, err := authService.GetUsersByIDs(peerIDs)
+	if err != nil {
+		return nil, err
+	}
+	unreadCounts := s.GetUnreadCounts(userID)
+
+	page.Conversations = make([]ConversationResponse, 0, len(conversations))
+	for i := range conversations {
+		conversation := &conversations[i]
+		peerID := conversation.Peer(userID)
+		peer, ok := peers[peerID]
+		if !ok {
+			// 对方账号已不存在
+			continue
+		}
+
+		page.Conversations = append(page.Conversations, ConversationResponse{
+			SessionID:   conversation.SessionID,
+			LastSeq:     conversation.LastSeq,
+			Peer:        peer,
+			LastMessage: s.lastVisibleMessage(conversation, userID),
+			Timestamp:   conversation.LastMessage.Timestamp,
+			UnreadCount: unreadCounts[peerID],
+		})
+	}
+	return page, nil
+}
+
+// lastVisibleMessage 获取会话中查看者可见的最后一条消息预览，跳过已撤回和查看者已删除的消息。
+// 会话摘要中的最后一条消息可见时直接使用，否则查询消息记录，没有可见消息时返回nil
+func (s *ConversationService) lastVisibleMessage(conversation *model.Conversation, viewerID uint) *LastMessageResponse {
+	last := conversation.LastMessage
+	if !last.Recalled && !last.HiddenFrom(viewerID) {
+		return &LastMessageResponse{
+			ID:         last.MessageID.Hex(),
+			FromUserID: last.FromUserID,
+			Preview:    messagePreview(last.Content),
+			Timestamp:  last.Timestamp,
+		}
+	}
+
+	collection, err := messagesCollection()
+	if err != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	filter := withoutSkipped(bson.M{
+		"session_id": conversation.SessionID,
+		"recalled":   bson.M{"$ne": true},
+		"hidden_for": bson.M{"$ne": viewerID},
+	})
+	opts := options.FindOne().SetSort(bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}})
+
+	var message model.ChatMessage
+	if err := collection.FindOne(ctx, filter, opts).Decode(&message); err != nil {
+		if err != mongo.ErrNoDocuments {
+			logrus.Warnf("会话 %s 最后可见消息查询失败: %v", conversation.SessionID, err)
+		}
+		return nil
+	}
+	return &LastMessageResponse{
+		ID:         message.ID.Hex(),
+		FromUserID: message.FromUserID,
+		Preview:    messagePreview(message.Content),
+		Timestamp:  message.Timestamp,
+	}
+}
+
+// HideLastMessage 用户删除的消息是会话最后一条消息时记录在摘要中，会话列表改为预览上一条可见消息
+func (s *ConversationService) HideLastMessage(message *model.ChatMessage, userID uint) error {
+	collection, err := conversationsCollection()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	filter := bson.M{"_id": message.SessionID, "last_message.message_id": message.ID}
+	update := bson.M{"$addToSet": bson.M{"last_message.hidden_for": userID}}
+	if _, err := collection.UpdateOne(ctx, filter, update); err != nil {
+		logrus.Errorf("会话摘要更新失败: %v", err)
+		return errors.New("会话摘要更新失败")
+	}
+	return nil
+}
+
+// UpdateReadPosition 推进用户在会话中的已读位置，位置未前进时返回false
+func (s *ConversationService) UpdateReadPosition(sessionID string, readerID uint, position model.ReadPosition) (bool, error) {
+	collection, err := conversationsCollection()
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	field := "read_positions." + strconv.FormatUint(uint64(readerID), 10)
+	position.UpdatedAt = time.Now()
+
+	// 已读位置只能前进
+	filter := bson.M{
+		"_id": sessionID,
+		"$or": bson.A{
+			bson.M{field + ".timestamp": bson.M{"$lt": position.Timestamp}},
+			bson.M{field: bson.M{"$exists": false}},
+		},
+	}
+	result, err := collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{field: position}})
+	if err != nil {
+		logrus.Errorf("已读位置更新失败: %v", err)
+		return false, errors.New("已读位置更新失败")
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// SavePendingReceipt 暂存发送者离线时的已读回执，同一会话只保留最新位置
+func (s *ConversationService) SavePendingReceipt(receipt *model.ReadReceipt) error {
+	collection, err := readReceiptsCollection()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	receipt.ID = receipt.SessionID + ":" + strconv.FormatUint(uint64(receipt.ReaderID), 10)
+	receipt.CreatedAt = time.Now()
+
+	_, err = collection.ReplaceOne(ctx, bson.M{"_id": receipt.ID}, receipt, options.Replace().SetUpsert(true))
+	if err != nil {
+		logrus.Errorf("已读回执暂存失败: %v", err)
+		return errors.New("已读回执暂存失败")
+	}
+	return nil
+}
+
+// TakePendingReceipts 取出并删除发给指定用户的待投递已读回执
+func (s *ConversationService) TakePendingReceipts(userID uint) ([]model.ReadReceipt, error) {
+	collection, err := readReceiptsCollection()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{"to_user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}}))
+	if err != nil {
+		logrus.Errorf("查询已读回执失败: %v", err)
+		return nil, errors.New("查询已读回执失败")
+	}
+
+	var receipts []model.ReadReceipt
+	if err := cursor.All(ctx, &receipts); err != nil {
+		logrus.Errorf("读取已读回执失败: %v", err)
+		return nil, errors.New("查询已读回执失败")
+	}
+
+	if len(receipts) > 0 {
+		ids := make([]string, 0, len(receipts))
+		for _, receipt := range receipts {
+			ids = append(ids, receipt.ID)
+		}
+		if _, err := collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+			logrus.Warnf("已读回执清理失败: %v", err)
+		}
+	}
+	return receipts, nil
+}
+
+// IncrUnread 接收者与发送者会话的未读数加一
+func (s *ConversationService) IncrUnread(userID, peerID uint) {
+	redisClient := global.GetRedisClient()
+	if redisClient == nil {
+		return
+	}
+
+	key := unreadKeyPrefix + strconv.FormatUint(uint64(userID), 10)
+	if err := redisClient.HIncrBy(key, strconv.FormatUint(uint64(peerID), 10), 1).Err(); err != nil {
+		logrus.Warnf("未读数更新失败: %v", err)
+	}
+}
+
+// DecrUnread 对方发来的未读消息被标记已读、撤回或被用户删除后，从用户与对方会话的未读数中扣除。
+// 扣除可能先于对应的递增执行，计数暂时为负时按0返回，不删除计数
+func (s *ConversationService) DecrUnread(userID, peerID uint, count int64) {
+	redisClient := global.GetRedisClient()
+	if redisClient == nil || count <= 0 {
+		return
+	}
+
+	key := unreadKeyPrefix + strconv.FormatUint(uint64(userID), 10)
+	if err := redisClient.HIncrBy(key, strconv.FormatUint(uint64(peerID), 10), -count).Err(); err != nil {
+		logrus.Warnf("未读数更新失败: %v", err)
+	}
+}
+
+// GetUnreadCounts 获取用户各会话的未读数，键为对方用户ID
+func (s *ConversationService) GetUnreadCounts(userID uint) map[uint]int64 {
+	counts := make(map[uint]int64)
+
+	redisClient := global.GetRedisClient()
+	if redisClient == nil {
+		return counts
+	}
+
+	values, err := redisClient.HGetAll(unreadKeyPrefix + strconv.FormatUint(uint64(userID), 10)).Result()
+	if err != nil {
+		logrus.Warnf("未读数查询失败: %v", err)
+		return counts
+	}
+
+	for field, value := range values {
+		peerID, err := strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			continue
+		}
+		count, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || count <= 0 {
+			continue
+		}
+		counts[uint(peerID)] = count
+	}
+	return counts
+}
+
+// encodeConversationCursor 生成会话列表游标，由最后消息时间和会话ID组成
+func encodeConversationCursor(timestamp time.Time, sessionID string) string {
+	raw := strconv.FormatInt(timestamp.UnixMilli(), 10) + ":" + sessionID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeConversationCursor 解析会话列表游标
+func decodeConversationCursor(cursor string) (time.Time, string, error) {
+	invalid := errors.New("游标格式错误")
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", invalid
+	}
+
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return time.Time{}, "", invalid
+	}
+
+	millis, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, "", invalid
+	}
+	return time.UnixMilli(millis), parts[1], nil
+}
+
+// sortedParticipants 按用户ID升序返回会话参与者
+func sortedParticipants(userA, userB uint) []uint {
+	if userA > userB {
+		userA, userB = userB, userA
+	}
+	return []uint{userA, userB}
+}
+
+// messagePreview 截取消息预览
+func messagePreview(content string) string {
+	if utf8.RuneCountInString(content) <= previewLength {
+		return content
+	}
+	return string([]rune(content)[:previewLength]) + "..."
+}
+
+// readReceiptsCollection 获取待投递已读回执集合
+func readReceiptsCollection() (*mongo.Collection, error) {
+	client := global.GetMongoDBClient()
+	if client == nil {
+		return nil, errors.New("消息存储不可用")
+	}
+	return client.Database(config.MongoDBName).Collection(readReceiptCollection), nil
+}
+
+// conversationsCollection 获取会话集合
+func conversationsCollection() (*mongo.Collection, error) {
+	client := global.GetMongoDBClient()
+	if client == nil {
+		return nil, errors.New("消息存储不可用")
+	}
+	return client.Database(config.MongoDBName).Collection(conversationCollection), nil
+}
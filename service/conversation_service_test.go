@@ -0,0 +1,313 @@
+package service
+
+import (
+	"go_chat/global"
+	"go_chat/model"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestMessagePreview(t *testing.T) {
+	long := strings.Repeat("好", previewLength+1)
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"短消息原样返回", "hello", "hello"},
+		{"恰好为预览长度", strings.Repeat("好", previewLength), strings.Repeat("好", previewLength)},
+		{"按字符截断", long, strings.Repeat("好", previewLength) + "..."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := messagePreview(tt.content); got != tt.want {
+				t.Errorf("messagePreview() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnreadCounts(t *testing.T) {
+	server := setupRedis(t)
+
+	conversationService.IncrUnread(1, 2)
+	conversationService.IncrUnread(1, 2)
+	conversationService.IncrUnread(1, 3)
+	conversationService.IncrUnread(2, 1)
+	server.HSet(unreadKeyPrefix+"1", "invalid", "5")
+	server.HSet(unreadKeyPrefix+"1", "4", "0")
+
+	counts := conversationService.GetUnreadCounts(1)
+	if len(counts) != 2 || counts[2] != 2 || counts[3] != 1 {
+		t.Errorf("GetUnreadCounts(1) = %v, want map[2:2 3:1]", counts)
+	}
+
+	conversationService.DecrUnread(1, 2, 2)
+	if counts := conversationService.GetUnreadCounts(1); counts[2] != 0 || counts[3] != 1 {
+		t.Errorf("扣除后 GetUnreadCounts(1) = %v, want map[3:1]", counts)
+	}
+	if counts := conversationService.GetUnreadCounts(2); counts[1] != 1 {
+		t.Errorf("GetUnreadCounts(2) = %v, want map[1:1]", counts)
+	}
+
+	// 扣除可能先于递增执行，计数为负时不返回，递增后恢复
+	conversationService.DecrUnread(1, 3, 1)
+	conversationService.DecrUnread(1, 5, 1)
+	if counts := conversationService.GetUnreadCounts(1); counts[3] != 0 || counts[5] != 0 {
+		t.Errorf("扣除后 GetUnreadCounts(1) = %v, want empty", counts)
+	}
+	conversationService.IncrUnread(1, 5)
+	conversationService.IncrUnread(1, 5)
+	if counts := conversationService.GetUnreadCounts(1); counts[5] != 1 {
+		t.Errorf("先扣除后递增 GetUnreadCounts(1) = %v, want map[5:1]", counts)
+	}
+
+	global.RedisClient = nil
+	if counts := conversationService.GetUnreadCounts(1); len(counts) != 0 {
+		t.Errorf("Redis不可用时 GetUnreadCounts() = %v, want empty", counts)
+	}
+}
+
+func TestListConversations(t *testing.T) {
+	mt := newMockMongo(t)
+
+	mt.Run("加载对方信息和未读数", func(mt *mtest.T) {
+		useMockMongo(mt)
+		db := setupMySQL(t, &model.User{})
+		setupRedis(t)
+		me := model.User{UserName: "alice", Status: model.Active}
+		bob := model.User{UserName: "bob", Status: model.Active}
+		db.Create(&me)
+		db.Create(&bob)
+		conversationService.IncrUnread(me.ID, bob.ID)
+
+		now := time.Now().Truncate(time.Millisecond)
+		conversations := []model.Conversation{
+			{
+				SessionID:    model.PrivateSessionID(me.ID, bob.ID),
+				Participants: []uint{me.ID, bob.ID},
+				LastMessage:  model.LastMessage{MessageID: primitive.NewObjectID(), FromUserID: bob.ID, Content: strings.Repeat("a", previewLength+5), Timestamp: now},
+			},
+			{
+				// 对方账号已删除的会话不返回
+				SessionID:    model.PrivateSessionID(me.ID, 999),
+				Participants: []uint{me.ID, 999},
+				LastMessage:  model.LastMessage{MessageID: primitive.NewObjectID(), FromUserID: 999, Timestamp: now.Add(-time.Minute)},
+			},
+		}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "go_chat_test.conversations", mtest.FirstBatch,
+			toBSON(mt, conversations[0]), toBSON(mt, conversations[1])))
+
+		page, err := conversationService.ListConversations(me.ID, "", 0)
+		if err != nil {
+			mt.Fatalf("ListConversations() error = %v", err)
+		}
+		if len(page.Conversations) != 1 || page.NextCursor != "" {
+			mt.Fatalf("ListConversations() = %+v, want 1 条且没有下一页", page)
+		}
+		got := page.Conversations[0]
+		if got.Peer.ID != bob.ID || got.UnreadCount != 1 || !got.Timestamp.Equal(now) {
+			mt.Errorf("会话 = %+v, want 对方bob、未读1", got)
+		}
+		if got.LastMessage.ID != conversations[0].LastMessage.MessageID.Hex() || got.LastMessage.Preview != strings.Repeat("a", previewLength)+"..." {
+			mt.Errorf("最后一条消息 = %+v", got.LastMessage)
+		}
+
+		event := mt.GetStartedEvent()
+		if participant := event.Command.Lookup("filter", "participants").AsInt64(); participant != int64(me.ID) {
+			mt.Errorf("查询条件 participants = %d, want %d", participant, me.ID)
+		}
+	})
+
+	mt.Run("分页", func(mt *mtest.T) {
+		useMockMongo(mt)
+		db := setupMySQL(t, &model.User{})
+		me := model.User{UserName: "alice", Status: model.Active}
+		db.Create(&me)
+		now := time.Now().Truncate(time.Millisecond)
+		var docs []bson.D
+		for i := 0; i < 3; i++ {
+			peer := model.User{UserName: "peer" + strconv.Itoa(i), Status: model.Active}
+			db.Create(&peer)
+			docs = append(docs, toBSON(mt, model.Conversation{
+				SessionID:    model.PrivateSessionID(me.ID, peer.ID),
+				Participants: []uint{me.ID, peer.ID},
+				LastMessage:  model.LastMessage{MessageID: primitive.NewObjectID(), FromUserID: peer.ID, Content: "hi", Timestamp: now.Add(-time.Duration(i) * time.Minute)},
+			}))
+		}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "go_chat_test.conversations", mtest.FirstBatch, docs...),
+			mtest.CreateCursorResponse(0, "go_chat_test.conversations", mtest.FirstBatch),
+		)
+
+		page, err := conversationService.ListConversations(me.ID, "", 2)
+		if err != nil {
+			mt.Fatalf("ListConversations() error = %v", err)
+		}
+		if len(page.Conversations) != 2 || page.NextCursor == "" {
+			mt.Fatalf("ListConversations() = %+v, want 2 条和下一页游标", page)
+		}
+		// 多取一条判断是否还有下一页
+		if limit := nextCommand(mt, "find").Lookup("limit").AsInt64(); limit != 3 {
+			mt.Errorf("limit = %d, want 3", limit)
+		}
+
+		timestamp, sessionID, err := decodeConversationCursor(page.NextCursor)
+		if err != nil || !timestamp.Equal(now.Add(-time.Minute)) || sessionID != page.Conversations[1].SessionID {
+			mt.Errorf("游标 = %v, %q, %v, want 第二条会话的时间和ID", timestamp, sessionID, err)
+		}
+
+		if _, err := conversationService.ListConversations(me.ID, page.NextCursor, 2); err != nil {
+			mt.Fatalf("下一页 ListConversations() error = %v", err)
+		}
+		or, _ := nextCommand(mt, "find").Lookup("filter", "$or").Array().Values()
+		if len(or) != 2 || or[1].Document().Lookup("_id", "$lt").StringValue() != sessionID {
+			mt.Errorf("下一页查询条件 $or = %v, want 按时间和会话ID排在游标之后", or)
+		}
+
+		if _, err := conversationService.ListConversations(me.ID, "not-a-cursor", 2); err == nil || err.Error() != "游标格式错误" {
+			mt.Errorf("ListConversations() error = %v, want 游标格式错误", err)
+		}
+	})
+
+	mt.Run("预览跳过已撤回和已删除的消息", func(mt *mtest.T) {
+		useMockMongo(mt)
+		db := setupMySQL(t, &model.User{})
+		me := model.User{UserName: "alice", Status: model.Active}
+		bob := model.User{UserName: "bob", Status: model.Active}
+		carol := model.User{UserName: "carol", Status: model.Active}
+		db.Create(&me)
+		db.Create(&bob)
+		db.Create(&carol)
+
+		now := time.Now().Truncate(time.Millisecond)
+		recalled := model.Conversation{
+			SessionID:    model.PrivateSessionID(me.ID, bob.ID),
+			Participants: []uint{me.ID, bob.ID},
+			LastMessage:  model.LastMessage{MessageID: primitive.NewObjectID(), FromUserID: bob.ID, Recalled: true, Timestamp: now},
+		}
+		hidden := model.Conversation{
+			SessionID:    model.PrivateSessionID(me.ID, carol.ID),
+			Participants: []uint{me.ID, carol.ID},
+			LastMessage:  model.LastMessage{MessageID: primitive.NewObjectID(), FromUserID: carol.ID, Content: "secret", HiddenFor: []uint{me.ID}, Timestamp: now.Add(-time.Minute)},
+		}
+		previous := model.ChatMessage{ID: primitive.NewObjectID(), SessionID: recalled.SessionID, FromUserID: me.ID, ToUserID: bob.ID, Content: "earlier", Timestamp: now.Add(-time.Hour)}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "go_chat_test.conversations", mtest.FirstBatch, toBSON(mt, recalled), toBSON(mt, hidden)),
+			messageResponse(mt, previous),
+			mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch),
+		)
+
+		page, err := conversationService.ListConversations(me.ID, "", 0)
+		if err != nil || len(page.Conversations) != 2 {
+			mt.Fatalf("ListConversations() = %+v, %v, want 2 条", page, err)
+		}
+		if last := page.Conversations[0].LastMessage; last == nil || last.ID != previous.ID.Hex() || last.Preview != "earlier" {
+			mt.Errorf("撤回后的预览 = %+v, want 上一条可见消息", last)
+		}
+		if last := page.Conversations[1].LastMessage; last != nil {
+			mt.Errorf("没有可见消息时预览 = %+v, want nil", last)
+		}
+
+		nextCommand(mt, "find")
+		filter := nextCommand(mt, "find").Lookup("filter").Document()
+		if !filter.Lookup("recalled", "$ne").Boolean() || filter.Lookup("hidden_for", "$ne").AsInt64() != int64(me.ID) {
+			mt.Errorf("可见消息查询条件 = %v, want 排除已撤回和查看者已删除的消息", filter)
+		}
+	})
+
+	mt.Run("查询失败", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 1, Message: "boom"}))
+
+		if _, err := conversationService.ListConversations(1, "", 0); err == nil || err.Error() != "查询会话列表失败" {
+			mt.Errorf("ListConversations() error = %v, want 查询会话列表失败", err)
+		}
+	})
+}
+
+func TestTouchConversation(t *testing.T) {
+	mt := newMockMongo(t)
+
+	mt.Run("按参与者排序并插入或更新", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		message := &model.ChatMessage{ID: primitive.NewObjectID(), SessionID: "private_3_7", FromUserID: 7, ToUserID: 3, Content: "hi", Timestamp: time.Now()}
+		if err := conversationService.TouchConversation(message); err != nil {
+			mt.Fatalf("TouchConversation() error = %v", err)
+		}
+
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		if !update.Lookup("upsert").Boolean() {
+			mt.Error("会话摘要未使用upsert")
+		}
+		if id := update.Lookup("q", "_id").StringValue(); id != "private_3_7" {
+			mt.Errorf("会话ID = %q, want private_3_7", id)
+		}
+		participants, _ := update.Lookup("u", "$set", "participants").Array().Values()
+		if len(participants) != 2 || participants[0].AsInt64() != 3 || participants[1].AsInt64() != 7 {
+			mt.Errorf("participants = %v, want [3 7]", participants)
+		}
+	})
+
+	mt.Run("重复插入视为已被更新的消息覆盖", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{Code: 11000, Message: "duplicate key"}))
+
+		message := &model.ChatMessage{SessionID: "private_1_2", FromUserID: 1, ToUserID: 2, Timestamp: time.Now()}
+		if err := conversationService.TouchConversation(message); err != nil {
+			mt.Errorf("TouchConversation() error = %v, want nil", err)
+		}
+	})
+}
+
+func TestNextSeq(t *testing.T) {
+	mt := newMockMongo(t)
+
+	mt.Run("原子递增并在首次写入时创建会话", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(seqResponse(mt, "private_3_7", 5))
+
+		seq, err := conversationService.NextSeq("private_3_7", []uint{3, 7})
+		if err != nil || seq != 5 {
+			mt.Fatalf("NextSeq() = %d, %v, want 5", seq, err)
+		}
+
+		command := mt.GetStartedEvent().Command
+		if !command.Lookup("upsert").Boolean() || !command.Lookup("new").Boolean() {
+			mt.Error("序号分配应使用upsert并返回更新后的文档")
+		}
+		if inc := command.Lookup("update", "$inc", "last_seq").AsInt64(); inc != 1 {
+			mt.Errorf("$inc last_seq = %d, want 1", inc)
+		}
+		participants, _ := command.Lookup("update", "$setOnInsert", "participants").Array().Values()
+		if len(participants) != 2 || participants[0].AsInt64() != 3 {
+			mt.Errorf("participants = %v, want [3 7]", participants)
+		}
+	})
+}
+
+func TestGetLastSeqs(t *testing.T) {
+	mt := newMockMongo(t)
+
+	mt.Run("按会话返回最新序号", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "go_chat_test.conversations", mtest.FirstBatch,
+			toBSON(mt, model.Conversation{SessionID: "private_1_2", LastSeq: 3}),
+			toBSON(mt, model.Conversation{SessionID: "private_1_5", LastSeq: 8}),
+		))
+
+		seqs, err := conversationService.GetLastSeqs(1)
+		if err != nil || len(seqs) != 2 || seqs["private_1_2"] != 3 || seqs["private_1_5"] != 8 {
+			mt.Errorf("GetLastSeqs() = %v, %v", seqs, err)
+		}
+	})
+}
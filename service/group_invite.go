@@ -0,0 +1,487 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"go_chat/config"
+	"go_chat/global"
+	"go_chat/model"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/sirupsen/logrus"
+)
+
+// 邀请链接状态
+const (
+	InviteStatusActive    = "active"    // 可以使用
+	InviteStatusExpired   = "expired"   // 已过期
+	InviteStatusExhausted = "exhausted" // 使用次数已达上限
+	InviteStatusRevoked   = "revoked"   // 已撤销
+)
+
+// 使用邀请链接的结果
+const (
+	RedeemStatusJoined        = "joined"         // 已加入群组
+	RedeemStatusPending       = "pending"        // 已提交入群申请，等待审批
+	RedeemStatusAlreadyMember = "already_member" // 原本就是群成员
+)
+
+// 邀请链接不可用的原因，并发使用时在事务中判定
+var (
+	errInviteInvalid   = errors.New("邀请链接无效")
+	errInviteExpired   = errors.New("邀请链接已过期")
+	errInviteExhausted = errors.New("邀请链接使用次数已达上限")
+)
+
+// CreateInviteRequest 创建邀请链接请求
+type CreateInviteRequest struct {
+	ExpiresIn       int64 `json:"expires_in"`       // 有效期秒数，0表示永不过期
+	MaxUses         int   `json:"max_uses"`         // 最大使用次数，0表示不限
+	RequireApproval bool  `json:"require_approval"` // 是否需要群主或管理员审批
+}
+
+// InviteResponse 邀请链接信息
+type InviteResponse struct {
+	ID              uint       `json:"id"`
+	Token           string     `json:"token"`
+	GroupID         uint       `json:"group_id"`
+	CreatorID       uint       `json:"creator_id"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+	MaxUses         int        `json:"max_uses"`
+	Uses            int        `json:"uses"`
+	RequireApproval bool       `json:"require_approval"`
+	Status          string     `json:"status"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// RedeemInviteResponse 使用邀请链接的结果
+type RedeemInviteResponse struct {
+	GroupID   uint   `json:"group_id"`
+	GroupName string `json:"group_name"`
+	Status    string `json:"status"`               // joined、pending或already_member
+	RequestID uint   `json:"request_id,omitempty"` // 需要审批时的入群申请ID
+}
+
+// JoinRequestResponse 入群申请信息
+type JoinRequestResponse struct {
+	ID         uint       `json:"id"`
+	GroupID    uint       `json:"group_id"`
+	UserID     uint       `json:"user_id"`
+	UserName   string     `json:"username"`
+	Avatar     string     `json:"avatar"`
+	InviteID   uint       `json:"invite_id"`
+	Status     string     `json:"status"`
+	ReviewerID uint       `json:"reviewer_id,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreateInvite 群主或管理员创建邀请链接
+func (s *GroupService) CreateInvite(operatorID, groupID uint, req CreateInviteRequest) (*InviteResponse, error) {
+	if req.ExpiresIn < 0 {
+		return nil, errors.New("有效期无效")
+	}
+	if req.MaxUses < 0 {
+		return nil, errors.New("使用次数无效")
+	}
+	if _, err := s.requireManager(groupID, operatorID); err != nil {
+		return nil, err
+	}
+
+	token, err := generateInviteToken()
+	if err != nil {
+		logrus.Error("邀请链接生成失败:", err)
+		return nil, errors.New("邀请链接创建失败")
+	}
+
+	invite := model.GroupInvite{
+		Token:           token,
+		GroupID:         groupID,
+		CreatorID:       operatorID,
+		MaxUses:         req.MaxUses,
+		RequireApproval: req.RequireApproval,
+	}
+	if req.ExpiresIn > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresIn) * time.Second)
+		invite.ExpiresAt = &expiresAt
+	}
+
+	db := global.GetMySQLClient()
+	if err := db.Create(&invite).Error; err != nil {
+		logrus.Error("邀请链接创建失败:", err)
+		return nil, errors.New("邀请链接创建失败")
+	}
+
+	logrus.Infof("用户 %d 为群组 %d 创建了邀请链接 %d", operatorID, groupID, invite.ID)
+	return newInviteResponse(&invite), nil
+}
+
+// ListInvites 群主或管理员查看群组的全部邀请链接，包括已失效的链接
+func (s *GroupService) ListInvites(operatorID, groupID uint) ([]InviteResponse, error) {
+	if _, err := s.requireManager(groupID, operatorID); err != nil {
+		return nil, err
+	}
+
+	db := global.GetMySQLClient()
+	var invites []model.GroupInvite
+	if err := db.Where("group_id = ?", groupID).Order("id DESC").Find(&invites).Error; err != nil {
+		logrus.Error("查询邀请链接失败:", err)
+		return nil, errors.New("查询邀请链接失败")
+	}
+
+	result := make([]InviteResponse, 0, len(invites))
+	for i := range invites {
+		result = append(result, *newInviteResponse(&invites[i]))
+	}
+	return result, nil
+}
+
+// RevokeInvite 群主或管理员撤销邀请链接，撤销后不能再使用，已提交的入群申请不受影响
+func (s *GroupService) RevokeInvite(operatorID, groupID, inviteID uint) error {
+	if _, err := s.requireManager(groupID, operatorID); err != nil {
+		return err
+	}
+
+	db := global.GetMySQLClient()
+	var invite model.GroupInvite
+	if err := db.Where("id = ? AND group_id = ?", inviteID, groupID).First(&invite).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return errors.New("邀请链接不存在")
+		}
+		logrus.Error("查询邀请链接失败:", err)
+		return errors.New("查询邀请链接失败")
+	}
+	if invite.RevokedAt != nil {
+		return nil
+	}
+
+	if err := db.Model(&invite).Update("revoked_at", time.Now()).Error; err != nil {
+		logrus.Error("撤销邀请链接失败:", err)
+		return errors.New("撤销邀请链接失败")
+	}
+	return nil
+}
+
+// RedeemInvite 使用邀请链接加入群组，需要审批的链接提交入群申请
+func (s *GroupService) RedeemInvite(userID uint, token string) (*RedeemInviteResponse, error) {
+	db := global.GetMySQLClient()
+	if db == nil {
+		return nil, errors.New("数据库连接不可用")
+	}
+
+	var invite model.GroupInvite
+	if err := db.Where("token = ?", token).First(&invite).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, errors.New("邀请链接无效")
+		}
+		logrus.Error("查询邀请链接失败:", err)
+		return nil, errors.New("查询邀请链接失败")
+	}
+	if invite.RevokedAt != nil {
+		return nil, errors.New("邀请链接无效")
+	}
+
+	group, err := s.GetGroup(invite.GroupID)
+	if err != nil {
+		if err.Error() == "群组不存在" {
+			return nil, errors.New("邀请链接无效")
+		}
+		return nil, err
+	}
+
+	response := &RedeemInviteResponse{GroupID: group.ID, GroupName: group.Name}
+	if s.IsMember(group.ID, userID) {
+		response.Status = RedeemStatusAlreadyMember
+		return response, nil
+	}
+
+	if invite.RequireApproval {
+		// 重复使用链接时返回尚未处理的申请，不再消耗使用次数
+		var pending model.GroupJoinRequest
+		err := db.Where("group_id = ? AND user_id = ? AND status = ?", group.ID, userID, model.JoinRequestPending).
+			First(&pending).Error
+		if err == nil {
+			response.Status = RedeemStatusPending
+			response.RequestID = pending.ID
+			return response, nil
+		}
+		if !gorm.IsRecordNotFoundError(err) {
+			logrus.Error("查询入群申请失败:", err)
+			return nil, errors.New("查询入群申请失败")
+		}
+
+		request := model.GroupJoinRequest{
+			GroupID:  group.ID,
+			UserID:   userID,
+			InviteID: invite.ID,
+			Status:   model.JoinRequestPending,
+		}
+		// 链接状态和封禁记录在锁定后校验，与撤销、封禁操作不会交错。
+		// 各事务均先锁定邀请链接再锁定群组，避免互相等待
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := consumeInvite(tx, invite.ID); err != nil {
+				return err
+			}
+			if err := lockGroup(tx, group.ID); err != nil {
+				return err
+			}
+			if err := ensureNotBanned(tx, group.ID, userID); err != nil {
+				return err
+			}
+			return tx.Create(&request).Error
+		})
+		if isRedeemRejection(err) {
+			return nil, err
+		}
+		if err != nil {
+			logrus.Error("提交入群申请失败:", err)
+			return nil, errors.New("提交入群申请失败")
+		}
+
+		logrus.Infof("用户 %d 通过邀请链接 %d 申请加入群组 %d", userID, invite.ID, group.ID)
+		response.Status = RedeemStatusPending
+		response.RequestID = request.ID
+		return response, nil
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := consumeInvite(tx, invite.ID); err != nil {
+			return err
+		}
+		if err := reserveCapacity(tx, group.ID, 1); err != nil {
+			return err
+		}
+		if err := ensureNotBanned(tx, group.ID, userID); err != nil {
+			return err
+		}
+		return tx.Create(&model.GroupMember{GroupID: group.ID, UserID: userID, Role: model.GroupRoleMember}).Error
+	})
+	if isRedeemRejection(err) {
+		return nil, err
+	}
+	if err != nil {
+		// 并发使用时唯一索引冲突，视为已加入
+		if s.IsMember(group.ID, userID) {
+			response.Status = RedeemStatusAlreadyMember
+			return response, nil
+		}
+		logrus.Error("通过邀请链接入群失败:", err)
+		return nil, errors.New("加入群组失败")
+	}
+
+	logrus.Infof("用户 %d 通过邀请链接 %d 加入群组 %d", userID, invite.ID, group.ID)
+	response.Status = RedeemStatusJoined
+	return response, nil
+}
+
+// ListJoinRequests 群主或管理员查看入群申请，status为空时返回等待审批的申请
+func (s *GroupService) ListJoinRequests(operatorID, groupID uint, status string) ([]JoinRequestResponse, error) {
+	if status == "" {
+		status = model.JoinRequestPending
+	}
+	if _, err := s.requireManager(groupID, operatorID); err != nil {
+		return nil, err
+	}
+
+	db := global.GetMySQLClient()
+	var requests []model.GroupJoinRequest
+	if err := db.Where("group_id = ? AND status = ?", groupID, status).Order("id").Find(&requests).Error; err != nil {
+		logrus.Error("查询入群申请失败:", err)
+		return nil, errors.New("查询入群申请失败")
+	}
+
+	userIDs := make([]uint, 0, len(requests))
+	for _, request := range requests {
+		userIDs = append(userIDs, request.UserID)
+	}
+	users, err := authService.GetUsersByIDs(userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]JoinRequestResponse, 0, len(requests))
+	for _, request := range requests {
+		response := JoinRequestResponse{
+			ID:         request.ID,
+			GroupID:    request.GroupID,
+			UserID:     request.UserID,
+			InviteID:   request.InviteID,
+			Status:     request.Status,
+			ReviewerID: request.ReviewerID,
+			ReviewedAt: request.ReviewedAt,
+			CreatedAt:  request.CreatedAt,
+		}
+		if user, ok := users[request.UserID]; ok {
+			response.UserName = user.UserName
+			response.Avatar = user.Avatar
+		}
+		result = append(result, response)
+	}
+	return result, nil
+}
+
+// ReviewJoinRequest 群主或管理员审批入群申请，通过时申请人加入群组。
+// 返回申请人ID，以及申请人是否因本次审批而加入群组
+func (s *GroupService) ReviewJoinRequest(operatorID, groupID, requestID uint, approve bool) (uint, bool, error) {
+	if _, err := s.requireManager(groupID, operatorID); err != nil {
+		return 0, false, err
+	}
+
+	db := global.GetMySQLClient()
+	var request model.GroupJoinRequest
+	if err := db.Where("id = ? AND group_id = ?", requestID, groupID).First(&request).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return 0, false, errors.New("入群申请不存在")
+		}
+		logrus.Error("查询入群申请失败:", err)
+		return 0, false, errors.New("查询入群申请失败")
+	}
+	if request.Status != model.JoinRequestPending {
+		return 0, false, errors.New("入群申请已处理")
+	}
+
+	status := model.JoinRequestRejected
+	joined := false
+	if approve {
+		status = model.JoinRequestApproved
+		joined = !s.IsMember(groupID, request.UserID)
+	}
+
+	now := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 仅更新仍在等待审批的申请，避免多名管理员同时审批
+		result := tx.Model(&model.GroupJoinRequest{}).
+			Where("id = ? AND status = ?", request.ID, model.JoinRequestPending).
+			Updates(map[string]interface{}{"status": status, "reviewer_id": operatorID, "reviewed_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("入群申请已处理")
+		}
+		if !joined {
+			return nil
+		}
+		if err := reserveCapacity(tx, groupID, 1); err != nil {
+			return err
+		}
+		// 在群组记录锁定后检查封禁，审批期间被封禁的用户不会入群
+		if err := ensureNotBanned(tx, groupID, request.UserID); err != nil {
+			return err
+		}
+		return tx.Create(&model.GroupMember{GroupID: groupID, UserID: request.UserID, Role: model.GroupRoleMember}).Error
+	})
+	if err != nil {
+		if err.Error() == "入群申请已处理" || err == errGroupFull || err == errGroupBanned {
+			return 0, false, err
+		}
+		logrus.Error("审批入群申请失败:", err)
+		return 0, false, errors.New("审批入群申请失败")
+	}
+	return request.UserID, joined, nil
+}
+
+// requireManager 校验操作者是群主或管理员
+func (s *GroupService) requireManager(groupID, userID uint) (*model.GroupMember, error) {
+	if _, err := s.GetGroup(groupID); err != nil {
+		return nil, err
+	}
+	member, err := s.GetMember(groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !member.IsManager() {
+		return nil, errors.New("权限不足")
+	}
+	return member, nil
+}
+
+// lockGroup 在事务中锁定群组记录，加入、审批和封禁同一群组的事务在群组记录上排队执行
+func lockGroup(tx *gorm.DB, groupID uint) error {
+	var group model.Group
+	return tx.Set("gorm:query_option", "FOR UPDATE").First(&group, groupID).Error
+}
+
+// reserveCapacity 在事务中锁定群组记录后统计成员数，校验还能加入count名成员。
+// 并发加入同一群组的事务在群组记录上排队，不会同时通过校验而超出人数上限
+func reserveCapacity(tx *gorm.DB, groupID uint, count int) error {
+	if err := lockGroup(tx, groupID); err != nil {
+		return err
+	}
+
+	var members int
+	if err := tx.Model(&model.GroupMember{}).Where("group_id = ?", groupID).Count(&members).Error; err != nil {
+		return err
+	}
+	if members+count > config.GroupMaxMembers {
+		return errGroupFull
+	}
+	return nil
+}
+
+// consumeInvite 在事务中锁定邀请链接记录，确认链接仍然可用后消耗一次使用次数。
+// 撤销、过期和次数用完均以锁定后读到的记录为准
+func consumeInvite(tx *gorm.DB, inviteID uint) error {
+	var invite model.GroupInvite
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&invite, inviteID).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return errInviteInvalid
+		}
+		return err
+	}
+	switch {
+	case invite.RevokedAt != nil:
+		return errInviteInvalid
+	case invite.IsExpired():
+		return errInviteExpired
+	case invite.IsExhausted():
+		return errInviteExhausted
+	}
+	return tx.Model(&invite).UpdateColumn("uses", gorm.Expr("uses + 1")).Error
+}
+
+// isRedeemRejection 判断使用邀请链接的事务是否因链接或群组状态被拒绝，这类错误直接返回给用户
+func isRedeemRejection(err error) bool {
+	switch err {
+	case errInviteInvalid, errInviteExpired, errInviteExhausted, errGroupFull, errGroupBanned:
+		return true
+	}
+	return false
+}
+
+// newInviteResponse 转换邀请链接信息
+func newInviteResponse(invite *model.GroupInvite) *InviteResponse {
+	status := InviteStatusActive
+	switch {
+	case invite.RevokedAt != nil:
+		status = InviteStatusRevoked
+	case invite.IsExpired():
+		status = InviteStatusExpired
+	case invite.IsExhausted():
+		status = InviteStatusExhausted
+	}
+
+	return &InviteResponse{
+		ID:              invite.ID,
+		Token:           invite.Token,
+		GroupID:         invite.GroupID,
+		CreatorID:       invite.CreatorID,
+		ExpiresAt:       invite.ExpiresAt,
+		MaxUses:         invite.MaxUses,
+		Uses:            invite.Uses,
+		RequireApproval: invite.RequireApproval,
+		Status:          status,
+		CreatedAt:       invite.CreatedAt,
+	}
+}
+
+// generateInviteToken 生成邀请链接令牌
+func generateInviteToken() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
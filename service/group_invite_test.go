@@ -0,0 +1,235 @@
+package service
+
+import (
+	"go_chat/config"
+	"go_chat/global"
+	"go_chat/model"
+	"testing"
+	"time"
+)
+
+// setupInvites 准备邀请链接测试所需的数据库，返回用户ID和由第一个用户创建的群组ID
+func setupInvites(t *testing.T, userCount int) ([]uint, uint) {
+	t.Helper()
+	users := setupGroups(t, userCount)
+	if err := global.MySQLClient.AutoMigrate(&model.GroupInvite{}, &model.GroupJoinRequest{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	group, err := groupService.CreateGroup(users[0], CreateGroupRequest{Name: "g"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return users, group.ID
+}
+
+// createInvite 创建邀请链接，失败时终止测试
+func createInvite(t *testing.T, operatorID, groupID uint, req CreateInviteRequest) *InviteResponse {
+	t.Helper()
+	invite, err := groupService.CreateInvite(operatorID, groupID, req)
+	if err != nil {
+		t.Fatalf("CreateInvite() error = %v", err)
+	}
+	return invite
+}
+
+func TestCreateInvite(t *testing.T) {
+	users, groupID := setupInvites(t, 2)
+	groupService.AddMembers(users[0], groupID, []uint{users[1]})
+
+	invite := createInvite(t, users[0], groupID, CreateInviteRequest{ExpiresIn: 3600, MaxUses: 5})
+	if len(invite.Token) != 32 || invite.Status != InviteStatusActive || invite.ExpiresAt == nil || invite.MaxUses != 5 {
+		t.Errorf("CreateInvite() = %+v", invite)
+	}
+
+	tests := []struct {
+		name       string
+		operatorID uint
+		req        CreateInviteRequest
+		wantErr    string
+	}{
+		{"有效期为负", users[0], CreateInviteRequest{ExpiresIn: -1}, "有效期无效"},
+		{"使用次数为负", users[0], CreateInviteRequest{MaxUses: -1}, "使用次数无效"},
+		{"普通成员不能创建", users[1], CreateInviteRequest{}, "权限不足"},
+	}
+	for _, tt := range tests {
+		if _, err := groupService.CreateInvite(tt.operatorID, groupID, tt.req); err == nil || err.Error() != tt.wantErr {
+			t.Errorf("%s: CreateInvite() error = %v, want %s", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRedeemInvite(t *testing.T) {
+	users, groupID := setupInvites(t, 4)
+	owner := users[0]
+
+	invite := createInvite(t, owner, groupID, CreateInviteRequest{MaxUses: 2})
+	joined, err := groupService.RedeemInvite(users[1], invite.Token)
+	if err != nil || joined.Status != RedeemStatusJoined || !groupService.IsMember(groupID, users[1]) {
+		t.Fatalf("RedeemInvite() = %+v, %v, want joined", joined, err)
+	}
+	// 已是成员时不消耗次数
+	if again, err := groupService.RedeemInvite(users[1], invite.Token); err != nil || again.Status != RedeemStatusAlreadyMember {
+		t.Errorf("重复使用 = %+v, %v, want already_member", again, err)
+	}
+	if _, err := groupService.RedeemInvite(users[2], invite.Token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := groupService.RedeemInvite(users[3], invite.Token); err == nil || err.Error() != "邀请链接使用次数已达上限" {
+		t.Errorf("次数用完 error = %v, want 邀请链接使用次数已达上限", err)
+	}
+
+	expired := createInvite(t, owner, groupID, CreateInviteRequest{ExpiresIn: 60})
+	global.MySQLClient.Model(&model.GroupInvite{}).Where("id = ?", expired.ID).Update("expires_at", time.Now().Add(-time.Second))
+	revoked := createInvite(t, owner, groupID, CreateInviteRequest{})
+	if err := groupService.RevokeInvite(owner, groupID, revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+	full := createInvite(t, owner, groupID, CreateInviteRequest{})
+	config.GroupMaxMembers = 3
+
+	rejects := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{"链接不存在", "missing", "邀请链接无效"},
+		{"已过期", expired.Token, "邀请链接已过期"},
+		{"已撤销", revoked.Token, "邀请链接无效"},
+		{"群已满", full.Token, "群成员数量超过限制"},
+	}
+	for _, tt := range rejects {
+		if _, err := groupService.RedeemInvite(users[3], tt.token); err == nil || err.Error() != tt.wantErr {
+			t.Errorf("%s: RedeemInvite() error = %v, want %s", tt.name, err, tt.wantErr)
+		}
+	}
+
+	invites, err := groupService.ListInvites(owner, groupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := map[uint]string{}
+	for _, listed := range invites {
+		statuses[listed.ID] = listed.Status
+	}
+	if statuses[invite.ID] != InviteStatusExhausted || statuses[expired.ID] != InviteStatusExpired || statuses[revoked.ID] != InviteStatusRevoked || statuses[full.ID] != InviteStatusActive {
+		t.Errorf("邀请链接状态 = %v", statuses)
+	}
+}
+
+func TestJoinRequestReview(t *testing.T) {
+	users, groupID := setupInvites(t, 4)
+	owner := users[0]
+	invite := createInvite(t, owner, groupID, CreateInviteRequest{MaxUses: 2, RequireApproval: true})
+
+	pending, err := groupService.RedeemInvite(users[1], invite.Token)
+	if err != nil || pending.Status != RedeemStatusPending || pending.RequestID == 0 || groupService.IsMember(groupID, users[1]) {
+		t.Fatalf("RedeemInvite() = %+v, %v, want pending", pending, err)
+	}
+	// 重复申请返回同一申请，不再消耗次数
+	again, err := groupService.RedeemInvite(users[1], invite.Token)
+	if err != nil || again.RequestID != pending.RequestID {
+		t.Errorf("重复申请 = %+v, %v, want 申请 %d", again, err, pending.RequestID)
+	}
+	rejected, _ := groupService.RedeemInvite(users[2], invite.Token)
+
+	requests, err := groupService.ListJoinRequests(owner, groupID, "")
+	if err != nil || len(requests) != 2 {
+		t.Fatalf("ListJoinRequests() = %+v, %v, want 2", requests, err)
+	}
+
+	userID, joined, err := groupService.ReviewJoinRequest(owner, groupID, pending.RequestID, true)
+	if err != nil || userID != users[1] || !joined || !groupService.IsMember(groupID, users[1]) {
+		t.Errorf("通过申请 = %d, %v, %v", userID, joined, err)
+	}
+	if _, joined, err := groupService.ReviewJoinRequest(owner, groupID, rejected.RequestID, false); err != nil || joined || groupService.IsMember(groupID, users[2]) {
+		t.Errorf("拒绝申请 joined = %v, %v", joined, err)
+	}
+	if _, _, err := groupService.ReviewJoinRequest(owner, groupID, pending.RequestID, false); err == nil || err.Error() != "入群申请已处理" {
+		t.Errorf("重复审批 error = %v, want 入群申请已处理", err)
+	}
+	if _, _, err := groupService.ReviewJoinRequest(users[1], groupID, rejected.RequestID, true); err == nil || err.Error() != "权限不足" {
+		t.Errorf("普通成员审批 error = %v, want 权限不足", err)
+	}
+	if _, err := groupService.RedeemInvite(users[3], invite.Token); err == nil || err.Error() != "邀请链接使用次数已达上限" {
+		t.Errorf("次数用完 error = %v, want 邀请链接使用次数已达上限", err)
+	}
+
+	// 申请时群未满，审批时已满的申请不能通过
+	approval := createInvite(t, owner, groupID, CreateInviteRequest{RequireApproval: true})
+	late, err := groupService.RedeemInvite(users[3], approval.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.GroupMaxMembers = 2
+	if _, _, err := groupService.ReviewJoinRequest(owner, groupID, late.RequestID, true); err == nil || err.Error() != "群成员数量超过限制" {
+		t.Errorf("群已满时通过申请 error = %v, want 群成员数量超过限制", err)
+	}
+	if groupService.IsMember(groupID, users[3]) {
+		t.Error("群已满时不应加入成员")
+	}
+}
+
+func TestConsumeInvite(t *testing.T) {
+	users, groupID := setupInvites(t, 1)
+	db := global.MySQLClient
+	past, future := time.Now().Add(-time.Second), time.Now().Add(time.Hour)
+	revokedAt := time.Now()
+
+	tests := []struct {
+		name     string
+		invite   model.GroupInvite
+		wantErr  error
+		wantUses int
+	}{
+		{"可以使用", model.GroupInvite{Token: "active", ExpiresAt: &future, MaxUses: 2, Uses: 1}, nil, 2},
+		{"不限次数", model.GroupInvite{Token: "unlimited", Uses: 5}, nil, 6},
+		{"已过期", model.GroupInvite{Token: "expired", ExpiresAt: &past}, errInviteExpired, 0},
+		{"已撤销", model.GroupInvite{Token: "revoked", RevokedAt: &revokedAt}, errInviteInvalid, 0},
+		{"次数用完", model.GroupInvite{Token: "exhausted", MaxUses: 1, Uses: 1}, errInviteExhausted, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invite := tt.invite
+			invite.GroupID, invite.CreatorID = groupID, users[0]
+			db.Create(&invite)
+
+			if err := consumeInvite(db, invite.ID); err != tt.wantErr {
+				t.Fatalf("consumeInvite() error = %v, want %v", err, tt.wantErr)
+			}
+			var stored model.GroupInvite
+			db.First(&stored, invite.ID)
+			if stored.Uses != tt.wantUses {
+				t.Errorf("uses = %d, want %d", stored.Uses, tt.wantUses)
+			}
+		})
+	}
+
+	if err := consumeInvite(db, 999); err != errInviteInvalid {
+		t.Errorf("链接不存在 error = %v, want %v", err, errInviteInvalid)
+	}
+}
+
+func TestJoinRequestBannedBeforeReview(t *testing.T) {
+	users, groupID := setupInvites(t, 2)
+	owner, applicant := users[0], users[1]
+	invite := createInvite(t, owner, groupID, CreateInviteRequest{RequireApproval: true})
+	pending, err := groupService.RedeemInvite(applicant, invite.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 申请提交后才写入的封禁记录同样阻止入群，审批不生效
+	db := global.MySQLClient
+	db.Create(&model.GroupBan{GroupID: groupID, UserID: applicant, OperatorID: owner})
+	if _, _, err := groupService.ReviewJoinRequest(owner, groupID, pending.RequestID, true); err != errGroupBanned {
+		t.Errorf("ReviewJoinRequest() error = %v, want %v", err, errGroupBanned)
+	}
+	if groupService.IsMember(groupID, applicant) {
+		t.Error("被封禁用户不应入群")
+	}
+	var request model.GroupJoinRequest
+	db.First(&request, pending.RequestID)
+	if request.Status != model.JoinRequestPending {
+		t.Errorf("申请状态 = %s, want 审批回滚后仍为 pending", request.Status)
+	}
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go_chat/model"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SaveGroupMessage 保存群聊消息，整个群只存储一份。去重规则与私聊消息相同，重复发送时返回true
+func (s *MessageService) SaveGroupMessage(message *model.ChatMessage) (bool, error) {
+	message.SessionID = model.GroupSessionID(message.GroupID)
+	return s.saveSharedMessage(message)
+}
+
+// SaveChannelMessage 保存频道消息，整个频道只存储一份，离线订阅者从历史消息中读取。重复发送时返回true
+func (s *MessageService) SaveChannelMessage(message *model.ChatMessage) (bool, error) {
+	message.SessionID = model.ChannelSessionID(message.ChannelID)
+	return s.saveSharedMessage(message)
+}
+
+// saveSharedMessage 保存群聊或频道这类只存储一份的消息，调用前需设置会话ID。重复发送时返回true
+func (s *MessageService) saveSharedMessage(message *model.ChatMessage) (bool, error) {
+	collection, err := messagesCollection()
+	if err != nil {
+		return false, err
+	}
+
+	if message.ClientMsgID != "" {
+		duplicate, err := s.claimClientMsgID(message.FromUserID, message.ClientMsgID)
+		if err != nil {
+			return false, err
+		}
+		if duplicate != nil {
+			*message = *duplicate
+			return true, nil
+		}
+	}
+
+	message.ID = primitive.NewObjectID()
+	message.Status = model.MessageStatusSent
+
+	seq, err := conversationService.NextSeq(message.SessionID, nil)
+	if err != nil {
+		s.releaseClientMsgID(message.FromUserID, message.ClientMsgID)
+		return false, err
+	}
+	message.Seq = seq
+	message.CreatedAt = time.Now()
+	message.UpdatedAt = message.CreatedAt
+
+	if err := s.insertSequenced(collection, message); err != nil {
+		logrus.Errorf("消息 %s 保存失败: %v", message.SessionID, err)
+		s.releaseClientMsgID(message.FromUserID, message.ClientMsgID)
+		return false, errors.New("消息保存失败")
+	}
+	return false, nil
+}
+
+// MarkGroupDelivered 记录群成员已确认收到群聊消息
+func (s *MessageService) MarkGroupDelivered(messageID string, userID uint) error {
+	collection, err := messagesCollection()
+	if err != nil {
+		return err
+	}
+
+	id, err := primitive.ObjectIDFromHex(messageID)
+	if err != nil {
+		return errors.New("消息ID格式错误")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "group_id": bson.M{"$gt": 0}}
+	update := bson.M{"$addToSet": bson.M{"delivered_to": userID}}
+	if _, err := collection.UpdateOne(ctx, filter, update); err != nil {
+		logrus.Errorf("群聊消息送达记录失败: %v", err)
+		return errors.New("消息状态更新失败")
+	}
+	return nil
+}
+
+// ListPendingGroupMessages 按时间从早到晚获取用户在各群中尚未确认收到的消息，只包含入群之后的消息
+func (s *MessageService) ListPendingGroupMessages(userID uint, memberships []model.GroupMember, limit int64) ([]model.ChatMessage, error) {
+	if len(memberships) == 0 {
+		return nil, nil
+	}
+
+	collection, err := messagesCollection()
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(bson.A, 0, len(memberships))
+	for _, membership := range memberships {
+		groups = append(groups, bson.M{
+			"group_id":  membership.GroupID,
+			"timestamp": bson.M{"$gte": membership.CreatedAt},
+		})
+	}
+	filter := bson.M{
+		"$or":          groups,
+		"from_user_id": bson.M{"$ne": userID},
+		"delivered_to": bson.M{"$ne": userID},
+		"hidden_for":   bson.M{"$ne": userID},
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: 1}, {Key: "_id", Value: 1}}).
+		SetLimit(limit)
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		logrus.Errorf("查询群聊离线消息失败: %v", err)
+		return nil, errors.New("查询离线消息失败")
+	}
+
+	var messages []model.ChatMessage
+	if err := cursor.All(ctx, &messages); err != nil {
+		logrus.Errorf("读取群聊离线消息失败: %v", err)
+		return nil, errors.New("查询离线消息失败")
+	}
+	return messages, nil
+}
+
+// isParticipant 用户是否可以查看消息：私聊为会话双方，群聊为当前群成员，频道为当前订阅者
+func (s *MessageService) isParticipant(message *model.ChatMessage, userID uint) bool {
+	if message.GroupID != 0 {
+		return groupService.IsMember(message.GroupID, userID)
+	}
+	if message.ChannelID != 0 {
+		return channelService.IsSubscriber(message.ChannelID, userID)
+	}
+	return message.FromUserID == userID || message.ToUserID == userID
+}
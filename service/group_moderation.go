@@ -0,0 +1,348 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go_chat/global"
+	"go_chat/model"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jinzhu/gorm"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	slowModeKeyPrefix   = "go_chat:slow_mode:" // 慢速模式发言间隔键前缀，后接群组ID和用户ID
+	maxMuteDuration     = 30 * 24 * 3600       // 禁言最长秒数
+	maxSlowModeInterval = 3600                 // 慢速模式最长间隔秒数
+	maxBanReasonLength  = 200                  // 封禁原因最大字符数
+)
+
+// errGroupBanned 用户已被群组封禁，加入成员的事务在锁定群组后同样会返回
+var errGroupBanned = errors.New("已被禁止加入该群组")
+
+// MuteMemberRequest 禁言请求
+type MuteMemberRequest struct {
+	Duration int64 `json:"duration" binding:"required"` // 禁言秒数
+}
+
+// SetSlowModeRequest 设置慢速模式请求
+type SetSlowModeRequest struct {
+	Interval int `json:"interval"` // 两次发言的最小间隔秒数，0表示关闭
+}
+
+// BanMemberRequest 封禁请求
+type BanMemberRequest struct {
+	UserID uint   `json:"user_id" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+// GroupBanResponse 封禁记录
+type GroupBanResponse struct {
+	UserID     uint      `json:"user_id"`
+	UserName   string    `json:"username"`
+	Avatar     string    `json:"avatar"`
+	OperatorID uint      `json:"operator_id"`
+	Reason     string    `json:"reason,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// MuteMember 禁言成员指定时长，只能禁言角色比自己低的成员，返回禁言截止时间
+func (s *GroupService) MuteMember(operatorID, groupID, userID uint, duration int64) (*time.Time, error) {
+	if duration <= 0 || duration > maxMuteDuration {
+		return nil, errors.New("禁言时长无效")
+	}
+	target, err := s.moderationTarget(operatorID, groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	until := time.Now().Add(time.Duration(duration) * time.Second)
+	db := global.GetMySQLClient()
+	if err := db.Model(target).Update("muted_until", until).Error; err != nil {
+		logrus.Error("禁言成员失败:", err)
+		return nil, errors.New("禁言成员失败")
+	}
+	return &until, nil
+}
+
+// UnmuteMember 解除成员禁言
+func (s *GroupService) UnmuteMember(operatorID, groupID, userID uint) error {
+	target, err := s.moderationTarget(operatorID, groupID, userID)
+	if err != nil {
+		return err
+	}
+
+	db := global.GetMySQLClient()
+	if err := db.Model(target).Update("muted_until", gorm.Expr("NULL")).Error; err != nil {
+		logrus.Error("解除禁言失败:", err)
+		return errors.New("解除禁言失败")
+	}
+	return nil
+}
+
+// SetSlowMode 群主或管理员设置慢速模式间隔，群主和管理员发言不受限制
+func (s *GroupService) SetSlowMode(operatorID, groupID uint, interval int) error {
+	if interval < 0 || interval > maxSlowModeInterval {
+		return errors.New("慢速模式间隔无效")
+	}
+	if _, err := s.requireManager(groupID, operatorID); err != nil {
+		return err
+	}
+
+	db := global.GetMySQLClient()
+	if err := db.Model(&model.Group{}).Where("id = ?", groupID).Update("slow_mode", interval).Error; err != nil {
+		logrus.Error("设置慢速模式失败:", err)
+		return errors.New("设置慢速模式失败")
+	}
+	return nil
+}
+
+// BanMember 封禁用户：将其移出群组并禁止再次加入，同时拒绝其尚未处理的入群申请。
+// 可以封禁尚未入群的用户，已在群中时只能封禁角色比自己低的成员。返回用户封禁前是否为群成员
+func (s *GroupService) BanMember(operatorID, groupID uint, req BanMemberRequest) (bool, error) {
+	if operatorID == req.UserID {
+		return false, errors.New("不能封禁自己")
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if utf8.RuneCountInString(reason) > maxBanReasonLength {
+		return false, errors.New("封禁原因过长")
+	}
+
+	operator, err := s.requireManager(groupID, operatorID)
+	if err != nil {
+		return false, err
+	}
+	if err := s.checkUsersExist([]uint{req.UserID}); err != nil {
+		return false, err
+	}
+	target, err := s.GetMember(groupID, req.UserID)
+	if err != nil && err.Error() != "不是群成员" {
+		return false, err
+	}
+	if target != nil && !operator.Outranks(target) {
+		return false, errors.New("权限不足")
+	}
+
+	db := global.GetMySQLClient()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// 与加入群组的事务在群组记录上排队，封禁提交后不会再有用户通过邀请或审批入群
+		if err := lockGroup(tx, groupID); err != nil {
+			return err
+		}
+		if target != nil {
+			if err := tx.Delete(target).Error; err != nil {
+				return err
+			}
+		}
+		err := tx.Model(&model.GroupJoinRequest{}).
+			Where("group_id = ? AND user_id = ? AND status = ?", groupID, req.UserID, model.JoinRequestPending).
+			Updates(map[string]interface{}{
+				"status":      model.JoinRequestRejected,
+				"reviewer_id": operatorID,
+				"reviewed_at": time.Now(),
+			}).Error
+		if err != nil {
+			return err
+		}
+		// 重复封禁时更新原因和操作者
+		var ban model.GroupBan
+		return tx.Where(model.GroupBan{GroupID: groupID, UserID: req.UserID}).
+			Assign(model.GroupBan{OperatorID: operatorID, Reason: reason}).
+			FirstOrCreate(&ban).Error
+	})
+	if err != nil {
+		logrus.Error("封禁用户失败:", err)
+		return false, errors.New("封禁用户失败")
+	}
+
+	logrus.Infof("用户 %d 在群组 %d 中封禁了用户 %d", operatorID, groupID, req.UserID)
+	return target != nil, nil
+}
+
+// UnbanMember 解除封禁，解除后用户可以重新加入，但不会自动回到群中
+func (s *GroupService) UnbanMember(operatorID, groupID, userID uint) error {
+	if _, err := s.requireManager(groupID, operatorID); err != nil {
+		return err
+	}
+
+	db := global.GetMySQLClient()
+	result := db.Where("group_id = ? AND user_id = ?", groupID, userID).Delete(&model.GroupBan{})
+	if result.Error != nil {
+		logrus.Error("解除封禁失败:", result.Error)
+		return errors.New("解除封禁失败")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("该用户未被封禁")
+	}
+	return nil
+}
+
+// ListBans 群主或管理员查看封禁列表
+func (s *GroupService) ListBans(operatorID, groupID uint) ([]GroupBanResponse, error) {
+	if _, err := s.requireManager(groupID, operatorID); err != nil {
+		return nil, err
+	}
+
+	db := global.GetMySQLClient()
+	var bans []model.GroupBan
+	if err := db.Where("group_id = ?", groupID).Order("id DESC").Find(&bans).Error; err != nil {
+		logrus.Error("查询封禁列表失败:", err)
+		return nil, errors.New("查询封禁列表失败")
+	}
+
+	userIDs := make([]uint, 0, len(bans))
+	for _, ban := range bans {
+		userIDs = append(userIDs, ban.UserID)
+	}
+	users, err := authService.GetUsersByIDs(userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]GroupBanResponse, 0, len(bans))
+	for _, ban := range bans {
+		response := GroupBanResponse{
+			UserID:     ban.UserID,
+			OperatorID: ban.OperatorID,
+			Reason:     ban.Reason,
+			CreatedAt:  ban.CreatedAt,
+		}
+		if user, ok := users[ban.UserID]; ok {
+			response.UserName = user.UserName
+			response.Avatar = user.Avatar
+		}
+		result = append(result, response)
+	}
+	return result, nil
+}
+
+// IsBanned 用户是否被群组封禁，查询失败时视为未封禁
+func (s *GroupService) IsBanned(groupID, userID uint) bool {
+	db := global.GetMySQLClient()
+	if db == nil {
+		return false
+	}
+
+	var count int
+	if err := db.Model(&model.GroupBan{}).Where("group_id = ? AND user_id = ?", groupID, userID).Count(&count).Error; err != nil {
+		logrus.Warnf("查询群 %d 封禁记录失败: %v", groupID, err)
+		return false
+	}
+	return count > 0
+}
+
+// CheckSendPermission 校验用户能否在群中发言：必须是群成员、未被禁言，且普通成员受慢速模式限制。
+// 被拒绝时返回需要等待的时长，禁言和慢速模式以外的错误等待时长为0
+func (s *GroupService) CheckSendPermission(groupID, userID uint, clientMsgID string) (time.Duration, error) {
+	group, err := s.GetGroup(groupID)
+	if err != nil {
+		return 0, err
+	}
+	member, err := s.GetMember(groupID, userID)
+	if err != nil {
+		if err.Error() == "不是群成员" && s.IsBanned(groupID, userID) {
+			return 0, errors.New("已被禁止加入该群组")
+		}
+		return 0, err
+	}
+	if member.IsMuted() {
+		return time.Until(*member.MutedUntil), errors.New("已被禁言")
+	}
+	if group.SlowMode > 0 && !member.IsManager() {
+		if wait := claimSendSlot(groupID, userID, clientMsgID, time.Duration(group.SlowMode)*time.Second); wait > 0 {
+			return wait, errors.New("慢速模式下发言过于频繁")
+		}
+	}
+	return 0, nil
+}
+
+// ReleaseSendSlot 消息未能保存时释放慢速模式的发言间隔，允许立即重新发送
+func (s *GroupService) ReleaseSendSlot(groupID, userID uint) {
+	redisClient := global.GetRedisClient()
+	if redisClient == nil {
+		return
+	}
+	redisClient.Del(slowModeKey(groupID, userID))
+}
+
+// checkNotBanned 校验用户未被群组封禁
+func (s *GroupService) checkNotBanned(groupID uint, userIDs ...uint) error {
+	return ensureNotBanned(global.GetMySQLClient(), groupID, userIDs...)
+}
+
+// ensureNotBanned 通过指定连接检查用户均未被群组封禁，在事务中调用时读取事务内的封禁记录
+func ensureNotBanned(db *gorm.DB, groupID uint, userIDs ...uint) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	var count int
+	if err := db.Model(&model.GroupBan{}).Where("group_id = ? AND user_id IN (?)", groupID, userIDs).Count(&count).Error; err != nil {
+		logrus.Error("查询封禁记录失败:", err)
+		return errors.New("查询群组失败")
+	}
+	if count > 0 {
+		return errGroupBanned
+	}
+	return nil
+}
+
+// moderationTarget 校验操作者可以管理目标成员并返回目标成员记录
+func (s *GroupService) moderationTarget(operatorID, groupID, userID uint) (*model.GroupMember, error) {
+	if operatorID == userID {
+		return nil, errors.New("不能对自己执行该操作")
+	}
+	operator, err := s.requireManager(groupID, operatorID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.GetMember(groupID, userID)
+	if err != nil {
+		if err.Error() == "不是群成员" {
+			return nil, errors.New("该用户不是群成员")
+		}
+		return nil, err
+	}
+	if !operator.Outranks(target) {
+		return nil, errors.New("权限不足")
+	}
+	return target, nil
+}
+
+// claimSendSlot 占用慢速模式下的发言间隔，间隔未结束时返回剩余时长。
+// 使用相同client_msg_id重发的消息视为同一条消息，不受间隔限制，由消息去重返回原消息；Redis不可用时不限制
+func claimSendSlot(groupID, userID uint, clientMsgID string, interval time.Duration) time.Duration {
+	redisClient := global.GetRedisClient()
+	if redisClient == nil {
+		return 0
+	}
+
+	key := slowModeKey(groupID, userID)
+	claimed, err := redisClient.SetNX(key, clientMsgID, interval).Result()
+	if err != nil {
+		logrus.Warnf("慢速模式检查失败: %v", err)
+		return 0
+	}
+	if claimed {
+		return 0
+	}
+
+	if clientMsgID != "" {
+		if previous, err := redisClient.Get(key).Result(); err == nil && previous == clientMsgID {
+			return 0
+		}
+	}
+	wait, err := redisClient.PTTL(key).Result()
+	if err != nil || wait <= 0 {
+		return 0
+	}
+	return wait
+}
+
+// slowModeKey 慢速模式发言间隔的Redis键
+func slowModeKey(groupID, userID uint) string {
+	return fmt.Sprintf("%s%d:%d", slowModeKeyPrefix, groupID, userID)
+}
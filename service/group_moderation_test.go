@@ -0,0 +1,172 @@
+package service
+
+import (
+	"go_chat/global"
+	"go_chat/model"
+	"testing"
+	"time"
+)
+
+// setupModeration 准备群管理测试：第一个用户为群主，第二个为管理员，其余为普通成员
+func setupModeration(t *testing.T, userCount int) ([]uint, uint) {
+	t.Helper()
+	users, groupID := setupInvites(t, userCount)
+	setupRedis(t)
+	if _, err := groupService.AddMembers(users[0], groupID, users[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := groupService.SetMemberRole(users[0], groupID, users[1], model.GroupRoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	return users, groupID
+}
+
+func TestMuteMember(t *testing.T) {
+	users, groupID := setupModeration(t, 4)
+	owner, admin, member := users[0], users[1], users[2]
+
+	rejects := []struct {
+		name       string
+		operatorID uint
+		userID     uint
+		duration   int64
+		wantErr    string
+	}{
+		{"时长为0", admin, member, 0, "禁言时长无效"},
+		{"时长超过上限", admin, member, maxMuteDuration + 1, "禁言时长无效"},
+		{"禁言自己", admin, admin, 60, "不能对自己执行该操作"},
+		{"普通成员不能禁言", member, users[3], 60, "权限不足"},
+		{"管理员不能禁言群主", admin, owner, 60, "权限不足"},
+	}
+	for _, tt := range rejects {
+		if _, err := groupService.MuteMember(tt.operatorID, groupID, tt.userID, tt.duration); err == nil || err.Error() != tt.wantErr {
+			t.Errorf("%s: MuteMember() error = %v, want %s", tt.name, err, tt.wantErr)
+		}
+	}
+
+	until, err := groupService.MuteMember(admin, groupID, member, 60)
+	if err != nil || time.Until(*until) <= 0 {
+		t.Fatalf("MuteMember() = %v, %v", until, err)
+	}
+	wait, err := groupService.CheckSendPermission(groupID, member, "")
+	if err == nil || err.Error() != "已被禁言" || wait <= 50*time.Second || wait > time.Minute {
+		t.Errorf("禁言中发言 = %v, %v, want 已被禁言 并等待约1分钟", wait, err)
+	}
+
+	if err := groupService.UnmuteMember(admin, groupID, member); err != nil {
+		t.Fatalf("UnmuteMember() error = %v", err)
+	}
+	if _, err := groupService.CheckSendPermission(groupID, member, ""); err != nil {
+		t.Errorf("解除禁言后发言 error = %v", err)
+	}
+}
+
+func TestSlowMode(t *testing.T) {
+	users, groupID := setupModeration(t, 3)
+	owner, admin, member := users[0], users[1], users[2]
+
+	if err := groupService.SetSlowMode(member, groupID, 10); err == nil || err.Error() != "权限不足" {
+		t.Errorf("普通成员设置 error = %v, want 权限不足", err)
+	}
+	if err := groupService.SetSlowMode(admin, groupID, maxSlowModeInterval+1); err == nil || err.Error() != "慢速模式间隔无效" {
+		t.Errorf("间隔超过上限 error = %v, want 慢速模式间隔无效", err)
+	}
+	if err := groupService.SetSlowMode(admin, groupID, 10); err != nil {
+		t.Fatalf("SetSlowMode() error = %v", err)
+	}
+
+	if _, err := groupService.CheckSendPermission(groupID, member, "first"); err != nil {
+		t.Fatalf("首次发言 error = %v", err)
+	}
+	// 相同client_msg_id的重发不受限制，由消息去重处理
+	if _, err := groupService.CheckSendPermission(groupID, member, "first"); err != nil {
+		t.Errorf("重发 error = %v, want nil", err)
+	}
+	wait, err := groupService.CheckSendPermission(groupID, member, "second")
+	if err == nil || err.Error() != "慢速模式下发言过于频繁" || wait <= 0 || wait > 10*time.Second {
+		t.Errorf("间隔内再次发言 = %v, %v, want 慢速模式下发言过于频繁", wait, err)
+	}
+
+	// 消息保存失败时释放发言间隔
+	groupService.ReleaseSendSlot(groupID, member)
+	if _, err := groupService.CheckSendPermission(groupID, member, "second"); err != nil {
+		t.Errorf("释放间隔后发言 error = %v", err)
+	}
+
+	// 群主和管理员不受慢速模式限制
+	for _, manager := range []uint{owner, admin} {
+		for i := 0; i < 2; i++ {
+			if _, err := groupService.CheckSendPermission(groupID, manager, ""); err != nil {
+				t.Errorf("用户 %d 发言 error = %v, want nil", manager, err)
+			}
+		}
+	}
+
+	// Redis不可用时不限制
+	global.RedisClient = nil
+	if _, err := groupService.CheckSendPermission(groupID, member, "third"); err != nil {
+		t.Errorf("Redis不可用时发言 error = %v, want nil", err)
+	}
+}
+
+func TestBanMember(t *testing.T) {
+	users, groupID := setupModeration(t, 5)
+	owner, admin, member, applicant := users[0], users[1], users[2], users[4]
+	groupService.RemoveMember(owner, groupID, applicant)
+	invite := createInvite(t, owner, groupID, CreateInviteRequest{RequireApproval: true})
+	pending, err := groupService.RedeemInvite(applicant, invite.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := groupService.BanMember(admin, groupID, BanMemberRequest{UserID: owner}); err == nil || err.Error() != "权限不足" {
+		t.Errorf("管理员封禁群主 error = %v, want 权限不足", err)
+	}
+	if _, err := groupService.BanMember(admin, groupID, BanMemberRequest{UserID: admin}); err == nil || err.Error() != "不能封禁自己" {
+		t.Errorf("封禁自己 error = %v, want 不能封禁自己", err)
+	}
+
+	wasMember, err := groupService.BanMember(admin, groupID, BanMemberRequest{UserID: member, Reason: " spam "})
+	if err != nil || !wasMember || groupService.IsMember(groupID, member) {
+		t.Fatalf("BanMember() = %v, %v, want 成员被移出", wasMember, err)
+	}
+	// 可以封禁尚未入群的用户，尚未处理的入群申请被拒绝
+	if wasMember, err := groupService.BanMember(admin, groupID, BanMemberRequest{UserID: applicant}); err != nil || wasMember {
+		t.Fatalf("封禁申请人 = %v, %v", wasMember, err)
+	}
+	var request model.GroupJoinRequest
+	global.MySQLClient.First(&request, pending.RequestID)
+	if request.Status != model.JoinRequestRejected || request.ReviewerID != admin {
+		t.Errorf("入群申请 = %+v, want 已被拒绝", request)
+	}
+
+	bans, err := groupService.ListBans(owner, groupID)
+	if err != nil || len(bans) != 2 {
+		t.Fatalf("ListBans() = %+v, %v, want 2", bans, err)
+	}
+
+	// 被封禁后不能通过任何方式加入或发言
+	if _, err := groupService.CheckSendPermission(groupID, member, ""); err == nil || err.Error() != "已被禁止加入该群组" {
+		t.Errorf("封禁后发言 error = %v, want 已被禁止加入该群组", err)
+	}
+	if _, err := groupService.AddMembers(owner, groupID, []uint{member}); err == nil || err.Error() != "已被禁止加入该群组" {
+		t.Errorf("添加被封禁用户 error = %v, want 已被禁止加入该群组", err)
+	}
+	open := createInvite(t, owner, groupID, CreateInviteRequest{})
+	if _, err := groupService.RedeemInvite(member, open.Token); err == nil || err.Error() != "已被禁止加入该群组" {
+		t.Errorf("被封禁用户使用邀请链接 error = %v, want 已被禁止加入该群组", err)
+	}
+
+	if err := groupService.UnbanMember(admin, groupID, member); err != nil {
+		t.Fatalf("UnbanMember() error = %v", err)
+	}
+	if err := groupService.UnbanMember(admin, groupID, member); err == nil || err.Error() != "该用户未被封禁" {
+		t.Errorf("重复解除 error = %v, want 该用户未被封禁", err)
+	}
+	if groupService.IsMember(groupID, member) {
+		t.Error("解除封禁后不应自动回到群中")
+	}
+	if joined, err := groupService.RedeemInvite(member, open.Token); err != nil || joined.Status != RedeemStatusJoined {
+		t.Errorf("解除封禁后使用邀请链接 = %+v, %v, want joined", joined, err)
+	}
+}
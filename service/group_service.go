@@ -0,0 +1,565 @@
+package service
+
+import (
+	"errors"
+	"go_chat/config"
+	"go_chat/global"
+	"go_chat/model"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jinzhu/gorm"
+	"github.com/sirupsen/logrus"
+)
+
+// maxGroupNameLength 群名称最大字符数
+const maxGroupNameLength = 50
+
+type GroupService struct{}
+
+var groupService = NewGroupService()
+
+// errGroupFull 群成员已达上限，加入成员的事务在锁定群组后返回
+var errGroupFull = errors.New("群成员数量超过限制")
+
+// CreateGroupRequest 创建群组请求
+type CreateGroupRequest struct {
+	Name      string `json:"name" binding:"required"`
+	MemberIDs []uint `json:"member_ids"` // 创建者以外的初始成员
+}
+
+// RenameGroupRequest 修改群名称请求
+type RenameGroupRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// AddMembersRequest 添加群成员请求
+type AddMembersRequest struct {
+	UserIDs []uint `json:"user_ids" binding:"required"`
+}
+
+// SetMemberRoleRequest 设置成员角色请求
+type SetMemberRoleRequest struct {
+	Role string `json:"role" binding:"required"` // admin或member
+}
+
+// GroupResponse 群组信息
+type GroupResponse struct {
+	ID          uint      `json:"id"`
+	SessionID   string    `json:"session_id"`
+	Name        string    `json:"name"`
+	CreatorID   uint      `json:"creator_id"`
+	MemberCount int       `json:"member_count"`
+	Public      bool      `json:"public"`          // 是否为公开房间
+	Topic       string    `json:"topic,omitempty"` // 房间主题
+	SlowMode    int       `json:"slow_mode"`       // 慢速模式间隔秒数，0表示关闭
+	Role        string    `json:"role,omitempty"`  // 当前用户在群中的角色
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// GroupMemberResponse 群成员信息
+type GroupMemberResponse struct {
+	UserID     uint       `json:"user_id"`
+	UserName   string     `json:"username"`
+	Avatar     string     `json:"avatar"`
+	Role       string     `json:"role"`
+	MutedUntil *time.Time `json:"muted_until,omitempty"` // 禁言截止时间
+	JoinedAt   time.Time  `json:"joined_at"`
+}
+
+// NewGroupService 创建群组服务实例
+func NewGroupService() *GroupService {
+	return &GroupService{}
+}
+
+// CreateGroup 创建群组，创建者成为群主
+func (s *GroupService) CreateGroup(ownerID uint, req CreateGroupRequest) (*GroupResponse, error) {
+	name, err := normalizeGroupName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	memberIDs := uniqueUserIDs(req.MemberIDs, ownerID)
+	if len(memberIDs)+1 > config.GroupMaxMembers {
+		return nil, errGroupFull
+	}
+	if err := s.checkUsersExist(memberIDs); err != nil {
+		return nil, err
+	}
+
+	db := global.GetMySQLClient()
+	if db == nil {
+		return nil, errors.New("数据库连接不可用")
+	}
+
+	group := model.Group{Name: name, CreatorID: ownerID}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&group).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&model.GroupMember{GroupID: group.ID, UserID: ownerID, Role: model.GroupRoleOwner}).Error; err != nil {
+			return err
+		}
+		for _, userID := range memberIDs {
+			if err := tx.Create(&model.GroupMember{GroupID: group.ID, UserID: userID, Role: model.GroupRoleMember}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logrus.Error("群组创建失败:", err)
+		return nil, errors.New("群组创建失败")
+	}
+
+	logrus.Infof("用户 %d 创建了群组 %d", ownerID, group.ID)
+	return newGroupResponse(&group, len(memberIDs)+1, model.GroupRoleOwner), nil
+}
+
+// ListGroups 获取用户加入的群组
+func (s *GroupService) ListGroups(userID uint) ([]GroupResponse, error) {
+	db := global.GetMySQLClient()
+	if db == nil {
+		return nil, errors.New("数据库连接不可用")
+	}
+
+	memberships, err := s.ListMemberships(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(memberships) == 0 {
+		return []GroupResponse{}, nil
+	}
+
+	groupIDs := make([]uint, 0, len(memberships))
+	roles := make(map[uint]string, len(memberships))
+	for _, membership := range memberships {
+		groupIDs = append(groupIDs, membership.GroupID)
+		roles[membership.GroupID] = membership.Role
+	}
+
+	var groups []model.Group
+	if err := db.Where("id IN (?)", groupIDs).Order("id").Find(&groups).Error; err != nil {
+		logrus.Error("查询群组列表失败:", err)
+		return nil, errors.New("查询群组失败")
+	}
+
+	counts, err := s.countMembers(groupIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]GroupResponse, 0, len(groups))
+	for i := range groups {
+		result = append(result, *newGroupResponse(&groups[i], counts[groups[i].ID], roles[groups[i].ID]))
+	}
+	return result, nil
+}
+
+// GetGroupDetail 获取群组信息和成员列表，只有群成员可以查看
+func (s *GroupService) GetGroupDetail(userID, groupID uint) (*GroupResponse, []GroupMemberResponse, error) {
+	group, err := s.GetGroup(groupID)
+	if err != nil {
+		return nil, nil, err
+	}
+	member, err := s.GetMember(groupID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	members, err := s.ListMembers(groupID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newGroupResponse(group, len(members), member.Role), members, nil
+}
+
+// RenameGroup 群主或管理员修改群名称
+func (s *GroupService) RenameGroup(operatorID, groupID uint, name string) (*GroupResponse, error) {
+	name, err := normalizeGroupName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := s.GetGroup(groupID)
+	if err != nil {
+		return nil, err
+	}
+	operator, err := s.GetMember(groupID, operatorID)
+	if err != nil {
+		return nil, err
+	}
+	if !operator.IsManager() {
+		return nil, errors.New("权限不足")
+	}
+
+	db := global.GetMySQLClient()
+	if err := db.Model(group).Update("name", name).Error; err != nil {
+		logrus.Error("群名称修改失败:", err)
+		return nil, errors.New("群名称修改失败")
+	}
+
+	counts, err := s.countMembers([]uint{groupID})
+	if err != nil {
+		return nil, err
+	}
+	return newGroupResponse(group, counts[groupID], operator.Role), nil
+}
+
+// AddMembers 群主或管理员添加成员，已在群中的用户会被忽略，返回新加入的用户ID
+func (s *GroupService) AddMembers(operatorID, groupID uint, userIDs []uint) ([]uint, error) {
+	if _, err := s.GetGroup(groupID); err != nil {
+		return nil, err
+	}
+	operator, err := s.GetMember(groupID, operatorID)
+	if err != nil {
+		return nil, err
+	}
+	if !operator.IsManager() {
+		return nil, errors.New("权限不足")
+	}
+
+	existing, err := s.ListMemberIDs(groupID)
+	if err != nil {
+		return nil, err
+	}
+	newIDs := uniqueUserIDs(userIDs, existing...)
+	if len(newIDs) == 0 {
+		return newIDs, nil
+	}
+	if len(existing)+len(newIDs) > config.GroupMaxMembers {
+		return nil, errGroupFull
+	}
+	if err := s.checkUsersExist(newIDs); err != nil {
+		return nil, err
+	}
+	if err := s.checkNotBanned(groupID, newIDs...); err != nil {
+		return nil, err
+	}
+
+	db := global.GetMySQLClient()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := reserveCapacity(tx, groupID, len(newIDs)); err != nil {
+			return err
+		}
+		for _, userID := range newIDs {
+			if err := tx.Create(&model.GroupMember{GroupID: groupID, UserID: userID, Role: model.GroupRoleMember}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == errGroupFull {
+		return nil, err
+	}
+	if err != nil {
+		logrus.Error("添加群成员失败:", err)
+		return nil, errors.New("添加群成员失败")
+	}
+	return newIDs, nil
+}
+
+// RemoveMember 移除群成员，只能移除角色比自己低的成员
+func (s *GroupService) RemoveMember(operatorID, groupID, userID uint) error {
+	if operatorID == userID {
+		return errors.New("不能移除自己，请使用退出群组")
+	}
+	if _, err := s.GetGroup(groupID); err != nil {
+		return err
+	}
+	operator, err := s.GetMember(groupID, operatorID)
+	if err != nil {
+		return err
+	}
+	target, err := s.GetMember(groupID, userID)
+	if err != nil {
+		if err.Error() == "不是群成员" {
+			return errors.New("该用户不是群成员")
+		}
+		return err
+	}
+	if !operator.IsManager() || !operator.Outranks(target) {
+		return errors.New("权限不足")
+	}
+
+	db := global.GetMySQLClient()
+	if err := db.Delete(target).Error; err != nil {
+		logrus.Error("移除群成员失败:", err)
+		return errors.New("移除群成员失败")
+	}
+	return nil
+}
+
+// LeaveGroup 成员退出群组。群主退出时群主转让给最早加入的管理员，没有管理员时转让给最早加入的成员；
+// 最后一名成员退出后群组解散。返回新群主ID，未转让时为0
+func (s *GroupService) LeaveGroup(userID, groupID uint) (uint, error) {
+	group, err := s.GetGroup(groupID)
+	if err != nil {
+		return 0, err
+	}
+	member, err := s.GetMember(groupID, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	db := global.GetMySQLClient()
+	var newOwnerID uint
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(member).Error; err != nil {
+			return err
+		}
+		if member.Role != model.GroupRoleOwner {
+			return nil
+		}
+
+		var successor model.GroupMember
+		err := tx.Where("group_id = ?", groupID).
+			Order(gorm.Expr("role = ? DESC, created_at, id", model.GroupRoleAdmin)).
+			First(&successor).Error
+		if gorm.IsRecordNotFoundError(err) {
+			return tx.Delete(group).Error
+		}
+		if err != nil {
+			return err
+		}
+		newOwnerID = successor.UserID
+		return tx.Model(&successor).Update("role", model.GroupRoleOwner).Error
+	})
+	if err != nil {
+		logrus.Error("退出群组失败:", err)
+		return 0, errors.New("退出群组失败")
+	}
+	return newOwnerID, nil
+}
+
+// SetMemberRole 群主设置成员为管理员或普通成员
+func (s *GroupService) SetMemberRole(operatorID, groupID, userID uint, role string) error {
+	if role != model.GroupRoleAdmin && role != model.GroupRoleMember {
+		return errors.New("角色无效")
+	}
+	if operatorID == userID {
+		return errors.New("不能修改自己的角色")
+	}
+	if _, err := s.GetGroup(groupID); err != nil {
+		return err
+	}
+	operator, err := s.GetMember(groupID, operatorID)
+	if err != nil {
+		return err
+	}
+	if operator.Role != model.GroupRoleOwner {
+		return errors.New("权限不足")
+	}
+	target, err := s.GetMember(groupID, userID)
+	if err != nil {
+		if err.Error() == "不是群成员" {
+			return errors.New("该用户不是群成员")
+		}
+		return err
+	}
+
+	db := global.GetMySQLClient()
+	if err := db.Model(target).Update("role", role).Error; err != nil {
+		logrus.Error("设置成员角色失败:", err)
+		return errors.New("设置成员角色失败")
+	}
+	return nil
+}
+
+// GetGroup 根据ID获取群组
+func (s *GroupService) GetGroup(groupID uint) (*model.Group, error) {
+	db := global.GetMySQLClient()
+	if db == nil {
+		return nil, errors.New("数据库连接不可用")
+	}
+
+	var group model.Group
+	if err := db.First(&group, groupID).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, errors.New("群组不存在")
+		}
+		logrus.Error("查询群组失败:", err)
+		return nil, errors.New("查询群组失败")
+	}
+	return &group, nil
+}
+
+// GetMember 获取用户在群中的成员记录，不是成员时返回错误
+func (s *GroupService) GetMember(groupID, userID uint) (*model.GroupMember, error) {
+	db := global.GetMySQLClient()
+	if db == nil {
+		return nil, errors.New("数据库连接不可用")
+	}
+
+	var member model.GroupMember
+	err := db.Where("group_id = ? AND user_id = ?", groupID, userID).First(&member).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, errors.New("不是群成员")
+		}
+		logrus.Error("查询群成员失败:", err)
+		return nil, errors.New("查询群成员失败")
+	}
+	return &member, nil
+}
+
+// IsMember 用户是否为群成员，查询失败时视为不是成员
+func (s *GroupService) IsMember(groupID, userID uint) bool {
+	_, err := s.GetMember(groupID, userID)
+	return err == nil
+}
+
+// ListMemberIDs 获取群组全部成员的用户ID
+func (s *GroupService) ListMemberIDs(groupID uint) ([]uint, error) {
+	db := global.GetMySQLClient()
+	if db == nil {
+		return nil, errors.New("数据库连接不可用")
+	}
+
+	var userIDs []uint
+	if err := db.Model(&model.GroupMember{}).Where("group_id = ?", groupID).Pluck("user_id", &userIDs).Error; err != nil {
+		logrus.Error("查询群成员失败:", err)
+		return nil, errors.New("查询群成员失败")
+	}
+	return userIDs, nil
+}
+
+// ListMembers 获取群成员列表，按入群时间排列
+func (s *GroupService) ListMembers(groupID uint) ([]GroupMemberResponse, error) {
+	db := global.GetMySQLClient()
+	if db == nil {
+		return nil, errors.New("数据库连接不可用")
+	}
+
+	var members []model.GroupMember
+	if err := db.Where("group_id = ?", groupID).Order("created_at, id").Find(&members).Error; err != nil {
+		logrus.Error("查询群成员失败:", err)
+		return nil, errors.New("查询群成员失败")
+	}
+
+	userIDs := make([]uint, 0, len(members))
+	for _, member := range members {
+		userIDs = append(userIDs, member.UserID)
+	}
+	users, err := authService.GetUsersByIDs(userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]GroupMemberResponse, 0, len(members))
+	for _, member := range members {
+		response := GroupMemberResponse{
+			UserID:   member.UserID,
+			Role:     member.Role,
+			JoinedAt: member.CreatedAt,
+		}
+		if member.IsMuted() {
+			response.MutedUntil = member.MutedUntil
+		}
+		if user, ok := users[member.UserID]; ok {
+			response.UserName = user.UserName
+			response.Avatar = user.Avatar
+		}
+		result = append(result, response)
+	}
+	return result, nil
+}
+
+// ListMemberships 获取用户的全部群成员记录
+func (s *GroupService) ListMemberships(userID uint) ([]model.GroupMember, error) {
+	db := global.GetMySQLClient()
+	if db == nil {
+		return nil, errors.New("数据库连接不可用")
+	}
+
+	var memberships []model.GroupMember
+	if err := db.Where("user_id = ?", userID).Find(&memberships).Error; err != nil {
+		logrus.Error("查询用户群组失败:", err)
+		return nil, errors.New("查询群组失败")
+	}
+	return memberships, nil
+}
+
+// countMembers 统计群组成员数，键为群组ID
+func (s *GroupService) countMembers(groupIDs []uint) (map[uint]int, error) {
+	db := global.GetMySQLClient()
+
+	var rows []struct {
+		GroupID uint
+		Count   int
+	}
+	err := db.Model(&model.GroupMember{}).
+		Select("group_id, COUNT(*) AS count").
+		Where("group_id IN (?)", groupIDs).
+		Group("group_id").
+		Scan(&rows).Error
+	if err != nil {
+		logrus.Error("统计群成员失败:", err)
+		return nil, errors.New("查询群组失败")
+	}
+
+	counts := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		counts[row.GroupID] = row.Count
+	}
+	return counts, nil
+}
+
+// checkUsersExist 校验用户均存在
+func (s *GroupService) checkUsersExist(userIDs []uint) error {
+	users, err := authService.GetUsersByIDs(userIDs)
+	if err != nil {
+		return err
+	}
+	if len(users) != len(userIDs) {
+		return errors.New("用户不存在")
+	}
+	return nil
+}
+
+// newGroupResponse 转换群组信息
+func newGroupResponse(group *model.Group, memberCount int, role string) *GroupResponse {
+	return &GroupResponse{
+		ID:          group.ID,
+		SessionID:   model.GroupSessionID(group.ID),
+		Name:        group.Name,
+		CreatorID:   group.CreatorID,
+		MemberCount: memberCount,
+		Public:      group.Public,
+		Topic:       group.Topic,
+		SlowMode:    group.SlowMode,
+		Role:        role,
+		CreatedAt:   group.CreatedAt,
+	}
+}
+
+// normalizeGroupName 去除群名称首尾空白并校验长度
+func normalizeGroupName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("群名称不能为空")
+	}
+	if utf8.RuneCountInString(name) > maxGroupNameLength {
+		return "", errors.New("群名称过长")
+	}
+	return name, nil
+}
+
+// uniqueUserIDs 去除重复和无效的用户ID，并排除exclude中的用户
+func uniqueUserIDs(userIDs []uint, exclude ...uint) []uint {
+	seen := make(map[uint]bool, len(userIDs)+len(exclude))
+	for _, userID := range exclude {
+		seen[userID] = true
+	}
+
+	result := make([]uint, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if userID == 0 || seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		result = append(result, userID)
+	}
+	return result
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"go_chat/config"
+	"go_chat/global"
+	"go_chat/model"
+	"testing"
+	"time"
+)
+
+// setupGroups 准备群组测试所需的数据库和用户，返回创建的用户ID
+func setupGroups(t *testing.T, userCount int) []uint {
+	t.Helper()
+	db := setupMySQL(t, &model.User{}, &model.Group{}, &model.GroupMember{}, &model.GroupBan{})
+	previous := config.GroupMaxMembers
+	t.Cleanup(func() { config.GroupMaxMembers = previous })
+	config.GroupMaxMembers = 10
+
+	userIDs := make([]uint, 0, userCount)
+	for i := 0; i < userCount; i++ {
+		user := model.User{UserName: "user" + string(rune('a'+i)), Status: model.Active}
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+		userIDs = append(userIDs, user.ID)
+	}
+	return userIDs
+}
+
+// memberRole 查询成员角色，不是成员时返回空
+func memberRole(t *testing.T, groupID, userID uint) string {
+	t.Helper()
+	member, err := groupService.GetMember(groupID, userID)
+	if err != nil {
+		return ""
+	}
+	return member.Role
+}
+
+func TestCreateGroup(t *testing.T) {
+	users := setupGroups(t, 3)
+
+	group, err := groupService.CreateGroup(users[0], CreateGroupRequest{Name: "  项目组 ", MemberIDs: []uint{users[1], users[2], users[1], users[0]}})
+	if err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+	if group.Name != "项目组" || group.MemberCount != 3 || group.Role != model.GroupRoleOwner || group.SessionID != model.GroupSessionID(group.ID) {
+		t.Errorf("CreateGroup() = %+v", group)
+	}
+	if memberRole(t, group.ID, users[0]) != model.GroupRoleOwner || memberRole(t, group.ID, users[1]) != model.GroupRoleMember {
+		t.Error("创建者应为群主，其余为普通成员")
+	}
+
+	config.GroupMaxMembers = 2
+	rejects := []struct {
+		name    string
+		req     CreateGroupRequest
+		wantErr string
+	}{
+		{"名称为空", CreateGroupRequest{Name: "  "}, "群名称不能为空"},
+		{"名称过长", CreateGroupRequest{Name: string(make([]rune, maxGroupNameLength+1))}, "群名称过长"},
+		{"成员超过上限", CreateGroupRequest{Name: "g", MemberIDs: users[1:]}, "群成员数量超过限制"},
+		{"成员不存在", CreateGroupRequest{Name: "g", MemberIDs: []uint{999}}, "用户不存在"},
+	}
+	for _, tt := range rejects {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := groupService.CreateGroup(users[0], tt.req); err == nil || err.Error() != tt.wantErr {
+				t.Errorf("CreateGroup() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGroupMemberManagement(t *testing.T) {
+	users := setupGroups(t, 5)
+	owner, admin, member, outsider, newcomer := users[0], users[1], users[2], users[3], users[4]
+	group, err := groupService.CreateGroup(owner, CreateGroupRequest{Name: "g", MemberIDs: []uint{admin, member}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := groupService.SetMemberRole(owner, group.ID, admin, model.GroupRoleAdmin); err != nil {
+		t.Fatalf("SetMemberRole() error = %v", err)
+	}
+
+	t.Run("添加成员", func(t *testing.T) {
+		if _, err := groupService.AddMembers(member, group.ID, []uint{newcomer}); err == nil || err.Error() != "权限不足" {
+			t.Errorf("普通成员添加 error = %v, want 权限不足", err)
+		}
+		if _, err := groupService.AddMembers(outsider, group.ID, []uint{newcomer}); err == nil || err.Error() != "不是群成员" {
+			t.Errorf("非成员添加 error = %v, want 不是群成员", err)
+		}
+		added, err := groupService.AddMembers(admin, group.ID, []uint{member, newcomer, newcomer})
+		if err != nil || len(added) != 1 || added[0] != newcomer {
+			t.Errorf("AddMembers() = %v, %v, want [%d]", added, err, newcomer)
+		}
+		config.GroupMaxMembers = 4
+		if _, err := groupService.AddMembers(owner, group.ID, []uint{outsider}); err == nil || err.Error() != "群成员数量超过限制" {
+			t.Errorf("超过上限 error = %v, want 群成员数量超过限制", err)
+		}
+	})
+
+	t.Run("设置角色", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			operatorID uint
+			userID     uint
+			role       string
+			wantErr    string
+		}{
+			{"角色无效", owner, member, model.GroupRoleOwner, "角色无效"},
+			{"修改自己", owner, owner, model.GroupRoleMember, "不能修改自己的角色"},
+			{"管理员不能设置角色", admin, member, model.GroupRoleAdmin, "权限不足"},
+			{"目标不是成员", owner, outsider, model.GroupRoleAdmin, "该用户不是群成员"},
+		}
+		for _, tt := range tests {
+			if err := groupService.SetMemberRole(tt.operatorID, group.ID, tt.userID, tt.role); err == nil || err.Error() != tt.wantErr {
+				t.Errorf("%s: SetMemberRole() error = %v, want %s", tt.name, err, tt.wantErr)
+			}
+		}
+	})
+
+	t.Run("移除成员", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			operatorID uint
+			userID     uint
+			wantErr    string
+		}{
+			{"移除自己", admin, admin, "不能移除自己，请使用退出群组"},
+			{"普通成员不能移除", member, newcomer, "权限不足"},
+			{"管理员不能移除群主", admin, owner, "权限不足"},
+			{"目标不是成员", admin, outsider, "该用户不是群成员"},
+			{"管理员移除普通成员", admin, newcomer, ""},
+		}
+		for _, tt := range tests {
+			err := groupService.RemoveMember(tt.operatorID, group.ID, tt.userID)
+			if tt.wantErr == "" {
+				if err != nil || groupService.IsMember(group.ID, tt.userID) {
+					t.Errorf("%s: RemoveMember() error = %v, want 成员已移除", tt.name, err)
+				}
+			} else if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("%s: RemoveMember() error = %v, want %s", tt.name, err, tt.wantErr)
+			}
+		}
+	})
+}
+
+func TestLeaveGroup(t *testing.T) {
+	users := setupGroups(t, 3)
+	owner, first, admin := users[0], users[1], users[2]
+	group, err := groupService.CreateGroup(owner, CreateGroupRequest{Name: "g"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := groupService.AddMembers(owner, group.ID, []uint{first}); err != nil {
+		t.Fatal(err)
+	}
+	// 保证入群时间先后不同
+	time.Sleep(10 * time.Millisecond)
+	if _, err := groupService.AddMembers(owner, group.ID, []uint{admin}); err != nil {
+		t.Fatal(err)
+	}
+	if err := groupService.SetMemberRole(owner, group.ID, admin, model.GroupRoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+
+	// 群主优先转让给管理员，即使管理员入群更晚
+	newOwner, err := groupService.LeaveGroup(owner, group.ID)
+	if err != nil || newOwner != admin || memberRole(t, group.ID, admin) != model.GroupRoleOwner {
+		t.Fatalf("LeaveGroup() = %d, %v, want 转让给管理员 %d", newOwner, err, admin)
+	}
+	if newOwner, err := groupService.LeaveGroup(first, group.ID); err != nil || newOwner != 0 {
+		t.Errorf("普通成员退出 = %d, %v, want 0", newOwner, err)
+	}
+
+	// 最后一名成员退出后解散
+	if _, err := groupService.LeaveGroup(admin, group.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := groupService.GetGroup(group.ID); err == nil || err.Error() != "群组不存在" {
+		t.Errorf("GetGroup() error = %v, want 群组不存在", err)
+	}
+	if _, err := groupService.LeaveGroup(admin, group.ID); err == nil {
+		t.Error("重复退出 error = nil")
+	}
+	var remaining int
+	global.MySQLClient.Model(&model.GroupMember{}).Where("group_id = ?", group.ID).Count(&remaining)
+	if remaining != 0 {
+		t.Errorf("剩余成员 = %d, want 0", remaining)
+	}
+}
+
+func TestReserveCapacity(t *testing.T) {
+	users := setupGroups(t, 2)
+	group, err := groupService.CreateGroup(users[0], CreateGroupRequest{Name: "g", MemberIDs: []uint{users[1]}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.GroupMaxMembers = 3
+
+	tests := []struct {
+		name    string
+		groupID uint
+		count   int
+		wantErr error
+	}{
+		{"未超过上限", group.ID, 1, nil},
+		{"超过上限", group.ID, 2, errGroupFull},
+	}
+	for _, tt := range tests {
+		if err := reserveCapacity(global.MySQLClient, tt.groupID, tt.count); err != tt.wantErr {
+			t.Errorf("%s: reserveCapacity() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+	if err := reserveCapacity(global.MySQLClient, group.ID+1, 1); err == nil {
+		t.Error("群组不存在时 reserveCapacity() error = nil")
+	}
+}
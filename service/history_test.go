@@ -0,0 +1,139 @@
+package service
+
+import (
+	"encoding/base64"
+	"go_chat/model"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestHistoryCursorRoundTrip(t *testing.T) {
+	id := primitive.NewObjectID()
+	tests := []struct {
+		name      string
+		timestamp time.Time
+	}{
+		{"毫秒精度", time.UnixMilli(1700000000123)},
+		{"纪元时间", time.UnixMilli(0)},
+		{"纪元之前", time.UnixMilli(-1500)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timestamp, decodedID, err := decodeHistoryCursor(encodeHistoryCursor(tt.timestamp, id))
+			if err != nil {
+				t.Fatalf("decodeHistoryCursor() error = %v", err)
+			}
+			if !timestamp.Equal(tt.timestamp) {
+				t.Errorf("timestamp = %v, want %v", timestamp, tt.timestamp)
+			}
+			if decodedID != id {
+				t.Errorf("id = %s, want %s", decodedID.Hex(), id.Hex())
+			}
+		})
+	}
+}
+
+func TestHistoryCursorTruncatesToMillis(t *testing.T) {
+	timestamp := time.Unix(1700000000, 123456789)
+	decoded, _, err := decodeHistoryCursor(encodeHistoryCursor(timestamp, primitive.NewObjectID()))
+	if err != nil {
+		t.Fatalf("decodeHistoryCursor() error = %v", err)
+	}
+	if want := time.UnixMilli(timestamp.UnixMilli()); !decoded.Equal(want) {
+		t.Errorf("timestamp = %v, want %v", decoded, want)
+	}
+}
+
+func TestDecodeHistoryCursorInvalid(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+	id := primitive.NewObjectID().Hex()
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"非base64", "!!!"},
+		{"标准base64带填充", base64.StdEncoding.EncodeToString([]byte("1:" + id))},
+		{"缺少分隔符", encode("1700000000000")},
+		{"时间不是数字", encode("abc:" + id)},
+		{"消息ID无效", encode("1700000000000:xyz")},
+		{"消息ID为空", encode("1700000000000:")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeHistoryCursor(tt.cursor); err == nil || err.Error() != "游标格式错误" {
+				t.Errorf("decodeHistoryCursor(%q) error = %v, want 游标格式错误", tt.cursor, err)
+			}
+		})
+	}
+}
+
+func TestListHistory(t *testing.T) {
+	mt := newMockMongo(t)
+	now := time.UnixMilli(1700000000000)
+	messages := make([]model.ChatMessage, 3)
+	for i := range messages {
+		messages[i] = model.ChatMessage{ID: primitive.NewObjectID(), SessionID: "private_1_2", Timestamp: now.Add(-time.Duration(i) * time.Second)}
+	}
+
+	mt.Run("多取一条判断下一页", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch,
+			toBSON(mt, messages[0]), toBSON(mt, messages[1]), toBSON(mt, messages[2])))
+
+		page, err := NewMessageService().ListHistory("private_1_2", 1, "", 2)
+		if err != nil {
+			mt.Fatalf("ListHistory() error = %v", err)
+		}
+		if len(page.Messages) != 2 || page.Messages[1].ID != messages[1].ID {
+			mt.Fatalf("page.Messages = %+v, want 前两条", page.Messages)
+		}
+		if page.NextCursor != encodeHistoryCursor(messages[1].Timestamp, messages[1].ID) {
+			mt.Errorf("NextCursor = %q, want 指向第二条消息", page.NextCursor)
+		}
+
+		event := mt.GetStartedEvent()
+		if limit := event.Command.Lookup("limit").AsInt64(); limit != 3 {
+			mt.Errorf("查询条数 = %d, want 3", limit)
+		}
+		if viewer := event.Command.Lookup("filter", "hidden_for", "$ne").AsInt64(); viewer != 1 {
+			mt.Errorf("查询条件未排除查看者删除的消息: hidden_for $ne %d", viewer)
+		}
+	})
+
+	mt.Run("最后一页", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch, toBSON(mt, messages[2])))
+
+		before := encodeHistoryCursor(messages[1].Timestamp, messages[1].ID)
+		page, err := NewMessageService().ListHistory("private_1_2", 1, before, 0)
+		if err != nil {
+			mt.Fatalf("ListHistory() error = %v", err)
+		}
+		if len(page.Messages) != 1 || page.NextCursor != "" {
+			mt.Errorf("page = %+v, want 一条消息且没有下一页", page)
+		}
+
+		event := mt.GetStartedEvent()
+		if limit := event.Command.Lookup("limit").AsInt64(); limit != defaultHistoryLimit+1 {
+			mt.Errorf("查询条数 = %d, want %d", limit, defaultHistoryLimit+1)
+		}
+		if _, err := event.Command.Lookup("filter", "$or").Array().Values(); err != nil {
+			mt.Errorf("查询条件缺少游标范围: %v", err)
+		}
+	})
+
+	mt.Run("游标格式错误", func(mt *mtest.T) {
+		useMockMongo(mt)
+		if _, err := NewMessageService().ListHistory("private_1_2", 1, "!!!", 10); err == nil || err.Error() != "游标格式错误" {
+			mt.Errorf("ListHistory() error = %v, want 游标格式错误", err)
+		}
+	})
+}
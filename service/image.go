@@ -0,0 +1,360 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+
+	// 注册GIF解码器
+	_ "image/gif"
+)
+
+const (
+	maxImagePixels   = 40000000 // 可处理的最大像素数，防止解码超大图片耗尽内存
+	thumbnailQuality = 85       // JPEG缩略图质量
+)
+
+// thumbnailImage 生成的缩略图
+type thumbnailImage struct {
+	size        int // 缩略图的最长边
+	width       int
+	height      int
+	contentType string
+	data        []byte
+}
+
+// isProcessableImage 是否为服务端可以解码的图片类型
+func isProcessableImage(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+		return true
+	}
+	return false
+}
+
+// decodeImage 校验尺寸后解码图片，GIF只解码第一帧
+func decodeImage(data []byte) (image.Image, error) {
+	imageConfig, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("图片解析失败")
+	}
+	if imageConfig.Width <= 0 || imageConfig.Height <= 0 ||
+		imageConfig.Width*imageConfig.Height > maxImagePixels {
+		return nil, errors.New("图片尺寸超过限制")
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("图片解析失败")
+	}
+	return img, nil
+}
+
+// stripImageMetadata 去除图片中的EXIF等元数据（包含拍摄位置），不重新编码图片内容。
+// EXIF中的方向标记会以只包含方向的最小EXIF保留，同时返回该方向（1表示无需旋转）
+func stripImageMetadata(contentType string, data []byte) ([]byte, int, error) {
+	switch contentType {
+	case "image/jpeg":
+		return stripJPEGMetadata(data)
+	case "image/png":
+		return stripPNGMetadata(data)
+	}
+	// GIF不包含EXIF
+	return data, 1, nil
+}
+
+// stripJPEGMetadata 移除JPEG中的APP1（EXIF/XMP）、APP13（IPTC）段，EXIF段替换为只包含方向的最小EXIF
+func stripJPEGMetadata(data []byte) ([]byte, int, error) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, 0, errors.New("图片解析失败")
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+	orientation := 1
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil, 0, errors.New("图片解析失败")
+		}
+		marker := data[pos+1]
+		// 图像数据开始后不再有元数据段，其余内容原样保留
+		if marker == 0xDA {
+			break
+		}
+		// 填充字节和没有长度的标记
+		if marker == 0xFF || marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			out = append(out, data[pos:pos+2]...)
+			pos += 2
+			continue
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, 0, errors.New("图片解析失败")
+		}
+		switch {
+		case marker == 0xE1 && bytes.HasPrefix(data[pos+4:end], exifHeader):
+			// 只处理第一个EXIF段，保持其原有位置
+			if orientation == 1 {
+				if value := exifOrientation(data[pos+4+len(exifHeader) : end]); value != 1 {
+					orientation = value
+					segment := orientationEXIF(orientation)
+					out = append(out, 0xFF, 0xE1)
+					out = binary.BigEndian.AppendUint16(out, uint16(len(segment)+2))
+					out = append(out, segment...)
+				}
+			}
+		case marker == 0xE1 || marker == 0xED:
+		default:
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+	}
+	return append(out, data[pos:]...), orientation, nil
+}
+
+// stripPNGMetadata 移除PNG中的eXIf和文本块，eXIf块替换为只包含方向的最小EXIF
+func stripPNGMetadata(data []byte) ([]byte, int, error) {
+	const signatureLength = 8
+	if len(data) < signatureLength {
+		return nil, 0, errors.New("图片解析失败")
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:signatureLength]...)
+	orientation := 1
+	pos := signatureLength
+	for pos+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		end := pos + 12 + length
+		if end > len(data) {
+			return nil, 0, errors.New("图片解析失败")
+		}
+
+		switch string(data[pos+4 : pos+8]) {
+		case "eXIf":
+			if orientation == 1 {
+				if value := exifOrientation(data[pos+8 : end-4]); value != 1 {
+					orientation = value
+					out = appendPNGChunk(out, "eXIf", orientationEXIF(orientation)[len(exifHeader):])
+				}
+			}
+		case "tEXt", "zTXt", "iTXt":
+		default:
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+	}
+	return out, orientation, nil
+}
+
+// exifHeader JPEG的APP1段中EXIF数据的前缀，PNG的eXIf块直接以TIFF头开始
+var exifHeader = []byte("Exif\x00\x00")
+
+// exifOrientation 从TIFF格式的EXIF数据中读取IFD0的方向标记，不存在或无效时返回1
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	if order.Uint16(tiff[2:4]) != 42 {
+		return 1
+	}
+
+	ifd := uint64(order.Uint32(tiff[4:8]))
+	if ifd+2 > uint64(len(tiff)) {
+		return 1
+	}
+	count := uint64(order.Uint16(tiff[ifd : ifd+2]))
+	for i := uint64(0); i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > uint64(len(tiff)) {
+			break
+		}
+		// 方向标记为SHORT类型
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 && order.Uint16(tiff[entry+2:entry+4]) == 3 {
+			if value := int(order.Uint16(tiff[entry+8 : entry+10])); value >= 1 && value <= 8 {
+				return value
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// orientationEXIF 生成只包含方向标记的EXIF数据（含Exif前缀）
+func orientationEXIF(orientation int) []byte {
+	segment := make([]byte, 0, 32)
+	segment = append(segment, exifHeader...)
+	// 大端TIFF头，IFD0紧随其后
+	segment = append(segment, 'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08)
+	segment = binary.BigEndian.AppendUint16(segment, 1)
+	segment = binary.BigEndian.AppendUint16(segment, 0x0112)
+	segment = binary.BigEndian.AppendUint16(segment, 3)
+	segment = binary.BigEndian.AppendUint32(segment, 1)
+	segment = binary.BigEndian.AppendUint16(segment, uint16(orientation))
+	segment = append(segment, 0x00, 0x00)
+	// 没有下一个IFD
+	return binary.BigEndian.AppendUint32(segment, 0)
+}
+
+// appendPNGChunk 追加一个PNG数据块
+func appendPNGChunk(out []byte, chunkType string, data []byte) []byte {
+	out = binary.BigEndian.AppendUint32(out, uint32(len(data)))
+	start := len(out)
+	out = append(out, chunkType...)
+	out = append(out, data...)
+	return binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(out[start:]))
+}
+
+// applyOrientation 按EXIF方向旋转或翻转图片，使其与查看器显示的方向一致
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	source := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(source, source.Bounds(), img, bounds.Min, draw.Src)
+
+	destWidth, destHeight := width, height
+	if orientation >= 5 {
+		// 5-8需要交换宽高
+		destWidth, destHeight = height, width
+	}
+	dest := image.NewNRGBA(image.Rect(0, 0, destWidth, destHeight))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // 水平翻转
+				dx, dy = width-1-x, y
+			case 3: // 旋转180度
+				dx, dy = width-1-x, height-1-y
+			case 4: // 垂直翻转
+				dx, dy = x, height-1-y
+			case 5: // 沿主对角线翻转
+				dx, dy = y, x
+			case 6: // 顺时针旋转90度
+				dx, dy = height-1-y, x
+			case 7: // 沿副对角线翻转
+				dx, dy = height-1-y, width-1-x
+			case 8: // 逆时针旋转90度
+				dx, dy = y, width-1-x
+			}
+			from := y*source.Stride + x*4
+			to := dy*dest.Stride + dx*4
+			copy(dest.Pix[to:to+4], source.Pix[from:from+4])
+		}
+	}
+	return dest
+}
+
+// makeThumbnails 按最长边生成缩略图，不超过原图尺寸；有透明通道的图片生成PNG，其余生成JPEG
+func makeThumbnails(img image.Image, contentType string, sizes []int) ([]thumbnailImage, error) {
+	bounds := img.Bounds()
+	source := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(source, source.Bounds(), img, bounds.Min, draw.Src)
+
+	outputType := "image/jpeg"
+	if contentType != "image/jpeg" && !source.Opaque() {
+		outputType = "image/png"
+	}
+
+	var thumbnails []thumbnailImage
+	for _, size := range sizes {
+		width, height := fitWithin(bounds.Dx(), bounds.Dy(), size)
+		resized := downscale(source, width, height)
+
+		var buf bytes.Buffer
+		var err error
+		if outputType == "image/png" {
+			err = png.Encode(&buf, resized)
+		} else {
+			err = jpeg.Encode(&buf, resized, &jpeg.Options{Quality: thumbnailQuality})
+		}
+		if err != nil {
+			return nil, errors.New("缩略图生成失败")
+		}
+
+		thumbnails = append(thumbnails, thumbnailImage{
+			size:        size,
+			width:       width,
+			height:      height,
+			contentType: outputType,
+			data:        buf.Bytes(),
+		})
+	}
+	return thumbnails, nil
+}
+
+// fitWithin 计算按比例缩放到最长边不超过size的尺寸
+func fitWithin(width, height, size int) (int, int) {
+	if width <= size && height <= size {
+		return width, height
+	}
+	if width >= height {
+		return size, max(1, height*size/width)
+	}
+	return max(1, width*size/height), size
+}
+
+// downscale 按区域平均缩小图片
+func downscale(source *image.NRGBA, width, height int) *image.NRGBA {
+	srcWidth, srcHeight := source.Bounds().Dx(), source.Bounds().Dy()
+	if width == srcWidth && height == srcHeight {
+		return source
+	}
+
+	dest := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := y * srcHeight / height
+		y1 := max(y0+1, (y+1)*srcHeight/height)
+		for x := 0; x < width; x++ {
+			x0 := x * srcWidth / width
+			x1 := max(x0+1, (x+1)*srcWidth/width)
+
+			var r, g, b, a, count uint64
+			for sy := y0; sy < y1; sy++ {
+				offset := sy*source.Stride + x0*4
+				for sx := x0; sx < x1; sx++ {
+					pixel := source.Pix[offset : offset+4 : offset+4]
+					alpha := uint64(pixel[3])
+					// 按透明度加权，避免透明像素的颜色渗入
+					r += uint64(pixel[0]) * alpha
+					g += uint64(pixel[1]) * alpha
+					b += uint64(pixel[2]) * alpha
+					a += alpha
+					count++
+					offset += 4
+				}
+			}
+
+			i := y*dest.Stride + x*4
+			if a > 0 {
+				dest.Pix[i] = uint8(r / a)
+				dest.Pix[i+1] = uint8(g / a)
+				dest.Pix[i+2] = uint8(b / a)
+			}
+			dest.Pix[i+3] = uint8(a / count)
+		}
+	}
+	return dest
+}
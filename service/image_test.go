@@ -0,0 +1,381 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// buildTIFF 构造IFD0中包含一个其他标记和方向标记的TIFF数据
+func buildTIFF(order binary.AppendByteOrder, valueType uint16, orientation uint16) []byte {
+	tiff := []byte("MM")
+	if order == binary.LittleEndian {
+		tiff = []byte("II")
+	}
+	tiff = order.AppendUint16(tiff, 42)
+	tiff = order.AppendUint32(tiff, 8)
+	tiff = order.AppendUint16(tiff, 2)
+	// 拍摄设备标记，读取时应跳过
+	tiff = order.AppendUint16(tiff, 0x010F)
+	tiff = order.AppendUint16(tiff, 2)
+	tiff = order.AppendUint32(tiff, 4)
+	tiff = append(tiff, 'c', 'a', 'm', 0)
+	tiff = order.AppendUint16(tiff, 0x0112)
+	tiff = order.AppendUint16(tiff, valueType)
+	tiff = order.AppendUint32(tiff, 1)
+	tiff = order.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0)
+	return order.AppendUint32(tiff, 0)
+}
+
+// jpegSegment 构造带长度的JPEG段
+func jpegSegment(marker byte, payload []byte) []byte {
+	segment := []byte{0xFF, marker}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// testImage 按行生成图片，每个字母对应一种颜色，行之间用/分隔
+func testImage(layout string) *image.NRGBA {
+	rows := strings.Split(layout, "/")
+	img := image.NewNRGBA(image.Rect(0, 0, len(rows[0]), len(rows)))
+	for y, row := range rows {
+		for x := range row {
+			img.SetNRGBA(x, y, color.NRGBA{R: row[x], G: row[x], B: row[x], A: 255})
+		}
+	}
+	return img
+}
+
+// imageLayout 将图片还原为testImage的格式
+func imageLayout(img image.Image) string {
+	bounds := img.Bounds()
+	rows := make([]string, 0, bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		var row []byte
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			row = append(row, color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA).R)
+		}
+		rows = append(rows, string(row))
+	}
+	return strings.Join(rows, "/")
+}
+
+func TestExifOrientation(t *testing.T) {
+	valid := buildTIFF(binary.BigEndian, 3, 6)
+	badMagic := buildTIFF(binary.BigEndian, 3, 6)
+	badMagic[3] = 43
+	badOffset := buildTIFF(binary.BigEndian, 3, 6)
+	binary.BigEndian.PutUint32(badOffset[4:8], 1000)
+
+	tests := []struct {
+		name string
+		tiff []byte
+		want int
+	}{
+		{"大端", valid, 6},
+		{"小端", buildTIFF(binary.LittleEndian, 3, 8), 8},
+		{"正常方向", buildTIFF(binary.BigEndian, 3, 1), 1},
+		{"方向值超出范围", buildTIFF(binary.BigEndian, 3, 9), 1},
+		{"方向值为0", buildTIFF(binary.LittleEndian, 3, 0), 1},
+		{"类型不是SHORT", buildTIFF(binary.BigEndian, 4, 6), 1},
+		{"字节序无效", append([]byte("XX"), valid[2:]...), 1},
+		{"TIFF标识错误", badMagic, 1},
+		{"IFD偏移越界", badOffset, 1},
+		{"条目被截断", valid[:len(valid)-10], 1},
+		{"数据过短", valid[:6], 1},
+		{"空数据", nil, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exifOrientation(tt.tiff); got != tt.want {
+				t.Errorf("exifOrientation() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrientationEXIFRoundTrip(t *testing.T) {
+	for orientation := 1; orientation <= 8; orientation++ {
+		segment := orientationEXIF(orientation)
+		if !bytes.HasPrefix(segment, exifHeader) {
+			t.Fatalf("orientationEXIF(%d) 缺少Exif前缀", orientation)
+		}
+		if got := exifOrientation(segment[len(exifHeader):]); got != orientation {
+			t.Errorf("exifOrientation(orientationEXIF(%d)) = %d", orientation, got)
+		}
+	}
+}
+
+func TestStripJPEGMetadata(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, testImage("ab/cd"), nil); err != nil {
+		t.Fatal(err)
+	}
+	body := encoded.Bytes()[2:]
+
+	exif := func(orientation uint16) []byte {
+		return jpegSegment(0xE1, append(append([]byte{}, exifHeader...), buildTIFF(binary.LittleEndian, 3, orientation)...))
+	}
+	xmp := jpegSegment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta/>"))
+	iptc := jpegSegment(0xED, []byte("Photoshop 3.0\x00location"))
+	comment := jpegSegment(0xFE, []byte("comment"))
+	build := func(segments ...[]byte) []byte {
+		data := []byte{0xFF, 0xD8}
+		for _, segment := range segments {
+			data = append(data, segment...)
+		}
+		return append(data, body...)
+	}
+
+	tests := []struct {
+		name            string
+		data            []byte
+		wantOrientation int
+		wantSegments    [][]byte
+		removed         [][]byte
+	}{
+		{"没有元数据", build(), 1, nil, nil},
+		{"移除EXIF、XMP和IPTC", build(exif(1), xmp, iptc, comment), 1, [][]byte{comment}, [][]byte{exif(1), xmp, iptc}},
+		{"保留方向", build(exif(6), comment), 6, [][]byte{jpegSegment(0xE1, orientationEXIF(6)), comment}, [][]byte{exif(6)}},
+		{"只使用第一个EXIF段", build(exif(3), exif(8)), 3, [][]byte{jpegSegment(0xE1, orientationEXIF(3))}, [][]byte{exif(8)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stripped, orientation, err := stripJPEGMetadata(tt.data)
+			if err != nil {
+				t.Fatalf("stripJPEGMetadata() error = %v", err)
+			}
+			if orientation != tt.wantOrientation {
+				t.Errorf("orientation = %d, want %d", orientation, tt.wantOrientation)
+			}
+			want := build(tt.wantSegments...)
+			if !bytes.Equal(stripped, want) {
+				t.Errorf("stripJPEGMetadata() 输出长度 %d, want %d", len(stripped), len(want))
+			}
+			for _, segment := range tt.removed {
+				if bytes.Contains(stripped, segment) {
+					t.Errorf("元数据段未移除: % x", segment[:4])
+				}
+			}
+			if _, err := jpeg.Decode(bytes.NewReader(stripped)); err != nil {
+				t.Errorf("处理后的图片无法解码: %v", err)
+			}
+		})
+	}
+}
+
+func TestStripJPEGMetadataInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"空数据", nil},
+		{"不是JPEG", []byte("\x89PNG\r\n\x1a\n")},
+		{"段标记错误", []byte{0xFF, 0xD8, 0x00, 0xE0, 0x00, 0x04, 0x00, 0x00}},
+		{"段长度过小", []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x01, 0x00, 0x00}},
+		{"段长度越界", []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 0x00, 0x00}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := stripJPEGMetadata(tt.data); err == nil {
+				t.Error("stripJPEGMetadata() error = nil, want 图片解析失败")
+			}
+		})
+	}
+}
+
+func TestStripPNGMetadata(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, testImage("ab/cd")); err != nil {
+		t.Fatal(err)
+	}
+	// 签名和IHDR块之后插入元数据块
+	const headerLength = 8 + 12 + 13
+	header, rest := encoded.Bytes()[:headerLength], encoded.Bytes()[headerLength:]
+
+	exif := func(orientation uint16) []byte {
+		return appendPNGChunk(nil, "eXIf", buildTIFF(binary.BigEndian, 3, orientation))
+	}
+	text := appendPNGChunk(nil, "tEXt", []byte("Comment\x00location"))
+	itxt := appendPNGChunk(nil, "iTXt", []byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00<x:xmpmeta/>"))
+	gamma := appendPNGChunk(nil, "gAMA", []byte{0x00, 0x00, 0xB1, 0x8F})
+	build := func(chunks ...[]byte) []byte {
+		data := append([]byte{}, header...)
+		for _, chunk := range chunks {
+			data = append(data, chunk...)
+		}
+		return append(data, rest...)
+	}
+
+	tests := []struct {
+		name            string
+		data            []byte
+		wantOrientation int
+		wantChunks      [][]byte
+	}{
+		{"没有元数据", build(), 1, nil},
+		{"移除eXIf和文本块", build(exif(1), text, gamma, itxt), 1, [][]byte{gamma}},
+		{"保留方向", build(text, exif(8)), 8, [][]byte{appendPNGChunk(nil, "eXIf", orientationEXIF(8)[len(exifHeader):])}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stripped, orientation, err := stripPNGMetadata(tt.data)
+			if err != nil {
+				t.Fatalf("stripPNGMetadata() error = %v", err)
+			}
+			if orientation != tt.wantOrientation {
+				t.Errorf("orientation = %d, want %d", orientation, tt.wantOrientation)
+			}
+			if want := build(tt.wantChunks...); !bytes.Equal(stripped, want) {
+				t.Errorf("stripPNGMetadata() 输出长度 %d, want %d", len(stripped), len(want))
+			}
+			// 解码时会校验每个块的CRC
+			if _, err := png.Decode(bytes.NewReader(stripped)); err != nil {
+				t.Errorf("处理后的图片无法解码: %v", err)
+			}
+		})
+	}
+}
+
+func TestStripPNGMetadataInvalid(t *testing.T) {
+	signature := []byte("\x89PNG\r\n\x1a\n")
+	oversized := binary.BigEndian.AppendUint32(append([]byte{}, signature...), 100)
+	oversized = append(oversized, "IDAT\x00\x00\x00\x00"...)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"空数据", nil},
+		{"数据过短", signature[:4]},
+		{"块长度越界", oversized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := stripPNGMetadata(tt.data); err == nil {
+				t.Error("stripPNGMetadata() error = nil, want 图片解析失败")
+			}
+		})
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	tests := []struct {
+		orientation int
+		want        string
+	}{
+		{0, "ABC/DEF"},
+		{1, "ABC/DEF"},
+		{2, "CBA/FED"},
+		{3, "FED/CBA"},
+		{4, "DEF/ABC"},
+		{5, "AD/BE/CF"},
+		{6, "DA/EB/FC"},
+		{7, "FC/EB/DA"},
+		{8, "CF/BE/AD"},
+		{9, "ABC/DEF"},
+	}
+
+	for _, tt := range tests {
+		if got := imageLayout(applyOrientation(testImage("ABC/DEF"), tt.orientation)); got != tt.want {
+			t.Errorf("applyOrientation(%d) = %s, want %s", tt.orientation, got, tt.want)
+		}
+	}
+}
+
+func TestApplyOrientationOffsetBounds(t *testing.T) {
+	// 子图的起点不是原点
+	img := testImage("xxxx/xABC/xDEF").SubImage(image.Rect(1, 1, 4, 3))
+	if got := imageLayout(applyOrientation(img, 6)); got != "DA/EB/FC" {
+		t.Errorf("applyOrientation(6) = %s, want DA/EB/FC", got)
+	}
+}
+
+func TestFitWithin(t *testing.T) {
+	tests := []struct {
+		width, height, size   int
+		wantWidth, wantHeight int
+	}{
+		{100, 50, 200, 100, 50},
+		{200, 200, 200, 200, 200},
+		{400, 200, 200, 200, 100},
+		{200, 400, 200, 100, 200},
+		{300, 200, 128, 128, 85},
+		{10000, 1, 100, 100, 1},
+		{1, 10000, 100, 1, 100},
+	}
+
+	for _, tt := range tests {
+		width, height := fitWithin(tt.width, tt.height, tt.size)
+		if width != tt.wantWidth || height != tt.wantHeight {
+			t.Errorf("fitWithin(%d, %d, %d) = (%d, %d), want (%d, %d)",
+				tt.width, tt.height, tt.size, width, height, tt.wantWidth, tt.wantHeight)
+		}
+	}
+}
+
+func TestMakeThumbnails(t *testing.T) {
+	opaque := testImage(strings.Repeat("a", 40) + strings.Repeat("/"+strings.Repeat("a", 40), 19))
+	transparent := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+
+	tests := []struct {
+		name        string
+		img         image.Image
+		contentType string
+		wantType    string
+	}{
+		{"不透明图片生成JPEG", opaque, "image/png", "image/jpeg"},
+		{"透明图片生成PNG", transparent, "image/png", "image/png"},
+		{"JPEG始终生成JPEG", transparent, "image/jpeg", "image/jpeg"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thumbnails, err := makeThumbnails(tt.img, tt.contentType, []int{10, 100})
+			if err != nil {
+				t.Fatalf("makeThumbnails() error = %v", err)
+			}
+			if len(thumbnails) != 2 {
+				t.Fatalf("缩略图数量 = %d, want 2", len(thumbnails))
+			}
+			// 不超过原图尺寸
+			sizes := [][2]int{{10, 5}, {40, 20}}
+			for i, thumbnail := range thumbnails {
+				if thumbnail.contentType != tt.wantType || thumbnail.width != sizes[i][0] || thumbnail.height != sizes[i][1] {
+					t.Errorf("缩略图 %d = %dx%d %s", thumbnail.size, thumbnail.width, thumbnail.height, thumbnail.contentType)
+				}
+				decoded, _, err := image.Decode(bytes.NewReader(thumbnail.data))
+				if err != nil || decoded.Bounds().Dx() != sizes[i][0] {
+					t.Errorf("缩略图 %d 无法解码: %v", thumbnail.size, err)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeImageRejects(t *testing.T) {
+	if _, err := decodeImage([]byte("not an image")); err == nil || err.Error() != "图片解析失败" {
+		t.Errorf("decodeImage() error = %v, want 图片解析失败", err)
+	}
+
+	// 只声明尺寸的PNG头即可触发尺寸检查，无需真正分配像素
+	var ihdr []byte
+	ihdr = binary.BigEndian.AppendUint32(ihdr, 100000)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, 100000)
+	ihdr = append(ihdr, 8, 2, 0, 0, 0)
+	huge := appendPNGChunk([]byte("\x89PNG\r\n\x1a\n"), "IHDR", ihdr)
+	if _, err := decodeImage(huge); err == nil || err.Error() != "图片尺寸超过限制" {
+		t.Errorf("decodeImage() error = %v, want 图片尺寸超过限制", err)
+	}
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go_chat/config"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	TokenTypeAccess  = "access"  // 访问令牌
+	TokenTypeRefresh = "refresh" // 刷新令牌
+)
+
+// Claims JWT声明
+type Claims struct {
+	UserID    uint   `json:"user_id"`
+	UserName  string `json:"username"`
+	TokenType string `json:"token_type"`
+	SessionID string `json:"sid"` // 登录会话ID，同一次登录签发的访问令牌和刷新令牌共享
+	jwt.RegisteredClaims
+}
+
+// TokenPair 访问令牌与刷新令牌
+type TokenPair struct {
+	AccessToken      string    `json:"access_token"`
+	AccessExpiresAt  time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	TokenType        string    `json:"token_type"`
+}
+
+// GenerateTokenPair 为用户的登录会话签发访问令牌和刷新令牌
+func GenerateTokenPair(userID uint, userName, sessionID string) (*TokenPair, error) {
+	accessToken, accessExpiresAt, err := generateToken(userID, userName, sessionID, TokenTypeAccess, config.JwtAccessExpire)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, refreshExpiresAt, err := generateToken(userID, userName, sessionID, TokenTypeRefresh, config.JwtRefreshExpire)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      accessToken,
+		AccessExpiresAt:  accessExpiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refreshExpiresAt,
+		TokenType:        "Bearer",
+	}, nil
+}
+
+// ParseAccessToken 解析并校验访问令牌
+func ParseAccessToken(tokenString string) (*Claims, error) {
+	return parseToken(tokenString, TokenTypeAccess)
+}
+
+// ParseRefreshToken 解析并校验刷新令牌
+func ParseRefreshToken(tokenString string) (*Claims, error) {
+	return parseToken(tokenString, TokenTypeRefresh)
+}
+
+// NewSessionID 生成登录会话ID
+func NewSessionID() string {
+	return generateTokenID()
+}
+
+// generateToken 生成指定类型的令牌
+func generateToken(userID uint, userName, sessionID, tokenType string, expire time.Duration) (string, time.Time, error) {
+	if config.JwtSecret == "" {
+		return "", time.Time{}, errors.New("JWT密钥未配置")
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(expire)
+	claims := Claims{
+		UserID:    userID,
+		UserName:  userName,
+		TokenType: tokenType,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        generateTokenID(),
+			Issuer:    config.JwtIssuer,
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString([]byte(config.JwtSecret))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return tokenString, expiresAt, nil
+}
+
+// parseToken 解析令牌并校验签名、有效期和类型
+func parseToken(tokenString, tokenType string) (*Claims, error) {
+	if config.JwtSecret == "" {
+		return nil, errors.New("JWT密钥未配置")
+	}
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("不支持的签名算法: %v", token.Header["alg"])
+		}
+		return []byte(config.JwtSecret), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, errors.New("令牌无效或已过期")
+	}
+
+	if claims.TokenType != tokenType {
+		return nil, errors.New("令牌类型错误")
+	}
+	if config.JwtIssuer != "" && claims.Issuer != config.JwtIssuer {
+		return nil, errors.New("令牌签发者错误")
+	}
+	return claims, nil
+}
+
+// generateTokenID 生成令牌唯一ID
+func generateTokenID() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 10)
+	}
+	return hex.EncodeToString(bytes)
+}
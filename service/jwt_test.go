@@ -0,0 +1,146 @@
+package service
+
+import (
+	"go_chat/config"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const testJwtSecret = "test-secret"
+
+// setupJWT 设置测试用的JWT配置，测试结束后恢复
+func setupJWT(t *testing.T) {
+	t.Helper()
+	secret, issuer := config.JwtSecret, config.JwtIssuer
+	accessExpire, refreshExpire := config.JwtAccessExpire, config.JwtRefreshExpire
+	t.Cleanup(func() {
+		config.JwtSecret, config.JwtIssuer = secret, issuer
+		config.JwtAccessExpire, config.JwtRefreshExpire = accessExpire, refreshExpire
+	})
+	config.JwtSecret = testJwtSecret
+	config.JwtIssuer = "go_chat"
+	config.JwtAccessExpire = time.Hour
+	config.JwtRefreshExpire = 24 * time.Hour
+}
+
+// signClaims 使用指定密钥签发任意声明的令牌
+func signClaims(t *testing.T, method jwt.SigningMethod, key interface{}, claims Claims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("签发测试令牌失败: %v", err)
+	}
+	return token
+}
+
+// testClaims 生成默认有效的访问令牌声明
+func testClaims(now time.Time) Claims {
+	return Claims{
+		UserID:    1,
+		UserName:  "alice",
+		TokenType: TokenTypeAccess,
+		SessionID: "session-1",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        generateTokenID(),
+			Issuer:    "go_chat",
+			Subject:   "1",
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+}
+
+func TestGenerateTokenPair(t *testing.T) {
+	setupJWT(t)
+
+	tokens, err := GenerateTokenPair(42, "bob", "session-1")
+	if err != nil {
+		t.Fatalf("GenerateTokenPair() error = %v", err)
+	}
+	if remaining := time.Until(tokens.AccessExpiresAt); remaining <= 0 || remaining > time.Hour {
+		t.Errorf("访问令牌 %v 后过期, want (0, 1h]", remaining)
+	}
+	if remaining := time.Until(tokens.RefreshExpiresAt); remaining <= time.Hour || remaining > 24*time.Hour {
+		t.Errorf("刷新令牌 %v 后过期, want (1h, 24h]", remaining)
+	}
+
+	access, err := ParseAccessToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseAccessToken() error = %v", err)
+	}
+	refresh, err := ParseRefreshToken(tokens.RefreshToken)
+	if err != nil {
+		t.Fatalf("ParseRefreshToken() error = %v", err)
+	}
+	for _, claims := range []*Claims{access, refresh} {
+		if claims.UserID != 42 || claims.UserName != "bob" || claims.Subject != "42" || claims.SessionID != "session-1" {
+			t.Errorf("claims = %+v, want user 42 bob session-1", claims)
+		}
+	}
+	if access.ID == "" || access.ID == refresh.ID {
+		t.Errorf("令牌ID = %q 和 %q, want 不同的非空ID", access.ID, refresh.ID)
+	}
+
+	// 两种令牌不能互相替代
+	if _, err := ParseAccessToken(tokens.RefreshToken); err == nil || err.Error() != "令牌类型错误" {
+		t.Errorf("刷新令牌作为访问令牌 error = %v, want 令牌类型错误", err)
+	}
+	if _, err := ParseRefreshToken(tokens.AccessToken); err == nil || err.Error() != "令牌类型错误" {
+		t.Errorf("访问令牌作为刷新令牌 error = %v, want 令牌类型错误", err)
+	}
+}
+
+func TestParseAccessTokenRejects(t *testing.T) {
+	setupJWT(t)
+	now := time.Now()
+
+	expired := testClaims(now.Add(-2 * time.Hour))
+	notYetValid := testClaims(now.Add(time.Hour))
+	wrongType := testClaims(now)
+	wrongType.TokenType = "refresh"
+	wrongIssuer := testClaims(now)
+	wrongIssuer.Issuer = "other"
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{"空令牌", "", "令牌无效或已过期"},
+		{"格式错误", "not-a-jwt", "令牌无效或已过期"},
+		{"已过期", signClaims(t, jwt.SigningMethodHS256, []byte(testJwtSecret), expired), "令牌无效或已过期"},
+		{"尚未生效", signClaims(t, jwt.SigningMethodHS256, []byte(testJwtSecret), notYetValid), "令牌无效或已过期"},
+		{"签名密钥错误", signClaims(t, jwt.SigningMethodHS256, []byte("other-secret"), testClaims(now)), "令牌无效或已过期"},
+		{"签名算法为none", signClaims(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, testClaims(now)), "令牌无效或已过期"},
+		{"令牌类型错误", signClaims(t, jwt.SigningMethodHS256, []byte(testJwtSecret), wrongType), "令牌类型错误"},
+		{"签发者错误", signClaims(t, jwt.SigningMethodHS256, []byte(testJwtSecret), wrongIssuer), "令牌签发者错误"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ParseAccessToken(tt.token)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("ParseAccessToken() error = %v, want %s", err, tt.wantErr)
+			}
+			if claims != nil {
+				t.Errorf("ParseAccessToken() claims = %+v, want nil", claims)
+			}
+		})
+	}
+}
+
+func TestJWTRequiresSecret(t *testing.T) {
+	setupJWT(t)
+	token := signClaims(t, jwt.SigningMethodHS256, []byte(testJwtSecret), testClaims(time.Now()))
+	config.JwtSecret = ""
+
+	if _, err := GenerateTokenPair(1, "alice", "session-1"); err == nil || err.Error() != "JWT密钥未配置" {
+		t.Errorf("GenerateTokenPair() error = %v, want JWT密钥未配置", err)
+	}
+	if _, err := ParseAccessToken(token); err == nil || err.Error() != "JWT密钥未配置" {
+		t.Errorf("ParseAccessToken() error = %v, want JWT密钥未配置", err)
+	}
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"go_chat/config"
+	"go_chat/model"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// setupEditWindow 设置测试用的编辑时限，测试结束后恢复
+func setupEditWindow(t *testing.T) {
+	t.Helper()
+	previous := config.MessageEditWindow
+	t.Cleanup(func() { config.MessageEditWindow = previous })
+	config.MessageEditWindow = 15 * time.Minute
+}
+
+// messageResponse 模拟按ID查询消息的响应
+func messageResponse(mt *mtest.T, message model.ChatMessage) bson.D {
+	return mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch, toBSON(mt, message))
+}
+
+func TestEditMessageRejects(t *testing.T) {
+	setupEditWindow(t)
+	mt := newMockMongo(t)
+	message := model.ChatMessage{ID: primitive.NewObjectID(), SessionID: "private_1_2", FromUserID: 1, ToUserID: 2, Content: "hello", Timestamp: time.Now()}
+	expired := message
+	expired.Timestamp = time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name    string
+		userID  uint
+		content string
+		stored  *model.ChatMessage
+		wantErr string
+	}{
+		{"内容为空", 1, "  ", nil, "消息内容不能为空"},
+		{"超过字节数限制", 1, strings.Repeat("a", 4097), nil, "消息内容过长"},
+		{"多字节字符超过字节数限制", 1, strings.Repeat("中", 1366), nil, "消息内容过长"},
+		{"不是发送者", 2, "changed", &message, "只能编辑自己发送的消息"},
+		{"超过编辑时限", 1, "changed", &expired, "已超过可编辑时间"},
+		{"内容未变化", 1, "hello", &message, "消息内容未变化"},
+	}
+
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			useMockMongo(mt)
+			if tt.stored != nil {
+				mt.AddMockResponses(messageResponse(mt, *tt.stored))
+			}
+
+			if _, err := NewMessageService().EditMessage(tt.userID, message.ID.Hex(), tt.content); err == nil || err.Error() != tt.wantErr {
+				mt.Errorf("EditMessage() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEditMessage(t *testing.T) {
+	setupEditWindow(t)
+	mt := newMockMongo(t)
+	sentAt := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+	message := model.ChatMessage{ID: primitive.NewObjectID(), SessionID: "private_1_2", FromUserID: 1, ToUserID: 2, Content: "hello", Timestamp: sentAt}
+
+	mt.Run("首次编辑保存原内容", func(mt *mtest.T) {
+		useMockMongo(mt)
+		editedAt := time.Now().Truncate(time.Millisecond)
+		edited := message
+		edited.Content, edited.Edited, edited.EditedAt = "changed", true, &editedAt
+		mt.AddMockResponses(
+			messageResponse(mt, message),
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: toBSON(mt, edited)}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		result, err := NewMessageService().EditMessage(1, message.ID.Hex(), "changed")
+		if err != nil || result.Content != "changed" || !result.Edited {
+			mt.Fatalf("EditMessage() = %+v, %v, want 编辑后的消息", result, err)
+		}
+
+		command := nextCommand(mt, "findAndModify")
+		if exists, ok := command.Lookup("query", "edited_at", "$exists").BooleanOK(); !ok || exists {
+			mt.Error("首次编辑应要求消息未被编辑过")
+		}
+		var version model.MessageVersion
+		if err := bson.Unmarshal(command.Lookup("update", "$push", "edit_history").Document(), &version); err != nil {
+			mt.Fatal(err)
+		}
+		if version.Content != "hello" || !version.Timestamp.Equal(sentAt) {
+			mt.Errorf("历史版本 = %+v, want 原内容和发送时间", version)
+		}
+		refresh := nextCommand(mt, "update").Lookup("updates").Array().Index(0).Value().Document()
+		if id := refresh.Lookup("q", "last_message.message_id").ObjectID(); id != message.ID {
+			mt.Errorf("更新的会话预览 = %s, want %s", id.Hex(), message.ID.Hex())
+		}
+	})
+
+	mt.Run("再次编辑以上次编辑时间为版本号", func(mt *mtest.T) {
+		useMockMongo(mt)
+		previousEdit := time.Now().Add(-30 * time.Second).Truncate(time.Millisecond)
+		stored := message
+		stored.Content, stored.Edited, stored.EditedAt = "changed", true, &previousEdit
+		mt.AddMockResponses(messageResponse(mt, stored), mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}))
+
+		if _, err := NewMessageService().EditMessage(1, message.ID.Hex(), "again"); err == nil || err.Error() != "消息已被修改，请重试" {
+			mt.Errorf("EditMessage() error = %v, want 消息已被修改，请重试", err)
+		}
+
+		command := nextCommand(mt, "findAndModify")
+		if version := command.Lookup("query", "edited_at").Time(); !version.Equal(previousEdit) {
+			mt.Errorf("版本号 = %v, want %v", version, previousEdit)
+		}
+		if content := command.Lookup("update", "$push", "edit_history", "content").StringValue(); content != "changed" {
+			mt.Errorf("历史版本内容 = %q, want changed", content)
+		}
+	})
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"go_chat/config"
+	"go_chat/global"
+	"go_chat/model"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestRecallMessage(t *testing.T) {
+	previous := config.MessageRecallWindow
+	t.Cleanup(func() { config.MessageRecallWindow = previous })
+	config.MessageRecallWindow = 2 * time.Minute
+
+	mt := newMockMongo(t)
+	message := model.ChatMessage{ID: primitive.NewObjectID(), SessionID: "private_1_2", FromUserID: 1, ToUserID: 2, Content: "oops", Timestamp: time.Now()}
+	expired := message
+	expired.Timestamp = time.Now().Add(-time.Hour)
+	recalled := message
+	recalled.Recalled = true
+
+	rejects := []struct {
+		name    string
+		userID  uint
+		stored  model.ChatMessage
+		wantErr string
+	}{
+		{"不是发送者", 2, message, "只能撤回自己发送的消息"},
+		{"已经撤回", 1, recalled, "消息已撤回"},
+		{"超过撤回时限", 1, expired, "已超过可撤回时间"},
+	}
+	for _, tt := range rejects {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			useMockMongo(mt)
+			mt.AddMockResponses(messageResponse(mt, tt.stored))
+
+			if _, err := NewMessageService().RecallMessage(tt.userID, message.ID.Hex()); err == nil || err.Error() != tt.wantErr {
+				mt.Errorf("RecallMessage() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+
+	mt.Run("清空内容和编辑历史", func(mt *mtest.T) {
+		useMockMongo(mt)
+		setupRedis(mt.T)
+		conversationService.IncrUnread(2, 1)
+		recalledAt := time.Now()
+		result := message
+		result.Content, result.Recalled, result.RecalledAt = "", true, &recalledAt
+		mt.AddMockResponses(
+			messageResponse(mt, message),
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: toBSON(mt, result)}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		got, err := NewMessageService().RecallMessage(1, message.ID.Hex())
+		if err != nil || !got.Recalled || got.Content != "" {
+			mt.Fatalf("RecallMessage() = %+v, %v, want 已撤回", got, err)
+		}
+
+		command := nextCommand(mt, "findAndModify")
+		if content := command.Lookup("update", "$set", "content").StringValue(); content != "" {
+			mt.Errorf("撤回后内容 = %q, want empty", content)
+		}
+		if _, ok := command.Lookup("update", "$unset", "edit_history").StringValueOK(); !ok {
+			mt.Error("撤回时未清空编辑历史")
+		}
+		refresh := nextCommand(mt, "update").Lookup("updates").Array().Index(0).Value().Document()
+		if !refresh.Lookup("u", "$set", "last_message.recalled").Boolean() {
+			mt.Error("会话预览未标记为已撤回")
+		}
+		// 接收者尚未读到的消息撤回后不再计入未读数
+		if unread := conversationService.GetUnreadCounts(2); unread[1] != 0 {
+			mt.Errorf("撤回后未读数 = %v, want 0", unread)
+		}
+	})
+
+	mt.Run("并发撤回", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(messageResponse(mt, message), mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}))
+
+		if _, err := NewMessageService().RecallMessage(1, message.ID.Hex()); err == nil || err.Error() != "消息已撤回" {
+			mt.Errorf("RecallMessage() error = %v, want 消息已撤回", err)
+		}
+	})
+}
+
+func TestEditRecalledMessage(t *testing.T) {
+	setupEditWindow(t)
+	mt := newMockMongo(t)
+
+	mt.Run("已撤回的消息不能编辑", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(messageResponse(mt, model.ChatMessage{ID: primitive.NewObjectID(), FromUserID: 1, Recalled: true, Timestamp: time.Now()}))
+
+		if _, err := NewMessageService().EditMessage(1, primitive.NewObjectID().Hex(), "changed"); err == nil || err.Error() != "消息已撤回" {
+			mt.Errorf("EditMessage() error = %v, want 消息已撤回", err)
+		}
+	})
+}
+
+func TestHideMessage(t *testing.T) {
+	mt := newMockMongo(t)
+	message := model.ChatMessage{ID: primitive.NewObjectID(), SessionID: "private_1_2", FromUserID: 1, ToUserID: 2, Content: "hi", Timestamp: time.Now()}
+
+	for _, userID := range []uint{1, 2} {
+		mt.Run("会话参与者"+strconv.Itoa(int(userID)), func(mt *mtest.T) {
+			useMockMongo(mt)
+			setupRedis(mt.T)
+			conversationService.IncrUnread(2, 1)
+			mt.AddMockResponses(
+				messageResponse(mt, message),
+				mtest.CreateSuccessResponse(bson.E{Key: "value", Value: toBSON(mt, message)}),
+				mtest.CreateSuccessResponse(),
+			)
+
+			if err := NewMessageService().HideMessage(userID, message.ID.Hex()); err != nil {
+				mt.Fatalf("HideMessage() error = %v", err)
+			}
+			command := nextCommand(mt, "findAndModify")
+			if hidden := command.Lookup("query", "hidden_for", "$ne").AsInt64(); hidden != int64(userID) {
+				mt.Errorf("只应处理首次删除, hidden_for $ne = %d, want %d", hidden, userID)
+			}
+			if hidden := command.Lookup("update", "$addToSet", "hidden_for").AsInt64(); hidden != int64(userID) {
+				mt.Errorf("hidden_for = %d, want %d", hidden, userID)
+			}
+			// 会话摘要记录删除者，会话列表改为预览上一条可见消息
+			summary := nextCommand(mt, "update").Lookup("updates").Array().Index(0).Value().Document()
+			if hidden := summary.Lookup("u", "$addToSet", "last_message.hidden_for").AsInt64(); hidden != int64(userID) {
+				mt.Errorf("last_message.hidden_for = %d, want %d", hidden, userID)
+			}
+			// 只有接收者删除未读消息时扣除未读数
+			wantUnread := int64(1)
+			if userID == message.ToUserID {
+				wantUnread = 0
+			}
+			if unread := conversationService.GetUnreadCounts(2); unread[1] != wantUnread {
+				mt.Errorf("删除后未读数 = %v, want %d", unread, wantUnread)
+			}
+		})
+	}
+
+	mt.Run("重复删除不再扣除未读数", func(mt *mtest.T) {
+		useMockMongo(mt)
+		setupRedis(mt.T)
+		conversationService.IncrUnread(2, 1)
+		mt.AddMockResponses(messageResponse(mt, message), mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}))
+
+		if err := NewMessageService().HideMessage(2, message.ID.Hex()); err != nil {
+			mt.Fatalf("HideMessage() error = %v", err)
+		}
+		if unread := conversationService.GetUnreadCounts(2); unread[1] != 1 {
+			mt.Errorf("重复删除后未读数 = %v, want 1", unread)
+		}
+	})
+
+	mt.Run("非会话参与者", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(messageResponse(mt, message))
+
+		if err := NewMessageService().HideMessage(3, message.ID.Hex()); err == nil || err.Error() != "消息不存在" {
+			mt.Errorf("HideMessage() error = %v, want 消息不存在", err)
+		}
+	})
+
+	mt.Run("消息存储不可用", func(mt *mtest.T) {
+		useMockMongo(mt)
+		global.MongoDBClient = nil
+
+		if err := NewMessageService().HideMessage(1, message.ID.Hex()); err == nil || err.Error() != "消息存储不可用" {
+			mt.Errorf("HideMessage() error = %v, want 消息存储不可用", err)
+		}
+	})
+}
+
+func TestMarkReadUpToSkipsRecalledAndHidden(t *testing.T) {
+	mt := newMockMongo(t)
+
+	mt.Run("已撤回和已删除的消息不计入已读数量", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}, bson.E{Key: "nModified", Value: 2}))
+
+		count, err := NewMessageService().MarkReadUpTo("private_1_2", 2, time.Now())
+		if err != nil || count != 2 {
+			mt.Fatalf("MarkReadUpTo() = %d, %v, want 2", count, err)
+		}
+		filter := nextCommand(mt, "update").Lookup("updates").Array().Index(0).Value().Document().Lookup("q").Document()
+		if !filter.Lookup("recalled", "$ne").Boolean() {
+			mt.Error("已读状态更新未排除已撤回的消息")
+		}
+		if hidden := filter.Lookup("hidden_for", "$ne").AsInt64(); hidden != 2 {
+			mt.Errorf("hidden_for $ne = %d, want 2", hidden)
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"go_chat/model"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestGetReplyTarget(t *testing.T) {
+	mt := newMockMongo(t)
+	target := model.ChatMessage{ID: primitive.NewObjectID(), SessionID: "private_1_2", FromUserID: 2, ToUserID: 1, Content: "question", Timestamp: time.Now()}
+	otherSession := target
+	otherSession.SessionID = "private_2_3"
+	hidden := target
+	hidden.HiddenFor = []uint{1}
+	hiddenByPeer := target
+	hiddenByPeer.HiddenFor = []uint{2}
+	recalled := target
+	recalled.Recalled = true
+
+	tests := []struct {
+		name    string
+		stored  *model.ChatMessage
+		wantErr string
+	}{
+		{"同一会话的消息", &target, ""},
+		{"对方删除的消息仍可引用", &hiddenByPeer, ""},
+		{"消息不存在", nil, "引用的消息不存在"},
+		{"其他会话的消息", &otherSession, "引用的消息不存在"},
+		{"自己已删除的消息", &hidden, "引用的消息不存在"},
+		{"已撤回的消息", &recalled, "引用的消息已撤回"},
+	}
+
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			useMockMongo(mt)
+			if tt.stored != nil {
+				mt.AddMockResponses(messageResponse(mt, *tt.stored))
+			} else {
+				mt.AddMockResponses(mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch))
+			}
+
+			message, err := NewMessageService().GetReplyTarget(target.ID.Hex(), 1, "private_1_2")
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					mt.Errorf("GetReplyTarget() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || message.ID != target.ID {
+				mt.Errorf("GetReplyTarget() = %+v, %v, want %s", message, err, target.ID.Hex())
+			}
+		})
+	}
+
+	mt.Run("消息ID格式错误", func(mt *mtest.T) {
+		useMockMongo(mt)
+		if _, err := NewMessageService().GetReplyTarget("bad-id", 1, "private_1_2"); err == nil || err.Error() != "引用的消息不存在" {
+			mt.Errorf("GetReplyTarget() error = %v, want 引用的消息不存在", err)
+		}
+	})
+}
+
+func TestLoadQuotes(t *testing.T) {
+	mt := newMockMongo(t)
+	quoted := model.ChatMessage{ID: primitive.NewObjectID(), FromUserID: 2, Content: "question"}
+	recalled := model.ChatMessage{ID: primitive.NewObjectID(), FromUserID: 1, Recalled: true}
+	messages := []model.ChatMessage{
+		{ID: primitive.NewObjectID(), ReplyTo: quoted.ID},
+		{ID: primitive.NewObjectID()},
+		{ID: primitive.NewObjectID(), ReplyTo: recalled.ID},
+	}
+
+	mt.Run("批量加载引用快照", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch, toBSON(mt, quoted), toBSON(mt, recalled)))
+
+		quotes := NewMessageService().LoadQuotes(messages)
+		if quote := quotes[quoted.ID]; quote == nil || quote.Preview != "question" || quote.FromUserID != 2 {
+			mt.Errorf("引用快照 = %+v, want question", quote)
+		}
+		if quote := quotes[recalled.ID]; quote == nil || !quote.Recalled || quote.Preview != "" {
+			mt.Errorf("已撤回的引用快照 = %+v, want 已撤回且无内容", quote)
+		}
+		ids, _ := nextCommand(mt, "find").Lookup("filter", "_id", "$in").Array().Values()
+		if len(ids) != 2 {
+			mt.Errorf("查询的引用消息 = %v, want 2", ids)
+		}
+	})
+
+	mt.Run("没有引用时不查询", func(mt *mtest.T) {
+		useMockMongo(mt)
+		if quotes := NewMessageService().LoadQuotes(messages[1:2]); len(quotes) != 0 {
+			mt.Errorf("LoadQuotes() = %v, want empty", quotes)
+		}
+		if event := mt.GetStartedEvent(); event != nil {
+			mt.Errorf("没有引用时执行了 %s", event.CommandName)
+		}
+	})
+
+	mt.Run("查询失败不影响消息", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 1, Message: "boom"}))
+
+		if quotes := NewMessageService().LoadQuotes(messages); len(quotes) != 0 {
+			mt.Errorf("LoadQuotes() = %v, want empty", quotes)
+		}
+	})
+}
@@ -0,0 +1,844 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"go_chat/config"
+	"go_chat/global"
+	"go_chat/model"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	messageCollection = "messages"      // 消息集合名
+	mongoTimeout      = 5 * time.Second // MongoDB操作超时
+
+	dedupKeyPrefix = "go_chat:dedup:" // 客户端消息ID去重键前缀
+
+	defaultHistoryLimit = 20  // 历史消息默认每页条数
+	maxHistoryLimit     = 100 // 历史消息每页最大条数
+
+	maxMessageContentBytes = 4096 // 消息内容最大字节数，与WebSocket单条消息大小限制一致
+
+	insertAttempts   = 3                     // 已分配序号的消息最多写入次数
+	insertRetryDelay = 20 * time.Millisecond // 重新写入前的等待时间，按重试次数递增
+)
+
+type MessageService struct{}
+
+// NewMessageService 创建消息服务实例
+func NewMessageService() *MessageService {
+	return &MessageService{}
+}
+
+// SavePrivateMessage 保存私聊消息，成功后回填消息ID和会话ID。
+// 携带客户端消息ID且在去重窗口内已保存过时，不会重复写入，而是用原消息覆盖message并返回true
+func (s *MessageService) SavePrivateMessage(message *model.ChatMessage) (bool, error) {
+	collection, err := messagesCollection()
+	if err != nil {
+		return false, err
+	}
+
+	if message.ClientMsgID != "" {
+		duplicate, err := s.claimClientMsgID(message.FromUserID, message.ClientMsgID)
+		if err != nil {
+			return false, err
+		}
+		if duplicate != nil {
+			*message = *duplicate
+			return true, nil
+		}
+	}
+
+	message.ID = primitive.NewObjectID()
+	message.SessionID = model.PrivateSessionID(message.FromUserID, message.ToUserID)
+	message.Status = model.MessageStatusSent
+
+	// 分配会话内序号，写入失败时重试，仍失败则归还序号或标记为已跳过
+	participants := sortedParticipants(message.FromUserID, message.ToUserID)
+	seq, err := conversationService.NextSeq(message.SessionID, participants)
+	if err != nil {
+		s.releaseClientMsgID(message.FromUserID, message.ClientMsgID)
+		return false, err
+	}
+	message.Seq = seq
+	message.CreatedAt = time.Now()
+	message.UpdatedAt = message.CreatedAt
+
+	if err := s.insertSequenced(collection, message); err != nil {
+		logrus.Errorf("私聊消息保存失败: %v", err)
+		s.releaseClientMsgID(message.FromUserID, message.ClientMsgID)
+		return false, errors.New("消息保存失败")
+	}
+
+	// 更新会话摘要，失败不影响消息发送
+	if err := conversationService.TouchConversation(message); err != nil {
+		logrus.Warnf("会话 %s 摘要更新失败: %v", message.SessionID, err)
+	}
+	return false, nil
+}
+
+// insertSequenced 写入已分配序号的消息，失败时稍后重试，多次失败后才归还或跳过该序号。
+// 前一次写入可能已生效但未收到确认，重试时遇到唯一索引冲突即视为写入成功
+func (s *MessageService) insertSequenced(collection *mongo.Collection, message *model.ChatMessage) error {
+	var err error
+	for attempt := 0; attempt < insertAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * insertRetryDelay)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+		_, err = collection.InsertOne(ctx, message)
+		cancel()
+		if err == nil || (attempt > 0 && mongo.IsDuplicateKeyError(err)) {
+			return nil
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			// 首次写入就冲突说明序号已被占用，重试没有意义
+			break
+		}
+		logrus.Warnf("会话 %s 序号 %d 第 %d 次写入失败: %v", message.SessionID, message.Seq, attempt+1, err)
+	}
+
+	s.releaseSeq(message.SessionID, message.Seq)
+	return err
+}
+
+// releaseSeq 消息写入失败后处理已分配的序号：仍是会话最新序号时归还，否则写入占位记录标记该序号已跳过，
+// 避免按序号同步的客户端一直等待不存在的消息
+func (s *MessageService) releaseSeq(sessionID string, seq int64) {
+	if conversationService.RollbackSeq(sessionID, seq) {
+		return
+	}
+
+	collection, err := messagesCollection()
+	if err != nil {
+		logrus.Errorf("会话 %s 序号 %d 无法标记为跳过: %v", sessionID, seq, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	now := time.Now()
+	placeholder := bson.M{
+		"_id":        primitive.NewObjectID(),
+		"session_id": sessionID,
+		"seq":        seq,
+		"skipped":    true,
+		"timestamp":  now,
+		"created_at": now,
+	}
+	if _, err := collection.InsertOne(ctx, placeholder); err != nil {
+		logrus.Errorf("会话 %s 序号 %d 无法标记为跳过: %v", sessionID, seq, err)
+	}
+}
+
+// claimClientMsgID 检查客户端消息ID是否重复，返回去重窗口内已保存的原消息。
+// Redis可用时先占用该ID，避免并发重试同时写入
+func (s *MessageService) claimClientMsgID(fromUserID uint, clientMsgID string) (*model.ChatMessage, error) {
+	if existing, err := s.findByClientMsgID(fromUserID, clientMsgID); err != nil || existing != nil {
+		return existing, err
+	}
+
+	redisClient := global.GetRedisClient()
+	if redisClient == nil {
+		return nil, nil
+	}
+
+	claimed, err := redisClient.SetNX(dedupKey(fromUserID, clientMsgID), time.Now().Unix(), config.MessageDedupWindow).Result()
+	if err != nil {
+		// Redis异常时退化为仅依赖消息存储去重
+		logrus.Warnf("消息去重占用失败: %v", err)
+		return nil, nil
+	}
+	if claimed {
+		return nil, nil
+	}
+
+	// 已被占用，原消息可能刚刚写入
+	existing, err := s.findByClientMsgID(fromUserID, clientMsgID)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, errors.New("消息正在处理中")
+	}
+	return existing, nil
+}
+
+// releaseClientMsgID 消息保存失败时释放客户端消息ID，允许客户端重试
+func (s *MessageService) releaseClientMsgID(fromUserID uint, clientMsgID string) {
+	redisClient := global.GetRedisClient()
+	if redisClient == nil || clientMsgID == "" {
+		return
+	}
+	redisClient.Del(dedupKey(fromUserID, clientMsgID))
+}
+
+// findByClientMsgID 在去重窗口内按客户端消息ID查找发送者的消息
+func (s *MessageService) findByClientMsgID(fromUserID uint, clientMsgID string) (*model.ChatMessage, error) {
+	collection, err := messagesCollection()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	filter := bson.M{
+		"from_user_id":  fromUserID,
+		"client_msg_id": clientMsgID,
+		"created_at":    bson.M{"$gte": time.Now().Add(-config.MessageDedupWindow)},
+	}
+
+	var message model.ChatMessage
+	err = collection.FindOne(ctx, filter).Decode(&message)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		logrus.Errorf("消息去重查询失败: %v", err)
+		return nil, errors.New("消息保存失败")
+	}
+	return &message, nil
+}
+
+// withoutSkipped 在查询条件中排除已跳过序号的占位记录。占位记录不是真实消息，
+// 除ListAfterSeq需要据此推进同步位置外，读取消息的查询都通过此函数构造条件
+func withoutSkipped(filter bson.M) bson.M {
+	filter["skipped"] = bson.M{"$ne": true}
+	return filter
+}
+
+// dedupKey 客户端消息ID在Redis中的去重键
+func dedupKey(fromUserID uint, clientMsgID string) string {
+	return dedupKeyPrefix + strconv.FormatUint(uint64(fromUserID), 10) + ":" + clientMsgID
+}
+
+// GetMessage 根据消息ID获取消息
+func (s *MessageService) GetMessage(messageID string) (*model.ChatMessage, error) {
+	collection, err := messagesCollection()
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := primitive.ObjectIDFromHex(messageID)
+	if err != nil {
+		return nil, errors.New("消息ID格式错误")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	var message model.ChatMessage
+	if err := collection.FindOne(ctx, withoutSkipped(bson.M{"_id": id})).Decode(&message); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("消息不存在")
+		}
+		logrus.Errorf("查询消息失败: %v", err)
+		return nil, errors.New("查询消息失败")
+	}
+	return &message, nil
+}
+
+// UpdateStatus 推进消息投递状态，返回更新后的消息；状态不能前进时返回nil
+func (s *MessageService) UpdateStatus(messageID, status string) (*model.ChatMessage, error) {
+	collection, err := messagesCollection()
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := primitive.ObjectIDFromHex(messageID)
+	if err != nil {
+		return nil, errors.New("消息ID格式错误")
+	}
+
+	now := time.Now()
+	set := bson.M{"status": status, "updated_at": now}
+	switch status {
+	case model.MessageStatusStored:
+		set["is_offline"] = true
+	case model.MessageStatusDelivered:
+		set["is_offline"] = false
+		set["delivered_at"] = now
+	case model.MessageStatusRead:
+		set["read_at"] = now
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	// 群聊消息按成员分别记录送达，频道消息不跟踪投递，均不使用消息级别的投递状态
+	filter := bson.M{
+		"_id":        id,
+		"group_id":   bson.M{"$exists": false},
+		"channel_id": bson.M{"$exists": false},
+		"status":     bson.M{"$in": model.PreviousMessageStatuses(status)},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var message model.ChatMessage
+	err = collection.FindOneAndUpdate(ctx, filter, bson.M{"$set": set}, opts).Decode(&message)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		logrus.Errorf("消息状态更新失败: %v", err)
+		return nil, errors.New("消息状态更新失败")
+	}
+	return &message, nil
+}
+
+// QuotedMessage 被引用消息的快照，随引用它的消息一起下发
+type QuotedMessage struct {
+	MessageID  string `json:"message_id"`
+	FromUserID uint   `json:"from_user_id"`
+	Preview    string `json:"preview"`            // 截断后的内容，撤回后为空
+	Recalled   bool   `json:"recalled,omitempty"` // 被引用的消息是否已撤回
+}
+
+// NewQuotedMessage 根据被引用的消息生成快照
+func NewQuotedMessage(message *model.ChatMessage) *QuotedMessage {
+	return &QuotedMessage{
+		MessageID:  message.ID.Hex(),
+		FromUserID: message.FromUserID,
+		Preview:    messagePreview(message.Content),
+		Recalled:   message.Recalled,
+	}
+}
+
+// GetReplyTarget 获取发送者要引用的消息，只能引用同一会话中自己可见且未撤回的消息
+func (s *MessageService) GetReplyTarget(messageID string, fromUserID uint, sessionID string) (*model.ChatMessage, error) {
+	message, err := s.GetMessage(messageID)
+	if err != nil {
+		if err.Error() == "消息不存在" || err.Error() == "消息ID格式错误" {
+			return nil, errors.New("引用的消息不存在")
+		}
+		return nil, err
+	}
+
+	// 其他会话的消息和自己已删除的消息均视为不存在
+	if message.SessionID != sessionID {
+		return nil, errors.New("引用的消息不存在")
+	}
+	for _, hiddenFor := range message.HiddenFor {
+		if hiddenFor == fromUserID {
+			return nil, errors.New("引用的消息不存在")
+		}
+	}
+	if message.Recalled {
+		return nil, errors.New("引用的消息已撤回")
+	}
+	return message, nil
+}
+
+// LoadQuotes 批量加载消息所引用的原消息快照，键为被引用的消息ID；查询失败时返回空结果，不影响消息本身
+func (s *MessageService) LoadQuotes(messages []model.ChatMessage) map[primitive.ObjectID]*QuotedMessage {
+	quotes := make(map[primitive.ObjectID]*QuotedMessage)
+
+	var ids []primitive.ObjectID
+	for i := range messages {
+		if !messages[i].ReplyTo.IsZero() {
+			ids = append(ids, messages[i].ReplyTo)
+		}
+	}
+	if len(ids) == 0 {
+		return quotes
+	}
+
+	collection, err := messagesCollection()
+	if err != nil {
+		return quotes
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, withoutSkipped(bson.M{"_id": bson.M{"$in": ids}}))
+	if err != nil {
+		logrus.Warnf("查询引用消息失败: %v", err)
+		return quotes
+	}
+
+	var quoted []model.ChatMessage
+	if err := cursor.All(ctx, &quoted); err != nil {
+		logrus.Warnf("读取引用消息失败: %v", err)
+		return quotes
+	}
+	for i := range quoted {
+		quotes[quoted[i].ID] = NewQuotedMessage(&quoted[i])
+	}
+	return quotes
+}
+
+// EditMessageRequest 编辑消息请求
+type EditMessageRequest struct {
+	Content string `json:"content" binding:"required,max=4096"`
+}
+
+// EditMessage 发送者在编辑时限内修改消息内容，编辑前的内容保存到编辑历史，返回更新后的消息
+func (s *MessageService) EditMessage(userID uint, messageID, content string) (*model.ChatMessage, error) {
+	if strings.TrimSpace(content) == "" {
+		return nil, errors.New("消息内容不能为空")
+	}
+	// 多字节字符可能通过字符数校验，按字节数与发送时的限制保持一致
+	if len(content) > maxMessageContentBytes {
+		return nil, errors.New("消息内容过长")
+	}
+
+	message, err := s.GetMessage(messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.FromUserID != userID {
+		return nil, errors.New("只能编辑自己发送的消息")
+	}
+	if message.Recalled {
+		return nil, errors.New("消息已撤回")
+	}
+	if time.Since(message.Timestamp) > config.MessageEditWindow {
+		return nil, errors.New("已超过可编辑时间")
+	}
+	if message.Content == content {
+		return nil, errors.New("消息内容未变化")
+	}
+
+	collection, err := messagesCollection()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	version := model.MessageVersion{
+		Content:    message.Content,
+		Timestamp:  message.Timestamp,
+		ReplacedAt: now,
+	}
+	// 以最近一次编辑时间作为版本号，防止并发编辑丢失历史版本
+	filter := bson.M{"_id": message.ID, "recalled": bson.M{"$ne": true}, "edited_at": bson.M{"$exists": false}}
+	if message.EditedAt != nil {
+		version.Timestamp = *message.EditedAt
+		filter["edited_at"] = *message.EditedAt
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"content":    content,
+			"edited":     true,
+			"edited_at":  now,
+			"updated_at": now,
+		},
+		"$push": bson.M{"edit_history": version},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	var edited model.ChatMessage
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&edited)
+	if err == mongo.ErrNoDocuments {
+		return nil, errors.New("消息已被修改，请重试")
+	}
+	if err != nil {
+		logrus.Errorf("消息编辑失败: %v", err)
+		return nil, errors.New("消息编辑失败")
+	}
+
+	// 编辑的是会话最后一条消息时同步更新预览，失败不影响编辑结果
+	conversationService.RefreshLastMessage(&edited)
+	return &edited, nil
+}
+
+// RecallMessage 发送者在撤回时限内撤回消息，消息内容和编辑历史被清空，只保留撤回标记
+func (s *MessageService) RecallMessage(userID uint, messageID string) (*model.ChatMessage, error) {
+	message, err := s.GetMessage(messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.FromUserID != userID {
+		return nil, errors.New("只能撤回自己发送的消息")
+	}
+	if message.Recalled {
+		return nil, errors.New("消息已撤回")
+	}
+	if time.Since(message.Timestamp) > config.MessageRecallWindow {
+		return nil, errors.New("已超过可撤回时间")
+	}
+
+	collection, err := messagesCollection()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{"_id": message.ID, "recalled": bson.M{"$ne": true}}
+	update := bson.M{
+		"$set": bson.M{
+			"content":     "",
+			"recalled":    true,
+			"recalled_at": now,
+			"updated_at":  now,
+		},
+		"$unset": bson.M{"edit_history": "", "reactions": ""},
+	}
+
+	var recalled model.ChatMessage
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&recalled)
+	if err == mongo.ErrNoDocuments {
+		return nil, errors.New("消息已撤回")
+	}
+	if err != nil {
+		logrus.Errorf("消息撤回失败: %v", err)
+		return nil, errors.New("消息撤回失败")
+	}
+
+	conversationService.RefreshLastMessage(&recalled)
+	// 接收者尚未读到的消息撤回后不再计入未读数
+	if countsAsUnread(&recalled) {
+		conversationService.DecrUnread(recalled.ToUserID, recalled.FromUserID, 1)
+	}
+	return &recalled, nil
+}
+
+// HideMessage 会话参与者将消息从自己的记录中删除，不影响对方
+func (s *MessageService) HideMessage(userID uint, messageID string) error {
+	message, err := s.GetMessage(messageID)
+	if err != nil {
+		return err
+	}
+	// 非会话参与者同样返回不存在，避免泄露消息ID
+	if !s.isParticipant(message, userID) {
+		return errors.New("消息不存在")
+	}
+
+	collection, err := messagesCollection()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	// 只处理首次删除，返回删除前的消息用于扣除未读数
+	filter := bson.M{"_id": message.ID, "hidden_for": bson.M{"$ne": userID}}
+	update := bson.M{"$addToSet": bson.M{"hidden_for": userID}}
+	var hidden model.ChatMessage
+	err = collection.FindOneAndUpdate(ctx, filter, update).Decode(&hidden)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		logrus.Errorf("消息删除失败: %v", err)
+		return errors.New("消息删除失败")
+	}
+
+	// 删除对方发来的未读消息后不再计入未读数，已撤回的消息在撤回时已经扣除
+	if hidden.ToUserID == userID && !hidden.Recalled && countsAsUnread(&hidden) {
+		conversationService.DecrUnread(userID, hidden.FromUserID, 1)
+	}
+	// 会话列表的预览跳过自己删除的消息，失败不影响删除结果
+	conversationService.HideLastMessage(&hidden, userID)
+	return nil
+}
+
+// countsAsUnread 消息是否计入接收者的未读数，只有接收者尚未读到的私聊消息计入
+func countsAsUnread(message *model.ChatMessage) bool {
+	return message.GroupID == 0 && message.ChannelID == 0 && message.ToUserID != 0 && message.Status != model.MessageStatusRead
+}
+
+// MarkReadUpTo 将会话中发给阅读者且不晚于已读位置的消息标记为已读，返回更新数量
+func (s *MessageService) MarkReadUpTo(sessionID string, readerID uint, timestamp time.Time) (int64, error) {
+	collection, err := messagesCollection()
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{
+		"session_id": sessionID,
+		"to_user_id": readerID,
+		"timestamp":  bson.M{"$lte": timestamp},
+		"status":     bson.M{"$in": model.PreviousMessageStatuses(model.MessageStatusRead)},
+		// 已撤回和阅读者已删除的消息不再计入未读数，也不再推进状态
+		"recalled":   bson.M{"$ne": true},
+		"hidden_for": bson.M{"$ne": readerID},
+	}
+	update := bson.M{"$set": bson.M{
+		"status":     model.MessageStatusRead,
+		"read_at":    now,
+		"updated_at": now,
+	}}
+
+	result, err := collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		logrus.Errorf("消息已读状态更新失败: %v", err)
+		return 0, errors.New("消息状态更新失败")
+	}
+	return result.ModifiedCount, nil
+}
+
+// ListPendingOfflineMessages 按时间从早到晚获取用户尚未补发的离线消息
+func (s *MessageService) ListPendingOfflineMessages(userID uint, limit int64) ([]model.ChatMessage, error) {
+	collection, err := messagesCollection()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	filter := bson.M{
+		"to_user_id": userID,
+		"status":     model.MessageStatusStored,
+		"hidden_for": bson.M{"$ne": userID},
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: 1}, {Key: "_id", Value: 1}}).
+		SetLimit(limit)
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		logrus.Errorf("查询离线消息失败: %v", err)
+		return nil, errors.New("查询离线消息失败")
+	}
+
+	var messages []model.ChatMessage
+	if err := cursor.All(ctx, &messages); err != nil {
+		logrus.Errorf("读取离线消息失败: %v", err)
+		return nil, errors.New("查询离线消息失败")
+	}
+	return messages, nil
+}
+
+// SeqPage 按序号同步的结果
+type SeqPage struct {
+	Messages []model.ChatMessage // 按序号升序排列的消息，不包含已跳过序号的占位记录
+	LastSeq  int64               // 本次读取到的最大序号（包括占位记录），为0表示没有后续记录
+	Scanned  int64               // 本次读取的记录数（包括占位记录），达到上限时可能还有后续消息
+}
+
+// ListAfterSeq 按序号升序获取会话中序号大于afterSeq的消息，不包含查看者已删除的消息。
+// 已跳过序号的占位记录不会出现在结果中，只通过LastSeq推进同步位置
+func (s *MessageService) ListAfterSeq(sessionID string, viewerID uint, afterSeq int64, limit int64) (*SeqPage, error) {
+	collection, err := messagesCollection()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	filter := bson.M{
+		"session_id": sessionID,
+		"seq":        bson.M{"$gt": afterSeq},
+		"hidden_for": bson.M{"$ne": viewerID},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}).SetLimit(limit)
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		logrus.Errorf("同步消息查询失败: %v", err)
+		return nil, errors.New("同步消息查询失败")
+	}
+
+	var records []model.ChatMessage
+	if err := cursor.All(ctx, &records); err != nil {
+		logrus.Errorf("读取同步消息失败: %v", err)
+		return nil, errors.New("同步消息查询失败")
+	}
+
+	page := &SeqPage{Messages: records[:0], Scanned: int64(len(records))}
+	for _, record := range records {
+		page.LastSeq = record.Seq
+		if !record.Skipped {
+			page.Messages = append(page.Messages, record)
+		}
+	}
+	return page, nil
+}
+
+// HistoryPage 历史消息分页结果
+type HistoryPage struct {
+	Messages   []model.ChatMessage
+	NextCursor string // 下一页游标，为空表示没有更多
+}
+
+// ListHistory 按时间倒序分页获取查看者的会话历史消息，before为上一页返回的游标。
+// 查看者已删除的消息不会返回，已撤回的消息以撤回标记的形式保留
+func (s *MessageService) ListHistory(sessionID string, viewerID uint, before string, limit int64) (*HistoryPage, error) {
+	collection, err := messagesCollection()
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 || limit > maxHistoryLimit {
+		limit = defaultHistoryLimit
+	}
+
+	filter := withoutSkipped(bson.M{"session_id": sessionID, "hidden_for": bson.M{"$ne": viewerID}})
+	if before != "" {
+		timestamp, id, err := decodeHistoryCursor(before)
+		if err != nil {
+			return nil, err
+		}
+		filter["$or"] = bson.A{
+			bson.M{"timestamp": bson.M{"$lt": timestamp}},
+			bson.M{"timestamp": timestamp, "_id": bson.M{"$lt": id}},
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	// 多取一条用于判断是否还有下一页
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(limit + 1)
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		logrus.Errorf("查询历史消息失败: %v", err)
+		return nil, errors.New("查询历史消息失败")
+	}
+
+	var messages []model.ChatMessage
+	if err := cursor.All(ctx, &messages); err != nil {
+		logrus.Errorf("读取历史消息失败: %v", err)
+		return nil, errors.New("查询历史消息失败")
+	}
+
+	page := &HistoryPage{Messages: messages}
+	if int64(len(messages)) > limit {
+		page.Messages = messages[:limit]
+		last := page.Messages[limit-1]
+		page.NextCursor = encodeHistoryCursor(last.Timestamp, last.ID)
+	}
+	return page, nil
+}
+
+// encodeHistoryCursor 将最后一条消息的时间和ID编码为不透明游标
+func encodeHistoryCursor(timestamp time.Time, id primitive.ObjectID) string {
+	raw := strconv.FormatInt(timestamp.UnixMilli(), 10) + ":" + id.Hex()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeHistoryCursor 解析历史消息游标
+func decodeHistoryCursor(cursor string) (time.Time, primitive.ObjectID, error) {
+	invalid := errors.New("游标格式错误")
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, primitive.NilObjectID, invalid
+	}
+
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 {
+		return time.Time{}, primitive.NilObjectID, invalid
+	}
+
+	millis, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, primitive.NilObjectID, invalid
+	}
+
+	id, err := primitive.ObjectIDFromHex(parts[1])
+	if err != nil {
+		return time.Time{}, primitive.NilObjectID, invalid
+	}
+	return time.UnixMilli(millis), id, nil
+}
+
+// EnsureMessageIndexes 创建消息集合所需的索引
+func EnsureMessageIndexes() {
+	collection, err := messagesCollection()
+	if err != nil {
+		logrus.Warn("MongoDB未连接，跳过消息索引创建")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{
+			// 客户端消息ID去重
+			Keys: bson.D{{Key: "from_user_id", Value: 1}, {Key: "client_msg_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{
+				"client_msg_id": bson.M{"$exists": true},
+			}),
+		},
+		{
+			// 会话内序号唯一，用于断线同步
+			Keys: bson.D{{Key: "session_id", Value: 1}, {Key: "seq", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+				"seq": bson.M{"$gt": 0},
+			}),
+		},
+		{
+			// 会话历史消息分页
+			Keys: bson.D{{Key: "session_id", Value: 1}, {Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}},
+		},
+		{
+			// 离线消息补发
+			Keys: bson.D{{Key: "to_user_id", Value: 1}, {Key: "status", Value: 1}, {Key: "timestamp", Value: 1}},
+		},
+		{
+			// 群聊离线消息补发
+			Keys: bson.D{{Key: "group_id", Value: 1}, {Key: "timestamp", Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{
+				"group_id": bson.M{"$exists": true},
+			}),
+		},
+	}
+	if _, err := collection.Indexes().CreateMany(ctx, indexes); err != nil {
+		logrus.Errorf("消息索引创建失败: %v", err)
+		return
+	}
+
+	// 会话列表按参与者查询
+	conversations, _ := conversationsCollection()
+	_, err = conversations.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "participants", Value: 1}, {Key: "last_message.timestamp", Value: -1}},
+	})
+	if err != nil {
+		logrus.Errorf("会话索引创建失败: %v", err)
+		return
+	}
+
+	// 待投递已读回执按接收者查询
+	receipts, _ := readReceiptsCollection()
+	_, err = receipts.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "to_user_id", Value: 1}},
+	})
+	if err != nil {
+		logrus.Errorf("已读回执索引创建失败: %v", err)
+		return
+	}
+	logrus.Info("消息索引创建完成")
+}
+
+// messagesCollection 获取消息集合
+func messagesCollection() (*mongo.Collection, error) {
+	client := global.GetMongoDBClient()
+	if client == nil {
+		return nil, errors.New("消息存储不可用")
+	}
+	return client.Database(config.MongoDBName).Collection(messageCollection), nil
+}
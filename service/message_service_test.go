@@ -0,0 +1,353 @@
+package service
+
+import (
+	"go_chat/config"
+	"go_chat/global"
+	"go_chat/model"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// setupDedupWindow 设置测试用的去重窗口，测试结束后恢复
+func setupDedupWindow(t *testing.T) {
+	t.Helper()
+	previous := config.MessageDedupWindow
+	t.Cleanup(func() { config.MessageDedupWindow = previous })
+	config.MessageDedupWindow = 10 * time.Minute
+}
+
+// seqResponse 模拟分配序号后返回的会话文档
+func seqResponse(mt *mtest.T, sessionID string, seq int64) bson.D {
+	return mtest.CreateSuccessResponse(bson.E{Key: "value", Value: toBSON(mt, model.Conversation{SessionID: sessionID, LastSeq: seq})})
+}
+
+func TestSavePrivateMessage(t *testing.T) {
+	mt := newMockMongo(t)
+
+	mt.Run("写入消息并回填", func(mt *mtest.T) {
+		useMockMongo(mt)
+		// 序号分配、消息写入和会话摘要更新
+		mt.AddMockResponses(seqResponse(mt, "private_3_7", 1), mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse())
+
+		message := &model.ChatMessage{Type: "private", FromUserID: 7, ToUserID: 3, Content: "hi", Timestamp: time.Now()}
+		if duplicate, err := NewMessageService().SavePrivateMessage(message); err != nil || duplicate {
+			mt.Fatalf("SavePrivateMessage() = %v, %v, want 新消息", duplicate, err)
+		}
+		if message.ID.IsZero() || message.SessionID != "private_3_7" || message.Seq != 1 || message.CreatedAt.IsZero() {
+			mt.Errorf("message = %+v, want 回填ID、会话ID、序号和创建时间", message)
+		}
+
+		mt.GetStartedEvent()
+		event := mt.GetStartedEvent()
+		if event == nil || event.CommandName != "insert" {
+			mt.Fatalf("started event = %v, want insert", event)
+		}
+		var inserted model.ChatMessage
+		if err := bson.Unmarshal(event.Command.Lookup("documents").Array().Index(0).Value().Document(), &inserted); err != nil {
+			mt.Fatal(err)
+		}
+		if inserted.ID != message.ID || inserted.SessionID != message.SessionID || inserted.Seq != 1 || inserted.Content != "hi" {
+			mt.Errorf("写入的文档 = %+v, want %+v", inserted, message)
+		}
+	})
+
+	mt.Run("写入失败", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(seqResponse(mt, "private_1_2", 1), mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 1, Message: "boom"}))
+
+		_, err := NewMessageService().SavePrivateMessage(&model.ChatMessage{FromUserID: 1, ToUserID: 2})
+		if err == nil || err.Error() != "消息保存失败" {
+			mt.Errorf("SavePrivateMessage() error = %v, want 消息保存失败", err)
+		}
+	})
+
+	mt.Run("消息存储不可用", func(mt *mtest.T) {
+		useMockMongo(mt)
+		global.MongoDBClient = nil
+
+		_, err := NewMessageService().SavePrivateMessage(&model.ChatMessage{FromUserID: 1, ToUserID: 2})
+		if err == nil || err.Error() != "消息存储不可用" {
+			mt.Errorf("SavePrivateMessage() error = %v, want 消息存储不可用", err)
+		}
+	})
+}
+
+func TestSavePrivateMessageDeduplicates(t *testing.T) {
+	setupDedupWindow(t)
+	mt := newMockMongo(t)
+	original := model.ChatMessage{ID: primitive.NewObjectID(), SessionID: "private_1_2", ClientMsgID: "c-1", FromUserID: 1, ToUserID: 2, Content: "hi", Status: model.MessageStatusDelivered, CreatedAt: time.Now()}
+	noMatch := mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch)
+
+	mt.Run("首次发送占用消息ID后写入", func(mt *mtest.T) {
+		useMockMongo(mt)
+		server := setupRedis(mt.T)
+		mt.AddMockResponses(noMatch, seqResponse(mt, "private_1_2", 1), mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse())
+
+		message := &model.ChatMessage{ClientMsgID: "c-1", FromUserID: 1, ToUserID: 2, Content: "hi", Timestamp: time.Now()}
+		duplicate, err := NewMessageService().SavePrivateMessage(message)
+		if err != nil || duplicate {
+			mt.Fatalf("SavePrivateMessage() = %v, %v, want 新消息", duplicate, err)
+		}
+		if !server.Exists(dedupKey(1, "c-1")) {
+			mt.Error("客户端消息ID未占用")
+		}
+	})
+
+	mt.Run("重试时存储中已有原消息", func(mt *mtest.T) {
+		useMockMongo(mt)
+		setupRedis(mt.T)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch, toBSON(mt, original)))
+
+		message := &model.ChatMessage{ClientMsgID: "c-1", FromUserID: 1, ToUserID: 2, Content: "hi again"}
+		duplicate, err := NewMessageService().SavePrivateMessage(message)
+		if err != nil || !duplicate {
+			mt.Fatalf("SavePrivateMessage() = %v, %v, want 重复消息", duplicate, err)
+		}
+		if message.ID != original.ID || message.Content != "hi" || message.Status != model.MessageStatusDelivered {
+			mt.Errorf("message = %+v, want 原消息 %s", message, original.ID.Hex())
+		}
+		for event := mt.GetStartedEvent(); event != nil; event = mt.GetStartedEvent() {
+			if event.CommandName == "insert" {
+				mt.Error("重复消息被再次写入")
+			}
+		}
+	})
+
+	mt.Run("并发重试时原消息刚写入", func(mt *mtest.T) {
+		useMockMongo(mt)
+		server := setupRedis(mt.T)
+		server.Set(dedupKey(1, "c-1"), "1")
+		mt.AddMockResponses(noMatch, mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch, toBSON(mt, original)))
+
+		existing, err := NewMessageService().claimClientMsgID(1, "c-1")
+		if err != nil || existing == nil || existing.ID != original.ID {
+			mt.Errorf("claimClientMsgID() = %+v, %v, want 原消息 %s", existing, err, original.ID.Hex())
+		}
+	})
+
+	mt.Run("并发重试时原消息尚未写入", func(mt *mtest.T) {
+		useMockMongo(mt)
+		server := setupRedis(mt.T)
+		server.Set(dedupKey(1, "c-1"), "1")
+		mt.AddMockResponses(noMatch, noMatch)
+
+		if _, err := NewMessageService().claimClientMsgID(1, "c-1"); err == nil || err.Error() != "消息正在处理中" {
+			mt.Errorf("claimClientMsgID() error = %v, want 消息正在处理中", err)
+		}
+	})
+
+	mt.Run("写入失败时释放消息ID", func(mt *mtest.T) {
+		useMockMongo(mt)
+		server := setupRedis(mt.T)
+		mt.AddMockResponses(noMatch, seqResponse(mt, "private_1_2", 1), mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 1, Message: "boom"}))
+
+		message := &model.ChatMessage{ClientMsgID: "c-1", FromUserID: 1, ToUserID: 2}
+		if _, err := NewMessageService().SavePrivateMessage(message); err == nil || err.Error() != "消息保存失败" {
+			mt.Fatalf("SavePrivateMessage() error = %v, want 消息保存失败", err)
+		}
+		if server.Exists(dedupKey(1, "c-1")) {
+			mt.Error("写入失败后客户端消息ID未释放")
+		}
+	})
+}
+
+func TestSavePrivateMessageSeqFailure(t *testing.T) {
+	setupDedupWindow(t)
+	mt := newMockMongo(t)
+
+	mt.Run("序号分配失败时不写入并释放消息ID", func(mt *mtest.T) {
+		useMockMongo(mt)
+		server := setupRedis(mt.T)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch),
+			mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 1, Message: "boom"}),
+		)
+
+		message := &model.ChatMessage{ClientMsgID: "c-1", FromUserID: 1, ToUserID: 2}
+		if _, err := NewMessageService().SavePrivateMessage(message); err == nil || err.Error() != "消息序号分配失败" {
+			mt.Fatalf("SavePrivateMessage() error = %v, want 消息序号分配失败", err)
+		}
+		if server.Exists(dedupKey(1, "c-1")) {
+			mt.Error("序号分配失败后客户端消息ID未释放")
+		}
+		for event := mt.GetStartedEvent(); event != nil; event = mt.GetStartedEvent() {
+			if event.CommandName == "insert" {
+				mt.Error("序号分配失败后仍写入了消息")
+			}
+		}
+	})
+}
+
+func TestSavePrivateMessageReleasesSeq(t *testing.T) {
+	mt := newMockMongo(t)
+	failed := mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 1, Message: "boom"})
+	// 每次写入都失败，直到用完重试次数
+	insertFailed := make([]bson.D, insertAttempts)
+	for i := range insertFailed {
+		insertFailed[i] = failed
+	}
+	responses := func(seq bson.D, afterInsert ...bson.D) []bson.D {
+		return append(append([]bson.D{seq}, insertFailed...), afterInsert...)
+	}
+
+	mt.Run("序号仍是最新时归还", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(responses(seqResponse(mt, "private_1_2", 5), mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))...)
+
+		if _, err := NewMessageService().SavePrivateMessage(&model.ChatMessage{FromUserID: 1, ToUserID: 2}); err == nil || err.Error() != "消息保存失败" {
+			mt.Fatalf("SavePrivateMessage() error = %v, want 消息保存失败", err)
+		}
+		update := nextCommand(mt, "update").Lookup("updates").Array().Index(0).Value().Document()
+		if seq := update.Lookup("q", "last_seq").AsInt64(); seq != 5 {
+			mt.Errorf("归还条件 last_seq = %d, want 5", seq)
+		}
+		if inc := update.Lookup("u", "$inc", "last_seq").AsInt64(); inc != -1 {
+			mt.Errorf("归还 $inc = %d, want -1", inc)
+		}
+		if event := mt.GetStartedEvent(); event != nil {
+			mt.Errorf("归还成功后不应写入占位记录, got %s", event.CommandName)
+		}
+	})
+
+	mt.Run("后续序号已分配时写入占位记录", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(responses(seqResponse(mt, "private_1_2", 5),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}), mtest.CreateSuccessResponse())...)
+
+		NewMessageService().SavePrivateMessage(&model.ChatMessage{FromUserID: 1, ToUserID: 2})
+		nextCommand(mt, "update")
+		placeholder := nextCommand(mt, "insert").Lookup("documents").Array().Index(0).Value().Document()
+		if placeholder.Lookup("seq").AsInt64() != 5 || !placeholder.Lookup("skipped").Boolean() || placeholder.Lookup("session_id").StringValue() != "private_1_2" {
+			mt.Errorf("占位记录 = %v, want private_1_2 序号5 skipped", placeholder)
+		}
+	})
+
+	mt.Run("会话第一条消息写入失败时删除会话", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(responses(seqResponse(mt, "private_1_2", 1), mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}))...)
+
+		NewMessageService().SavePrivateMessage(&model.ChatMessage{FromUserID: 1, ToUserID: 2})
+		deleted := nextCommand(mt, "delete").Lookup("deletes").Array().Index(0).Value().Document()
+		if id := deleted.Lookup("q", "_id").StringValue(); id != "private_1_2" {
+			mt.Errorf("删除的会话 = %s, want private_1_2", id)
+		}
+		if event := mt.GetStartedEvent(); event != nil {
+			mt.Errorf("删除会话后不应写入占位记录, got %s", event.CommandName)
+		}
+	})
+}
+
+func TestInsertSequencedRetries(t *testing.T) {
+	mt := newMockMongo(t)
+	failed := mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 1, Message: "boom"})
+	duplicate := mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "E11000 duplicate key error"})
+	touched := mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1})
+
+	// countCommands 统计各命令的执行次数
+	countCommands := func(mt *mtest.T) map[string]int {
+		counts := map[string]int{}
+		for event := mt.GetStartedEvent(); event != nil; event = mt.GetStartedEvent() {
+			counts[event.CommandName]++
+		}
+		return counts
+	}
+
+	tests := []struct {
+		name        string
+		afterSeq    []bson.D
+		wantErr     bool
+		wantInserts int
+	}{
+		{"重试后写入成功", []bson.D{failed, mtest.CreateSuccessResponse(), touched}, false, 2},
+		{"重试时主键冲突说明前一次已写入", []bson.D{failed, duplicate, touched}, false, 2},
+		{"首次写入冲突时不重试", []bson.D{duplicate, touched}, true, 1},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			useMockMongo(mt)
+			mt.AddMockResponses(append([]bson.D{seqResponse(mt, "private_1_2", 5)}, tt.afterSeq...)...)
+
+			_, err := NewMessageService().SavePrivateMessage(&model.ChatMessage{FromUserID: 1, ToUserID: 2})
+			if (err != nil) != tt.wantErr {
+				mt.Fatalf("SavePrivateMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			counts := countCommands(mt)
+			if counts["insert"] != tt.wantInserts {
+				mt.Errorf("写入次数 = %d, want %d", counts["insert"], tt.wantInserts)
+			}
+			// 写入成功时更新会话摘要，失败时归还序号，都只执行一次更新
+			if counts["update"] != 1 {
+				mt.Errorf("更新次数 = %d, want 1", counts["update"])
+			}
+		})
+	}
+}
+
+func TestListAfterSeqHidesPlaceholders(t *testing.T) {
+	mt := newMockMongo(t)
+
+	mt.Run("占位记录只推进序号", func(mt *mtest.T) {
+		useMockMongo(mt)
+		records := []model.ChatMessage{
+			{ID: primitive.NewObjectID(), SessionID: "private_1_2", Seq: 2, Content: "a"},
+			{ID: primitive.NewObjectID(), SessionID: "private_1_2", Seq: 3, Skipped: true},
+			{ID: primitive.NewObjectID(), SessionID: "private_1_2", Seq: 4, Content: "b"},
+			{ID: primitive.NewObjectID(), SessionID: "private_1_2", Seq: 5, Skipped: true},
+		}
+		docs := make([]bson.D, 0, len(records))
+		for _, record := range records {
+			docs = append(docs, toBSON(mt, record))
+		}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch, docs...))
+
+		page, err := NewMessageService().ListAfterSeq("private_1_2", 1, 1, 4)
+		if err != nil {
+			mt.Fatalf("ListAfterSeq() error = %v", err)
+		}
+		if len(page.Messages) != 2 || page.Messages[0].Seq != 2 || page.Messages[1].Seq != 4 {
+			mt.Errorf("Messages = %+v, want seq 2, 4", page.Messages)
+		}
+		if page.LastSeq != 5 || page.Scanned != 4 {
+			mt.Errorf("LastSeq = %d, Scanned = %d, want 5, 4", page.LastSeq, page.Scanned)
+		}
+	})
+
+	mt.Run("没有后续记录", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch))
+
+		page, err := NewMessageService().ListAfterSeq("private_1_2", 1, 5, 10)
+		if err != nil || len(page.Messages) != 0 || page.LastSeq != 0 || page.Scanned != 0 {
+			mt.Errorf("ListAfterSeq() = %+v, %v, want 空结果", page, err)
+		}
+	})
+}
+
+func TestQueriesExcludeSkipped(t *testing.T) {
+	mt := newMockMongo(t)
+	id := primitive.NewObjectID()
+
+	tests := []struct {
+		name  string
+		query func()
+	}{
+		{"按ID获取消息", func() { NewMessageService().GetMessage(id.Hex()) }},
+		{"加载引用消息", func() { NewMessageService().LoadQuotes([]model.ChatMessage{{ReplyTo: id}}) }},
+		{"历史消息", func() { NewMessageService().ListHistory("private_1_2", 1, "", 10) }},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			useMockMongo(mt)
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch))
+
+			tt.query()
+			if skipped, ok := nextCommand(mt, "find").Lookup("filter", "skipped", "$ne").BooleanOK(); !ok || !skipped {
+				mt.Error("查询条件未排除已跳过序号的占位记录")
+			}
+		})
+	}
+}
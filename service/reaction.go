@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go_chat/config"
+	"go_chat/model"
+	"sort"
+	"unicode"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxReactionLength 表情的最大字节数，足以容纳组合表情
+const maxReactionLength = 32
+
+const (
+	variationSelectorEmoji = 0xfe0f // 按彩色表情显示前一个字符
+	combiningKeycap        = 0x20e3 // 与前面的数字或#*组成键帽表情
+)
+
+// emojiPictographs 可以作为表情主体的字符，包括补充平面的表情区段以及常用的符号类表情
+var emojiPictographs = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x00a9, Hi: 0x00ae, Stride: 5}, // © ®
+		{Lo: 0x203c, Hi: 0x203c, Stride: 1}, // ‼
+		{Lo: 0x2049, Hi: 0x2049, Stride: 1}, // ⁉
+		{Lo: 0x2122, Hi: 0x2122, Stride: 1}, // ™
+		{Lo: 0x2139, Hi: 0x2139, Stride: 1}, // ℹ
+		{Lo: 0x2194, Hi: 0x21aa, Stride: 1}, // 箭头
+		{Lo: 0x231a, Hi: 0x23ff, Stride: 1}, // ⌚ ⏰ 等
+		{Lo: 0x24c2, Hi: 0x24c2, Stride: 1}, // Ⓜ
+		{Lo: 0x25aa, Hi: 0x25fe, Stride: 1}, // 几何图形
+		{Lo: 0x2600, Hi: 0x27bf, Stride: 1}, // 杂项符号和装饰符号，如☀ ❤ ✅
+		{Lo: 0x2934, Hi: 0x2935, Stride: 1}, // 箭头
+		{Lo: 0x2b05, Hi: 0x2b55, Stride: 1}, // ⬅ ⭐ ⭕ 等
+		{Lo: 0x3030, Hi: 0x3030, Stride: 1}, // 〰
+		{Lo: 0x303d, Hi: 0x303d, Stride: 1}, // 〽
+		{Lo: 0x3297, Hi: 0x3299, Stride: 2}, // ㊗ ㊙
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x1f000, Hi: 0x1faff, Stride: 1}, // 表情、交通、国旗区域指示符等
+	},
+	LatinOffset: 1,
+}
+
+// emojiModifiers 只能跟在表情之后的修饰字符：变体选择符、零宽连接符、键帽组合符和标签字符
+var emojiModifiers = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x200d, Hi: 0x200d, Stride: 1},
+		{Lo: 0x20e3, Hi: 0x20e3, Stride: 1},
+		{Lo: 0xfe0e, Hi: 0xfe0f, Stride: 1},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0xe0020, Hi: 0xe007f, Stride: 1},
+	},
+}
+
+// ReactionSummary 表情回应汇总
+type ReactionSummary struct {
+	Emoji   string `json:"emoji"`
+	Count   int    `json:"count"`
+	UserIDs []uint `json:"user_ids"`
+}
+
+// SummarizeReactions 汇总消息的表情回应，按人数从多到少排列
+func SummarizeReactions(reactions map[string][]uint) []ReactionSummary {
+	if len(reactions) == 0 {
+		return nil
+	}
+
+	summaries := make([]ReactionSummary, 0, len(reactions))
+	for emoji, userIDs := range reactions {
+		if len(userIDs) == 0 {
+			continue
+		}
+		summaries = append(summaries, ReactionSummary{Emoji: emoji, Count: len(userIDs), UserIDs: userIDs})
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Count != summaries[j].Count {
+			return summaries[i].Count > summaries[j].Count
+		}
+		return summaries[i].Emoji < summaries[j].Emoji
+	})
+	return summaries
+}
+
+// AddReaction 用户对消息添加表情回应，返回更新后的消息；已添加过相同表情时不重复添加
+func (s *MessageService) AddReaction(userID uint, messageID, emoji string) (*model.ChatMessage, error) {
+	message, err := s.getReactionTarget(userID, messageID, emoji)
+	if err != nil {
+		return nil, err
+	}
+
+	field := "reactions." + emoji
+	// 同一用户的不同表情数量在同一次更新中校验，避免并发添加超出上限
+	reactedCount := bson.M{"$size": bson.M{"$filter": bson.M{
+		"input": bson.M{"$objectToArray": bson.M{"$ifNull": bson.A{"$reactions", bson.M{}}}},
+		"cond":  bson.M{"$in": bson.A{userID, "$$this.v"}},
+	}}}
+	filter := bson.M{
+		"_id":      message.ID,
+		"recalled": bson.M{"$ne": true},
+		field:      bson.M{"$ne": userID},
+		"$expr":    bson.M{"$lt": bson.A{reactedCount, config.MessageMaxReactions}},
+	}
+	update := bson.M{"$addToSet": bson.M{field: userID}}
+
+	updated, err := s.updateReactions(filter, update)
+	if err != nil {
+		return nil, err
+	}
+	if updated != nil {
+		return updated, nil
+	}
+
+	// 没有更新时区分已添加过和超出上限
+	current, err := s.GetMessage(messageID)
+	if err != nil {
+		return nil, err
+	}
+	if current.Recalled {
+		return nil, errors.New("消息已撤回")
+	}
+	for _, reactedUserID := range current.Reactions[emoji] {
+		if reactedUserID == userID {
+			return current, nil
+		}
+	}
+	return nil, errors.New("表情回应数量已达上限")
+}
+
+// RemoveReaction 用户取消对消息的表情回应，返回更新后的消息
+func (s *MessageService) RemoveReaction(userID uint, messageID, emoji string) (*model.ChatMessage, error) {
+	message, err := s.getReactionTarget(userID, messageID, emoji)
+	if err != nil {
+		return nil, err
+	}
+
+	field := "reactions." + emoji
+	updated, err := s.updateReactions(bson.M{"_id": message.ID}, bson.M{"$pull": bson.M{field: userID}})
+	if err != nil {
+		return nil, err
+	}
+	if updated == nil {
+		return nil, errors.New("消息不存在")
+	}
+
+	// 没有用户的表情直接移除
+	if users, ok := updated.Reactions[emoji]; ok && len(users) == 0 {
+		filter := bson.M{"_id": message.ID, field: bson.M{"$size": 0}}
+		if cleaned, err := s.updateReactions(filter, bson.M{"$unset": bson.M{field: ""}}); err == nil && cleaned != nil {
+			updated = cleaned
+		}
+	}
+	return updated, nil
+}
+
+// getReactionTarget 校验表情并获取用户可以回应的消息：必须是会话参与者且消息未被撤回和删除
+func (s *MessageService) getReactionTarget(userID uint, messageID, emoji string) (*model.ChatMessage, error) {
+	if !validReaction(emoji) {
+		return nil, errors.New("表情格式错误")
+	}
+
+	message, err := s.GetMessage(messageID)
+	if err != nil {
+		return nil, err
+	}
+	if !s.isParticipant(message, userID) {
+		return nil, errors.New("消息不存在")
+	}
+	for _, hiddenFor := range message.HiddenFor {
+		if hiddenFor == userID {
+			return nil, errors.New("消息不存在")
+		}
+	}
+	if message.Recalled {
+		return nil, errors.New("消息已撤回")
+	}
+	return message, nil
+}
+
+// updateReactions 更新表情回应并返回更新后的消息，没有匹配的消息时返回nil
+func (s *MessageService) updateReactions(filter, update bson.M) (*model.ChatMessage, error) {
+	collection, err := messagesCollection()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	var message model.ChatMessage
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&message)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		logrus.Errorf("表情回应更新失败: %v", err)
+		return nil, errors.New("表情回应更新失败")
+	}
+	return &message, nil
+}
+
+// validReaction 表情不能为空或过长，且只能由表情字符组成：单个表情或带肤色、变体选择符的表情，
+// 零宽连接的组合表情、国旗和数字键帽。文字和":+1:"一类的短代码都不接受
+func validReaction(emoji string) bool {
+	if emoji == "" || len(emoji) > maxReactionLength {
+		return false
+	}
+
+	runes := []rune(emoji)
+	for i, r := range runes {
+		switch {
+		case unicode.Is(emojiPictographs, r):
+		case isKeycapBase(r):
+			// 数字和#*只能作为键帽表情的第一个字符，例如"1️⃣"
+			next := runes[i+1:]
+			if len(next) > 0 && next[0] == variationSelectorEmoji {
+				next = next[1:]
+			}
+			if len(next) == 0 || next[0] != combiningKeycap {
+				return false
+			}
+		case unicode.Is(emojiModifiers, r):
+			// 修饰字符必须跟在表情之后
+			if i == 0 {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isKeycapBase 判断字符能否作为键帽表情的底字
+func isKeycapBase(r rune) bool {
+	return r >= '0' && r <= '9' || r == '#' || r == '*'
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"go_chat/config"
+	"go_chat/model"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestValidReaction(t *testing.T) {
+	tests := []struct {
+		name  string
+		emoji string
+		want  bool
+	}{
+		{"单个表情", "👍", true},
+		{"带变体选择符的表情", "❤️", true},
+		{"肤色组合表情", "👍🏽", true},
+		{"家庭组合表情", "👨‍👩‍👧‍👦", true},
+		{"国旗", "🇨🇳", true},
+		{"数字键帽", "1️⃣", true},
+		{"不带变体选择符的键帽", "#⃣", true},
+		{"恰好达到长度上限", strings.Repeat("👍", maxReactionLength/4), true},
+		{"空字符串", "", false},
+		{"超过长度上限", strings.Repeat("👍", maxReactionLength/4+1), false},
+		{"文字", "lol", false},
+		{"短代码", ":+1:", false},
+		{"单独的数字", "1", false},
+		{"表情后跟文字", "👍ok", false},
+		{"以零宽连接符开头", "\u200d👍", false},
+		{"单独的变体选择符", "\ufe0f", false},
+		{"包含点号", "👍.👍", false},
+		{"包含美元符号", "$set", false},
+		{"包含空格", "👍 👍", false},
+		{"包含换行", "👍\n", false},
+		{"包含控制字符", "\x00", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validReaction(tt.emoji); got != tt.want {
+				t.Errorf("validReaction(%q) = %v, want %v", tt.emoji, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeReactions(t *testing.T) {
+	tests := []struct {
+		name      string
+		reactions map[string][]uint
+		want      []ReactionSummary
+	}{
+		{"没有回应", nil, nil},
+		{
+			"按人数从多到少排列",
+			map[string][]uint{"👍": {1}, "❤️": {1, 2, 3}, "😂": {2, 3}},
+			[]ReactionSummary{
+				{Emoji: "❤️", Count: 3, UserIDs: []uint{1, 2, 3}},
+				{Emoji: "😂", Count: 2, UserIDs: []uint{2, 3}},
+				{Emoji: "👍", Count: 1, UserIDs: []uint{1}},
+			},
+		},
+		{
+			"人数相同时按表情排列",
+			map[string][]uint{"b": {1}, "a": {2}},
+			[]ReactionSummary{
+				{Emoji: "a", Count: 1, UserIDs: []uint{2}},
+				{Emoji: "b", Count: 1, UserIDs: []uint{1}},
+			},
+		},
+		{
+			"忽略已无人回应的表情",
+			map[string][]uint{"👍": {}, "😂": {4}},
+			[]ReactionSummary{{Emoji: "😂", Count: 1, UserIDs: []uint{4}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SummarizeReactions(tt.reactions); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SummarizeReactions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// reactionResponse 模拟表情回应更新后返回的消息，message为nil表示没有匹配的消息
+func reactionResponse(mt *mtest.T, message *model.ChatMessage) bson.D {
+	if message == nil {
+		return mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil})
+	}
+	return mtest.CreateSuccessResponse(bson.E{Key: "value", Value: toBSON(mt, *message)})
+}
+
+func TestAddReaction(t *testing.T) {
+	previous := config.MessageMaxReactions
+	t.Cleanup(func() { config.MessageMaxReactions = previous })
+	config.MessageMaxReactions = 3
+
+	mt := newMockMongo(t)
+	message := model.ChatMessage{ID: primitive.NewObjectID(), SessionID: "private_1_2", FromUserID: 1, ToUserID: 2, Content: "hi", Timestamp: time.Now()}
+	reacted := message
+	reacted.Reactions = map[string][]uint{"👍": {2}}
+
+	mt.Run("添加表情", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(messageResponse(mt, message), reactionResponse(mt, &reacted))
+
+		updated, err := NewMessageService().AddReaction(2, message.ID.Hex(), "👍")
+		if err != nil || len(updated.Reactions["👍"]) != 1 {
+			mt.Fatalf("AddReaction() = %+v, %v", updated, err)
+		}
+		command := nextCommand(mt, "findAndModify")
+		if user := command.Lookup("update", "$addToSet", "reactions.👍").AsInt64(); user != 2 {
+			mt.Errorf("$addToSet = %d, want 2", user)
+		}
+		if limit := command.Lookup("query", "$expr", "$lt").Array().Index(1).Value().AsInt64(); limit != 3 {
+			mt.Errorf("回应数量上限 = %d, want 3", limit)
+		}
+	})
+
+	mt.Run("重复添加返回当前消息", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(messageResponse(mt, reacted), reactionResponse(mt, nil), messageResponse(mt, reacted))
+
+		updated, err := NewMessageService().AddReaction(2, message.ID.Hex(), "👍")
+		if err != nil || updated.ID != message.ID {
+			mt.Errorf("AddReaction() = %+v, %v, want 当前消息", updated, err)
+		}
+	})
+
+	mt.Run("超出上限", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(messageResponse(mt, message), reactionResponse(mt, nil), messageResponse(mt, message))
+
+		if _, err := NewMessageService().AddReaction(2, message.ID.Hex(), "😂"); err == nil || err.Error() != "表情回应数量已达上限" {
+			mt.Errorf("AddReaction() error = %v, want 表情回应数量已达上限", err)
+		}
+	})
+
+	hidden := message
+	hidden.HiddenFor = []uint{2}
+	recalled := message
+	recalled.Recalled = true
+	rejects := []struct {
+		name    string
+		userID  uint
+		emoji   string
+		stored  *model.ChatMessage
+		wantErr string
+	}{
+		{"表情格式错误", 2, "lol", nil, "表情格式错误"},
+		{"非会话参与者", 3, "👍", &message, "消息不存在"},
+		{"自己已删除的消息", 2, "👍", &hidden, "消息不存在"},
+		{"已撤回的消息", 2, "👍", &recalled, "消息已撤回"},
+	}
+	for _, tt := range rejects {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			useMockMongo(mt)
+			if tt.stored != nil {
+				mt.AddMockResponses(messageResponse(mt, *tt.stored))
+			}
+			if _, err := NewMessageService().AddReaction(tt.userID, message.ID.Hex(), tt.emoji); err == nil || err.Error() != tt.wantErr {
+				mt.Errorf("AddReaction() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRemoveReaction(t *testing.T) {
+	mt := newMockMongo(t)
+	message := model.ChatMessage{ID: primitive.NewObjectID(), SessionID: "private_1_2", FromUserID: 1, ToUserID: 2, Timestamp: time.Now(), Reactions: map[string][]uint{"👍": {2}}}
+
+	mt.Run("最后一个用户取消后移除表情", func(mt *mtest.T) {
+		useMockMongo(mt)
+		emptied := message
+		emptied.Reactions = map[string][]uint{"👍": {}}
+		cleaned := message
+		cleaned.Reactions = nil
+		mt.AddMockResponses(messageResponse(mt, message), reactionResponse(mt, &emptied), reactionResponse(mt, &cleaned))
+
+		updated, err := NewMessageService().RemoveReaction(2, message.ID.Hex(), "👍")
+		if err != nil || len(updated.Reactions) != 0 {
+			mt.Fatalf("RemoveReaction() = %+v, %v, want 没有表情", updated, err)
+		}
+		nextCommand(mt, "findAndModify")
+		cleanup := nextCommand(mt, "findAndModify")
+		if _, ok := cleanup.Lookup("update", "$unset", "reactions.👍").StringValueOK(); !ok {
+			mt.Error("没有用户的表情未被移除")
+		}
+	})
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go_chat/global"
+	"go_chat/model"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jinzhu/gorm"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultRoomLimit   = 20  // 房间列表默认条数
+	maxRoomLimit       = 100 // 房间列表最大条数
+	maxRoomTopicLength = 200 // 房间主题最大字符数
+)
+
+// CreateRoomRequest 创建公开房间请求
+type CreateRoomRequest struct {
+	Name  string `json:"name" binding:"required"`
+	Topic string `json:"topic"`
+}
+
+// RoomResponse 公开房间信息
+type RoomResponse struct {
+	*GroupResponse
+	Joined bool `json:"joined"` // 当前用户是否已加入
+}
+
+// CreateRoom 创建公开房间，创建者成为群主
+func (s *GroupService) CreateRoom(ownerID uint, req CreateRoomRequest) (*RoomResponse, error) {
+	name, err := normalizeGroupName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	topic := strings.TrimSpace(req.Topic)
+	if utf8.RuneCountInString(topic) > maxRoomTopicLength {
+		return nil, errors.New("房间主题过长")
+	}
+
+	db := global.GetMySQLClient()
+	if db == nil {
+		return nil, errors.New("数据库连接不可用")
+	}
+
+	room := model.Group{Name: name, Topic: topic, Public: true, CreatorID: ownerID}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&room).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.GroupMember{GroupID: room.ID, UserID: ownerID, Role: model.GroupRoleOwner}).Error
+	})
+	if err != nil {
+		logrus.Error("房间创建失败:", err)
+		return nil, errors.New("房间创建失败")
+	}
+
+	logrus.Infof("用户 %d 创建了公开房间 %d", ownerID, room.ID)
+	return &RoomResponse{GroupResponse: newGroupResponse(&room, 1, model.GroupRoleOwner), Joined: true}, nil
+}
+
+// ListRooms 按名称或主题搜索公开房间，keyword为空时返回最新创建的房间
+func (s *GroupService) ListRooms(userID uint, keyword string, limit int) ([]RoomResponse, error) {
+	db := global.GetMySQLClient()
+	if db == nil {
+		return nil, errors.New("数据库连接不可用")
+	}
+
+	if limit <= 0 || limit > maxRoomLimit {
+		limit = defaultRoomLimit
+	}
+
+	query := db.Where("public = ?", true)
+	if keyword = strings.TrimSpace(keyword); keyword != "" {
+		pattern := "%" + escapeLike(keyword) + "%"
+		query = query.Where("name LIKE ? OR topic LIKE ?", pattern, pattern)
+	}
+
+	var rooms []model.Group
+	if err := query.Order("id DESC").Limit(limit).Find(&rooms).Error; err != nil {
+		logrus.Error("查询房间列表失败:", err)
+		return nil, errors.New("查询房间失败")
+	}
+	if len(rooms) == 0 {
+		return []RoomResponse{}, nil
+	}
+
+	roomIDs := make([]uint, 0, len(rooms))
+	for _, room := range rooms {
+		roomIDs = append(roomIDs, room.ID)
+	}
+	counts, err := s.countMembers(roomIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var memberships []model.GroupMember
+	if err := db.Where("user_id = ? AND group_id IN (?)", userID, roomIDs).Find(&memberships).Error; err != nil {
+		logrus.Error("查询房间成员失败:", err)
+		return nil, errors.New("查询房间失败")
+	}
+	roles := make(map[uint]string, len(memberships))
+	for _, membership := range memberships {
+		roles[membership.GroupID] = membership.Role
+	}
+
+	result := make([]RoomResponse, 0, len(rooms))
+	for i := range rooms {
+		role, joined := roles[rooms[i].ID]
+		result = append(result, RoomResponse{
+			GroupResponse: newGroupResponse(&rooms[i], counts[rooms[i].ID], role),
+			Joined:        joined,
+		})
+	}
+	return result, nil
+}
+
+// GetRoom 获取公开房间，非公开的群组同样返回房间不存在
+func (s *GroupService) GetRoom(roomID uint) (*model.Group, error) {
+	room, err := s.GetGroup(roomID)
+	if err != nil {
+		if err.Error() == "群组不存在" {
+			return nil, errors.New("房间不存在")
+		}
+		return nil, err
+	}
+	if !room.Public {
+		return nil, errors.New("房间不存在")
+	}
+	return room, nil
+}
+
+// JoinRoom 加入公开房间，已加入时返回false
+func (s *GroupService) JoinRoom(userID, roomID uint) (bool, error) {
+	if _, err := s.GetRoom(roomID); err != nil {
+		return false, err
+	}
+	if s.IsMember(roomID, userID) {
+		return false, nil
+	}
+	if err := s.checkNotBanned(roomID, userID); err != nil {
+		return false, err
+	}
+
+	db := global.GetMySQLClient()
+	member := model.GroupMember{GroupID: roomID, UserID: userID, Role: model.GroupRoleMember}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := reserveCapacity(tx, roomID, 1); err != nil {
+			return err
+		}
+		return tx.Create(&member).Error
+	})
+	if err == errGroupFull {
+		return false, err
+	}
+	if err != nil {
+		// 并发加入时唯一索引冲突，视为已加入
+		if s.IsMember(roomID, userID) {
+			return false, nil
+		}
+		logrus.Error("加入房间失败:", err)
+		return false, errors.New("加入房间失败")
+	}
+	return true, nil
+}
+
+// LeaveRoom 退出公开房间，规则与退出群组相同，返回新群主ID
+func (s *GroupService) LeaveRoom(userID, roomID uint) (uint, error) {
+	if _, err := s.GetRoom(roomID); err != nil {
+		return 0, err
+	}
+	return s.LeaveGroup(userID, roomID)
+}
+
+// ListRoomMemberIDs 获取公开房间全部成员的用户ID
+func (s *GroupService) ListRoomMemberIDs(roomID uint) ([]uint, error) {
+	if _, err := s.GetRoom(roomID); err != nil {
+		return nil, err
+	}
+	return s.ListMemberIDs(roomID)
+}
+
+// ListRoomPeers 获取用户所在的各公开房间的成员，键为房间ID，用于推送房间内的在线状态
+func (s *GroupService) ListRoomPeers(userID uint) (map[uint][]uint, error) {
+	db := global.GetMySQLClient()
+	if db == nil {
+		return nil, errors.New("数据库连接不可用")
+	}
+
+	// 表名按全局命名规则生成；group是MySQL保留字，需要加反引号
+	members := db.NewScope(&model.GroupMember{}).TableName()
+	groups := db.NewScope(&model.Group{}).TableName()
+	var rows []model.GroupMember
+	err := db.Table(members).
+		Select(fmt.Sprintf("`%s`.group_id, `%s`.user_id", members, members)).
+		Joins(fmt.Sprintf("JOIN `%s` AS mine ON mine.group_id = `%s`.group_id AND mine.user_id = ?", members, members), userID).
+		Joins(fmt.Sprintf("JOIN `%s` AS g ON g.id = `%s`.group_id AND g.public = ? AND g.deleted_at IS NULL", groups, members), true).
+		Scan(&rows).Error
+	if err != nil {
+		logrus.Error("查询房间成员失败:", err)
+		return nil, errors.New("查询房间失败")
+	}
+
+	peers := make(map[uint][]uint)
+	for _, row := range rows {
+		peers[row.GroupID] = append(peers[row.GroupID], row.UserID)
+	}
+	return peers, nil
+}
+
+// escapeLike 转义LIKE查询中的通配符
+func escapeLike(keyword string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(keyword)
+}
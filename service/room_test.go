@@ -0,0 +1,125 @@
+package service
+
+import (
+	"go_chat/config"
+	"go_chat/model"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCreateRoom(t *testing.T) {
+	users := setupGroups(t, 1)
+
+	room, err := groupService.CreateRoom(users[0], CreateRoomRequest{Name: " 闲聊 ", Topic: " 随便聊聊 "})
+	if err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+	if room.Name != "闲聊" || room.Topic != "随便聊聊" || !room.Public || !room.Joined || room.Role != model.GroupRoleOwner || room.MemberCount != 1 {
+		t.Errorf("CreateRoom() = %+v", room.GroupResponse)
+	}
+
+	if _, err := groupService.CreateRoom(users[0], CreateRoomRequest{Name: "r", Topic: strings.Repeat("长", maxRoomTopicLength+1)}); err == nil || err.Error() != "房间主题过长" {
+		t.Errorf("CreateRoom() error = %v, want 房间主题过长", err)
+	}
+}
+
+func TestListRooms(t *testing.T) {
+	users := setupGroups(t, 2)
+	golang, _ := groupService.CreateRoom(users[0], CreateRoomRequest{Name: "Go语言", Topic: "并发"})
+	music, _ := groupService.CreateRoom(users[0], CreateRoomRequest{Name: "音乐", Topic: "go起来"})
+	if _, err := groupService.CreateGroup(users[0], CreateGroupRequest{Name: "Go私有群"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := groupService.JoinRoom(users[1], golang.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		keyword string
+		limit   int
+		want    []uint
+	}{
+		{"最新创建的在前，不含私有群组", "", 0, []uint{music.ID, golang.ID}},
+		{"按名称或主题搜索", "go", 0, []uint{music.ID, golang.ID}},
+		{"按主题搜索", "并发", 0, []uint{golang.ID}},
+		{"限制条数", "", 1, []uint{music.ID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rooms, err := groupService.ListRooms(users[1], tt.keyword, tt.limit)
+			if err != nil {
+				t.Fatalf("ListRooms() error = %v", err)
+			}
+			var got []uint
+			for _, room := range rooms {
+				got = append(got, room.ID)
+				if joined := room.ID == golang.ID; room.Joined != joined {
+					t.Errorf("房间 %d Joined = %v, want %v", room.ID, room.Joined, joined)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ListRooms() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoinRoom(t *testing.T) {
+	users := setupGroups(t, 3)
+	room, _ := groupService.CreateRoom(users[0], CreateRoomRequest{Name: "r"})
+	group, _ := groupService.CreateGroup(users[0], CreateGroupRequest{Name: "g"})
+
+	if joined, err := groupService.JoinRoom(users[1], room.ID); err != nil || !joined {
+		t.Fatalf("JoinRoom() = %v, %v, want true", joined, err)
+	}
+	if joined, err := groupService.JoinRoom(users[1], room.ID); err != nil || joined {
+		t.Errorf("重复加入 = %v, %v, want false", joined, err)
+	}
+	if _, err := groupService.JoinRoom(users[1], group.ID); err == nil || err.Error() != "房间不存在" {
+		t.Errorf("加入私有群组 error = %v, want 房间不存在", err)
+	}
+	config.GroupMaxMembers = 2
+	if _, err := groupService.JoinRoom(users[2], room.ID); err == nil || err.Error() != "群成员数量超过限制" {
+		t.Errorf("房间已满 error = %v, want 群成员数量超过限制", err)
+	}
+	if _, err := groupService.LeaveRoom(users[0], group.ID); err == nil || err.Error() != "房间不存在" {
+		t.Errorf("通过房间接口退出私有群组 error = %v, want 房间不存在", err)
+	}
+}
+
+func TestListRoomPeers(t *testing.T) {
+	users := setupGroups(t, 4)
+	first, _ := groupService.CreateRoom(users[0], CreateRoomRequest{Name: "first"})
+	second, _ := groupService.CreateRoom(users[1], CreateRoomRequest{Name: "second"})
+	other, _ := groupService.CreateRoom(users[2], CreateRoomRequest{Name: "other"})
+	groupService.JoinRoom(users[1], first.ID)
+	groupService.JoinRoom(users[0], second.ID)
+	groupService.JoinRoom(users[3], other.ID)
+	// 私有群组的成员不属于房间成员
+	if _, err := groupService.CreateGroup(users[0], CreateGroupRequest{Name: "g", MemberIDs: []uint{users[3]}}); err != nil {
+		t.Fatal(err)
+	}
+
+	peers, err := groupService.ListRoomPeers(users[0])
+	if err != nil {
+		t.Fatalf("ListRoomPeers() error = %v", err)
+	}
+	if len(peers) != 2 {
+		t.Fatalf("ListRoomPeers() = %v, want 2 个房间", peers)
+	}
+	for _, roomID := range []uint{first.ID, second.ID} {
+		members := peers[roomID]
+		slices.Sort(members)
+		if !slices.Equal(members, []uint{users[0], users[1]}) {
+			t.Errorf("房间 %d 成员 = %v, want %v", roomID, members, users[:2])
+		}
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	if got := escapeLike(`50%_off\`); got != `50\%\_off\\` {
+		t.Errorf("escapeLike() = %s", got)
+	}
+}
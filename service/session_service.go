@@ -0,0 +1,191 @@
+package service
+
+import (
+	"errors"
+	"go_chat/global"
+	"go_chat/model"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// sessionTouchInterval 会话最后使用时间的最小更新间隔，避免每次请求都写库
+const sessionTouchInterval = time.Minute
+
+type SessionService struct{}
+
+// SessionResponse 会话响应结构
+type SessionResponse struct {
+	ID         uint      `json:"id"`
+	DeviceName string    `json:"device_name"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"` // 是否为当前请求所在的会话
+}
+
+// NewSessionService 创建会话服务实例
+func NewSessionService() *SessionService {
+	return &SessionService{}
+}
+
+var sessionService = NewSessionService()
+
+// CreateSession 登录成功后记录会话
+func (s *SessionService) CreateSession(userID uint, sessionID string, req LoginRequest, expiresAt time.Time) error {
+	db := global.GetMySQLClient()
+	if db == nil {
+		return errors.New("数据库连接不可用")
+	}
+
+	deviceName := req.DeviceName
+	if deviceName == "" {
+		deviceName = "未知设备"
+	}
+
+	session := model.UserSession{
+		SessionID:  sessionID,
+		UserID:     userID,
+		DeviceName: deviceName,
+		UserAgent:  req.UserAgent,
+		IP:         req.IP,
+		LastUsedAt: time.Now(),
+		ExpiresAt:  expiresAt,
+	}
+
+	if err := db.Create(&session).Error; err != nil {
+		logrus.Error("会话记录创建失败:", err)
+		return errors.New("会话创建失败")
+	}
+	return nil
+}
+
+// TouchSession 校验会话有效并更新最后使用时间
+func (s *SessionService) TouchSession(sessionID string) error {
+	session, err := s.getActiveSession(sessionID)
+	if err != nil {
+		return err
+	}
+
+	if time.Since(session.LastUsedAt) >= sessionTouchInterval {
+		db := global.GetMySQLClient()
+		if err := db.Model(session).Update("last_used_at", time.Now()).Error; err != nil {
+			logrus.Warnf("会话最后使用时间更新失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// ExtendSession 刷新令牌后延长会话有效期
+func (s *SessionService) ExtendSession(sessionID string, expiresAt time.Time) error {
+	session, err := s.getActiveSession(sessionID)
+	if err != nil {
+		return err
+	}
+
+	db := global.GetMySQLClient()
+	return db.Model(session).Updates(map[string]interface{}{
+		"last_used_at": time.Now(),
+		"expires_at":   expiresAt,
+	}).Error
+}
+
+// ListSessions 获取用户所有有效会话
+func (s *SessionService) ListSessions(userID uint, currentSessionID string) ([]SessionResponse, error) {
+	db := global.GetMySQLClient()
+	if db == nil {
+		return nil, errors.New("数据库连接不可用")
+	}
+
+	var sessions []model.UserSession
+	if err := db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at desc").Find(&sessions).Error; err != nil {
+		logrus.Error("查询会话列表失败:", err)
+		return nil, errors.New("查询会话列表失败")
+	}
+
+	result := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, SessionResponse{
+			ID:         session.ID,
+			DeviceName: session.DeviceName,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.SessionID == currentSessionID,
+		})
+	}
+	return result, nil
+}
+
+// RevokeSession 注销用户的指定会话，返回被注销会话的会话ID
+func (s *SessionService) RevokeSession(userID, id uint) (string, error) {
+	db := global.GetMySQLClient()
+	if db == nil {
+		return "", errors.New("数据库连接不可用")
+	}
+
+	// 只能注销自己的会话
+	var session model.UserSession
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&session).Error; err != nil {
+		return "", errors.New("会话不存在")
+	}
+
+	if err := s.revoke(&session); err != nil {
+		return "", err
+	}
+	return session.SessionID, nil
+}
+
+// RevokeSessionByID 根据会话ID注销会话
+func (s *SessionService) RevokeSessionByID(sessionID string) error {
+	db := global.GetMySQLClient()
+	if db == nil {
+		return errors.New("数据库连接不可用")
+	}
+
+	var session model.UserSession
+	if err := db.Where("session_id = ?", sessionID).First(&session).Error; err != nil {
+		return errors.New("会话不存在")
+	}
+	return s.revoke(&session)
+}
+
+// revoke 标记会话为已注销
+func (s *SessionService) revoke(session *model.UserSession) error {
+	if session.RevokedAt != nil {
+		return nil
+	}
+
+	db := global.GetMySQLClient()
+	now := time.Now()
+	if err := db.Model(session).Update("revoked_at", &now).Error; err != nil {
+		logrus.Error("会话注销失败:", err)
+		return errors.New("会话注销失败")
+	}
+
+	logrus.Infof("用户 %d 的会话 %d 已注销", session.UserID, session.ID)
+	return nil
+}
+
+// getActiveSession 查询有效会话
+func (s *SessionService) getActiveSession(sessionID string) (*model.UserSession, error) {
+	db := global.GetMySQLClient()
+	if db == nil {
+		return nil, errors.New("数据库连接不可用")
+	}
+
+	var session model.UserSession
+	if err := db.Where("session_id = ?", sessionID).First(&session).Error; err != nil {
+		return nil, errors.New("会话已失效")
+	}
+
+	if !session.IsActive() {
+		return nil, errors.New("会话已失效")
+	}
+	return &session, nil
+}
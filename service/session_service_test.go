@@ -0,0 +1,163 @@
+package service
+
+import (
+	"go_chat/global"
+	"go_chat/model"
+	"testing"
+	"time"
+)
+
+func TestLoginCreatesSession(t *testing.T) {
+	user := setupAuthService(t)
+	if err := user.SetPassword("secret123"); err != nil {
+		t.Fatal(err)
+	}
+	db := global.MySQLClient
+	db.Save(user)
+
+	tests := []struct {
+		name       string
+		deviceName string
+		wantDevice string
+	}{
+		{"指定设备名称", "我的笔记本", "我的笔记本"},
+		{"未指定设备名称", "", "未知设备"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := NewAuthService().Login(LoginRequest{
+				UserName:   "alice",
+				Password:   "secret123",
+				DeviceName: tt.deviceName,
+				UserAgent:  "test-agent",
+				IP:         "127.0.0.1",
+			})
+			if err != nil {
+				t.Fatalf("Login() error = %v", err)
+			}
+
+			claims, err := ParseAccessToken(resp.AccessToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var session model.UserSession
+			if err := db.Where("session_id = ?", claims.SessionID).First(&session).Error; err != nil {
+				t.Fatalf("登录会话未记录: %v", err)
+			}
+			if session.UserID != user.ID || session.DeviceName != tt.wantDevice || session.UserAgent != "test-agent" || session.IP != "127.0.0.1" {
+				t.Errorf("session = %+v", session)
+			}
+			if !session.ExpiresAt.Equal(resp.RefreshExpiresAt) {
+				t.Errorf("会话有效期 = %v, want 与刷新令牌一致 %v", session.ExpiresAt, resp.RefreshExpiresAt)
+			}
+		})
+	}
+
+	if _, err := NewAuthService().Login(LoginRequest{UserName: "alice", Password: "wrong"}); err == nil || err.Error() != "用户名或密码错误" {
+		t.Errorf("密码错误 error = %v, want 用户名或密码错误", err)
+	}
+}
+
+func TestListSessions(t *testing.T) {
+	user := setupAuthService(t)
+	db := global.MySQLClient
+
+	loginTokens(t, user.ID, "current")
+	loginTokens(t, user.ID, "other")
+	loginTokens(t, user.ID+1, "someone-else")
+	loginTokens(t, user.ID, "revoked")
+	if err := sessionService.RevokeSessionByID("revoked"); err != nil {
+		t.Fatal(err)
+	}
+	db.Create(&model.UserSession{SessionID: "expired", UserID: user.ID, ExpiresAt: time.Now().Add(-time.Minute)})
+
+	sessions, err := sessionService.ListSessions(user.ID, "current")
+	if err != nil {
+		t.Fatalf("ListSessions() error = %v", err)
+	}
+	current := map[bool]int{}
+	for _, session := range sessions {
+		current[session.Current]++
+	}
+	if len(sessions) != 2 || current[true] != 1 || current[false] != 1 {
+		t.Errorf("ListSessions() = %+v, want 当前会话和另一个有效会话", sessions)
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	user := setupAuthService(t)
+	db := global.MySQLClient
+	auth := NewAuthService()
+
+	tokens := loginTokens(t, user.ID, "session-1")
+	loginTokens(t, user.ID+1, "session-2")
+	var own, others model.UserSession
+	db.Where("session_id = ?", "session-1").First(&own)
+	db.Where("session_id = ?", "session-2").First(&others)
+
+	if _, err := sessionService.RevokeSession(user.ID, others.ID); err == nil || err.Error() != "会话不存在" {
+		t.Errorf("注销其他用户的会话 error = %v, want 会话不存在", err)
+	}
+
+	sessionID, err := sessionService.RevokeSession(user.ID, own.ID)
+	if err != nil || sessionID != "session-1" {
+		t.Fatalf("RevokeSession() = %q, %v, want session-1", sessionID, err)
+	}
+	// 会话注销后，该会话签发的令牌即使未过期也不能再使用
+	if _, _, err := auth.Authenticate(tokens.AccessToken); err == nil || err.Error() != "会话已失效" {
+		t.Errorf("Authenticate() error = %v, want 会话已失效", err)
+	}
+	if _, err := auth.Refresh(RefreshRequest{RefreshToken: tokens.RefreshToken}); err == nil || err.Error() != "会话已失效" {
+		t.Errorf("Refresh() error = %v, want 会话已失效", err)
+	}
+
+	// 重复注销不报错
+	if _, err := sessionService.RevokeSession(user.ID, own.ID); err != nil {
+		t.Errorf("重复注销 error = %v", err)
+	}
+}
+
+func TestTouchSession(t *testing.T) {
+	user := setupAuthService(t)
+	db := global.MySQLClient
+
+	now := time.Now()
+	tests := []struct {
+		sessionID   string
+		lastUsed    time.Time
+		wantErr     string
+		wantTouched bool
+	}{
+		{"stale", now.Add(-time.Hour), "", true},
+		{"recent", now.Add(-time.Second), "", false},
+		{"missing", time.Time{}, "会话已失效", false},
+	}
+	for _, tt := range tests {
+		if !tt.lastUsed.IsZero() {
+			db.Create(&model.UserSession{SessionID: tt.sessionID, UserID: user.ID, LastUsedAt: tt.lastUsed, ExpiresAt: now.Add(time.Hour)})
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sessionID, func(t *testing.T) {
+			err := sessionService.TouchSession(tt.sessionID)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("TouchSession() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("TouchSession() error = %v", err)
+			}
+
+			var session model.UserSession
+			db.Where("session_id = ?", tt.sessionID).First(&session)
+			touched := session.LastUsedAt.Sub(tt.lastUsed) > time.Millisecond
+			if touched != tt.wantTouched {
+				t.Errorf("最后使用时间 = %v, want touched %v", session.LastUsedAt, tt.wantTouched)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"go_chat/config"
+	"go_chat/global"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// newMockMongo 创建模拟MongoDB部署，每个子测试通过mt.Run获得独立的模拟客户端
+func newMockMongo(t *testing.T) *mtest.T {
+	t.Helper()
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	t.Cleanup(mt.Close)
+	return mt
+}
+
+// useMockMongo 将全局MongoDB客户端替换为子测试的模拟客户端，测试结束后恢复
+func useMockMongo(mt *mtest.T) {
+	previous, database := global.MongoDBClient, config.MongoDBName
+	mt.Cleanup(func() {
+		global.MongoDBClient, config.MongoDBName = previous, database
+	})
+	global.MongoDBClient = mt.Client
+	config.MongoDBName = "go_chat_test"
+}
+
+// toBSON 将文档编码为模拟响应中使用的bson.D
+func toBSON(mt *mtest.T, value interface{}) bson.D {
+	mt.Helper()
+	data, err := bson.Marshal(value)
+	if err != nil {
+		mt.Fatal(err)
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		mt.Fatal(err)
+	}
+	return doc
+}
+
+// setupRedis 启动内存Redis并替换全局客户端，测试结束后恢复
+func setupRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+
+	previous := global.RedisClient
+	t.Cleanup(func() {
+		global.RedisClient = previous
+		client.Close()
+	})
+	global.RedisClient = client
+	return server
+}
+
+// setupMySQL 使用内存SQLite替代MySQL并迁移指定的表，测试结束后恢复
+func setupMySQL(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+	// 每个测试使用独立的共享缓存内存库，连接池中的连接看到同一份数据
+	db, err := gorm.Open("sqlite3", "file:"+strings.ReplaceAll(t.Name(), "/", "_")+"?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	db.SingularTable(true)
+	// SQLite不支持行锁语法，事务本身已串行执行，去掉FOR UPDATE即可
+	db.Callback().Query().Before("gorm:query").Register("test:strip_for_update", func(scope *gorm.Scope) {
+		if option, ok := scope.Get("gorm:query_option"); ok && option == "FOR UPDATE" {
+			scope.Set("gorm:query_option", "")
+		}
+	})
+	if err := db.AutoMigrate(models...).Error; err != nil {
+		t.Fatalf("测试数据库迁移失败: %v", err)
+	}
+
+	previous := global.MySQLClient
+	t.Cleanup(func() {
+		global.MySQLClient = previous
+		db.Close()
+	})
+	global.MySQLClient = db
+	return db
+}
+
+// nextCommand 跳过其他命令，返回下一条指定名称的命令
+func nextCommand(mt *mtest.T, name string) bson.Raw {
+	mt.Helper()
+	for event := mt.GetStartedEvent(); event != nil; event = mt.GetStartedEvent() {
+		if event.CommandName == name {
+			return event.Command
+		}
+	}
+	mt.Fatalf("没有执行 %s 命令", name)
+	return nil
+}
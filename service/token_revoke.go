@@ -0,0 +1,86 @@
+package service
+
+import (
+	"errors"
+	"go_chat/global"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// revokedTokenKeyPrefix 已吊销令牌在Redis中的键前缀
+const revokedTokenKeyPrefix = "go_chat:jwt:revoked:"
+
+// RevokeToken 吊销令牌，Redis中的记录随令牌剩余有效期自动过期
+func RevokeToken(claims *Claims) error {
+	if claims == nil || claims.ID == "" {
+		return nil
+	}
+
+	ttl := time.Minute
+	if claims.ExpiresAt != nil {
+		ttl = time.Until(claims.ExpiresAt.Time)
+	}
+	if ttl <= 0 {
+		// 令牌已过期，无需记录
+		return nil
+	}
+
+	redisClient := global.GetRedisClient()
+	if redisClient == nil {
+		return errors.New("Redis连接不可用")
+	}
+
+	if err := redisClient.Set(revokedTokenKeyPrefix+claims.ID, claims.UserID, ttl).Err(); err != nil {
+		logrus.Errorf("令牌吊销失败: %v", err)
+		return errors.New("令牌吊销失败")
+	}
+	return nil
+}
+
+// ConsumeToken 原子地占用令牌，用于刷新令牌轮换：同一令牌只有第一次占用成功，之后视为已吊销。
+// Redis不可用时无法保证令牌只被使用一次，直接返回错误
+func ConsumeToken(claims *Claims) error {
+	if claims == nil || claims.ID == "" {
+		return errors.New("令牌已失效")
+	}
+
+	ttl := time.Minute
+	if claims.ExpiresAt != nil {
+		ttl = time.Until(claims.ExpiresAt.Time)
+	}
+	if ttl <= 0 {
+		return errors.New("令牌已失效")
+	}
+
+	redisClient := global.GetRedisClient()
+	if redisClient == nil {
+		return errors.New("Redis连接不可用")
+	}
+
+	claimed, err := redisClient.SetNX(revokedTokenKeyPrefix+claims.ID, claims.UserID, ttl).Result()
+	if err != nil {
+		logrus.Errorf("令牌占用失败: %v", err)
+		return errors.New("令牌校验失败")
+	}
+	if !claimed {
+		return errors.New("令牌已失效")
+	}
+	return nil
+}
+
+// IsTokenRevoked 检查令牌是否已被吊销。未配置Redis时没有吊销记录，视为未吊销；
+// 查询Redis出错时无法确认令牌状态，视为已吊销
+func IsTokenRevoked(tokenID string) bool {
+	redisClient := global.GetRedisClient()
+	if redisClient == nil || tokenID == "" {
+		return false
+	}
+
+	exists, err := redisClient.Exists(revokedTokenKeyPrefix + tokenID).Result()
+	if err != nil {
+		logrus.Errorf("查询令牌吊销状态失败: %v", err)
+		return true
+	}
+	return exists > 0
+}
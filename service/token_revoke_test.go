@@ -0,0 +1,115 @@
+package service
+
+import (
+	"go_chat/global"
+	"testing"
+	"time"
+)
+
+func TestRevokeToken(t *testing.T) {
+	setupJWT(t)
+	server := setupRedis(t)
+	now := time.Now()
+
+	active := testClaims(now)
+	withoutExpiry := testClaims(now)
+	withoutExpiry.ExpiresAt = nil
+	expired := testClaims(now.Add(-2 * time.Hour))
+	withoutID := testClaims(now)
+	withoutID.ID = ""
+
+	tests := []struct {
+		name        string
+		claims      *Claims
+		wantRevoked bool
+		wantTTL     time.Duration
+	}{
+		{"按剩余有效期记录", &active, true, time.Hour},
+		{"没有有效期时记录一分钟", &withoutExpiry, true, time.Minute},
+		{"已过期的令牌不记录", &expired, false, 0},
+		{"没有令牌ID", &withoutID, false, 0},
+		{"空声明", nil, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := RevokeToken(tt.claims); err != nil {
+				t.Fatalf("RevokeToken() error = %v", err)
+			}
+			if tt.claims == nil {
+				return
+			}
+			if got := IsTokenRevoked(tt.claims.ID); got != tt.wantRevoked {
+				t.Errorf("IsTokenRevoked() = %v, want %v", got, tt.wantRevoked)
+			}
+			if !tt.wantRevoked {
+				return
+			}
+			if ttl := server.TTL(revokedTokenKeyPrefix + tt.claims.ID); ttl <= tt.wantTTL-time.Minute/2 || ttl > tt.wantTTL {
+				t.Errorf("TTL = %v, want about %v", ttl, tt.wantTTL)
+			}
+		})
+	}
+}
+
+func TestRevokeTokenWithoutRedis(t *testing.T) {
+	setupJWT(t)
+	setupRedis(t)
+	claims := testClaims(time.Now())
+	global.RedisClient = nil
+
+	if err := RevokeToken(&claims); err == nil || err.Error() != "Redis连接不可用" {
+		t.Errorf("RevokeToken() error = %v, want Redis连接不可用", err)
+	}
+	if IsTokenRevoked(claims.ID) {
+		t.Error("IsTokenRevoked() = true, want false")
+	}
+}
+
+func TestConsumeToken(t *testing.T) {
+	setupJWT(t)
+	server := setupRedis(t)
+	now := time.Now()
+
+	active := testClaims(now)
+	expired := testClaims(now.Add(-2 * time.Hour))
+	withoutID := testClaims(now)
+	withoutID.ID = ""
+
+	if err := ConsumeToken(&active); err != nil {
+		t.Fatalf("ConsumeToken() error = %v", err)
+	}
+	if !IsTokenRevoked(active.ID) {
+		t.Error("占用后的令牌应视为已吊销")
+	}
+	if ttl := server.TTL(revokedTokenKeyPrefix + active.ID); ttl <= time.Hour-time.Minute/2 || ttl > time.Hour {
+		t.Errorf("TTL = %v, want about 1h", ttl)
+	}
+
+	tests := []struct {
+		name   string
+		claims *Claims
+	}{
+		{"重复占用", &active},
+		{"已过期", &expired},
+		{"没有令牌ID", &withoutID},
+		{"空声明", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ConsumeToken(tt.claims); err == nil || err.Error() != "令牌已失效" {
+				t.Errorf("ConsumeToken() error = %v, want 令牌已失效", err)
+			}
+		})
+	}
+
+	// Redis出错时无法确认吊销状态，按已吊销处理
+	server.Close()
+	fresh := testClaims(now)
+	if err := ConsumeToken(&fresh); err == nil || err.Error() != "令牌校验失败" {
+		t.Errorf("Redis出错时 ConsumeToken() error = %v, want 令牌校验失败", err)
+	}
+	if !IsTokenRevoked(fresh.ID) {
+		t.Error("Redis出错时 IsTokenRevoked() = false, want true")
+	}
+}
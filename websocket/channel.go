@@ -0,0 +1,300 @@
+package websocket
+
+import (
+	"go_chat/model"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ChannelMessageRequest 频道消息请求
+type ChannelMessageRequest struct {
+	From    *Client
+	Message *Message
+}
+
+// channelIndex 在线订阅者索引，频道消息只推送给索引中的在线用户，避免每条消息都查询全部订阅者
+type channelIndex struct {
+	mu          sync.RWMutex
+	subscribers map[uint]map[uint]struct{} // 频道ID到在线订阅者的映射
+	channels    map[uint][]uint            // 在线用户ID到其订阅频道的映射
+	pending     map[uint]struct{}          // 订阅频道加载失败、等待重试的在线用户
+}
+
+// newChannelIndex 创建在线订阅者索引
+func newChannelIndex() *channelIndex {
+	return &channelIndex{
+		subscribers: make(map[uint]map[uint]struct{}),
+		channels:    make(map[uint][]uint),
+		pending:     make(map[uint]struct{}),
+	}
+}
+
+// track 记录在线用户订阅的全部频道，替换之前的记录
+func (idx *channelIndex) track(userID uint, channelIDs []uint) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.removeLocked(userID)
+	delete(idx.pending, userID)
+	for _, channelID := range channelIDs {
+		idx.addLocked(channelID, userID)
+	}
+}
+
+// markPending 记录订阅频道加载失败的用户，等待重试
+func (idx *channelIndex) markPending(userID uint) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.pending[userID] = struct{}{}
+}
+
+// pendingUsers 获取等待重新加载订阅频道的用户
+func (idx *channelIndex) pendingUsers() []uint {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	userIDs := make([]uint, 0, len(idx.pending))
+	for userID := range idx.pending {
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs
+}
+
+// forget 用户下线后从索引中移除
+func (idx *channelIndex) forget(userID uint) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.removeLocked(userID)
+	delete(idx.pending, userID)
+}
+
+// subscribe 在线用户订阅频道
+func (idx *channelIndex) subscribe(channelID, userID uint) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if _, ok := idx.subscribers[channelID][userID]; !ok {
+		idx.addLocked(channelID, userID)
+	}
+}
+
+// unsubscribe 在线用户取消订阅频道
+func (idx *channelIndex) unsubscribe(channelID, userID uint) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if _, ok := idx.subscribers[channelID][userID]; !ok {
+		return
+	}
+	idx.deleteSubscriber(channelID, userID)
+
+	channels := idx.channels[userID]
+	for i, id := range channels {
+		if id == channelID {
+			idx.channels[userID] = append(channels[:i], channels[i+1:]...)
+			break
+		}
+	}
+}
+
+// online 获取频道的在线订阅者
+func (idx *channelIndex) online(channelID uint) []uint {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	userIDs := make([]uint, 0, len(idx.subscribers[channelID]))
+	for userID := range idx.subscribers[channelID] {
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs
+}
+
+// addLocked 添加订阅关系，调用方需持有写锁
+func (idx *channelIndex) addLocked(channelID, userID uint) {
+	subscribers, ok := idx.subscribers[channelID]
+	if !ok {
+		subscribers = make(map[uint]struct{})
+		idx.subscribers[channelID] = subscribers
+	}
+	subscribers[userID] = struct{}{}
+	idx.channels[userID] = append(idx.channels[userID], channelID)
+}
+
+// removeLocked 移除用户的全部订阅关系，调用方需持有写锁
+func (idx *channelIndex) removeLocked(userID uint) {
+	for _, channelID := range idx.channels[userID] {
+		idx.deleteSubscriber(channelID, userID)
+	}
+	delete(idx.channels, userID)
+}
+
+// deleteSubscriber 从频道中移除在线订阅者，频道没有在线订阅者时删除该频道，调用方需持有写锁
+func (idx *channelIndex) deleteSubscriber(channelID, userID uint) {
+	delete(idx.subscribers[channelID], userID)
+	if len(idx.subscribers[channelID]) == 0 {
+		delete(idx.subscribers, channelID)
+	}
+}
+
+// HandleChannelMessage 处理来自客户端的频道消息，只有频道所有者和发布者可以发送
+func (h *Hub) HandleChannelMessage(from *Client, message *Message) {
+	if message.ChannelID == 0 {
+		from.SendError(400, "频道ID不能为空")
+		return
+	}
+
+	if len(message.ClientMsgID) > maxClientMsgIDLength {
+		from.SendError(400, "client_msg_id过长")
+		return
+	}
+
+	if strings.TrimSpace(message.Content) == "" && len(message.Attachments) == 0 {
+		from.SendError(400, "消息内容不能为空")
+		return
+	}
+
+	if err := channelService.CheckPublish(message.ChannelID, from.UserID); err != nil {
+		h.rejectChannelPublish(from, message, err)
+		return
+	}
+
+	// 频道消息只存储一份，离线订阅者按已读位置从历史消息中读取
+	originalID := message.ID
+	stored := &model.ChatMessage{
+		ClientMsgID: message.ClientMsgID,
+		Type:        string(message.Type),
+		ChannelID:   message.ChannelID,
+		FromUserID:  from.UserID,
+		Content:     message.Content,
+		Timestamp:   message.Timestamp,
+	}
+
+	quote, attachments, err := h.resolveReferences(from, message, model.ChannelSessionID(message.ChannelID), stored)
+	if err != nil {
+		h.rejectMessage(from, message, originalID, err)
+		return
+	}
+
+	duplicate, err := messageService.SaveChannelMessage(stored)
+	if err != nil {
+		h.rejectMessage(from, message, originalID, err)
+		return
+	}
+
+	status := MessageStatusData{
+		MessageID:         stored.ID.Hex(),
+		OriginalMessageID: originalID,
+		ClientMsgID:       stored.ClientMsgID,
+		SessionID:         stored.SessionID,
+		ChannelID:         stored.ChannelID,
+		Status:            stored.Status,
+		Duplicate:         duplicate,
+		UpdatedAt:         stored.UpdatedAt,
+	}
+	if duplicate {
+		logrus.Infof("用户 %d 重复发送频道消息 %s，返回原消息 %s", from.UserID, message.ClientMsgID, stored.ID.Hex())
+		from.SendMessage(NewStatusMessage(status))
+		return
+	}
+
+	if err := attachmentService.ShareWithChannel(stored.Attachments, stored.ChannelID); err != nil {
+		logrus.Warnf("消息 %s 附件授权失败: %v", stored.ID.Hex(), err)
+	}
+
+	message.FromUserID = from.UserID
+	message.ToUserID = 0
+	applyStored(message, stored, quote, attachments)
+	from.SendMessage(NewStatusMessage(status))
+
+	h.ChannelMessage <- &ChannelMessageRequest{
+		From:    from,
+		Message: message,
+	}
+}
+
+// rejectChannelPublish 拒绝频道发布，向发送者返回对应的错误码
+func (h *Hub) rejectChannelPublish(from *Client, message *Message, err error) {
+	code := 500
+	switch err.Error() {
+	case "频道不存在":
+		code = 404
+	case "没有发布权限":
+		code = 403
+	}
+
+	errorMessage := NewErrorMessage(code, err.Error())
+	errorMessage.Data = ErrorData{
+		Code:        code,
+		Message:     err.Error(),
+		ChannelID:   message.ChannelID,
+		ClientMsgID: message.ClientMsgID,
+	}
+	from.SendMessage(errorMessage)
+}
+
+// handleChannelMessage 将频道消息推送给在线订阅者。消息只序列化一次，且不需要订阅者确认：
+// 未能收到的订阅者根据已读位置从历史消息中补读，服务端不按订阅者记录送达状态
+func (h *Hub) handleChannelMessage(req *ChannelMessageRequest) {
+	data, err := req.Message.ToJSON()
+	if err != nil {
+		logrus.Errorf("频道消息序列化失败: %v", err)
+		return
+	}
+
+	delivered := 0
+	for _, client := range h.onlineClients(h.channels.online(req.Message.ChannelID)) {
+		if client.UserID == req.Message.FromUserID {
+			continue
+		}
+		if client.enqueueEncoded(req.Message, data, "") {
+			delivered++
+		}
+	}
+	logrus.Infof("频道 %d 消息已推送给 %d 名在线订阅者", req.Message.ChannelID, delivered)
+}
+
+// SubscribeChannel 用户订阅频道后，在线时开始接收频道消息
+func (h *Hub) SubscribeChannel(userID, channelID uint) {
+	if _, online := h.GetUserClient(userID); online {
+		h.channels.subscribe(channelID, userID)
+	}
+}
+
+// UnsubscribeChannel 用户取消订阅频道后不再接收频道消息
+func (h *Hub) UnsubscribeChannel(userID, channelID uint) {
+	h.channels.unsubscribe(channelID, userID)
+}
+
+// trackChannels 用户上线时加载其订阅的频道。加载失败时通知客户端，
+// 并在定期清理时重试，重试成功前的频道消息由客户端按未读数从历史消息中补读
+func (h *Hub) trackChannels(client *Client) {
+	if err := h.loadChannels(client.UserID); err != nil {
+		h.channels.markPending(client.UserID)
+		client.SendError(503, "频道订阅加载失败，频道消息稍后恢复推送")
+	}
+}
+
+// retryPendingChannels 重新加载订阅频道加载失败的在线用户
+func (h *Hub) retryPendingChannels() {
+	for _, userID := range h.channels.pendingUsers() {
+		if _, online := h.GetUserClient(userID); !online {
+			h.channels.forget(userID)
+			continue
+		}
+		if err := h.loadChannels(userID); err == nil {
+			logrus.Infof("用户 %d 频道订阅重新加载成功", userID)
+		}
+	}
+}
+
+// loadChannels 查询用户订阅的频道并写入在线订阅者索引
+func (h *Hub) loadChannels(userID uint) error {
+	channelIDs, err := channelService.ListSubscribedChannelIDs(userID)
+	if err != nil {
+		logrus.Warnf("用户 %d 频道查询失败: %v", userID, err)
+		return err
+	}
+	h.channels.track(userID, channelIDs)
+	return nil
+}
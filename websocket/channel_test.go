@@ -0,0 +1,225 @@
+package websocket
+
+import (
+	"go_chat/model"
+	"slices"
+	"testing"
+)
+
+// sortedOnline 获取频道的在线订阅者并排序
+func sortedOnline(idx *channelIndex, channelID uint) []uint {
+	userIDs := idx.online(channelID)
+	slices.Sort(userIDs)
+	return userIDs
+}
+
+func TestChannelIndex(t *testing.T) {
+	tests := []struct {
+		name  string
+		apply func(idx *channelIndex)
+		want  map[uint][]uint // 频道ID到在线订阅者
+	}{
+		{
+			"记录订阅频道",
+			func(idx *channelIndex) {
+				idx.track(1, []uint{10, 20})
+				idx.track(2, []uint{20})
+			},
+			map[uint][]uint{10: {1}, 20: {1, 2}},
+		},
+		{
+			"重新记录时替换之前的频道",
+			func(idx *channelIndex) {
+				idx.track(1, []uint{10, 20})
+				idx.track(1, []uint{30})
+			},
+			map[uint][]uint{10: {}, 20: {}, 30: {1}},
+		},
+		{
+			"订阅新频道",
+			func(idx *channelIndex) {
+				idx.track(1, []uint{10})
+				idx.subscribe(20, 1)
+			},
+			map[uint][]uint{10: {1}, 20: {1}},
+		},
+		{
+			"重复订阅不重复记录",
+			func(idx *channelIndex) {
+				idx.track(1, []uint{10})
+				idx.subscribe(10, 1)
+				idx.unsubscribe(10, 1)
+			},
+			map[uint][]uint{10: {}},
+		},
+		{
+			"取消订阅",
+			func(idx *channelIndex) {
+				idx.track(1, []uint{10, 20})
+				idx.track(2, []uint{10})
+				idx.unsubscribe(10, 1)
+			},
+			map[uint][]uint{10: {2}, 20: {1}},
+		},
+		{
+			"取消未订阅的频道",
+			func(idx *channelIndex) {
+				idx.track(1, []uint{10})
+				idx.unsubscribe(20, 1)
+				idx.unsubscribe(10, 2)
+			},
+			map[uint][]uint{10: {1}, 20: {}},
+		},
+		{
+			"下线后移除",
+			func(idx *channelIndex) {
+				idx.track(1, []uint{10, 20})
+				idx.track(2, []uint{20})
+				idx.forget(1)
+			},
+			map[uint][]uint{10: {}, 20: {2}},
+		},
+		{
+			"取消订阅后下线",
+			func(idx *channelIndex) {
+				idx.track(1, []uint{10, 20})
+				idx.unsubscribe(10, 1)
+				idx.forget(1)
+			},
+			map[uint][]uint{10: {}, 20: {}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idx := newChannelIndex()
+			tt.apply(idx)
+			for channelID, want := range tt.want {
+				if got := sortedOnline(idx, channelID); !slices.Equal(got, want) {
+					t.Errorf("online(%d) = %v, want %v", channelID, got, want)
+				}
+			}
+			// 没有在线订阅者的频道不应残留在索引中
+			for channelID, subscribers := range idx.subscribers {
+				if len(subscribers) == 0 {
+					t.Errorf("频道 %d 没有在线订阅者但仍在索引中", channelID)
+				}
+			}
+		})
+	}
+}
+
+func TestChannelIndexPending(t *testing.T) {
+	tests := []struct {
+		name  string
+		apply func(idx *channelIndex)
+		want  []uint
+	}{
+		{"没有等待重试的用户", func(idx *channelIndex) {}, []uint{}},
+		{
+			"加载失败后等待重试",
+			func(idx *channelIndex) {
+				idx.markPending(1)
+				idx.markPending(2)
+				idx.markPending(1)
+			},
+			[]uint{1, 2},
+		},
+		{
+			"加载成功后不再重试",
+			func(idx *channelIndex) {
+				idx.markPending(1)
+				idx.markPending(2)
+				idx.track(1, []uint{10})
+			},
+			[]uint{2},
+		},
+		{
+			"下线后不再重试",
+			func(idx *channelIndex) {
+				idx.markPending(1)
+				idx.forget(1)
+			},
+			[]uint{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idx := newChannelIndex()
+			tt.apply(idx)
+			got := idx.pendingUsers()
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("pendingUsers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleChannelMessageFanOut(t *testing.T) {
+	setupMessageConfig(t)
+	hub := NewHub()
+	publisher, subscriber, outsider := newTestClient(hub, 1), newTestClient(hub, 2), newTestClient(hub, 3)
+	hub.UserClients[1], hub.UserClients[2], hub.UserClients[3] = publisher, subscriber, outsider
+	hub.channels.track(1, []uint{7})
+	hub.channels.track(2, []uint{7})
+
+	message := NewMessage(MessageTypeChannel, 1, 0, "公告")
+	message.ID, message.ChannelID = "channel-message", 7
+	hub.handleChannelMessage(&ChannelMessageRequest{From: publisher, Message: message})
+
+	if got := messagesOfType(receivedMessages(t, subscriber), MessageTypeChannel); len(got) != 1 || got[0].ChannelID != 7 {
+		t.Errorf("在线订阅者收到 = %v, want 1 条频道消息", got)
+	}
+	// 频道消息按已读位置同步，不等待逐条确认
+	if _, tracked := subscriber.inflight["channel-message"]; tracked {
+		t.Error("频道消息不应等待订阅者确认")
+	}
+	for _, client := range []*Client{publisher, outsider} {
+		if got := receivedMessages(t, client); len(got) != 0 {
+			t.Errorf("用户 %d 不应收到频道消息, got %v", client.UserID, got)
+		}
+	}
+}
+
+func TestRetryPendingChannels(t *testing.T) {
+	db := setupMySQL(t, &model.ChannelSubscriber{})
+	db.Create(&model.ChannelSubscriber{ChannelID: 7, UserID: 1, Role: model.ChannelRoleSubscriber})
+	hub := NewHub()
+	hub.UserClients[1] = newTestClient(hub, 1)
+	hub.channels.markPending(1)
+	hub.channels.markPending(2)
+
+	hub.retryPendingChannels()
+
+	if got := sortedOnline(hub.channels, 7); !slices.Equal(got, []uint{1}) {
+		t.Errorf("online(7) = %v, want [1]", got)
+	}
+	// 重新加载成功和已下线的用户都不再等待重试
+	if pending := hub.channels.pendingUsers(); len(pending) != 0 {
+		t.Errorf("pendingUsers() = %v, want none", pending)
+	}
+}
+
+func TestHandleChannelMessageSkipsRetry(t *testing.T) {
+	setupMessageConfig(t)
+	db := setupMySQL(t, &model.ChannelSubscriber{})
+	db.Create(&model.ChannelSubscriber{ChannelID: 7, UserID: 2, Role: model.ChannelRoleSubscriber})
+	hub := NewHub()
+	publisher := newTestClient(hub, 1)
+	hub.UserClients[1], hub.UserClients[2] = publisher, newTestClient(hub, 2)
+	hub.channels.markPending(2)
+
+	message := NewMessage(MessageTypeChannel, 1, 0, "公告")
+	message.ID, message.ChannelID = "channel-message", 7
+	hub.handleChannelMessage(&ChannelMessageRequest{From: publisher, Message: message})
+
+	// 推送路径不访问数据库，加载失败的用户只由定期清理重试
+	if pending := hub.channels.pendingUsers(); !slices.Equal(pending, []uint{2}) {
+		t.Errorf("pendingUsers() = %v, want [2]", pending)
+	}
+	if got := sortedOnline(hub.channels, 7); len(got) != 0 {
+		t.Errorf("online(7) = %v, want none", got)
+	}
+}
@@ -1,6 +1,7 @@
 package websocket
 
 import (
+	"go_chat/config"
 	"net/http"
 	"sync"
 	"time"
@@ -14,7 +15,8 @@ const (
 	writeWait      = 10 * time.Second    // 写入超时
 	pongWait       = 60 * time.Second    // Pong等待时间
 	pingPeriod     = (pongWait * 9) / 10 // Ping发送间隔
-	maxMessageSize = 512                 // 最大消息大小
+	maxMessageSize = 4096                // 最大消息大小，附件通过上传接口传输
+	sendBufferSize = 256                 // 发送缓冲区大小
 )
 
 // Client 代表一个WebSocket客户端连接
@@ -25,23 +27,45 @@ type Client struct {
 	UserName string `json:"username"` // 用户名
 	Avatar   string `json:"avatar"`   // 头像
 
+	// 认证信息
+	SessionID string `json:"-"` // 登录会话ID，用于退出登录时强制断开
+
+	// 断线恢复
+	ResumeToken string      `json:"-"` // 本连接的恢复令牌
+	resumeFrom  string      // 客户端携带的上一个连接的恢复令牌
+	resumable   bool        // 断开后是否进入宽限期
+	suspension  *suspension // 宽限期状态，为空表示未断开
+
 	// 连接管理
 	Hub  *Hub            `json:"-"` // 连接池引用
 	Conn *websocket.Conn `json:"-"` // WebSocket连接
-	Send chan []byte     `json:"-"` // 发送消息通道
+	Send chan *outbound  `json:"-"` // 发送消息通道
 
 	// 状态管理
 	IsAlive     bool      `json:"is_alive"`     // 连接状态
 	LastSeen    time.Time `json:"last_seen"`    // 最后活跃时间
 	ConnectedAt time.Time `json:"connected_at"` // 连接时间
 
+	// 在途消息（已发送但未收到客户端确认）
+	inflight   map[string]*inflightEntry `json:"-"`
+	backlog    bool                      `json:"-"` // 是否有因窗口已满而转为离线的消息
+	inflightMu sync.Mutex                `json:"-"`
+	flushMu    sync.Mutex                `json:"-"` // 串行化离线消息补发，避免同一条消息被并发加载和重复发送
+
 	// 并发控制
 	mu sync.RWMutex `json:"-"`
 }
 
+// outbound 待写入连接的消息
+type outbound struct {
+	data      []byte
+	messageID string // 需要跟踪投递状态的存储消息ID，为空表示不跟踪
+}
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	Subprotocols:    []string{tokenSubprotocol},
 	CheckOrigin: func(r *http.Request) bool {
 		// 在生产环境中应该检查Origin
 		return true
@@ -49,15 +73,19 @@ var upgrader = websocket.Upgrader{
 }
 
 // NewClient 创建新的客户端连接
-func NewClient(hub *Hub, conn *websocket.Conn, userID uint, userName string) *Client {
+func NewClient(hub *Hub, conn *websocket.Conn, userID uint, userName, avatar string) *Client {
 	return &Client{
 		ID:          generateClientID(),
 		UserID:      userID,
 		UserName:    userName,
+		Avatar:      avatar,
 		Hub:         hub,
 		Conn:        conn,
-		Send:        make(chan []byte, 256),
+		Send:        make(chan *outbound, sendBufferSize),
 		IsAlive:     true,
+		ResumeToken: generateRandomString(32),
+		resumable:   true,
+		inflight:    make(map[string]*inflightEntry),
 		LastSeen:    time.Now(),
 		ConnectedAt: time.Now(),
 	}
@@ -85,6 +113,10 @@ func (c *Client) ReadPump() {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				logrus.Errorf("WebSocket错误: %v", err)
 			}
+			// 客户端主动关闭时直接下线，不保留会话
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				c.disableResume()
+			}
 			break
 		}
 
@@ -125,23 +157,32 @@ func (c *Client) WritePump() {
 				return
 			}
 
+			batch := []*outbound{message}
 			w, err := c.Conn.NextWriter(websocket.TextMessage)
 			if err != nil {
 				return
 			}
-			w.Write(message)
+			w.Write(message.data)
 
 			// 发送队列中的其他消息
 			n := len(c.Send)
 			for i := 0; i < n; i++ {
+				next, ok := <-c.Send
+				if !ok {
+					break
+				}
+				batch = append(batch, next)
 				w.Write([]byte{'\n'})
-				w.Write(<-c.Send)
+				w.Write(next.data)
 			}
 
 			if err := w.Close(); err != nil {
 				return
 			}
 
+			// 从写入连接开始计算确认超时
+			c.markWritten(batch)
+
 		case <-ticker.C:
 			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
@@ -151,22 +192,71 @@ func (c *Client) WritePump() {
 	}
 }
 
-// SendMessage 发送消息给客户端
-func (c *Client) SendMessage(message *Message) {
+// SendMessage 发送消息给客户端，返回消息是否进入发送队列
+func (c *Client) SendMessage(message *Message) bool {
+	return c.enqueue(message, "")
+}
+
+// SendTracked 发送需要客户端确认的存储消息，消息进入在途窗口直到收到ack。
+// 在途窗口已满或发送队列已满时返回false，由调用方转为离线消息
+func (c *Client) SendTracked(message *Message, messageID string) bool {
+	c.inflightMu.Lock()
+	if _, exists := c.inflight[messageID]; exists {
+		// 已在途，等待确认或超时重传
+		c.inflightMu.Unlock()
+		return true
+	}
+	if len(c.inflight) >= config.MessageInflightWindow {
+		c.backlog = true
+		c.inflightMu.Unlock()
+		return false
+	}
+	c.inflight[messageID] = &inflightEntry{message: message}
+	c.inflightMu.Unlock()
+
+	if !c.enqueue(message, messageID) {
+		c.inflightMu.Lock()
+		delete(c.inflight, messageID)
+		c.inflightMu.Unlock()
+		return false
+	}
+	return true
+}
+
+// enqueue 将消息放入发送队列
+func (c *Client) enqueue(message *Message, messageID string) bool {
 	data, err := message.ToJSON()
 	if err != nil {
 		logrus.Errorf("消息序列化失败: %v", err)
-		return
+		return false
+	}
+	return c.enqueueEncoded(message, data, messageID)
+}
+
+// enqueueEncoded 将已序列化的消息放入发送队列，向大量客户端推送同一条消息时只需序列化一次
+func (c *Client) enqueueEncoded(message *Message, data []byte, messageID string) bool {
+	// 持有读锁防止发送过程中通道被关闭
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.IsAlive {
+		// 宽限期内缓存推送消息，需要确认的存储消息由重连后的离线补发负责
+		if c.suspension != nil && messageID == "" {
+			c.suspension.buffer(message)
+			return true
+		}
+		return false
 	}
 
 	select {
-	case c.Send <- data:
+	case c.Send <- &outbound{data: data, messageID: messageID}:
+		return true
 	default:
 		// 发送失败，客户端可能已断开连接
 		logrus.Warnf("向用户 %d 发送消息失败，连接可能已断开", c.UserID)
 		go func() {
 			c.Hub.Unregister <- c
 		}()
+		return false
 	}
 }
 
@@ -184,12 +274,30 @@ func (c *Client) handleMessage(message *Message) {
 	switch message.Type {
 	case MessageTypePrivate:
 		c.Hub.HandlePrivateMessage(c, message)
+	case MessageTypeGroup:
+		c.Hub.HandleGroupMessage(c, message)
+	case MessageTypeChannel:
+		c.Hub.HandleChannelMessage(c, message)
+	case MessageTypeJoin:
+		c.Hub.HandleRoomJoin(c, message)
+	case MessageTypeLeave:
+		c.Hub.HandleRoomLeave(c, message)
 	case MessageTypeHeartbeat:
 		c.handleHeartbeat(message)
 	case MessageTypeTyping:
 		c.Hub.HandleTypingMessage(c, message)
 	case MessageTypeRead:
 		c.Hub.HandleReadMessage(c, message)
+	case MessageTypeSync:
+		c.Hub.HandleSyncMessage(c, message)
+	case MessageTypeAck:
+		c.Hub.HandleAckMessage(c, message)
+	case MessageTypeEdit:
+		c.Hub.HandleEditMessage(c, message)
+	case MessageTypeRecall:
+		c.Hub.HandleRecallMessage(c, message)
+	case MessageTypeReactionAdd, MessageTypeReactionRemove:
+		c.Hub.HandleReactionMessage(c, message)
 	default:
 		logrus.Warnf("未知消息类型: %s", message.Type)
 		c.SendError(400, "未知消息类型")
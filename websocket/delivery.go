@@ -0,0 +1,263 @@
+package websocket
+
+import (
+	"go_chat/config"
+	"go_chat/model"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxAckBatch 单条确认消息最多携带的消息ID数量
+const maxAckBatch = 200
+
+// inflightEntry 已发送但未收到客户端确认的消息
+type inflightEntry struct {
+	message  *Message
+	sentAt   time.Time // 最近一次写入连接的时间，为零表示还在发送队列中
+	attempts int       // 写入连接的次数
+}
+
+// markWritten 记录在途消息写入连接的时间，从此开始计算确认超时
+func (c *Client) markWritten(batch []*outbound) {
+	now := time.Now()
+	c.inflightMu.Lock()
+	defer c.inflightMu.Unlock()
+	for _, message := range batch {
+		if entry, ok := c.inflight[message.messageID]; ok {
+			entry.sentAt = now
+			entry.attempts++
+		}
+	}
+}
+
+// ack 移除已确认的在途消息，返回确认的消息、未在途的消息ID，以及是否需要继续补发离线消息
+func (c *Client) ack(messageIDs []string) (acked []*Message, unknown []string, flush bool) {
+	c.inflightMu.Lock()
+	defer c.inflightMu.Unlock()
+	for _, id := range messageIDs {
+		if entry, ok := c.inflight[id]; ok {
+			delete(c.inflight, id)
+			acked = append(acked, entry.message)
+		} else {
+			unknown = append(unknown, id)
+		}
+	}
+	return acked, unknown, c.takeBacklogLocked()
+}
+
+// takeBacklog 在途窗口有空位且存在积压消息时返回true并清除积压标记
+func (c *Client) takeBacklog() bool {
+	c.inflightMu.Lock()
+	defer c.inflightMu.Unlock()
+	return c.takeBacklogLocked()
+}
+
+func (c *Client) takeBacklogLocked() bool {
+	if !c.backlog || len(c.inflight) >= config.MessageInflightWindow {
+		return false
+	}
+	c.backlog = false
+	return true
+}
+
+// dueRetransmits 返回确认超时需要重传的消息，exhausted表示有消息超过最大重传次数
+func (c *Client) dueRetransmits(now time.Time) (due map[string]*Message, exhausted bool) {
+	c.inflightMu.Lock()
+	defer c.inflightMu.Unlock()
+	due = make(map[string]*Message)
+	for id, entry := range c.inflight {
+		if entry.sentAt.IsZero() || now.Sub(entry.sentAt) < config.MessageAckTimeout {
+			continue
+		}
+		if entry.attempts > config.MessageMaxRetransmits {
+			return nil, true
+		}
+		// 重新入队前清空写入时间，避免在写入前再次被判定超时
+		entry.sentAt = time.Time{}
+		due[id] = entry.message
+	}
+	return due, false
+}
+
+// dropInflight 移除在途消息，不再重传也不再等待确认，返回消息是否在途
+func (c *Client) dropInflight(messageID string) bool {
+	c.inflightMu.Lock()
+	defer c.inflightMu.Unlock()
+	_, ok := c.inflight[messageID]
+	delete(c.inflight, messageID)
+	return ok
+}
+
+// takeInflight 取出全部在途消息并清空在途窗口
+func (c *Client) takeInflight() []*Message {
+	c.inflightMu.Lock()
+	defer c.inflightMu.Unlock()
+	messages := make([]*Message, 0, len(c.inflight))
+	for _, entry := range c.inflight {
+		messages = append(messages, entry.message)
+	}
+	c.inflight = make(map[string]*inflightEntry)
+	c.backlog = false
+	return messages
+}
+
+// HandleAckMessage 处理客户端确认，确认后的消息标记为已送达并通知发送者
+func (h *Hub) HandleAckMessage(from *Client, message *Message) {
+	var data AckData
+	if err := message.DecodeData(&data); err != nil {
+		from.SendError(400, "确认消息格式错误")
+		return
+	}
+	if len(data.MessageIDs) == 0 {
+		return
+	}
+	if len(data.MessageIDs) > maxAckBatch {
+		from.SendError(400, "单次确认的消息过多")
+		return
+	}
+
+	// 移出在途窗口和记录送达需要在补发之外完成，否则并发的补发可能在两者之间把消息当作未送达再次发送
+	from.flushMu.Lock()
+	acked, unknown, flush := from.ack(data.MessageIDs)
+	for _, message := range acked {
+		h.markDelivered(from.UserID, message.ID, message.GroupID)
+	}
+	from.flushMu.Unlock()
+
+	// 不在途的消息可能是上一个连接收到后才断开的，确认前校验接收者
+	for _, id := range unknown {
+		stored, err := messageService.GetMessage(id)
+		if err != nil {
+			continue
+		}
+		if stored.GroupID != 0 {
+			if stored.FromUserID == from.UserID || !groupService.IsMember(stored.GroupID, from.UserID) {
+				continue
+			}
+		} else if stored.ToUserID != from.UserID {
+			continue
+		}
+		h.markDelivered(from.UserID, id, stored.GroupID)
+	}
+
+	// 窗口腾出空位后继续补发因窗口已满而积压的离线消息
+	if flush {
+		h.deliverOfflineMessages(from)
+	}
+}
+
+// closeClient 关闭客户端连接，未确认的在途消息转为离线消息
+func (h *Hub) closeClient(client *Client) {
+	client.Close()
+	h.releaseInflight(client)
+}
+
+// releaseInflight 将未确认的在途消息转为离线消息，存储操作在主循环之外执行
+func (h *Hub) releaseInflight(client *Client) {
+	messages := client.takeInflight()
+	if len(messages) == 0 {
+		return
+	}
+	logrus.Warnf("用户 %d 有 %d 条消息未确认，转为离线消息", client.UserID, len(messages))
+
+	// 群聊消息没有投递状态，成员未确认的消息在重连时按送达记录补发
+	var messageIDs []string
+	for _, message := range messages {
+		if message.GroupID == 0 {
+			messageIDs = append(messageIDs, message.ID)
+		}
+	}
+	userID := client.UserID
+	h.background(func() { h.storeOffline(userID, messageIDs) })
+}
+
+// startRetransmitRoutine 启动重传协程，定期重传确认超时的消息
+func (h *Hub) startRetransmitRoutine() {
+	interval := config.MessageAckTimeout / 3
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		h.retransmit()
+	}
+}
+
+// retransmit 重传确认超时的消息，超过最大重传次数的连接视为失效并断开
+func (h *Hub) retransmit() {
+	h.mu.RLock()
+	clients := make([]*Client, 0, len(h.Clients))
+	for client := range h.Clients {
+		clients = append(clients, client)
+	}
+	h.mu.RUnlock()
+
+	now := time.Now()
+	for _, client := range clients {
+		due, exhausted := client.dueRetransmits(now)
+		if exhausted {
+			logrus.Warnf("用户 %d 超过最大重传次数仍未确认，断开连接", client.UserID)
+			h.Unregister <- client
+			continue
+		}
+
+		for id, message := range due {
+			if !client.enqueue(message, id) {
+				// 发送队列已满，连接会被注销，在途消息随之转为离线消息
+				break
+			}
+		}
+		if len(due) > 0 {
+			logrus.Infof("向用户 %d 重传 %d 条未确认消息", client.UserID, len(due))
+		}
+
+		// 兜底补发积压的离线消息，避免最后一次确认早于积压标记时消息滞留
+		if client.takeBacklog() {
+			h.deliverOfflineMessages(client)
+		}
+	}
+}
+
+// markDelivered 标记消息已送达：私聊消息推进投递状态，群聊消息记录该成员已收到
+func (h *Hub) markDelivered(userID uint, messageID string, groupID uint) {
+	if groupID == 0 {
+		h.updateMessageStatus(messageID, model.MessageStatusDelivered)
+		return
+	}
+	if err := messageService.MarkGroupDelivered(messageID, userID); err != nil {
+		logrus.Warnf("群聊消息 %s 送达记录失败: %v", messageID, err)
+	}
+}
+
+// markStored 将消息标记为等待补发
+func (h *Hub) markStored(messageID string) {
+	h.updateMessageStatus(messageID, model.MessageStatusStored)
+}
+
+// updateMessageStatus 推进消息状态，状态发生变化时推送给在线的发送者
+func (h *Hub) updateMessageStatus(messageID, status string) {
+	message, err := messageService.UpdateStatus(messageID, status)
+	if err != nil {
+		logrus.Warnf("消息 %s 状态更新为 %s 失败: %v", messageID, status, err)
+		return
+	}
+	if message == nil {
+		// 状态没有前进，例如已送达的消息不会退回离线状态
+		return
+	}
+
+	sender, online := h.GetUserClient(message.FromUserID)
+	if !online {
+		return
+	}
+	sender.SendMessage(NewStatusMessage(MessageStatusData{
+		MessageID: messageID,
+		SessionID: message.SessionID,
+		ToUserID:  message.ToUserID,
+		Status:    message.Status,
+		UpdatedAt: message.UpdatedAt,
+	}))
+}
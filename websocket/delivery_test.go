@@ -0,0 +1,236 @@
+package websocket
+
+import (
+	"go_chat/config"
+	"go_chat/model"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestUpdateMessageStatus(t *testing.T) {
+	mt := newMockMongo(t)
+	messageID := primitive.NewObjectID()
+
+	mt.Run("状态前进时通知在线的发送者", func(mt *mtest.T) {
+		useMockMongo(mt)
+		updated := model.ChatMessage{ID: messageID, SessionID: "private_1_2", FromUserID: 1, ToUserID: 2, Status: model.MessageStatusDelivered, UpdatedAt: time.Now()}
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: toBSON(mt, updated)}))
+
+		hub := NewHub()
+		sender := newTestClient(hub, 1)
+		hub.UserClients[1] = sender
+		hub.updateMessageStatus(messageID.Hex(), model.MessageStatusDelivered)
+
+		statuses := messagesOfType(receivedMessages(mt, sender), MessageTypeStatus)
+		if len(statuses) != 1 || messageData(statuses[0])["status"] != model.MessageStatusDelivered || messageData(statuses[0])["to_user_id"] != float64(2) {
+			mt.Errorf("发送者收到的状态 = %v, want delivered", statuses)
+		}
+
+		filter := nextCommand(mt, "findAndModify").Lookup("query", "status", "$in").Array()
+		previous, _ := filter.Values()
+		if len(previous) != 2 {
+			mt.Errorf("前置状态 = %v, want sent, stored", previous)
+		}
+	})
+
+	mt.Run("状态没有前进时不通知", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}))
+
+		hub := NewHub()
+		sender := newTestClient(hub, 1)
+		hub.UserClients[1] = sender
+		hub.updateMessageStatus(messageID.Hex(), model.MessageStatusStored)
+
+		if received := receivedMessages(mt, sender); len(received) != 0 {
+			mt.Errorf("发送者收到 = %v, want none", received)
+		}
+	})
+}
+
+func TestSendTrackedInflightWindow(t *testing.T) {
+	setupMessageConfig(t)
+	config.MessageInflightWindow = 2
+	client := newTestClient(NewHub(), 1)
+
+	for _, id := range []string{"a", "b"} {
+		if !client.SendTracked(NewMessage(MessageTypePrivate, 2, 1, id), id) {
+			t.Fatalf("SendTracked(%s) = false, want true", id)
+		}
+	}
+	// 重复发送在途消息不会再次入队
+	if !client.SendTracked(NewMessage(MessageTypePrivate, 2, 1, "a"), "a") || len(client.Send) != 2 {
+		t.Errorf("在途消息重复入队, 队列长度 = %d", len(client.Send))
+	}
+	if client.SendTracked(NewMessage(MessageTypePrivate, 2, 1, "c"), "c") {
+		t.Error("窗口已满时 SendTracked() = true, want false")
+	}
+
+	acked, unknown, flush := client.ack([]string{"a", "x"})
+	if len(acked) != 1 || acked[0].Content != "a" || len(unknown) != 1 || unknown[0] != "x" {
+		t.Errorf("ack() = %v, %v, want [a], [x]", acked, unknown)
+	}
+	if !flush {
+		t.Error("窗口腾出空位后应继续补发积压消息")
+	}
+	if client.takeBacklog() {
+		t.Error("积压标记应只被取出一次")
+	}
+}
+
+func TestDueRetransmits(t *testing.T) {
+	setupMessageConfig(t)
+	client := newTestClient(NewHub(), 1)
+	now := time.Now()
+	client.inflight = map[string]*inflightEntry{
+		"queued":  {message: NewMessage(MessageTypePrivate, 2, 1, "queued")},
+		"recent":  {message: NewMessage(MessageTypePrivate, 2, 1, "recent"), sentAt: now.Add(-time.Second), attempts: 1},
+		"timeout": {message: NewMessage(MessageTypePrivate, 2, 1, "timeout"), sentAt: now.Add(-config.MessageAckTimeout), attempts: 1},
+	}
+
+	due, exhausted := client.dueRetransmits(now)
+	if exhausted || len(due) != 1 || due["timeout"] == nil {
+		t.Fatalf("dueRetransmits() = %v, %v, want [timeout]", due, exhausted)
+	}
+	if !client.inflight["timeout"].sentAt.IsZero() {
+		t.Error("重传前应清空写入时间")
+	}
+
+	client.markWritten([]*outbound{{messageID: "timeout"}})
+	if entry := client.inflight["timeout"]; entry.attempts != 2 || entry.sentAt.IsZero() {
+		t.Errorf("markWritten() 后 entry = %+v, want 第2次写入", entry)
+	}
+
+	client.inflight["timeout"].attempts = config.MessageMaxRetransmits + 1
+	client.inflight["timeout"].sentAt = now.Add(-config.MessageAckTimeout)
+	if _, exhausted := client.dueRetransmits(now); !exhausted {
+		t.Error("超过最大重传次数时 exhausted = false, want true")
+	}
+}
+
+func TestHandleAckMessage(t *testing.T) {
+	setupMessageConfig(t)
+	mt := newMockMongo(t)
+	messageID := primitive.NewObjectID()
+	delivered := model.ChatMessage{ID: messageID, SessionID: "private_1_2", FromUserID: 2, ToUserID: 1, Status: model.MessageStatusDelivered, UpdatedAt: time.Now()}
+	ackMessage := func(ids ...string) *Message {
+		message := NewMessage(MessageTypeAck, 1, 0, "")
+		message.Data = AckData{MessageIDs: ids}
+		return message
+	}
+
+	mt.Run("确认在途消息后标记已送达", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: toBSON(mt, delivered)}))
+
+		hub := NewHub()
+		client := newTestClient(hub, 1)
+		tracked := NewMessage(MessageTypePrivate, 2, 1, "hi")
+		tracked.ID = messageID.Hex()
+		client.SendTracked(tracked, tracked.ID)
+		hub.HandleAckMessage(client, ackMessage(messageID.Hex()))
+
+		if len(client.inflight) != 0 {
+			mt.Errorf("确认后在途消息 = %v, want none", client.inflight)
+		}
+		command := nextCommand(mt, "findAndModify")
+		if status := command.Lookup("update", "$set", "status").StringValue(); status != model.MessageStatusDelivered {
+			mt.Errorf("更新的状态 = %q, want delivered", status)
+		}
+	})
+
+	mt.Run("上一个连接的消息校验接收者", func(mt *mtest.T) {
+		useMockMongo(mt)
+		foreign := delivered
+		foreign.ToUserID = 3
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch, toBSON(mt, foreign)))
+
+		hub := NewHub()
+		hub.HandleAckMessage(newTestClient(hub, 1), ackMessage(messageID.Hex()))
+
+		nextCommand(mt, "find")
+		if event := mt.GetStartedEvent(); event != nil {
+			mt.Errorf("不能确认发给其他用户的消息, got %s", event.CommandName)
+		}
+	})
+
+	t.Run("单次确认过多", func(t *testing.T) {
+		hub := NewHub()
+		client := newTestClient(hub, 1)
+		hub.HandleAckMessage(client, ackMessage(make([]string, maxAckBatch+1)...))
+
+		errs := messagesOfType(receivedMessages(t, client), MessageTypeError)
+		if len(errs) != 1 || messageData(errs[0])["message"] != "单次确认的消息过多" {
+			t.Errorf("错误消息 = %v, want 单次确认的消息过多", errs)
+		}
+	})
+}
+
+func TestCloseClientStoresInflight(t *testing.T) {
+	setupMessageConfig(t)
+	mt := newMockMongo(t)
+
+	mt.Run("未确认的消息转为离线消息", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}))
+
+		hub := NewHub()
+		client := newTestClient(hub, 1)
+		tracked := NewMessage(MessageTypePrivate, 2, 1, "hi")
+		tracked.ID = primitive.NewObjectID().Hex()
+		client.SendTracked(tracked, tracked.ID)
+		// 连接已经关闭，只需要处理在途消息
+		client.IsAlive = false
+		hub.closeClient(client)
+		hub.workers.Wait()
+
+		if len(client.inflight) != 0 {
+			mt.Errorf("关闭后在途消息 = %v, want none", client.inflight)
+		}
+		command := nextCommand(mt, "findAndModify")
+		if status := command.Lookup("update", "$set", "status").StringValue(); status != model.MessageStatusStored {
+			mt.Errorf("更新的状态 = %q, want stored", status)
+		}
+	})
+}
+
+func TestDeliverOfflineMessagesSerialized(t *testing.T) {
+	setupMessageConfig(t)
+	mt := newMockMongo(t)
+
+	mt.Run("补发等待进行中的确认完成且不重复发送", func(mt *mtest.T) {
+		useMockMongo(mt)
+		pending := model.ChatMessage{ID: primitive.NewObjectID(), Type: string(MessageTypePrivate), SessionID: "private_1_2", FromUserID: 2, ToUserID: 1, Content: "hi", Status: model.MessageStatusStored, Timestamp: time.Now()}
+		for i := 0; i < 2; i++ {
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch, toBSON(mt, pending)))
+		}
+
+		hub := NewHub()
+		client := newTestClient(hub, 1)
+
+		// 模拟确认处理中，补发需要等待确认完成后再查询
+		client.flushMu.Lock()
+		done := make(chan struct{})
+		go func() {
+			hub.deliverOfflineMessages(client)
+			close(done)
+		}()
+		select {
+		case <-done:
+			mt.Fatal("确认处理中时补发不应执行")
+		case <-time.After(50 * time.Millisecond):
+		}
+		client.flushMu.Unlock()
+		<-done
+
+		// 再次补发查询到同一条消息时，已在途的消息不再发送
+		hub.deliverOfflineMessages(client)
+		if got := messagesOfType(receivedMessages(mt.T, client), MessageTypePrivate); len(got) != 1 || got[0].ID != pending.ID.Hex() {
+			mt.Errorf("补发的消息 = %v, want 1 条", got)
+		}
+	})
+}
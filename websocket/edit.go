@@ -0,0 +1,43 @@
+package websocket
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// HandleEditMessage 处理编辑消息，content为新内容，data中携带被编辑的消息ID
+func (h *Hub) HandleEditMessage(from *Client, message *Message) {
+	var data MessageEditData
+	if err := message.DecodeData(&data); err != nil || data.MessageID == "" {
+		from.SendError(400, "被编辑的消息ID不能为空")
+		return
+	}
+
+	if _, err := h.EditMessage(from.UserID, data.MessageID, message.Content); err != nil {
+		from.SendError(400, err.Error())
+	}
+}
+
+// EditMessage 编辑已发送的消息，并将新内容推送给在线的会话参与者
+func (h *Hub) EditMessage(userID uint, messageID, content string) (*Message, error) {
+	stored, err := messageService.EditMessage(userID, messageID, content)
+	if err != nil {
+		return nil, err
+	}
+
+	edit := NewMessage(MessageTypeEdit, stored.FromUserID, stored.ToUserID, stored.Content)
+	edit.Seq = stored.Seq
+	edit.GroupID = stored.GroupID
+	edit.Timestamp = *stored.EditedAt
+	edit.Data = MessageEditData{
+		MessageID: stored.ID.Hex(),
+		SessionID: stored.SessionID,
+		EditedAt:  *stored.EditedAt,
+	}
+
+	for _, client := range h.participantClients(stored) {
+		client.SendMessage(edit)
+	}
+
+	logrus.Infof("用户 %d 编辑了消息 %s", userID, messageID)
+	return edit, nil
+}
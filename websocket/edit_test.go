@@ -0,0 +1,58 @@
+package websocket
+
+import (
+	"go_chat/config"
+	"go_chat/model"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestEditMessagePushesToBothSides(t *testing.T) {
+	previous := config.MessageEditWindow
+	t.Cleanup(func() { config.MessageEditWindow = previous })
+	config.MessageEditWindow = 15 * time.Minute
+	mt := newMockMongo(t)
+
+	mt.Run("推送给在线的发送者和接收者", func(mt *mtest.T) {
+		useMockMongo(mt)
+		message := model.ChatMessage{ID: primitive.NewObjectID(), SessionID: "private_1_2", Seq: 3, FromUserID: 1, ToUserID: 2, Content: "hello", Timestamp: time.Now()}
+		editedAt := time.Now()
+		edited := message
+		edited.Content, edited.Edited, edited.EditedAt = "changed", true, &editedAt
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch, toBSON(mt, message)),
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: toBSON(mt, edited)}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		hub := NewHub()
+		sender, receiver := newTestClient(hub, 1), newTestClient(hub, 2)
+		hub.UserClients[1], hub.UserClients[2] = sender, receiver
+
+		request := NewMessage(MessageTypeEdit, 1, 2, "changed")
+		request.Data = MessageEditData{MessageID: message.ID.Hex()}
+		hub.HandleEditMessage(sender, request)
+
+		for _, client := range []*Client{sender, receiver} {
+			edits := messagesOfType(receivedMessages(mt, client), MessageTypeEdit)
+			if len(edits) != 1 || edits[0].Content != "changed" || edits[0].Seq != 3 || messageData(edits[0])["message_id"] != message.ID.Hex() {
+				mt.Errorf("用户 %d 收到的编辑 = %v", client.UserID, edits)
+			}
+		}
+	})
+
+	t.Run("缺少消息ID", func(t *testing.T) {
+		hub := NewHub()
+		sender := newTestClient(hub, 1)
+		hub.HandleEditMessage(sender, NewMessage(MessageTypeEdit, 1, 2, "changed"))
+
+		errs := messagesOfType(receivedMessages(t, sender), MessageTypeError)
+		if len(errs) != 1 || messageData(errs[0])["message"] != "被编辑的消息ID不能为空" {
+			t.Errorf("错误消息 = %v, want 被编辑的消息ID不能为空", errs)
+		}
+	})
+}
@@ -0,0 +1,266 @@
+package websocket
+
+import (
+	"fmt"
+	"go_chat/model"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// GroupMessageRequest 群聊消息请求
+type GroupMessageRequest struct {
+	From      *Client // 系统消息为空
+	Message   *Message
+	MemberIDs []uint // 发送时的群成员
+}
+
+// HandleGroupMessage 处理来自客户端的群聊消息，每次发送都会校验发送者是否为群成员
+func (h *Hub) HandleGroupMessage(from *Client, message *Message) {
+	if message.GroupID == 0 {
+		from.SendError(400, "群组ID不能为空")
+		return
+	}
+
+	if len(message.ClientMsgID) > maxClientMsgIDLength {
+		from.SendError(400, "client_msg_id过长")
+		return
+	}
+
+	if strings.TrimSpace(message.Content) == "" && len(message.Attachments) == 0 {
+		from.SendError(400, "消息内容不能为空")
+		return
+	}
+
+	// 校验成员身份、禁言和慢速模式
+	if wait, err := groupService.CheckSendPermission(message.GroupID, from.UserID, message.ClientMsgID); err != nil {
+		h.rejectGroupSend(from, message, wait, err)
+		return
+	}
+
+	originalID := message.ID
+	memberIDs, err := groupService.ListMemberIDs(message.GroupID)
+	if err != nil {
+		h.releaseGroupSend(from, message, originalID, err)
+		return
+	}
+
+	// 群聊消息只存储一份，离线成员上线后根据送达记录补发
+	stored := &model.ChatMessage{
+		ClientMsgID: message.ClientMsgID,
+		Type:        string(message.Type),
+		GroupID:     message.GroupID,
+		FromUserID:  from.UserID,
+		Content:     message.Content,
+		Timestamp:   message.Timestamp,
+	}
+
+	quote, attachments, err := h.resolveReferences(from, message, model.GroupSessionID(message.GroupID), stored)
+	if err != nil {
+		h.releaseGroupSend(from, message, originalID, err)
+		return
+	}
+
+	duplicate, err := messageService.SaveGroupMessage(stored)
+	if err != nil {
+		h.releaseGroupSend(from, message, originalID, err)
+		return
+	}
+
+	status := MessageStatusData{
+		MessageID:         stored.ID.Hex(),
+		OriginalMessageID: originalID,
+		ClientMsgID:       stored.ClientMsgID,
+		SessionID:         stored.SessionID,
+		GroupID:           stored.GroupID,
+		Status:            stored.Status,
+		Duplicate:         duplicate,
+		UpdatedAt:         stored.UpdatedAt,
+	}
+	if duplicate {
+		logrus.Infof("用户 %d 重复发送群聊消息 %s，返回原消息 %s", from.UserID, message.ClientMsgID, stored.ID.Hex())
+		from.SendMessage(NewStatusMessage(status))
+		return
+	}
+
+	if err := attachmentService.ShareWithGroup(stored.Attachments, stored.GroupID); err != nil {
+		logrus.Warnf("消息 %s 附件授权失败: %v", stored.ID.Hex(), err)
+	}
+
+	message.FromUserID = from.UserID
+	message.ToUserID = 0
+	applyStored(message, stored, quote, attachments)
+	from.SendMessage(NewStatusMessage(status))
+
+	h.GroupMessage <- &GroupMessageRequest{
+		From:      from,
+		Message:   message,
+		MemberIDs: memberIDs,
+	}
+}
+
+// rejectGroupSend 拒绝群聊发言，向发送者返回对应的错误码和需要等待的时长
+func (h *Hub) rejectGroupSend(from *Client, message *Message, wait time.Duration, err error) {
+	code := 500
+	switch err.Error() {
+	case "群组不存在":
+		code = 404
+	case "不是群成员":
+		code = 403
+	case "已被禁止加入该群组":
+		code = ErrorCodeGroupBanned
+	case "已被禁言":
+		code = ErrorCodeGroupMuted
+	case "慢速模式下发言过于频繁":
+		code = ErrorCodeGroupSlowMode
+	}
+
+	errorMessage := NewErrorMessage(code, err.Error())
+	errorMessage.Data = ErrorData{
+		Code:        code,
+		Message:     err.Error(),
+		GroupID:     message.GroupID,
+		ClientMsgID: message.ClientMsgID,
+		RetryAfter:  int((wait + time.Second - 1) / time.Second),
+	}
+	from.SendMessage(errorMessage)
+}
+
+// releaseGroupSend 群聊消息未能保存时通知发送者，并释放慢速模式的发言间隔
+func (h *Hub) releaseGroupSend(from *Client, message *Message, originalID string, err error) {
+	groupService.ReleaseSendSlot(message.GroupID, from.UserID)
+	h.rejectMessage(from, message, originalID, err)
+}
+
+// handleGroupMessage 将群聊消息推送给在线成员，需要成员确认收到
+func (h *Hub) handleGroupMessage(req *GroupMessageRequest) {
+	delivered := 0
+	for _, client := range h.onlineClients(req.MemberIDs) {
+		if client.UserID == req.Message.FromUserID {
+			continue
+		}
+		// 发送失败的成员在重连时按送达记录补发
+		if client.SendTracked(req.Message, req.Message.ID) {
+			delivered++
+		}
+	}
+	logrus.Infof("群 %d 消息已推送给 %d 名在线成员，共 %d 名成员",
+		req.Message.GroupID, delivered, len(req.MemberIDs))
+}
+
+// NotifyGroup 向群组在线成员推送群组事件，extraUserIDs为已不在群中但需要收到通知的用户
+func (h *Hub) NotifyGroup(event GroupEventData, extraUserIDs ...uint) {
+	memberIDs, err := groupService.ListMemberIDs(event.GroupID)
+	if err != nil {
+		logrus.Warnf("群 %d 事件推送失败: %v", event.GroupID, err)
+		return
+	}
+
+	notice := NewSystemMessage(MessageTypeGroupEvent, event)
+	notice.FromUserID = event.OperatorID
+	notice.GroupID = event.GroupID
+	for _, client := range h.onlineClients(append(memberIDs, extraUserIDs...)) {
+		client.SendMessage(notice)
+	}
+}
+
+// AnnounceGroupJoin 用户通过邀请链接入群后，向群内写入一条系统入群消息并推送给在线成员，
+// 同时推送成员变化的群组事件。系统消息的发送者为0，离线成员上线后按送达记录补发
+func (h *Hub) AnnounceGroupJoin(groupID, userID, operatorID uint) {
+	name := fmt.Sprintf("用户 %d", userID)
+	if user, err := authService.GetUserByID(userID); err == nil {
+		name = user.UserName
+	}
+
+	stored := &model.ChatMessage{
+		Type:      string(MessageTypeJoin),
+		GroupID:   groupID,
+		Content:   fmt.Sprintf("%s 通过邀请链接加入了群聊", name),
+		Timestamp: time.Now(),
+	}
+	if _, err := messageService.SaveGroupMessage(stored); err != nil {
+		logrus.Warnf("群 %d 入群消息保存失败: %v", groupID, err)
+	} else if memberIDs, err := groupService.ListMemberIDs(groupID); err == nil {
+		h.GroupMessage <- &GroupMessageRequest{
+			Message:   NewMessageFromStored(stored),
+			MemberIDs: memberIDs,
+		}
+	}
+
+	h.NotifyGroup(GroupEventData{
+		GroupID:    groupID,
+		Event:      GroupEventMembersAdded,
+		OperatorID: operatorID,
+		UserIDs:    []uint{userID},
+	})
+}
+
+// participantClients 获取可以看到消息的在线用户连接：私聊为会话双方，群聊为当前群成员，频道为在线订阅者
+func (h *Hub) participantClients(stored *model.ChatMessage) []*Client {
+	if stored.ChannelID != 0 {
+		return h.onlineClients(h.channels.online(stored.ChannelID))
+	}
+	if stored.GroupID == 0 {
+		return h.onlineClients([]uint{stored.FromUserID, stored.ToUserID})
+	}
+
+	memberIDs, err := groupService.ListMemberIDs(stored.GroupID)
+	if err != nil {
+		logrus.Warnf("群 %d 成员查询失败: %v", stored.GroupID, err)
+		return nil
+	}
+	return h.onlineClients(memberIDs)
+}
+
+// onlineClients 获取指定用户中在线的连接
+func (h *Hub) onlineClients(userIDs []uint) []*Client {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	clients := make([]*Client, 0, len(userIDs))
+	seen := make(map[uint]bool, len(userIDs))
+	for _, userID := range userIDs {
+		if seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		if client, ok := h.UserClients[userID]; ok {
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
+// pendingGroupMessages 获取用户在各群中尚未确认收到的消息
+func (h *Hub) pendingGroupMessages(userID uint) []model.ChatMessage {
+	memberships, err := groupService.ListMemberships(userID)
+	if err != nil {
+		logrus.Warnf("用户 %d 群组查询失败: %v", userID, err)
+		return nil
+	}
+
+	messages, err := messageService.ListPendingGroupMessages(userID, memberships, offlineFlushLimit)
+	if err != nil {
+		logrus.Warnf("用户 %d 群聊离线消息查询失败: %v", userID, err)
+		return nil
+	}
+	return messages
+}
+
+// mergePending 按时间顺序合并私聊和群聊离线消息，最多保留limit条
+func mergePending(private, group []model.ChatMessage, limit int) []model.ChatMessage {
+	if len(group) == 0 {
+		return private
+	}
+
+	merged := append(private, group...)
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Timestamp.Before(merged[j].Timestamp)
+	})
+	if len(merged) > limit {
+		merged = merged[:limit]
+	}
+	return merged
+}
@@ -0,0 +1,151 @@
+package websocket
+
+import (
+	"go_chat/model"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMergePending(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	message := func(content string, offset int) model.ChatMessage {
+		return model.ChatMessage{Content: content, Timestamp: base.Add(time.Duration(offset) * time.Second)}
+	}
+
+	tests := []struct {
+		name    string
+		private []model.ChatMessage
+		group   []model.ChatMessage
+		limit   int
+		want    []string
+	}{
+		{"都为空", nil, nil, 10, nil},
+		{"只有私聊", []model.ChatMessage{message("p1", 1), message("p2", 2)}, nil, 10, []string{"p1", "p2"}},
+		{"只有群聊", nil, []model.ChatMessage{message("g1", 1), message("g2", 2)}, 10, []string{"g1", "g2"}},
+		{
+			"按时间交错合并",
+			[]model.ChatMessage{message("p1", 1), message("p3", 3)},
+			[]model.ChatMessage{message("g2", 2), message("g4", 4)},
+			10,
+			[]string{"p1", "g2", "p3", "g4"},
+		},
+		{
+			"时间相同时私聊在前",
+			[]model.ChatMessage{message("p1", 1)},
+			[]model.ChatMessage{message("g1", 1)},
+			10,
+			[]string{"p1", "g1"},
+		},
+		{
+			"超出上限时保留最早的消息",
+			[]model.ChatMessage{message("p2", 2), message("p5", 5)},
+			[]model.ChatMessage{message("g1", 1), message("g3", 3), message("g4", 4)},
+			3,
+			[]string{"g1", "p2", "g3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, merged := range mergePending(tt.private, tt.group, tt.limit) {
+				got = append(got, merged.Content)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("mergePending() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleGroupMessageFanOut(t *testing.T) {
+	setupMessageConfig(t)
+	hub := NewHub()
+	sender, online := newTestClient(hub, 1), newTestClient(hub, 2)
+	outsider := newTestClient(hub, 4)
+	hub.UserClients[1], hub.UserClients[2], hub.UserClients[4] = sender, online, outsider
+
+	message := NewMessage(MessageTypeGroup, 1, 0, "hello")
+	message.ID, message.GroupID = "group-message", 7
+	hub.handleGroupMessage(&GroupMessageRequest{From: sender, Message: message, MemberIDs: []uint{1, 2, 3}})
+
+	if got := messagesOfType(receivedMessages(t, online), MessageTypeGroup); len(got) != 1 || got[0].GroupID != 7 {
+		t.Errorf("在线成员收到 = %v, want 1 条群聊消息", got)
+	}
+	if _, tracked := online.inflight["group-message"]; !tracked {
+		t.Error("群聊消息应等待成员确认")
+	}
+	for _, client := range []*Client{sender, outsider} {
+		if got := receivedMessages(t, client); len(got) != 0 {
+			t.Errorf("用户 %d 不应收到群聊消息, got %v", client.UserID, got)
+		}
+	}
+}
+
+func TestHandleGroupMessageRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		message func() *Message
+		wantErr string
+	}{
+		{"缺少群组ID", func() *Message { return NewMessage(MessageTypeGroup, 1, 0, "hi") }, "群组ID不能为空"},
+		{"内容为空", func() *Message {
+			message := NewMessage(MessageTypeGroup, 1, 0, " ")
+			message.GroupID = 7
+			return message
+		}, "消息内容不能为空"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub()
+			sender := newTestClient(hub, 1)
+			hub.HandleGroupMessage(sender, tt.message())
+
+			errs := messagesOfType(receivedMessages(t, sender), MessageTypeError)
+			if len(errs) != 1 || messageData(errs[0])["message"] != tt.wantErr {
+				t.Errorf("错误消息 = %v, want %s", errs, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandleGroupMessageModeration(t *testing.T) {
+	roomID := setupRooms(t, 1, 2)
+	hub := NewHub()
+	if _, err := groupService.MuteMember(1, roomID, 2, 90); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		userID    uint
+		wantCode  float64
+		wantErr   string
+		wantRetry bool
+	}{
+		{"被禁言", 2, ErrorCodeGroupMuted, "已被禁言", true},
+		{"不是群成员", 3, 403, "不是群成员", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := newTestClient(hub, tt.userID)
+			message := NewMessage(MessageTypeGroup, tt.userID, 0, "hi")
+			message.GroupID, message.ClientMsgID = roomID, "c1"
+			hub.HandleGroupMessage(sender, message)
+
+			errs := messagesOfType(receivedMessages(t, sender), MessageTypeError)
+			if len(errs) != 1 {
+				t.Fatalf("错误消息 = %v, want 1", errs)
+			}
+			data := messageData(errs[0])
+			if data["code"] != tt.wantCode || data["message"] != tt.wantErr || data["client_msg_id"] != "c1" || data["group_id"] != float64(roomID) {
+				t.Errorf("错误消息 = %v, want %v %s", data, tt.wantCode, tt.wantErr)
+			}
+			if retry, _ := data["retry_after"].(float64); (retry > 0) != tt.wantRetry || retry > 90 {
+				t.Errorf("retry_after = %v", data["retry_after"])
+			}
+		})
+	}
+}
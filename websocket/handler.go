@@ -1,13 +1,21 @@
 package websocket
 
 import (
+	"go_chat/middleware"
+	"go_chat/service"
 	"net/http"
-	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
 )
 
+// tokenSubprotocol 通过子协议传递令牌时使用的协议名，格式: ["access_token", "<token>"]
+const tokenSubprotocol = "access_token"
+
+var authService = service.NewAuthService()
+
 // Handler WebSocket处理器
 type Handler struct {
 	Hub *Hub
@@ -22,32 +30,33 @@ func NewHandler(hub *Hub) *Handler {
 
 // HandleWebSocket 处理WebSocket连接升级
 func (h *Handler) HandleWebSocket(c *gin.Context) {
-	logrus.Infof("收到WebSocket连接请求: %s", c.Request.URL.String())
-
-	// 从查询参数获取用户信息（暂时用于测试）
-	userIDStr := c.Query("user_id")
-	userName := c.Query("username")
-
-	logrus.Infof("连接参数: userID=%s, userName=%s", userIDStr, userName)
-
-	if userIDStr == "" || userName == "" {
-		logrus.Warnf("连接参数不完整: userID=%s, userName=%s", userIDStr, userName)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "user_id和username参数不能为空",
+	logrus.Infof("收到WebSocket连接请求: %s", c.Request.URL.Path)
+
+	// 从请求头、子协议或查询参数获取访问令牌
+	token := extractToken(c.Request)
+	if token == "" {
+		logrus.Warn("WebSocket连接缺少访问令牌")
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "缺少访问令牌",
 		})
 		return
 	}
 
-	userID, err := strconv.ParseUint(userIDStr, 10, 32)
+	// 校验令牌并加载用户信息
+	claims, user, err := authService.Authenticate(token)
 	if err != nil {
-		logrus.Errorf("userID解析失败: %s, 错误: %v", userIDStr, err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "user_id格式错误",
+		logrus.Warnf("WebSocket令牌校验失败: %v", err)
+		status := http.StatusUnauthorized
+		if err.Error() == "数据库连接不可用" {
+			status = http.StatusInternalServerError
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
 		})
 		return
 	}
 
-	logrus.Infof("准备升级WebSocket连接...")
+	logrus.Infof("令牌校验通过: userID=%d, userName=%s", claims.UserID, user.UserName)
 
 	// 升级HTTP连接为WebSocket
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
@@ -58,26 +67,31 @@ func (h *Handler) HandleWebSocket(c *gin.Context) {
 
 	logrus.Infof("WebSocket升级成功，创建客户端连接...")
 
-	// 创建客户端连接
-	client := NewClient(h.Hub, conn, uint(userID), userName)
-
-	logrus.Infof("客户端创建完成，准备注册到Hub...")
+	// 创建客户端连接，用户信息以数据库为准
+	client := NewClient(h.Hub, conn, user.ID, user.UserName, user.Avatar)
+	client.SessionID = claims.SessionID
+	client.resumeFrom = c.Query("resume_token")
 
 	// 注册客户端
 	h.Hub.Register <- client
 
-	logrus.Infof("客户端已发送到注册通道，启动协程...")
-
 	// 启动客户端协程
 	go client.WritePump()
 	go client.ReadPump()
 
-	logrus.Infof("WebSocket连接处理完成：用户 %s (ID: %d)", userName, userID)
+	logrus.Infof("WebSocket连接处理完成：用户 %s (ID: %d)", user.UserName, user.ID)
 }
 
-// GetOnlineUsers 获取在线用户列表API
+// GetOnlineUsers 获取与当前用户同在公开房间的在线用户列表API
 func (h *Handler) GetOnlineUsers(c *gin.Context) {
-	users := h.Hub.getOnlineUsers()
+	users, err := h.Hub.PeerOnlineUsers(middleware.CurrentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": err.Error(),
+		})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "获取在线用户列表成功",
@@ -87,3 +101,23 @@ func (h *Handler) GetOnlineUsers(c *gin.Context) {
 		},
 	})
 }
+
+// extractToken 依次从Authorization头、Sec-WebSocket-Protocol子协议和token查询参数中读取令牌
+func extractToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		parts := strings.SplitN(auth, " ", 2)
+		if len(parts) == 2 && strings.EqualFold(parts[0], "Bearer") {
+			return strings.TrimSpace(parts[1])
+		}
+	}
+
+	// 浏览器无法自定义请求头，允许通过子协议传递: new WebSocket(url, ["access_token", token])
+	protocols := websocket.Subprotocols(r)
+	for i, protocol := range protocols {
+		if protocol == tokenSubprotocol && i+1 < len(protocols) {
+			return protocols[i+1]
+		}
+	}
+
+	return r.URL.Query().Get("token")
+}
@@ -0,0 +1,47 @@
+package websocket
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExtractToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		headers map[string]string
+		want    string
+	}{
+		{"没有令牌", "/ws", nil, ""},
+		{"Authorization头", "/ws", map[string]string{"Authorization": "Bearer header-token"}, "header-token"},
+		{"Bearer不区分大小写", "/ws", map[string]string{"Authorization": "bearer  header-token "}, "header-token"},
+		{"子协议", "/ws", map[string]string{"Sec-WebSocket-Protocol": "access_token, proto-token"}, "proto-token"},
+		{"子协议缺少令牌", "/ws", map[string]string{"Sec-WebSocket-Protocol": "access_token"}, ""},
+		{"查询参数", "/ws?token=query-token", nil, "query-token"},
+		{
+			"请求头优先于子协议和查询参数",
+			"/ws?token=query-token",
+			map[string]string{"Authorization": "Bearer header-token", "Sec-WebSocket-Protocol": "access_token, proto-token"},
+			"header-token",
+		},
+		{
+			"子协议优先于查询参数",
+			"/ws?token=query-token",
+			map[string]string{"Sec-WebSocket-Protocol": "access_token, proto-token"},
+			"proto-token",
+		},
+		{"非Bearer认证头", "/ws?token=query-token", map[string]string{"Authorization": "Basic abc"}, "query-token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.url, nil)
+			for key, value := range tt.headers {
+				r.Header.Set(key, value)
+			}
+			if got := extractToken(r); got != tt.want {
+				t.Errorf("extractToken() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,12 +1,29 @@
 package websocket
 
 import (
+	"errors"
+	"go_chat/model"
+	"go_chat/service"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	offlineFlushLimit    = 200 // 上线时单次补发的离线消息上限，需小于客户端发送缓冲区
+	maxClientMsgIDLength = 64  // 客户端消息ID最大长度
+)
+
+var (
+	messageService      = service.NewMessageService()
+	conversationService = service.NewConversationService()
+	attachmentService   = service.NewAttachmentService()
+	groupService        = service.NewGroupService()
+	channelService      = service.NewChannelService()
+)
+
 // Hub 维护活跃客户端集合并向客户端广播消息
 type Hub struct {
 	// 客户端管理
@@ -16,10 +33,18 @@ type Hub struct {
 	// 消息通道
 	Register   chan *Client  // 客户端注册通道
 	Unregister chan *Client  // 客户端注销通道
+	Expire     chan *Client  // 断线宽限期结束通道
 	Broadcast  chan *Message // 广播消息通道
 
-	// 私聊处理
+	// 私聊、群聊和频道处理
 	PrivateMessage chan *PrivateMessageRequest // 私聊消息通道
+	GroupMessage   chan *GroupMessageRequest   // 群聊消息通道
+	ChannelMessage chan *ChannelMessageRequest // 频道消息通道
+
+	channels *channelIndex // 频道在线订阅者
+
+	// 主循环之外执行的存储操作
+	workers sync.WaitGroup
 
 	// 并发控制
 	mu sync.RWMutex
@@ -38,8 +63,12 @@ func NewHub() *Hub {
 		UserClients:    make(map[uint]*Client),
 		Register:       make(chan *Client),
 		Unregister:     make(chan *Client),
+		Expire:         make(chan *Client),
 		Broadcast:      make(chan *Message),
 		PrivateMessage: make(chan *PrivateMessageRequest),
+		GroupMessage:   make(chan *GroupMessageRequest),
+		ChannelMessage: make(chan *ChannelMessageRequest),
+		channels:       newChannelIndex(),
 	}
 }
 
@@ -48,6 +77,8 @@ func (h *Hub) Run() {
 	logrus.Info("🚀 Hub主循环开始运行...")
 	// 启动清理协程
 	go h.startCleanupRoutine()
+	// 启动重传协程
+	go h.startRetransmitRoutine()
 
 	for {
 		select {
@@ -58,11 +89,20 @@ func (h *Hub) Run() {
 		case client := <-h.Unregister:
 			h.unregisterClient(client)
 
+		case client := <-h.Expire:
+			h.expireClient(client)
+
 		case message := <-h.Broadcast:
 			h.broadcastMessage(message)
 
 		case privateMsg := <-h.PrivateMessage:
 			h.handlePrivateMessage(privateMsg)
+
+		case groupMsg := <-h.GroupMessage:
+			h.handleGroupMessage(groupMsg)
+
+		case channelMsg := <-h.ChannelMessage:
+			h.handleChannelMessage(channelMsg)
 		}
 	}
 }
@@ -72,51 +112,168 @@ func (h *Hub) registerClient(client *Client) {
 	logrus.Infof("Hub开始注册客户端: 用户 %s (ID: %d)", client.UserName, client.UserID)
 
 	h.mu.Lock()
-	defer h.mu.Unlock()
-
 	// 检查是否已有同用户的连接
-	if existingClient, exists := h.UserClients[client.UserID]; exists {
+	existingClient, replaced := h.UserClients[client.UserID]
+	if replaced {
 		logrus.Infof("用户 %d 重新连接，关闭旧连接", client.UserID)
-		existingClient.Close()
 		delete(h.Clients, existingClient)
 	}
 
 	// 注册新客户端
 	h.Clients[client] = true
 	h.UserClients[client.UserID] = client
+	onlineCount := len(h.Clients)
+	h.mu.Unlock()
+
+	// 凭恢复令牌重连时续接原会话，其他用户不会看到离开和加入
+	resumed := replaced && existingClient.canBeResumedBy(client)
+	var pending []*Message
+	var dropped int
+	if replaced {
+		pending, dropped = existingClient.takeSuspension()
+		if !resumed {
+			pending, dropped = nil, 0
+		}
+		// 旧连接未确认的消息转为离线消息，随后通过新连接补发
+		h.closeClient(existingClient)
+	}
+
+	h.sendResumeToken(client, resumed, dropped)
+
+	if resumed {
+		for _, message := range pending {
+			client.SendMessage(message)
+		}
+		h.background(func() { h.deliverPending(client) })
+		logrus.Infof("用户 %d 续接会话，补发 %d 条缓存消息，当前在线用户: %d",
+			client.UserID, len(pending), onlineCount)
+		return
+	}
 
 	logrus.Infof("用户 %s (ID: %d) 已连接，当前在线用户: %d",
-		client.UserName, client.UserID, len(h.Clients))
+		client.UserName, client.UserID, onlineCount)
+
+	// 加载订阅的频道，频道消息不补发，由客户端按已读位置读取历史消息
+	h.trackChannels(client)
 
-	// 发送用户列表给新用户
-	logrus.Infof("发送用户列表给新用户 %d", client.UserID)
-	h.sendUserListToClient(client)
+	// 补发离线消息和已读回执
+	h.background(func() { h.deliverPending(client) })
 
-	// 通知其他用户有新用户加入
-	logrus.Infof("广播用户加入消息: %d", client.UserID)
+	// 通知同房间的用户有新用户上线，并发送在线用户列表给新用户
+	logrus.Infof("推送用户上线消息: %d", client.UserID)
 	h.broadcastUserJoin(client)
 
 	logrus.Infof("用户 %d 注册完成", client.UserID)
 }
 
-// unregisterClient 注销客户端
+// unregisterClient 注销客户端，连接意外断开时先进入宽限期等待重连
 func (h *Hub) unregisterClient(client *Client) {
 	h.mu.Lock()
-	defer h.mu.Unlock()
+	if _, ok := h.Clients[client]; !ok || client.awaitingResume() {
+		// 已经注销，或正处于宽限期
+		h.mu.Unlock()
+		return
+	}
+	if client.suspend() {
+		h.mu.Unlock()
+		h.releaseInflight(client)
+		logrus.Infof("用户 %d 连接断开，保留会话等待重连", client.UserID)
+		return
+	}
+	h.mu.Unlock()
 
-	if _, ok := h.Clients[client]; ok {
+	h.removeClient(client)
+}
+
+// removeClient 移除客户端并通知其他用户该用户已离开
+func (h *Hub) removeClient(client *Client) {
+	h.mu.Lock()
+	_, ok := h.Clients[client]
+	offline := false
+	if ok {
 		delete(h.Clients, client)
-		delete(h.UserClients, client.UserID)
-		client.Close()
+		// 用户可能已经用新连接替换了当前连接
+		if h.UserClients[client.UserID] == client {
+			delete(h.UserClients, client.UserID)
+			offline = true
+		}
+	}
+	onlineCount := len(h.Clients)
+	h.mu.Unlock()
+
+	if offline {
+		h.channels.forget(client.UserID)
+	}
+
+	if ok {
+		h.closeClient(client)
 
 		logrus.Infof("用户 %s (ID: %d) 已断开连接，当前在线用户: %d",
-			client.UserName, client.UserID, len(h.Clients))
+			client.UserName, client.UserID, onlineCount)
 
-		// 通知其他用户有用户离开
+		// 通知同房间的用户有用户下线
 		h.broadcastUserLeave(client)
 	}
 }
 
+// background 在主循环之外执行可能阻塞的存储操作，数据库或Redis变慢时不影响其他连接的注册和消息转发。
+// 操作结果通过客户端的发送通道推送，不修改Hub的状态
+func (h *Hub) background(task func()) {
+	h.workers.Add(1)
+	go func() {
+		defer h.workers.Done()
+		task()
+	}()
+}
+
+// deliverPending 补发用户的离线消息和离线期间收到的已读回执
+func (h *Hub) deliverPending(client *Client) {
+	h.deliverOfflineMessages(client)
+	h.deliverPendingReceipts(client)
+}
+
+// storeOffline 将无法投递的私聊消息标记为离线消息。标记在主循环之外执行，接收者可能在标记完成前
+// 已经重新上线并完成了补发，因此标记后接收者在线时再补发一次，已在途的消息不会重复发送
+func (h *Hub) storeOffline(userID uint, messageIDs []string) {
+	if len(messageIDs) == 0 {
+		return
+	}
+	for _, messageID := range messageIDs {
+		h.markStored(messageID)
+	}
+	if client, online := h.GetUserClient(userID); online && client.isOnline() {
+		h.deliverOfflineMessages(client)
+	}
+}
+
+// deliverOfflineMessages 按时间顺序补发用户的离线消息。注册、确认和重传都会触发补发，
+// 同一连接的补发串行执行：后执行的补发重新查询时，已在途的消息不会再次发送
+func (h *Hub) deliverOfflineMessages(client *Client) {
+	client.flushMu.Lock()
+	defer client.flushMu.Unlock()
+
+	// 单次补发数量不超过发送缓冲区，剩余消息在下次连接时继续补发
+	messages, err := messageService.ListPendingOfflineMessages(client.UserID, offlineFlushLimit)
+	if err != nil {
+		logrus.Warnf("用户 %d 离线消息查询失败: %v", client.UserID, err)
+		return
+	}
+	messages = mergePending(messages, h.pendingGroupMessages(client.UserID), offlineFlushLimit)
+	if len(messages) == 0 {
+		return
+	}
+
+	// 客户端确认收到后才会标记为已送达，在途窗口已满时剩余消息等待窗口腾出空位后补发
+	queued := 0
+	for _, message := range NewMessagesFromStored(messages) {
+		if !client.SendTracked(message, message.ID) {
+			break
+		}
+		queued++
+	}
+	logrus.Infof("已向用户 %d 补发 %d 条离线消息", client.UserID, queued)
+}
+
 // broadcastMessage 广播消息给所有客户端
 func (h *Hub) broadcastMessage(message *Message) {
 	h.mu.RLock()
@@ -138,25 +295,16 @@ func (h *Hub) handlePrivateMessage(req *PrivateMessageRequest) {
 	targetClient, exists := h.UserClients[req.Message.ToUserID]
 	h.mu.RUnlock()
 
-	if !exists {
-		// 目标用户不在线，发送离线消息提示
-		req.From.SendError(404, "目标用户不在线")
-		logrus.Infof("私聊消息发送失败：用户 %d 不在线", req.Message.ToUserID)
+	// 目标用户不在线或发送队列已满时，消息转为离线消息，待对方上线后补发
+	if !exists || !targetClient.SendTracked(req.Message, req.Message.ID) {
+		message := req.Message
+		h.background(func() { h.storeOffline(message.ToUserID, []string{message.ID}) })
+		logrus.Infof("用户 %d 暂时无法接收，私聊消息已存储为离线消息", req.Message.ToUserID)
 		return
 	}
 
-	// 发送消息给目标用户
-	targetClient.SendMessage(req.Message)
-
-	// 发送确认消息给发送者
-	ackMessage := NewMessage(MessageTypeRead, req.Message.ToUserID, req.Message.FromUserID, "")
-	ackMessage.Data = map[string]interface{}{
-		"original_message_id": req.Message.ID,
-		"status":              "delivered",
-	}
-	req.From.SendMessage(ackMessage)
-
-	logrus.Infof("私聊消息已发送：%d -> %d", req.Message.FromUserID, req.Message.ToUserID)
+	// 送达状态在对方确认收到后推送
+	logrus.Infof("私聊消息已进入发送队列：%d -> %d", req.Message.FromUserID, req.Message.ToUserID)
 }
 
 // HandlePrivateMessage 处理来自客户端的私聊消息
@@ -172,6 +320,77 @@ func (h *Hub) HandlePrivateMessage(from *Client, message *Message) {
 		return
 	}
 
+	if len(message.ClientMsgID) > maxClientMsgIDLength {
+		from.SendError(400, "client_msg_id过长")
+		return
+	}
+
+	if strings.TrimSpace(message.Content) == "" && len(message.Attachments) == 0 {
+		from.SendError(400, "消息内容不能为空")
+		return
+	}
+
+	// 投递前先持久化消息
+	originalID := message.ID
+	stored := &model.ChatMessage{
+		ClientMsgID: message.ClientMsgID,
+		Type:        string(message.Type),
+		FromUserID:  message.FromUserID,
+		ToUserID:    message.ToUserID,
+		Content:     message.Content,
+		Timestamp:   message.Timestamp,
+	}
+
+	sessionID := model.PrivateSessionID(from.UserID, message.ToUserID)
+	quote, attachments, err := h.resolveReferences(from, message, sessionID, stored)
+	if err != nil {
+		h.rejectMessage(from, message, originalID, err)
+		return
+	}
+
+	duplicate, err := messageService.SavePrivateMessage(stored)
+	if err != nil {
+		h.rejectMessage(from, message, originalID, err)
+		return
+	}
+
+	if duplicate {
+		// 客户端重试发送，返回原消息的ID和当前状态，不再重复投递
+		logrus.Infof("用户 %d 重复发送消息 %s，返回原消息 %s", from.UserID, message.ClientMsgID, stored.ID.Hex())
+		from.SendMessage(NewStatusMessage(MessageStatusData{
+			MessageID:         stored.ID.Hex(),
+			OriginalMessageID: originalID,
+			ClientMsgID:       stored.ClientMsgID,
+			SessionID:         stored.SessionID,
+			ToUserID:          stored.ToUserID,
+			Status:            stored.Status,
+			Duplicate:         true,
+			UpdatedAt:         stored.UpdatedAt,
+		}))
+		return
+	}
+
+	// 接收者收到消息后即可下载其中的附件
+	if err := attachmentService.ShareWith(stored.Attachments, stored.ToUserID); err != nil {
+		logrus.Warnf("消息 %s 附件授权失败: %v", stored.ID.Hex(), err)
+	}
+
+	// 未读数在发送者的读协程中更新，不占用Hub主循环
+	conversationService.IncrUnread(stored.ToUserID, stored.FromUserID)
+
+	applyStored(message, stored, quote, attachments)
+
+	// 告知发送者消息已保存，携带存储后的消息ID
+	from.SendMessage(NewStatusMessage(MessageStatusData{
+		MessageID:         stored.ID.Hex(),
+		OriginalMessageID: originalID,
+		ClientMsgID:       stored.ClientMsgID,
+		SessionID:         stored.SessionID,
+		ToUserID:          stored.ToUserID,
+		Status:            stored.Status,
+		UpdatedAt:         stored.CreatedAt,
+	}))
+
 	// 发送到私聊处理通道
 	h.PrivateMessage <- &PrivateMessageRequest{
 		From:    from,
@@ -179,6 +398,65 @@ func (h *Hub) HandlePrivateMessage(from *Client, message *Message) {
 	}
 }
 
+// resolveReferences 校验消息引用的原消息和附件并写入stored：
+// 引用的消息必须是同一会话中发送者可见的消息，附件只能是自己上传或收到的附件
+func (h *Hub) resolveReferences(from *Client, message *Message, sessionID string, stored *model.ChatMessage) (*service.QuotedMessage, []*service.AttachmentResponse, error) {
+	var quote *service.QuotedMessage
+	if message.ReplyTo != "" {
+		target, err := messageService.GetReplyTarget(message.ReplyTo, from.UserID, sessionID)
+		if err != nil {
+			return nil, nil, err
+		}
+		stored.ReplyTo = target.ID
+		quote = service.NewQuotedMessage(target)
+	}
+
+	var attachments []*service.AttachmentResponse
+	if len(message.Attachments) > 0 {
+		attachable, err := attachmentService.GetAttachable(from.UserID, message.Attachments)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i := range attachable {
+			stored.Attachments = append(stored.Attachments, attachable[i].ID)
+			attachments = append(attachments, service.NewAttachmentResponse(&attachable[i]))
+		}
+	}
+	return quote, attachments, nil
+}
+
+// applyStored 用存储后的消息回填待投递的消息
+func applyStored(message *Message, stored *model.ChatMessage, quote *service.QuotedMessage, attachments []*service.AttachmentResponse) {
+	message.ID = stored.ID.Hex()
+	message.Seq = stored.Seq
+	if quote != nil {
+		message.ReplyTo = quote.MessageID
+	}
+	message.Attachments = message.Attachments[:0]
+	for _, attachment := range attachments {
+		message.Attachments = append(message.Attachments, attachment.ID)
+	}
+	message.Data = PrivateMessageData{
+		MessageID:   stored.ID.Hex(),
+		SessionID:   stored.SessionID,
+		IsOffline:   stored.IsOffline,
+		Status:      stored.Status,
+		ReplyTo:     quote,
+		Attachments: attachments,
+	}
+}
+
+// rejectMessage 通知发送者消息发送失败
+func (h *Hub) rejectMessage(from *Client, message *Message, originalID string, err error) {
+	from.SendMessage(NewStatusMessage(MessageStatusData{
+		OriginalMessageID: originalID,
+		ClientMsgID:       message.ClientMsgID,
+		Status:            model.MessageStatusFailed,
+		Reason:            err.Error(),
+		UpdatedAt:         time.Now(),
+	}))
+}
+
 // HandleTypingMessage 处理正在输入消息
 func (h *Hub) HandleTypingMessage(from *Client, message *Message) {
 	h.mu.RLock()
@@ -190,44 +468,162 @@ func (h *Hub) HandleTypingMessage(from *Client, message *Message) {
 	}
 }
 
-// HandleReadMessage 处理消息已读
+// HandleReadMessage 处理消息已读，to_user_id为对方用户ID，data中携带已读位置
 func (h *Hub) HandleReadMessage(from *Client, message *Message) {
-	// 这里可以添加消息已读的处理逻辑
-	// 比如更新数据库中的消息状态
-	logrus.Infof("用户 %d 已读消息", from.UserID)
+	if message.ToUserID == 0 || message.ToUserID == from.UserID {
+		from.SendError(400, "目标用户ID无效")
+		return
+	}
+
+	var watermark ReadReceiptData
+	if err := message.DecodeData(&watermark); err != nil {
+		from.SendError(400, "已读位置格式错误")
+		return
+	}
+
+	if _, err := h.MarkRead(from.UserID, message.ToUserID, watermark); err != nil {
+		from.SendError(400, err.Error())
+	}
 }
 
-// sendUserListToClient 发送在线用户列表给指定客户端
-func (h *Hub) sendUserListToClient(client *Client) {
-	users := h.getOnlineUsers()
-	userListMessage := NewSystemMessage(MessageTypeUserList, UserListData{Users: users})
-	client.SendMessage(userListMessage)
+// MarkRead 记录阅读者在与对方会话中的已读位置，扣除已读消息的未读数并向对方发送已读回执
+func (h *Hub) MarkRead(readerID, peerID uint, watermark ReadReceiptData) (*ReadReceiptData, error) {
+	sessionID := model.PrivateSessionID(readerID, peerID)
+	position, advanced, err := applyRead(readerID, peerID, watermark)
+	if err != nil {
+		return nil, err
+	}
+	receipt := newReadReceipt(sessionID, readerID, position)
+
+	// 已读位置没有前进时无需重复通知
+	if !advanced {
+		return receipt, nil
+	}
+
+	if targetClient, online := h.GetUserClient(peerID); online {
+		receiptMessage := NewMessage(MessageTypeRead, readerID, peerID, "")
+		receiptMessage.Data = receipt
+		targetClient.SendMessage(receiptMessage)
+	} else {
+		err := conversationService.SavePendingReceipt(&model.ReadReceipt{
+			SessionID: sessionID,
+			ReaderID:  readerID,
+			ToUserID:  peerID,
+			MessageID: position.MessageID,
+			Timestamp: position.Timestamp,
+		})
+		if err != nil {
+			logrus.Warnf("已读回执暂存失败: %v", err)
+		}
+	}
+
+	logrus.Infof("用户 %d 已读会话 %s 至 %s", readerID, sessionID, position.Timestamp.Format(time.RFC3339))
+	return receipt, nil
 }
 
-// broadcastUserJoin 广播用户加入消息
-func (h *Hub) broadcastUserJoin(client *Client) {
-	joinMessage := NewSystemMessage(MessageTypeJoin, client.ToOnlineUser())
-	h.Broadcast <- joinMessage
+// ApplyRead 记录阅读者的已读位置并扣除已读消息的未读数，不发送已读回执，用于没有WebSocket服务时
+func ApplyRead(readerID, peerID uint, watermark ReadReceiptData) (*ReadReceiptData, error) {
+	position, _, err := applyRead(readerID, peerID, watermark)
+	if err != nil {
+		return nil, err
+	}
+	return newReadReceipt(model.PrivateSessionID(readerID, peerID), readerID, position), nil
 }
 
-// broadcastUserLeave 广播用户离开消息
-func (h *Hub) broadcastUserLeave(client *Client) {
-	leaveMessage := NewSystemMessage(MessageTypeLeave, client.ToOnlineUser())
-	h.Broadcast <- leaveMessage
+// applyRead 解析并保存已读位置，将位置之前对方发来的消息标记为已读，返回已读位置是否前进
+func applyRead(readerID, peerID uint, watermark ReadReceiptData) (model.ReadPosition, bool, error) {
+	sessionID := model.PrivateSessionID(readerID, peerID)
+	position := model.ReadPosition{Timestamp: watermark.Timestamp}
+
+	if watermark.MessageID != "" {
+		// 以消息ID为准，必须是本会话中的消息
+		stored, err := messageService.GetMessage(watermark.MessageID)
+		if err != nil {
+			return position, false, err
+		}
+		if stored.SessionID != sessionID {
+			return position, false, errors.New("消息不属于该会话")
+		}
+		position.MessageID = stored.ID
+		position.Timestamp = stored.Timestamp
+	}
+
+	// 未指定位置或位置超前时视为全部已读
+	if position.Timestamp.IsZero() || position.Timestamp.After(time.Now()) {
+		position.Timestamp = time.Now()
+	}
+
+	advanced, err := conversationService.UpdateReadPosition(sessionID, readerID, position)
+	if err != nil {
+		return position, false, err
+	}
+
+	// 对方发来的消息状态推进为已读，状态变化通过已读回执通知对方。
+	// 只扣除本次标记为已读的消息，已读位置之后的消息仍计入未读数
+	marked, err := messageService.MarkReadUpTo(sessionID, readerID, position.Timestamp)
+	if err != nil {
+		logrus.Warnf("会话 %s 消息已读状态更新失败: %v", sessionID, err)
+	}
+	conversationService.DecrUnread(readerID, peerID, marked)
+	return position, advanced, nil
 }
 
-// getOnlineUsers 获取在线用户列表
-func (h *Hub) getOnlineUsers() []OnlineUser {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
+// newReadReceipt 根据已读位置生成已读回执
+func newReadReceipt(sessionID string, readerID uint, position model.ReadPosition) *ReadReceiptData {
+	receipt := &ReadReceiptData{
+		SessionID: sessionID,
+		Timestamp: position.Timestamp,
+		ReaderID:  readerID,
+	}
+	if !position.MessageID.IsZero() {
+		receipt.MessageID = position.MessageID.Hex()
+	}
+	return receipt
+}
 
-	users := make([]OnlineUser, 0, len(h.Clients))
-	for client := range h.Clients {
-		if client.IsAlive {
-			users = append(users, client.ToOnlineUser())
+// deliverPendingReceipts 补发用户离线期间收到的已读回执
+func (h *Hub) deliverPendingReceipts(client *Client) {
+	receipts, err := conversationService.TakePendingReceipts(client.UserID)
+	if err != nil {
+		logrus.Warnf("用户 %d 已读回执查询失败: %v", client.UserID, err)
+		return
+	}
+
+	for _, receipt := range receipts {
+		receiptMessage := NewMessage(MessageTypeRead, receipt.ReaderID, client.UserID, "")
+		data := &ReadReceiptData{
+			SessionID: receipt.SessionID,
+			Timestamp: receipt.Timestamp,
+			ReaderID:  receipt.ReaderID,
+		}
+		if !receipt.MessageID.IsZero() {
+			data.MessageID = receipt.MessageID.Hex()
 		}
+		receiptMessage.Data = data
+		client.SendMessage(receiptMessage)
 	}
-	return users
+}
+
+// sendUserListToClient 发送与用户同在公开房间的在线用户列表给指定客户端
+func (h *Hub) sendUserListToClient(client *Client, peers map[uint][]uint) {
+	users := h.onlineUsersOf(peerUserIDs(client.UserID, peers))
+	userListMessage := NewSystemMessage(MessageTypeUserList, UserListData{Users: users})
+	client.SendMessage(userListMessage)
+}
+
+// broadcastUserJoin 通知用户所在房间的在线成员该用户已上线，并向用户推送在线用户列表和各房间的在线成员列表
+// （在Hub主循环中调用，直接推送避免阻塞Broadcast通道）
+func (h *Hub) broadcastUserJoin(client *Client) {
+	peers := h.broadcastPresence(client, MessageTypeJoin)
+	h.sendUserListToClient(client, peers)
+	for roomID, memberIDs := range peers {
+		h.sendRoomUserList(client, roomID, memberIDs)
+	}
+}
+
+// broadcastUserLeave 通知用户所在房间的在线成员该用户已下线
+func (h *Hub) broadcastUserLeave(client *Client) {
+	h.broadcastPresence(client, MessageTypeLeave)
 }
 
 // GetOnlineUserCount 获取在线用户数量
@@ -245,6 +641,30 @@ func (h *Hub) GetUserClient(userID uint) (*Client, bool) {
 	return client, exists
 }
 
+// DisconnectSession 强制断开属于指定登录会话的客户端连接
+func (h *Hub) DisconnectSession(sessionID string, reason string) int {
+	if sessionID == "" {
+		return 0
+	}
+
+	h.mu.RLock()
+	var targets []*Client
+	for client := range h.Clients {
+		if client.SessionID == sessionID {
+			targets = append(targets, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range targets {
+		logrus.Infof("强制断开用户 %d 的会话 %s: %s", client.UserID, sessionID, reason)
+		client.disableResume()
+		client.SendError(401, reason)
+		h.Unregister <- client
+	}
+	return len(targets)
+}
+
 // startCleanupRoutine 启动清理协程，定期清理断开的连接
 func (h *Hub) startCleanupRoutine() {
 	ticker := time.NewTicker(30 * time.Second)
@@ -252,20 +672,20 @@ func (h *Hub) startCleanupRoutine() {
 
 	for range ticker.C {
 		h.cleanupDeadConnections()
+		h.retryPendingChannels()
 	}
 }
 
 // cleanupDeadConnections 清理死连接
 func (h *Hub) cleanupDeadConnections() {
 	h.mu.Lock()
-	defer h.mu.Unlock()
-
 	now := time.Now()
 	var toRemove []*Client
 
+	var offline []uint
 	for client := range h.Clients {
-		// 检查连接是否超时（超过2分钟无活动）
-		if now.Sub(client.LastSeen) > 2*time.Minute {
+		// 检查连接是否超时（超过2分钟无活动），宽限期内的连接由宽限期计时器处理
+		if now.Sub(client.LastSeen) > 2*time.Minute && !client.isSuspended() {
 			toRemove = append(toRemove, client)
 		}
 	}
@@ -273,7 +693,19 @@ func (h *Hub) cleanupDeadConnections() {
 	for _, client := range toRemove {
 		logrus.Warnf("清理超时连接：用户 %d", client.UserID)
 		delete(h.Clients, client)
-		delete(h.UserClients, client.UserID)
-		client.Close()
+		if h.UserClients[client.UserID] == client {
+			delete(h.UserClients, client.UserID)
+			offline = append(offline, client.UserID)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, userID := range offline {
+		h.channels.forget(userID)
+	}
+
+	// 在锁外关闭连接，未确认的消息需要写回数据库
+	for _, client := range toRemove {
+		h.closeClient(client)
 	}
 }
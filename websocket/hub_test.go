@@ -0,0 +1,222 @@
+package websocket
+
+import (
+	"go_chat/model"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestRegisterClientDeliversOfflineMessages(t *testing.T) {
+	setupMessageConfig(t)
+	mt := newMockMongo(t)
+
+	mt.Run("按时间顺序补发并跟踪投递状态", func(mt *mtest.T) {
+		useMockMongo(mt)
+		first := model.ChatMessage{ID: primitive.NewObjectID(), SessionID: "private_1_2", Type: "private", FromUserID: 1, ToUserID: 2, Content: "first", Timestamp: time.Now().Add(-time.Minute), IsOffline: true, Status: model.MessageStatusStored}
+		second := model.ChatMessage{ID: primitive.NewObjectID(), SessionID: "private_1_2", Type: "private", FromUserID: 1, ToUserID: 2, Content: "second", Timestamp: time.Now(), IsOffline: true, Status: model.MessageStatusStored}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch, toBSON(mt, first), toBSON(mt, second)),
+			mtest.CreateCursorResponse(0, "go_chat_test.read_receipts", mtest.FirstBatch),
+		)
+
+		hub := NewHub()
+		client := newTestClient(hub, 2)
+		hub.registerClient(client)
+		hub.workers.Wait()
+
+		tracked := trackedOutbound(queuedOutbound(client))
+		if len(tracked) != 2 || tracked[0].messageID != first.ID.Hex() || tracked[1].messageID != second.ID.Hex() {
+			mt.Fatalf("跟踪的消息 = %v, want %s, %s", tracked, first.ID.Hex(), second.ID.Hex())
+		}
+		private := decodeOutbound(mt, tracked)
+		if private[0].Content != "first" || messageData(private[0])["is_offline"] != true || messageData(private[0])["status"] != model.MessageStatusStored {
+			mt.Errorf("补发的消息 = %+v", private[0])
+		}
+	})
+
+	mt.Run("没有离线消息", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "go_chat_test.read_receipts", mtest.FirstBatch),
+		)
+
+		hub := NewHub()
+		client := newTestClient(hub, 2)
+		hub.registerClient(client)
+		hub.workers.Wait()
+
+		if private := messagesOfType(receivedMessages(mt, client), MessageTypePrivate); len(private) != 0 {
+			mt.Errorf("补发的消息 = %v, want none", private)
+		}
+		if hub.UserClients[2] != client {
+			mt.Error("客户端未注册")
+		}
+	})
+}
+
+func TestHandlePrivateMessage(t *testing.T) {
+	setupMessageConfig(t)
+	mt := newMockMongo(t)
+
+	mt.Run("接收者离线时转为离线消息并通知发送者", func(mt *mtest.T) {
+		useMockMongo(mt)
+		stored := model.ChatMessage{ID: primitive.NewObjectID(), SessionID: "private_1_2", FromUserID: 1, ToUserID: 2, Status: model.MessageStatusStored, UpdatedAt: time.Now()}
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: toBSON(mt, stored)}))
+
+		hub := NewHub()
+		sender := newTestClient(hub, 1)
+		hub.UserClients[1] = sender
+		message := NewMessage(MessageTypePrivate, 1, 2, "hello")
+		message.ID = stored.ID.Hex()
+		hub.handlePrivateMessage(&PrivateMessageRequest{From: sender, Message: message})
+		hub.workers.Wait()
+
+		statuses := messagesOfType(receivedMessages(mt, sender), MessageTypeStatus)
+		if len(statuses) != 1 {
+			mt.Fatalf("发送者收到的状态 = %v, want 1", statuses)
+		}
+		if data := messageData(statuses[0]); data["status"] != model.MessageStatusStored || data["message_id"] != stored.ID.Hex() {
+			mt.Errorf("状态消息 = %v, want stored", data)
+		}
+
+		update := nextCommand(mt, "findAndModify")
+		if status := update.Lookup("update", "$set", "status").StringValue(); status != model.MessageStatusStored {
+			mt.Errorf("更新的状态 = %q, want stored", status)
+		}
+	})
+
+	mt.Run("接收者在线时进入发送队列", func(mt *mtest.T) {
+		useMockMongo(mt)
+
+		hub := NewHub()
+		sender := newTestClient(hub, 1)
+		target := newTestClient(hub, 2)
+		hub.UserClients[2] = target
+		message := NewMessage(MessageTypePrivate, 1, 2, "hello")
+		message.ID = primitive.NewObjectID().Hex()
+		hub.handlePrivateMessage(&PrivateMessageRequest{From: sender, Message: message})
+		hub.workers.Wait()
+
+		queued := queuedOutbound(target)
+		if len(queued) != 1 || queued[0].messageID != message.ID {
+			mt.Errorf("接收者队列 = %v, want 跟踪 %s", queued, message.ID)
+		}
+		if event := mt.GetStartedEvent(); event != nil {
+			mt.Errorf("在线投递不应访问存储, got %s", event.CommandName)
+		}
+	})
+}
+
+func TestHandlePrivateMessageCountsUnread(t *testing.T) {
+	mt := newMockMongo(t)
+
+	mt.Run("保存后在发送者协程中累加未读数", func(mt *mtest.T) {
+		useMockMongo(mt)
+		setupRedis(mt.T)
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: toBSON(mt, model.Conversation{SessionID: "private_1_2", LastSeq: 1})}),
+			mtest.CreateSuccessResponse(),
+			updatedResponse(1),
+		)
+
+		hub := NewHub()
+		sender := newTestClient(hub, 1)
+		forwarded := make(chan *PrivateMessageRequest, 1)
+		go func() { forwarded <- <-hub.PrivateMessage }()
+		hub.HandlePrivateMessage(sender, NewMessage(MessageTypePrivate, 1, 2, "hi"))
+
+		if request := <-forwarded; request.Message.Seq != 1 {
+			mt.Errorf("转发的消息 = %+v, want seq 1", request.Message)
+		}
+		if unread := conversationService.GetUnreadCounts(2); unread[1] != 1 {
+			mt.Errorf("未读数 = %v, want 1", unread)
+		}
+	})
+}
+
+func TestStoreOffline(t *testing.T) {
+	setupMessageConfig(t)
+	mt := newMockMongo(t)
+
+	mt.Run("标记完成前接收者已上线时重新补发", func(mt *mtest.T) {
+		useMockMongo(mt)
+		pending := model.ChatMessage{ID: primitive.NewObjectID(), SessionID: "private_1_2", Type: "private", FromUserID: 1, ToUserID: 2, Content: "hi", Status: model.MessageStatusStored, Timestamp: time.Now()}
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: toBSON(mt, pending)}),
+			mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch, toBSON(mt, pending)),
+		)
+
+		hub := NewHub()
+		target := newTestClient(hub, 2)
+		hub.UserClients[2] = target
+		hub.storeOffline(2, []string{pending.ID.Hex()})
+
+		nextCommand(mt, "findAndModify")
+		nextCommand(mt, "find")
+		if tracked := trackedOutbound(queuedOutbound(target)); len(tracked) != 1 || tracked[0].messageID != pending.ID.Hex() {
+			mt.Errorf("补发的消息 = %v, want %s", tracked, pending.ID.Hex())
+		}
+	})
+
+	mt.Run("接收者离线时只标记", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}))
+
+		hub := NewHub()
+		hub.storeOffline(2, []string{primitive.NewObjectID().Hex()})
+
+		nextCommand(mt, "findAndModify")
+		if event := mt.GetStartedEvent(); event != nil {
+			mt.Errorf("接收者离线时不应补发, got %s", event.CommandName)
+		}
+	})
+}
+
+func TestHandlePrivateMessageDuplicate(t *testing.T) {
+	mt := newMockMongo(t)
+
+	mt.Run("重试时返回原消息且不再投递", func(mt *mtest.T) {
+		useMockMongo(mt)
+		original := model.ChatMessage{ID: primitive.NewObjectID(), SessionID: "private_1_2", ClientMsgID: "c-1", FromUserID: 1, ToUserID: 2, Status: model.MessageStatusDelivered, CreatedAt: time.Now()}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch, toBSON(mt, original)))
+
+		hub := NewHub()
+		sender := newTestClient(hub, 1)
+		message := NewMessage(MessageTypePrivate, 1, 2, "hi")
+		message.ClientMsgID = "c-1"
+		hub.HandlePrivateMessage(sender, message)
+
+		statuses := messagesOfType(receivedMessages(mt, sender), MessageTypeStatus)
+		if len(statuses) != 1 {
+			mt.Fatalf("发送者收到的状态 = %v, want 1", statuses)
+		}
+		data := messageData(statuses[0])
+		if data["duplicate"] != true || data["message_id"] != original.ID.Hex() || data["status"] != model.MessageStatusDelivered {
+			mt.Errorf("状态消息 = %v, want 原消息 delivered", data)
+		}
+		select {
+		case request := <-hub.PrivateMessage:
+			mt.Errorf("重复消息再次投递: %+v", request.Message)
+		default:
+		}
+	})
+}
+
+func TestHandlePrivateMessageRejectsLongClientMsgID(t *testing.T) {
+	hub := NewHub()
+	sender := newTestClient(hub, 1)
+	message := NewMessage(MessageTypePrivate, 1, 2, "hi")
+	message.ClientMsgID = strings.Repeat("x", maxClientMsgIDLength+1)
+	hub.HandlePrivateMessage(sender, message)
+
+	errs := messagesOfType(receivedMessages(t, sender), MessageTypeError)
+	if len(errs) != 1 || messageData(errs[0])["message"] != "client_msg_id过长" {
+		t.Errorf("错误消息 = %v, want client_msg_id过长", errs)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"go_chat/model"
+	"go_chat/service"
 	"time"
 )
 
@@ -13,39 +15,188 @@ type MessageType string
 
 const (
 	// 系统消息
-	MessageTypeJoin     MessageType = "join"      // 用户加入
-	MessageTypeLeave    MessageType = "leave"     // 用户离开
+	MessageTypeJoin     MessageType = "join"      // 加入房间或房间成员上线
+	MessageTypeLeave    MessageType = "leave"     // 退出房间或房间成员下线
 	MessageTypeUserList MessageType = "user_list" // 在线用户列表
 	MessageTypeError    MessageType = "error"     // 错误消息
 
 	// 聊天消息
 	MessageTypePrivate   MessageType = "private"   // 私聊消息
+	MessageTypeGroup     MessageType = "group"     // 群聊消息
+	MessageTypeChannel   MessageType = "channel"   // 频道消息，只有发布者可以发送
 	MessageTypeHeartbeat MessageType = "heartbeat" // 心跳检测
 	MessageTypeTyping    MessageType = "typing"    // 正在输入
 	MessageTypeRead      MessageType = "read"      // 消息已读
+	MessageTypeStatus    MessageType = "status"    // 消息投递状态变化
+	MessageTypeSync      MessageType = "sync"      // 断线重连后按序号同步消息
+	MessageTypeAck       MessageType = "ack"       // 客户端确认收到消息
+	MessageTypeResume    MessageType = "resume"    // 下发恢复令牌，断线重连时续接会话
+	MessageTypeEdit      MessageType = "edit"      // 编辑已发送的消息
+	MessageTypeRecall    MessageType = "recall"    // 撤回已发送的消息
+
+	MessageTypeReactionAdd    MessageType = "reaction_add"    // 添加表情回应
+	MessageTypeReactionRemove MessageType = "reaction_remove" // 取消表情回应
+
+	MessageTypeGroupEvent MessageType = "group_event" // 群组信息或成员变化
+)
+
+// 群组事件
+const (
+	GroupEventCreated       = "created"        // 创建群组
+	GroupEventRenamed       = "renamed"        // 修改群名称
+	GroupEventMembersAdded  = "members_added"  // 添加成员
+	GroupEventMemberRemoved = "member_removed" // 移除成员
+	GroupEventMemberLeft    = "member_left"    // 成员退出
+	GroupEventRoleChanged   = "role_changed"   // 成员角色变化
+	GroupEventMemberMuted   = "member_muted"   // 成员被禁言
+	GroupEventMemberUnmuted = "member_unmuted" // 成员被解除禁言
+	GroupEventMemberBanned  = "member_banned"  // 用户被封禁
+	GroupEventSlowMode      = "slow_mode"      // 慢速模式间隔变化
+)
+
+// 群聊发言被拒绝时的错误码，不是群成员时仍为403
+const (
+	ErrorCodeGroupBanned   = 4031 // 已被群组封禁
+	ErrorCodeGroupMuted    = 4032 // 被禁言，retry_after为剩余禁言秒数
+	ErrorCodeGroupSlowMode = 4291 // 慢速模式下发言过于频繁，retry_after为需要等待的秒数
 )
 
 // Message WebSocket消息结构
 type Message struct {
-	ID         string      `json:"id"`             // 消息唯一ID
-	Type       MessageType `json:"type"`           // 消息类型
-	FromUserID uint        `json:"from_user_id"`   // 发送者ID
-	ToUserID   uint        `json:"to_user_id"`     // 接收者ID (私聊时使用)
-	Content    string      `json:"content"`        // 消息内容
-	Timestamp  time.Time   `json:"timestamp"`      // 时间戳
-	Data       interface{} `json:"data,omitempty"` // 附加数据
+	ID          string      `json:"id"`                      // 消息唯一ID
+	ClientMsgID string      `json:"client_msg_id,omitempty"` // 客户端生成的幂等键，重试发送时保持不变
+	Type        MessageType `json:"type"`                    // 消息类型
+	Seq         int64       `json:"seq,omitempty"`           // 会话内序号 (已存储的私聊消息)
+	ReplyTo     string      `json:"reply_to,omitempty"`      // 引用的消息ID
+	Attachments []string    `json:"attachments,omitempty"`   // 附件ID，先通过上传接口获取
+	FromUserID  uint        `json:"from_user_id"`            // 发送者ID
+	ToUserID    uint        `json:"to_user_id"`              // 接收者ID (私聊时使用)
+	GroupID     uint        `json:"group_id,omitempty"`      // 群组ID (群聊时使用)
+	RoomID      uint        `json:"room_id,omitempty"`       // 房间ID (加入、退出房间和房间在线状态时使用)
+	ChannelID   uint        `json:"channel_id,omitempty"`    // 频道ID (频道消息时使用)
+	Content     string      `json:"content"`                 // 消息内容
+	Timestamp   time.Time   `json:"timestamp"`               // 时间戳
+	Data        interface{} `json:"data,omitempty"`          // 附加数据
 }
 
 // PrivateMessageData 私聊消息附加数据
 type PrivateMessageData struct {
-	MessageID string `json:"message_id"` // MongoDB中的消息ID
-	SessionID string `json:"session_id"` // 会话ID
-	IsOffline bool   `json:"is_offline"` // 是否离线消息
+	MessageID string     `json:"message_id"`          // MongoDB中的消息ID
+	SessionID string     `json:"session_id"`          // 会话ID
+	IsOffline bool       `json:"is_offline"`          // 是否离线消息
+	Status    string     `json:"status"`              // 投递状态
+	Edited    bool       `json:"edited,omitempty"`    // 是否被编辑过
+	EditedAt  *time.Time `json:"edited_at,omitempty"` // 最近一次编辑时间
+	Recalled  bool       `json:"recalled,omitempty"`  // 是否已撤回，撤回后内容为空
+
+	ReplyTo   *service.QuotedMessage    `json:"reply_to,omitempty"`  // 被引用消息的快照
+	Reactions []service.ReactionSummary `json:"reactions,omitempty"` // 表情回应汇总
+
+	Attachments []*service.AttachmentResponse `json:"attachments,omitempty"` // 附件信息
+}
+
+// ReactionData 表情回应附加数据，客户端发送message_id和emoji，服务端推送时附带最新汇总
+type ReactionData struct {
+	MessageID string                    `json:"message_id"`
+	SessionID string                    `json:"session_id,omitempty"`
+	Emoji     string                    `json:"emoji"`
+	UserID    uint                      `json:"user_id,omitempty"`   // 添加或取消回应的用户
+	Reactions []service.ReactionSummary `json:"reactions,omitempty"` // 该消息当前的表情回应汇总
+}
+
+// MessageEditData 编辑消息附加数据，新内容放在消息的content中
+type MessageEditData struct {
+	MessageID string    `json:"message_id"`
+	SessionID string    `json:"session_id,omitempty"`
+	EditedAt  time.Time `json:"edited_at,omitempty"`
+}
+
+// MessageStatusData 投递状态变化附加数据，推送给消息发送者
+type MessageStatusData struct {
+	MessageID         string    `json:"message_id,omitempty"`          // 存储后的消息ID
+	OriginalMessageID string    `json:"original_message_id,omitempty"` // 客户端发送时携带的消息ID
+	ClientMsgID       string    `json:"client_msg_id,omitempty"`       // 客户端生成的幂等键
+	Duplicate         bool      `json:"duplicate,omitempty"`           // 是否为重复发送
+	SessionID         string    `json:"session_id,omitempty"`          // 会话ID
+	ToUserID          uint      `json:"to_user_id,omitempty"`          // 接收者ID
+	GroupID           uint      `json:"group_id,omitempty"`            // 群组ID
+	ChannelID         uint      `json:"channel_id,omitempty"`          // 频道ID
+	Status            string    `json:"status"`                        // sent/stored/delivered/read/failed
+	Reason            string    `json:"reason,omitempty"`              // 失败原因
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// GroupEventData 群组事件附加数据
+type GroupEventData struct {
+	GroupID    uint   `json:"group_id"`
+	Event      string `json:"event"`
+	OperatorID uint   `json:"operator_id"`            // 执行操作的用户
+	UserIDs    []uint `json:"user_ids,omitempty"`     // 被添加、移除或修改角色的成员
+	Name       string `json:"name,omitempty"`         // 群名称
+	Role       string `json:"role,omitempty"`         // 修改后的角色
+	NewOwnerID uint   `json:"new_owner_id,omitempty"` // 群主退出后接任的新群主
+
+	MutedUntil *time.Time `json:"muted_until,omitempty"` // 禁言截止时间
+	SlowMode   *int       `json:"slow_mode,omitempty"`   // 慢速模式间隔秒数，0表示关闭
+	Reason     string     `json:"reason,omitempty"`      // 封禁原因
+}
+
+// MessageRecallData 撤回消息附加数据
+type MessageRecallData struct {
+	MessageID  string    `json:"message_id"`
+	SessionID  string    `json:"session_id,omitempty"`
+	RecalledAt time.Time `json:"recalled_at,omitempty"`
+}
+
+// ReadReceiptData 已读回执附加数据，message_id和timestamp任选其一作为已读位置
+type ReadReceiptData struct {
+	SessionID string    `json:"session_id,omitempty"` // 会话ID
+	MessageID string    `json:"message_id,omitempty"` // 已读到的消息ID
+	Timestamp time.Time `json:"timestamp"`            // 已读到的消息时间
+	ReaderID  uint      `json:"reader_id,omitempty"`  // 阅读者ID
+}
+
+// SyncRequestData 同步请求附加数据，客户端上报每个会话已收到的最大序号
+type SyncRequestData struct {
+	Conversations []SyncCursor `json:"conversations"`
+}
+
+// SyncCursor 会话同步位置
+type SyncCursor struct {
+	SessionID string `json:"session_id"`
+	LastSeq   int64  `json:"last_seq"`
+}
+
+// SyncResultData 同步结果附加数据，在补发的消息之后发送
+type SyncResultData struct {
+	Conversations []SyncState `json:"conversations"`
+}
+
+// SyncState 会话同步结果
+type SyncState struct {
+	SessionID string `json:"session_id"`
+	SyncedSeq int64  `json:"synced_seq"` // 本次同步到的序号
+	LastSeq   int64  `json:"last_seq"`   // 服务端最新序号
+	HasMore   bool   `json:"has_more"`   // 是否还有未同步的消息，需要继续发送sync
+}
+
+// AckData 客户端确认附加数据，可批量确认多条已收到的消息
+type AckData struct {
+	MessageIDs []string `json:"message_ids"`
+}
+
+// ResumeData 恢复令牌附加数据，连接建立后下发
+type ResumeData struct {
+	ResumeToken string `json:"resume_token"`      // 重连时通过resume_token查询参数携带
+	GracePeriod int    `json:"grace_period"`      // 断线后会话保留的秒数
+	Resumed     bool   `json:"resumed"`           // 本次连接是否续接了原会话
+	Dropped     int    `json:"dropped,omitempty"` // 宽限期内因缓存已满丢弃的推送消息数
 }
 
 // UserListData 用户列表附加数据
 type UserListData struct {
-	Users []OnlineUser `json:"users"`
+	RoomID uint         `json:"room_id,omitempty"` // 房间在线成员列表所属的房间，为空表示全部在线用户
+	Users  []OnlineUser `json:"users"`
 }
 
 // OnlineUser 在线用户信息
@@ -61,6 +212,11 @@ type OnlineUser struct {
 type ErrorData struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
+
+	GroupID     uint   `json:"group_id,omitempty"`      // 群聊发言被拒绝时的群组ID
+	ChannelID   uint   `json:"channel_id,omitempty"`    // 频道发布被拒绝时的频道ID
+	ClientMsgID string `json:"client_msg_id,omitempty"` // 被拒绝消息的client_msg_id
+	RetryAfter  int    `json:"retry_after,omitempty"`   // 需要等待的秒数
 }
 
 // NewMessage 创建新消息
@@ -85,6 +241,11 @@ func NewSystemMessage(msgType MessageType, data interface{}) *Message {
 	}
 }
 
+// NewStatusMessage 创建投递状态消息
+func NewStatusMessage(data MessageStatusData) *Message {
+	return NewSystemMessage(MessageTypeStatus, data)
+}
+
 // NewErrorMessage 创建错误消息
 func NewErrorMessage(code int, message string) *Message {
 	return &Message{
@@ -98,6 +259,71 @@ func NewErrorMessage(code int, message string) *Message {
 	}
 }
 
+// NewMessageFromStored 根据存储的消息构建WebSocket消息
+func NewMessageFromStored(stored *model.ChatMessage) *Message {
+	message := &Message{
+		ID:          stored.ID.Hex(),
+		ClientMsgID: stored.ClientMsgID,
+		Type:        MessageType(stored.Type),
+		Seq:         stored.Seq,
+		FromUserID:  stored.FromUserID,
+		ToUserID:    stored.ToUserID,
+		GroupID:     stored.GroupID,
+		ChannelID:   stored.ChannelID,
+		Content:     stored.Content,
+		Timestamp:   stored.Timestamp,
+		Data: PrivateMessageData{
+			MessageID: stored.ID.Hex(),
+			SessionID: stored.SessionID,
+			IsOffline: stored.IsOffline,
+			Status:    stored.Status,
+			Edited:    stored.Edited,
+			EditedAt:  stored.EditedAt,
+			Recalled:  stored.Recalled,
+			Reactions: service.SummarizeReactions(stored.Reactions),
+		},
+	}
+	if !stored.ReplyTo.IsZero() {
+		message.ReplyTo = stored.ReplyTo.Hex()
+	}
+	for _, attachmentID := range stored.Attachments {
+		message.Attachments = append(message.Attachments, attachmentID.Hex())
+	}
+	return message
+}
+
+// NewMessagesFromStored 批量转换存储的消息，并附带被引用消息的快照和附件信息
+func NewMessagesFromStored(stored []model.ChatMessage) []*Message {
+	quotes := messageService.LoadQuotes(stored)
+	attachments := attachmentService.LoadAttachments(stored)
+	messages := make([]*Message, 0, len(stored))
+	for i := range stored {
+		message := NewMessageFromStored(&stored[i])
+		data := message.Data.(PrivateMessageData)
+		data.ReplyTo = quotes[stored[i].ReplyTo]
+		for _, attachmentID := range stored[i].Attachments {
+			if attachment, ok := attachments[attachmentID]; ok {
+				data.Attachments = append(data.Attachments, attachment)
+			}
+		}
+		message.Data = data
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+// DecodeData 将消息附加数据解析到指定结构
+func (m *Message) DecodeData(v interface{}) error {
+	if m.Data == nil {
+		return nil
+	}
+	raw, err := json.Marshal(m.Data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
 // ToJSON 消息序列化为JSON
 func (m *Message) ToJSON() ([]byte, error) {
 	return json.Marshal(m)
@@ -0,0 +1,65 @@
+package websocket
+
+import (
+	"go_chat/model"
+	"go_chat/service"
+
+	"github.com/sirupsen/logrus"
+)
+
+// HandleReactionMessage 处理添加或取消表情回应，data中携带message_id和emoji
+func (h *Hub) HandleReactionMessage(from *Client, message *Message) {
+	var data ReactionData
+	if err := message.DecodeData(&data); err != nil || data.MessageID == "" {
+		from.SendError(400, "表情回应的消息ID不能为空")
+		return
+	}
+
+	var (
+		stored *model.ChatMessage
+		err    error
+	)
+	if message.Type == MessageTypeReactionAdd {
+		stored, err = messageService.AddReaction(from.UserID, data.MessageID, data.Emoji)
+	} else {
+		stored, err = messageService.RemoveReaction(from.UserID, data.MessageID, data.Emoji)
+	}
+	if err != nil {
+		from.SendError(400, err.Error())
+		return
+	}
+
+	h.broadcastReaction(message.Type, from.UserID, data.Emoji, stored)
+}
+
+// broadcastReaction 将表情回应变化推送给在线的会话参与者
+func (h *Hub) broadcastReaction(msgType MessageType, userID uint, emoji string, stored *model.ChatMessage) {
+	peerID := stored.ToUserID
+	if peerID == userID {
+		peerID = stored.FromUserID
+	}
+
+	notice := NewMessage(msgType, userID, peerID, "")
+	notice.GroupID = stored.GroupID
+	notice.Data = ReactionData{
+		MessageID: stored.ID.Hex(),
+		SessionID: stored.SessionID,
+		Emoji:     emoji,
+		UserID:    userID,
+		Reactions: service.SummarizeReactions(stored.Reactions),
+	}
+
+	for _, client := range h.participantClients(stored) {
+		client.SendMessage(notice)
+	}
+
+	logrus.Infof("用户 %d 对消息 %s %s表情 %s", userID, stored.ID.Hex(), reactionAction(msgType), emoji)
+}
+
+// reactionAction 日志中的操作描述
+func reactionAction(msgType MessageType) string {
+	if msgType == MessageTypeReactionAdd {
+		return "添加"
+	}
+	return "取消"
+}
@@ -0,0 +1,62 @@
+package websocket
+
+import (
+	"go_chat/config"
+	"go_chat/model"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestHandleReactionMessage(t *testing.T) {
+	setupMessageConfig(t)
+	previous := config.MessageMaxReactions
+	t.Cleanup(func() { config.MessageMaxReactions = previous })
+	config.MessageMaxReactions = 3
+	mt := newMockMongo(t)
+
+	mt.Run("通知会话双方", func(mt *mtest.T) {
+		useMockMongo(mt)
+		message := model.ChatMessage{ID: primitive.NewObjectID(), SessionID: "private_1_2", FromUserID: 1, ToUserID: 2, Content: "hi", Timestamp: time.Now()}
+		reacted := message
+		reacted.Reactions = map[string][]uint{"👍": {2}}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch, toBSON(mt, message)),
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: toBSON(mt, reacted)}),
+		)
+
+		hub := NewHub()
+		sender, receiver := newTestClient(hub, 1), newTestClient(hub, 2)
+		hub.UserClients[1], hub.UserClients[2] = sender, receiver
+
+		request := NewMessage(MessageTypeReactionAdd, 2, 1, "")
+		request.Data = ReactionData{MessageID: message.ID.Hex(), Emoji: "👍"}
+		hub.HandleReactionMessage(receiver, request)
+
+		for _, client := range []*Client{sender, receiver} {
+			notices := messagesOfType(receivedMessages(mt, client), MessageTypeReactionAdd)
+			if len(notices) != 1 {
+				mt.Fatalf("用户 %d 收到的表情回应 = %v", client.UserID, notices)
+			}
+			data := messageData(notices[0])
+			reactions, _ := data["reactions"].([]interface{})
+			if data["message_id"] != message.ID.Hex() || data["emoji"] != "👍" || len(reactions) != 1 {
+				mt.Errorf("用户 %d 收到的表情回应 = %v", client.UserID, data)
+			}
+		}
+	})
+
+	t.Run("缺少消息ID", func(t *testing.T) {
+		hub := NewHub()
+		sender := newTestClient(hub, 1)
+		hub.HandleReactionMessage(sender, NewMessage(MessageTypeReactionAdd, 1, 2, ""))
+
+		errs := messagesOfType(receivedMessages(t, sender), MessageTypeError)
+		if len(errs) != 1 || messageData(errs[0])["message"] != "表情回应的消息ID不能为空" {
+			t.Errorf("错误消息 = %v, want 表情回应的消息ID不能为空", errs)
+		}
+	})
+}
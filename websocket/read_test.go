@@ -0,0 +1,178 @@
+package websocket
+
+import (
+	"go_chat/model"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestMarkRead(t *testing.T) {
+	mt := newMockMongo(t)
+	stored := model.ChatMessage{ID: primitive.NewObjectID(), SessionID: "private_1_2", FromUserID: 2, ToUserID: 1, Content: "hi", Timestamp: time.Now().Add(-time.Minute).Truncate(time.Millisecond)}
+
+	mt.Run("对方在线时立即发送回执", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch, toBSON(mt, stored)),
+			updatedResponse(1),
+			updatedResponse(1),
+		)
+
+		hub := NewHub()
+		peer := newTestClient(hub, 2)
+		hub.UserClients[2] = peer
+
+		receipt, err := hub.MarkRead(1, 2, ReadReceiptData{MessageID: stored.ID.Hex()})
+		if err != nil {
+			mt.Fatalf("MarkRead() error = %v", err)
+		}
+		if receipt.MessageID != stored.ID.Hex() || !receipt.Timestamp.Equal(stored.Timestamp) || receipt.ReaderID != 1 {
+			mt.Errorf("receipt = %+v, want 已读到 %s", receipt, stored.ID.Hex())
+		}
+
+		receipts := messagesOfType(receivedMessages(mt, peer), MessageTypeRead)
+		if len(receipts) != 1 || messageData(receipts[0])["message_id"] != stored.ID.Hex() {
+			mt.Errorf("对方收到的回执 = %v", receipts)
+		}
+	})
+
+	mt.Run("对方离线时暂存回执", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(updatedResponse(1), updatedResponse(2), mtest.CreateSuccessResponse())
+
+		hub := NewHub()
+		readAt := time.Now().Add(-time.Second).Truncate(time.Millisecond)
+		if _, err := hub.MarkRead(1, 2, ReadReceiptData{Timestamp: readAt}); err != nil {
+			mt.Fatalf("MarkRead() error = %v", err)
+		}
+
+		update := nextCommand(mt, "update").Lookup("updates").Array().Index(0).Value().Document()
+		if id := update.Lookup("q", "_id").StringValue(); id != "private_1_2" {
+			mt.Errorf("更新的会话 = %q, want private_1_2", id)
+		}
+		markRead := nextCommand(mt, "update").Lookup("updates").Array().Index(0).Value().Document()
+		if !markRead.Lookup("multi").Boolean() || markRead.Lookup("q", "to_user_id").AsInt64() != 1 {
+			mt.Errorf("已读状态更新 = %v, want 更新发给阅读者的消息", markRead)
+		}
+		replace := nextCommand(mt, "update").Lookup("updates").Array().Index(0).Value().Document()
+		var saved model.ReadReceipt
+		if err := bson.Unmarshal(replace.Lookup("u").Document(), &saved); err != nil {
+			mt.Fatal(err)
+		}
+		if saved.ID != "private_1_2:1" || saved.ToUserID != 2 || !saved.Timestamp.Equal(readAt) {
+			mt.Errorf("暂存的回执 = %+v", saved)
+		}
+	})
+
+	mt.Run("已读位置未前进时不重复通知", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(updatedResponse(0), updatedResponse(0))
+
+		hub := NewHub()
+		peer := newTestClient(hub, 2)
+		hub.UserClients[2] = peer
+
+		if _, err := hub.MarkRead(1, 2, ReadReceiptData{}); err != nil {
+			mt.Fatalf("MarkRead() error = %v", err)
+		}
+		if received := receivedMessages(mt, peer); len(received) != 0 {
+			mt.Errorf("对方收到 = %v, want none", received)
+		}
+	})
+
+	mt.Run("只扣除已读位置之前的未读数", func(mt *mtest.T) {
+		useMockMongo(mt)
+		setupRedis(mt.T)
+		for i := 0; i < 3; i++ {
+			conversationService.IncrUnread(1, 2)
+		}
+		// 已读位置之前有两条未读消息，之后的一条仍然未读
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch, toBSON(mt, stored)),
+			updatedResponse(1),
+			updatedResponse(2),
+			mtest.CreateSuccessResponse(),
+		)
+
+		if _, err := NewHub().MarkRead(1, 2, ReadReceiptData{MessageID: stored.ID.Hex()}); err != nil {
+			mt.Fatalf("MarkRead() error = %v", err)
+		}
+		if unread := conversationService.GetUnreadCounts(1); unread[2] != 1 {
+			mt.Errorf("已读后未读数 = %v, want 1", unread)
+		}
+	})
+
+	mt.Run("没有WebSocket服务时同样按已读位置扣除", func(mt *mtest.T) {
+		useMockMongo(mt)
+		setupRedis(mt.T)
+		conversationService.IncrUnread(1, 2)
+		conversationService.IncrUnread(1, 2)
+		mt.AddMockResponses(updatedResponse(1), updatedResponse(1))
+
+		readAt := time.Now().Add(-time.Second).Truncate(time.Millisecond)
+		receipt, err := ApplyRead(1, 2, ReadReceiptData{Timestamp: readAt})
+		if err != nil || !receipt.Timestamp.Equal(readAt) {
+			mt.Fatalf("ApplyRead() = %+v, %v, want 已读到 %v", receipt, err, readAt)
+		}
+		if unread := conversationService.GetUnreadCounts(1); unread[2] != 1 {
+			mt.Errorf("已读后未读数 = %v, want 1", unread)
+		}
+		if events := mt.GetAllStartedEvents(); len(events) != 2 {
+			mt.Errorf("执行了 %d 条命令, want 2（不暂存回执）", len(events))
+		}
+	})
+
+	mt.Run("消息不属于该会话", func(mt *mtest.T) {
+		useMockMongo(mt)
+		other := stored
+		other.SessionID = "private_2_3"
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch, toBSON(mt, other)))
+
+		if _, err := NewHub().MarkRead(1, 2, ReadReceiptData{MessageID: other.ID.Hex()}); err == nil || err.Error() != "消息不属于该会话" {
+			mt.Errorf("MarkRead() error = %v, want 消息不属于该会话", err)
+		}
+	})
+}
+
+func TestHandleReadMessageRejectsInvalidTarget(t *testing.T) {
+	hub := NewHub()
+	client := newTestClient(hub, 1)
+
+	for _, toUserID := range []uint{0, 1} {
+		hub.HandleReadMessage(client, NewMessage(MessageTypeRead, 1, toUserID, ""))
+	}
+	if errs := messagesOfType(receivedMessages(t, client), MessageTypeError); len(errs) != 2 {
+		t.Errorf("错误消息 = %v, want 2", errs)
+	}
+}
+
+func TestDeliverPendingReceipts(t *testing.T) {
+	mt := newMockMongo(t)
+
+	mt.Run("补发并删除暂存的回执", func(mt *mtest.T) {
+		useMockMongo(mt)
+		receipt := model.ReadReceipt{ID: "private_1_2:2", SessionID: "private_1_2", ReaderID: 2, ToUserID: 1, MessageID: primitive.NewObjectID(), Timestamp: time.Now()}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "go_chat_test.read_receipts", mtest.FirstBatch, toBSON(mt, receipt)),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+		)
+
+		hub := NewHub()
+		client := newTestClient(hub, 1)
+		hub.deliverPendingReceipts(client)
+
+		receipts := messagesOfType(receivedMessages(mt, client), MessageTypeRead)
+		if len(receipts) != 1 || receipts[0].FromUserID != 2 || messageData(receipts[0])["message_id"] != receipt.MessageID.Hex() {
+			mt.Errorf("补发的回执 = %v", receipts)
+		}
+		deleted := nextCommand(mt, "delete").Lookup("deletes").Array().Index(0).Value().Document()
+		ids, _ := deleted.Lookup("q", "_id", "$in").Array().Values()
+		if len(ids) != 1 || ids[0].StringValue() != receipt.ID {
+			mt.Errorf("删除的回执 = %v, want %s", ids, receipt.ID)
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package websocket
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// HandleRecallMessage 处理撤回消息，data中携带被撤回的消息ID
+func (h *Hub) HandleRecallMessage(from *Client, message *Message) {
+	var data MessageRecallData
+	if err := message.DecodeData(&data); err != nil || data.MessageID == "" {
+		from.SendError(400, "被撤回的消息ID不能为空")
+		return
+	}
+
+	if _, err := h.RecallMessage(from.UserID, data.MessageID); err != nil {
+		from.SendError(400, err.Error())
+	}
+}
+
+// RecallMessage 撤回已发送的消息，并通知在线的会话参与者
+func (h *Hub) RecallMessage(userID uint, messageID string) (*Message, error) {
+	stored, err := messageService.RecallMessage(userID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	recall := NewMessage(MessageTypeRecall, stored.FromUserID, stored.ToUserID, "")
+	recall.Seq = stored.Seq
+	recall.GroupID = stored.GroupID
+	recall.Timestamp = *stored.RecalledAt
+	recall.Data = MessageRecallData{
+		MessageID:  stored.ID.Hex(),
+		SessionID:  stored.SessionID,
+		RecalledAt: *stored.RecalledAt,
+	}
+
+	// 接收者尚未确认的原消息不再重传，避免撤回后再次收到原内容；转为离线消息后重连时补发撤回标记。
+	// 群聊成员未确认的消息仍按送达记录补发
+	for _, client := range h.participantClients(stored) {
+		if client.dropInflight(stored.ID.Hex()) && stored.GroupID == 0 {
+			h.markStored(stored.ID.Hex())
+		}
+		client.SendMessage(recall)
+	}
+
+	logrus.Infof("用户 %d 撤回了消息 %s", userID, messageID)
+	return recall, nil
+}
@@ -0,0 +1,70 @@
+package websocket
+
+import (
+	"go_chat/config"
+	"go_chat/model"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestRecallMessage(t *testing.T) {
+	setupMessageConfig(t)
+	previous := config.MessageRecallWindow
+	t.Cleanup(func() { config.MessageRecallWindow = previous })
+	config.MessageRecallWindow = 2 * time.Minute
+	mt := newMockMongo(t)
+
+	mt.Run("通知双方并停止重传原消息", func(mt *mtest.T) {
+		useMockMongo(mt)
+		message := model.ChatMessage{ID: primitive.NewObjectID(), SessionID: "private_1_2", Seq: 7, FromUserID: 1, ToUserID: 2, Content: "oops", Timestamp: time.Now(), Status: model.MessageStatusSent}
+		recalledAt := time.Now()
+		recalled := message
+		recalled.Content, recalled.Recalled, recalled.RecalledAt = "", true, &recalledAt
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch, toBSON(mt, message)),
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: toBSON(mt, recalled)}),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}),
+		)
+
+		hub := NewHub()
+		sender, receiver := newTestClient(hub, 1), newTestClient(hub, 2)
+		hub.UserClients[1], hub.UserClients[2] = sender, receiver
+		receiver.SendTracked(NewMessageFromStored(&message), message.ID.Hex())
+		queuedOutbound(receiver)
+
+		request := NewMessage(MessageTypeRecall, 1, 2, "")
+		request.Data = MessageRecallData{MessageID: message.ID.Hex()}
+		hub.HandleRecallMessage(sender, request)
+
+		for _, client := range []*Client{sender, receiver} {
+			recalls := messagesOfType(receivedMessages(mt, client), MessageTypeRecall)
+			if len(recalls) != 1 || recalls[0].Seq != 7 || messageData(recalls[0])["message_id"] != message.ID.Hex() {
+				mt.Errorf("用户 %d 收到的撤回 = %v", client.UserID, recalls)
+			}
+		}
+		if len(receiver.inflight) != 0 {
+			mt.Errorf("接收者在途消息 = %v, want none", receiver.inflight)
+		}
+		nextCommand(mt, "findAndModify")
+		stored := nextCommand(mt, "findAndModify")
+		if status := stored.Lookup("update", "$set", "status").StringValue(); status != model.MessageStatusStored {
+			mt.Errorf("原消息状态 = %q, want stored", status)
+		}
+	})
+
+	t.Run("缺少消息ID", func(t *testing.T) {
+		hub := NewHub()
+		sender := newTestClient(hub, 1)
+		hub.HandleRecallMessage(sender, NewMessage(MessageTypeRecall, 1, 2, ""))
+
+		errs := messagesOfType(receivedMessages(t, sender), MessageTypeError)
+		if len(errs) != 1 || messageData(errs[0])["message"] != "被撤回的消息ID不能为空" {
+			t.Errorf("错误消息 = %v, want 被撤回的消息ID不能为空", errs)
+		}
+	})
+}
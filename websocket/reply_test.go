@@ -0,0 +1,60 @@
+package websocket
+
+import (
+	"go_chat/model"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestHandlePrivateMessageReply(t *testing.T) {
+	mt := newMockMongo(t)
+
+	mt.Run("引用已撤回的消息", func(mt *mtest.T) {
+		useMockMongo(mt)
+		target := model.ChatMessage{ID: primitive.NewObjectID(), SessionID: "private_1_2", FromUserID: 2, ToUserID: 1, Recalled: true, Timestamp: time.Now()}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch, toBSON(mt, target)))
+
+		hub := NewHub()
+		sender := newTestClient(hub, 1)
+		message := NewMessage(MessageTypePrivate, 1, 2, "reply")
+		message.ReplyTo = target.ID.Hex()
+		hub.HandlePrivateMessage(sender, message)
+
+		statuses := messagesOfType(receivedMessages(mt, sender), MessageTypeStatus)
+		if len(statuses) != 1 || messageData(statuses[0])["status"] != model.MessageStatusFailed || messageData(statuses[0])["reason"] != "引用的消息已撤回" {
+			mt.Errorf("发送者收到的状态 = %v, want failed 引用的消息已撤回", statuses)
+		}
+		for event := mt.GetStartedEvent(); event != nil; event = mt.GetStartedEvent() {
+			if event.CommandName == "insert" {
+				mt.Error("引用无效时不应保存消息")
+			}
+		}
+	})
+}
+
+func TestNewMessagesFromStored(t *testing.T) {
+	mt := newMockMongo(t)
+
+	mt.Run("附带被引用消息的快照", func(mt *mtest.T) {
+		useMockMongo(mt)
+		quoted := model.ChatMessage{ID: primitive.NewObjectID(), SessionID: "private_1_2", FromUserID: 2, Content: "question"}
+		reply := model.ChatMessage{ID: primitive.NewObjectID(), SessionID: "private_1_2", Type: "private", FromUserID: 1, ToUserID: 2, Content: "answer", ReplyTo: quoted.ID}
+		plain := model.ChatMessage{ID: primitive.NewObjectID(), SessionID: "private_1_2", Type: "private", FromUserID: 1, ToUserID: 2, Content: "plain"}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch, toBSON(mt, quoted)))
+
+		messages := NewMessagesFromStored([]model.ChatMessage{reply, plain})
+		if len(messages) != 2 || messages[0].ReplyTo != quoted.ID.Hex() || messages[1].ReplyTo != "" {
+			mt.Fatalf("messages = %+v", messages)
+		}
+		data := messages[0].Data.(PrivateMessageData)
+		if data.ReplyTo == nil || data.ReplyTo.Preview != "question" {
+			mt.Errorf("引用快照 = %+v, want question", data.ReplyTo)
+		}
+		if messages[1].Data.(PrivateMessageData).ReplyTo != nil {
+			mt.Error("没有引用的消息不应附带快照")
+		}
+	})
+}
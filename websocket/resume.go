@@ -0,0 +1,125 @@
+package websocket
+
+import (
+	"crypto/subtle"
+	"go_chat/config"
+	"sync"
+	"time"
+)
+
+// suspension 连接意外断开后的宽限期状态，期间用户保持在线并缓存推送消息
+type suspension struct {
+	mu      sync.Mutex
+	pending []*Message
+	dropped int
+	timer   *time.Timer
+}
+
+// buffer 缓存推送消息，超出上限时丢弃最早的消息
+func (s *suspension) buffer(message *Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) >= config.WebSocketResumeBuffer {
+		s.pending = s.pending[1:]
+		s.dropped++
+	}
+	s.pending = append(s.pending, message)
+}
+
+// suspend 关闭连接并进入宽限期，宽限期结束后通过Expire通道下线。
+// 连接已关闭、客户端主动断开或未启用宽限期时返回false
+func (c *Client) suspend() bool {
+	grace := config.WebSocketResumeGrace
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.IsAlive || !c.resumable || grace <= 0 {
+		return false
+	}
+
+	c.IsAlive = false
+	close(c.Send)
+	c.Conn.Close()
+	c.suspension = &suspension{}
+	c.suspension.timer = time.AfterFunc(grace, func() {
+		c.Hub.Expire <- c
+	})
+	return true
+}
+
+// takeSuspension 结束宽限期，返回期间缓存的消息和丢弃的消息数
+func (c *Client) takeSuspension() ([]*Message, int) {
+	c.mu.Lock()
+	s := c.suspension
+	c.suspension = nil
+	c.mu.Unlock()
+
+	if s == nil {
+		return nil, 0
+	}
+	s.timer.Stop()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending, s.dropped
+}
+
+// isSuspended 是否处于宽限期
+func (c *Client) isSuspended() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.suspension != nil
+}
+
+// awaitingResume 是否处于宽限期且仍可续接
+func (c *Client) awaitingResume() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.suspension != nil && c.resumable
+}
+
+// isOnline 连接正常或处于宽限期的用户均视为在线
+func (c *Client) isOnline() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.IsAlive || c.suspension != nil
+}
+
+// disableResume 断开后不再保留会话，用于主动断开和强制下线
+func (c *Client) disableResume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resumable = false
+}
+
+// canBeResumedBy 新连接是否携带了本连接的恢复令牌，且属于同一登录会话
+func (c *Client) canBeResumedBy(next *Client) bool {
+	if next.resumeFrom == "" || next.SessionID != c.SessionID {
+		return false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.resumable {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(next.resumeFrom), []byte(c.ResumeToken)) == 1
+}
+
+// sendResumeToken 向客户端下发本连接的恢复令牌
+func (h *Hub) sendResumeToken(client *Client, resumed bool, dropped int) {
+	client.SendMessage(NewSystemMessage(MessageTypeResume, ResumeData{
+		ResumeToken: client.ResumeToken,
+		GracePeriod: int(config.WebSocketResumeGrace / time.Second),
+		Resumed:     resumed,
+		Dropped:     dropped,
+	}))
+}
+
+// expireClient 宽限期结束仍未重连，用户正式下线
+func (h *Hub) expireClient(client *Client) {
+	if !client.isSuspended() {
+		// 已在宽限期内续接
+		return
+	}
+	h.removeClient(client)
+}
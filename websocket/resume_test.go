@@ -0,0 +1,171 @@
+package websocket
+
+import (
+	"go_chat/config"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// setupResumeConfig 设置测试用的断线宽限期配置，测试结束后恢复
+func setupResumeConfig(t testing.TB, buffer int) {
+	t.Helper()
+	grace, previous := config.WebSocketResumeGrace, config.WebSocketResumeBuffer
+	t.Cleanup(func() {
+		config.WebSocketResumeGrace, config.WebSocketResumeBuffer = grace, previous
+	})
+	config.WebSocketResumeGrace = 30 * time.Second
+	config.WebSocketResumeBuffer = buffer
+}
+
+// suspendedClient 构造处于宽限期的旧连接，并登记到Hub中
+func suspendedClient(hub *Hub, userID uint) *Client {
+	client := newTestClient(hub, userID)
+	client.SessionID = "session-a"
+	client.IsAlive = false
+	client.suspension = &suspension{timer: time.NewTimer(time.Hour)}
+	hub.Clients[client] = true
+	hub.UserClients[userID] = client
+	return client
+}
+
+func TestCanBeResumedBy(t *testing.T) {
+	tests := []struct {
+		name       string
+		resumable  bool
+		sessionID  string
+		resumeFrom string
+		want       bool
+	}{
+		{"令牌和会话一致", true, "session-a", "token-a", true},
+		{"未携带恢复令牌", true, "session-a", "", false},
+		{"恢复令牌不一致", true, "session-a", "token-b", false},
+		{"恢复令牌为前缀", true, "session-a", "token", false},
+		{"登录会话不同", true, "session-b", "token-a", false},
+		{"旧连接不可恢复", false, "session-a", "token-a", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := &Client{SessionID: "session-a", ResumeToken: "token-a", resumable: tt.resumable}
+			next := &Client{SessionID: tt.sessionID, resumeFrom: tt.resumeFrom}
+			if got := previous.canBeResumedBy(next); got != tt.want {
+				t.Errorf("canBeResumedBy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuspensionBuffer(t *testing.T) {
+	setupResumeConfig(t, 2)
+	s := &suspension{}
+	for _, content := range []string{"a", "b", "c"} {
+		s.buffer(NewMessage(MessageTypeTyping, 1, 0, content))
+	}
+	if len(s.pending) != 2 || s.pending[0].Content != "b" || s.dropped != 1 {
+		t.Errorf("pending = %v, dropped = %d, want [b c] 且丢弃1条", s.pending, s.dropped)
+	}
+}
+
+func TestEnqueueWhileSuspended(t *testing.T) {
+	setupResumeConfig(t, 10)
+	setupMessageConfig(t)
+	client := suspendedClient(NewHub(), 1)
+
+	if !client.SendMessage(NewMessage(MessageTypeTyping, 2, 0, "hi")) {
+		t.Error("宽限期内推送消息应进入缓存")
+	}
+	if client.SendTracked(NewMessage(MessageTypePrivate, 2, 1, "hi"), "m-1") {
+		t.Error("宽限期内需要确认的消息应转为离线消息")
+	}
+	if len(client.suspension.pending) != 1 {
+		t.Errorf("缓存的消息 = %v, want 1", client.suspension.pending)
+	}
+}
+
+func TestRegisterClientResume(t *testing.T) {
+	setupResumeConfig(t, 10)
+	setupMessageConfig(t)
+	mt := newMockMongo(t)
+	emptyOffline := func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "go_chat_test.read_receipts", mtest.FirstBatch),
+		)
+	}
+
+	mt.Run("凭恢复令牌续接会话", func(mt *mtest.T) {
+		useMockMongo(mt)
+		emptyOffline(mt)
+
+		hub := NewHub()
+		other := newTestClient(hub, 2)
+		hub.Clients[other] = true
+		hub.UserClients[2] = other
+		previous := suspendedClient(hub, 1)
+		previous.suspension.buffer(NewMessage(MessageTypeTyping, 2, 0, "buffered"))
+
+		next := newTestClient(hub, 1)
+		next.SessionID = "session-a"
+		next.resumeFrom = previous.ResumeToken
+		hub.registerClient(next)
+		hub.workers.Wait()
+
+		received := receivedMessages(mt, next)
+		resumes := messagesOfType(received, MessageTypeResume)
+		if len(resumes) != 1 || messageData(resumes[0])["resumed"] != true || messageData(resumes[0])["resume_token"] != next.ResumeToken {
+			mt.Errorf("恢复消息 = %v, want resumed", resumes)
+		}
+		if typing := messagesOfType(received, MessageTypeTyping); len(typing) != 1 || typing[0].Content != "buffered" {
+			mt.Errorf("补发的缓存消息 = %v, want buffered", typing)
+		}
+		if len(messagesOfType(received, MessageTypeUserList)) != 0 {
+			mt.Error("续接会话不应重新发送用户列表")
+		}
+		if joins := messagesOfType(receivedMessages(mt, other), MessageTypeJoin); len(joins) != 0 {
+			mt.Errorf("其他用户收到加入消息 = %v, want none", joins)
+		}
+		if _, ok := hub.Clients[previous]; ok || hub.UserClients[1] != next {
+			mt.Error("旧连接未被替换")
+		}
+	})
+
+	mt.Run("恢复令牌错误时作为新连接", func(mt *mtest.T) {
+		useMockMongo(mt)
+		emptyOffline(mt)
+
+		hub := NewHub()
+		previous := suspendedClient(hub, 1)
+		previous.suspension.buffer(NewMessage(MessageTypeTyping, 2, 0, "buffered"))
+
+		next := newTestClient(hub, 1)
+		next.SessionID = "session-a"
+		next.resumeFrom = "wrong-token"
+		hub.registerClient(next)
+		hub.workers.Wait()
+
+		received := receivedMessages(mt, next)
+		if resumes := messagesOfType(received, MessageTypeResume); len(resumes) != 1 || messageData(resumes[0])["resumed"] != false {
+			mt.Errorf("恢复消息 = %v, want 未续接", resumes)
+		}
+		if typing := messagesOfType(received, MessageTypeTyping); len(typing) != 0 {
+			mt.Errorf("未续接时不应补发缓存消息, got %v", typing)
+		}
+		if previous.isSuspended() {
+			mt.Error("旧连接的宽限期未结束")
+		}
+	})
+}
+
+func TestExpireClient(t *testing.T) {
+	hub := NewHub()
+	client := suspendedClient(hub, 1)
+	client.takeSuspension()
+
+	// 已续接的连接不会被下线
+	hub.expireClient(client)
+	if hub.UserClients[1] != client {
+		t.Error("已结束宽限期的连接被移除")
+	}
+}
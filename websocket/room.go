@@ -0,0 +1,207 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RoomPresenceData 房间成员状态变化附加数据
+type RoomPresenceData struct {
+	OnlineUser
+	RoomID     uint `json:"room_id"`
+	Membership bool `json:"membership,omitempty"` // true表示加入或退出房间，false表示房间成员上线或下线
+}
+
+// HandleRoomJoin 处理客户端加入公开房间，已加入的房间只返回房间在线成员列表
+func (h *Hub) HandleRoomJoin(from *Client, message *Message) {
+	if message.RoomID == 0 {
+		from.SendError(400, "房间ID不能为空")
+		return
+	}
+
+	joined, err := groupService.JoinRoom(from.UserID, message.RoomID)
+	if err != nil {
+		from.SendError(roomErrorCode(err), err.Error())
+		return
+	}
+	h.JoinRoom(from.UserID, message.RoomID, joined)
+}
+
+// HandleRoomLeave 处理客户端退出公开房间
+func (h *Hub) HandleRoomLeave(from *Client, message *Message) {
+	if message.RoomID == 0 {
+		from.SendError(400, "房间ID不能为空")
+		return
+	}
+
+	newOwnerID, err := groupService.LeaveRoom(from.UserID, message.RoomID)
+	if err != nil {
+		from.SendError(roomErrorCode(err), err.Error())
+		return
+	}
+	h.LeaveRoom(from.UserID, message.RoomID, newOwnerID)
+}
+
+// JoinRoom 用户加入房间后通知房间在线成员，并向该用户推送房间在线成员列表。
+// joined为false表示用户原本就在房间中，不再重复通知
+func (h *Hub) JoinRoom(userID, roomID uint, joined bool) {
+	memberIDs, err := groupService.ListRoomMemberIDs(roomID)
+	if err != nil {
+		logrus.Warnf("房间 %d 成员查询失败: %v", roomID, err)
+		return
+	}
+
+	if joined {
+		notice := NewSystemMessage(MessageTypeJoin, RoomPresenceData{
+			OnlineUser: h.presenceOf(userID),
+			RoomID:     roomID,
+			Membership: true,
+		})
+		notice.RoomID = roomID
+		for _, client := range h.onlineClients(memberIDs) {
+			client.SendMessage(notice)
+		}
+	}
+
+	if client, online := h.GetUserClient(userID); online {
+		h.sendRoomUserList(client, roomID, memberIDs)
+	}
+}
+
+// LeaveRoom 用户退出房间后通知房间在线成员和该用户本人，群主转让时同时推送群组事件
+func (h *Hub) LeaveRoom(userID, roomID, newOwnerID uint) {
+	memberIDs, err := groupService.ListMemberIDs(roomID)
+	if err != nil {
+		// 最后一名成员退出后房间已解散
+		memberIDs = nil
+	}
+
+	notice := NewSystemMessage(MessageTypeLeave, RoomPresenceData{
+		OnlineUser: h.presenceOf(userID),
+		RoomID:     roomID,
+		Membership: true,
+	})
+	notice.RoomID = roomID
+	for _, client := range h.onlineClients(append(memberIDs, userID)) {
+		client.SendMessage(notice)
+	}
+
+	if newOwnerID != 0 {
+		h.NotifyGroup(GroupEventData{
+			GroupID:    roomID,
+			Event:      GroupEventMemberLeft,
+			OperatorID: userID,
+			UserIDs:    []uint{userID},
+			NewOwnerID: newOwnerID,
+		})
+	}
+}
+
+// RoomOnlineUsers 获取房间中的在线成员
+func (h *Hub) RoomOnlineUsers(roomID uint) ([]OnlineUser, error) {
+	memberIDs, err := groupService.ListRoomMemberIDs(roomID)
+	if err != nil {
+		return nil, err
+	}
+	return h.onlineUsersOf(memberIDs), nil
+}
+
+// PeerOnlineUsers 获取与用户同在公开房间中的其他在线用户
+func (h *Hub) PeerOnlineUsers(userID uint) ([]OnlineUser, error) {
+	peers, err := groupService.ListRoomPeers(userID)
+	if err != nil {
+		return nil, err
+	}
+	return h.onlineUsersOf(peerUserIDs(userID, peers)), nil
+}
+
+// peerUserIDs 合并各房间的成员ID，去掉重复的用户和用户本人
+func peerUserIDs(userID uint, peers map[uint][]uint) []uint {
+	seen := map[uint]bool{userID: true}
+	var userIDs []uint
+	for _, memberIDs := range peers {
+		for _, memberID := range memberIDs {
+			if !seen[memberID] {
+				seen[memberID] = true
+				userIDs = append(userIDs, memberID)
+			}
+		}
+	}
+	return userIDs
+}
+
+// broadcastPresence 用户上线或下线时通知其所在各公开房间的在线成员，每个房间单独推送一条消息
+func (h *Hub) broadcastPresence(client *Client, msgType MessageType) map[uint][]uint {
+	peers, err := groupService.ListRoomPeers(client.UserID)
+	if err != nil {
+		logrus.Warnf("用户 %d 房间查询失败: %v", client.UserID, err)
+		return nil
+	}
+
+	user := client.ToOnlineUser()
+	if msgType == MessageTypeLeave {
+		user.Status = "offline"
+		user.LastSeen = time.Now()
+	}
+	for roomID, memberIDs := range peers {
+		notice := NewSystemMessage(msgType, RoomPresenceData{OnlineUser: user, RoomID: roomID})
+		notice.RoomID = roomID
+		for _, peer := range h.onlineClients(memberIDs) {
+			if peer != client {
+				peer.SendMessage(notice)
+			}
+		}
+	}
+	return peers
+}
+
+// sendRoomUserList 向客户端推送房间在线成员列表
+func (h *Hub) sendRoomUserList(client *Client, roomID uint, memberIDs []uint) {
+	message := NewSystemMessage(MessageTypeUserList, UserListData{
+		RoomID: roomID,
+		Users:  h.onlineUsersOf(memberIDs),
+	})
+	message.RoomID = roomID
+	client.SendMessage(message)
+}
+
+// onlineUsersOf 获取指定用户中在线用户的信息
+func (h *Hub) onlineUsersOf(userIDs []uint) []OnlineUser {
+	clients := h.onlineClients(userIDs)
+	users := make([]OnlineUser, 0, len(clients))
+	for _, client := range clients {
+		if client.isOnline() {
+			users = append(users, client.ToOnlineUser())
+		}
+	}
+	return users
+}
+
+// presenceOf 获取用户的在线状态，用户不在线时从数据库读取基本信息
+func (h *Hub) presenceOf(userID uint) OnlineUser {
+	if client, online := h.GetUserClient(userID); online && client.isOnline() {
+		return client.ToOnlineUser()
+	}
+
+	user := OnlineUser{UserID: userID, Status: "offline"}
+	if info, err := authService.GetUserByID(userID); err == nil {
+		user.UserName = info.UserName
+		user.Avatar = info.Avatar
+	}
+	return user
+}
+
+// roomErrorCode 房间操作错误对应的错误码
+func roomErrorCode(err error) int {
+	switch err.Error() {
+	case "房间不存在", "不是群成员":
+		return 404
+	case "群成员数量超过限制":
+		return 403
+	case "已被禁止加入该群组":
+		return ErrorCodeGroupBanned
+	default:
+		return 500
+	}
+}
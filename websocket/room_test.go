@@ -0,0 +1,136 @@
+package websocket
+
+import (
+	"go_chat/config"
+	"go_chat/model"
+	"go_chat/service"
+	"testing"
+)
+
+// setupRooms 准备公开房间测试所需的数据库，创建房间并让指定用户加入
+func setupRooms(t *testing.T, memberIDs ...uint) uint {
+	t.Helper()
+	db := setupMySQL(t, &model.User{}, &model.Group{}, &model.GroupMember{}, &model.GroupBan{})
+	previous := config.GroupMaxMembers
+	t.Cleanup(func() { config.GroupMaxMembers = previous })
+	config.GroupMaxMembers = 10
+
+	// 自增ID依次为1到4
+	for _, name := range []string{"user1", "user2", "user3", "user4"} {
+		db.Create(&model.User{UserName: name, Status: model.Active})
+	}
+	room, err := groupService.CreateRoom(memberIDs[0], service.CreateRoomRequest{Name: "room"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, userID := range memberIDs[1:] {
+		if _, err := groupService.JoinRoom(userID, room.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return room.ID
+}
+
+func TestRoomPresence(t *testing.T) {
+	roomID := setupRooms(t, 1, 2)
+	hub := NewHub()
+	member, stranger := newTestClient(hub, 2), newTestClient(hub, 3)
+	hub.UserClients[2], hub.UserClients[3] = member, stranger
+
+	// 上线时只通知同房间的用户，并收到房间在线成员列表
+	joining := newTestClient(hub, 1)
+	hub.UserClients[1] = joining
+	hub.broadcastUserJoin(joining)
+
+	if joins := messagesOfType(receivedMessages(t, member), MessageTypeJoin); len(joins) != 1 || joins[0].RoomID != roomID {
+		t.Errorf("同房间成员收到 = %v, want 1 条上线通知", joins)
+	}
+	if got := receivedMessages(t, stranger); len(got) != 0 {
+		t.Errorf("其他用户不应收到上线通知, got %v", got)
+	}
+	// 先收到同房间的在线用户列表，再收到各房间的在线成员列表
+	lists := messagesOfType(receivedMessages(t, joining), MessageTypeUserList)
+	if len(lists) != 2 || lists[0].RoomID != 0 || lists[1].RoomID != roomID {
+		t.Fatalf("上线用户收到的成员列表 = %v", lists)
+	}
+	if users, _ := messageData(lists[0])["users"].([]interface{}); len(users) != 1 || users[0].(map[string]interface{})["user_id"] != float64(2) {
+		t.Errorf("在线用户列表 = %v, want 只有同房间的用户2", users)
+	}
+	if users, _ := messageData(lists[1])["users"].([]interface{}); len(users) != 2 {
+		t.Errorf("房间在线成员 = %v, want 2", users)
+	}
+
+	// 加入房间时通知房间在线成员，退出时同时通知本人
+	hub.HandleRoomJoin(stranger, &Message{Type: MessageTypeJoin, RoomID: roomID})
+	if joins := messagesOfType(receivedMessages(t, member), MessageTypeJoin); len(joins) != 1 || messageData(joins[0])["membership"] != true {
+		t.Errorf("房间成员收到 = %v, want 1 条加入通知", joins)
+	}
+	if lists := messagesOfType(receivedMessages(t, stranger), MessageTypeUserList); len(lists) != 1 {
+		t.Errorf("加入者收到的成员列表 = %v, want 1", lists)
+	}
+	hub.HandleRoomLeave(stranger, &Message{Type: MessageTypeLeave, RoomID: roomID})
+	for _, client := range []*Client{member, stranger} {
+		if leaves := messagesOfType(receivedMessages(t, client), MessageTypeLeave); len(leaves) != 1 {
+			t.Errorf("用户 %d 收到 = %v, want 1 条退出通知", client.UserID, leaves)
+		}
+	}
+	queuedOutbound(joining)
+
+	// 下线时同样只通知同房间的用户
+	hub.broadcastUserLeave(member)
+	if leaves := messagesOfType(receivedMessages(t, joining), MessageTypeLeave); len(leaves) != 1 || messageData(leaves[0])["status"] != "offline" {
+		t.Errorf("同房间成员收到 = %v, want 1 条下线通知", leaves)
+	}
+	if got := receivedMessages(t, stranger); len(got) != 0 {
+		t.Errorf("已退出房间的用户不应收到下线通知, got %v", got)
+	}
+}
+
+func TestPeerOnlineUsers(t *testing.T) {
+	setupRooms(t, 1, 2, 3)
+	other, err := groupService.CreateRoom(2, service.CreateRoomRequest{Name: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := groupService.JoinRoom(1, other.ID); err != nil {
+		t.Fatal(err)
+	}
+	hub := NewHub()
+	for _, userID := range []uint{1, 2, 4} {
+		hub.UserClients[userID] = newTestClient(hub, userID)
+	}
+
+	// 用户2同在两个房间只出现一次，不包含本人、离线的用户3和不同房间的用户4
+	users, err := hub.PeerOnlineUsers(1)
+	if err != nil {
+		t.Fatalf("PeerOnlineUsers() error = %v", err)
+	}
+	if len(users) != 1 || users[0].UserID != 2 {
+		t.Errorf("PeerOnlineUsers() = %+v, want [2]", users)
+	}
+}
+
+func TestHandleRoomJoinRejects(t *testing.T) {
+	setupRooms(t, 1)
+	tests := []struct {
+		name     string
+		roomID   uint
+		wantCode float64
+		wantErr  string
+	}{
+		{"缺少房间ID", 0, 400, "房间ID不能为空"},
+		{"房间不存在", 999, 404, "房间不存在"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub()
+			client := newTestClient(hub, 2)
+			hub.HandleRoomJoin(client, &Message{Type: MessageTypeJoin, RoomID: tt.roomID})
+
+			errs := messagesOfType(receivedMessages(t, client), MessageTypeError)
+			if len(errs) != 1 || messageData(errs[0])["message"] != tt.wantErr || messageData(errs[0])["code"] != tt.wantCode {
+				t.Errorf("错误消息 = %v, want %v %s", errs, tt.wantCode, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package websocket
+
+import (
+	"go_chat/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// syncBatchLimit 单次同步补发的消息上限，需小于客户端发送缓冲区
+const syncBatchLimit = 200
+
+// HandleSyncMessage 处理同步请求，按序号补发客户端缺失的消息，最后发送同步结果
+func (h *Hub) HandleSyncMessage(from *Client, message *Message) {
+	var request SyncRequestData
+	if err := message.DecodeData(&request); err != nil {
+		from.SendError(400, "同步请求格式错误")
+		return
+	}
+
+	// 只能同步自己参与的会话
+	lastSeqs, err := conversationService.GetLastSeqs(from.UserID)
+	if err != nil {
+		from.SendError(500, err.Error())
+		return
+	}
+
+	cursors := make(map[string]int64, len(request.Conversations))
+	for _, cursor := range request.Conversations {
+		cursors[cursor.SessionID] = cursor.LastSeq
+	}
+
+	result := SyncResultData{Conversations: make([]SyncState, 0, len(lastSeqs))}
+	remaining := int64(syncBatchLimit)
+
+	for sessionID, lastSeq := range lastSeqs {
+		syncedSeq, requested := cursors[sessionID]
+		state := SyncState{SessionID: sessionID, SyncedSeq: syncedSeq, LastSeq: lastSeq}
+
+		// 客户端未上报的会话只返回最新序号，由客户端决定是否从0开始同步
+		if requested && syncedSeq < lastSeq {
+			if remaining <= 0 {
+				state.HasMore = true
+				result.Conversations = append(result.Conversations, state)
+				continue
+			}
+
+			limit := remaining
+			page, err := messageService.ListAfterSeq(sessionID, from.UserID, syncedSeq, limit)
+			if err != nil {
+				logrus.Warnf("会话 %s 同步失败: %v", sessionID, err)
+				state.HasMore = true
+				result.Conversations = append(result.Conversations, state)
+				continue
+			}
+
+			replays := NewMessagesFromStored(page.Messages)
+			replayed := 0
+			for i := range page.Messages {
+				stored := &page.Messages[i]
+				replay := replays[i]
+				var queued bool
+				if stored.ToUserID == from.UserID && isUndelivered(stored.Status) {
+					// 发给自己且尚未送达的消息需要客户端确认
+					queued = from.SendTracked(replay, stored.ID.Hex())
+				} else {
+					queued = from.SendMessage(replay)
+				}
+				if !queued {
+					break
+				}
+				state.SyncedSeq = stored.Seq
+				replayed++
+			}
+			if replayed == len(page.Messages) && page.LastSeq > state.SyncedSeq {
+				// 消息全部发出时，已跳过的序号同样视为已同步
+				state.SyncedSeq = page.LastSeq
+			}
+			remaining -= int64(replayed)
+			// 取满上限或中途发送失败说明可能还有后续消息；未取满时已同步到最新序号
+			state.HasMore = (page.Scanned == limit || replayed < len(page.Messages)) && state.SyncedSeq < lastSeq
+		}
+
+		result.Conversations = append(result.Conversations, state)
+	}
+
+	from.SendMessage(NewSystemMessage(MessageTypeSync, result))
+	logrus.Infof("用户 %d 同步完成，补发 %d 条消息", from.UserID, syncBatchLimit-remaining)
+}
+
+// isUndelivered 消息是否还未送达接收者
+func isUndelivered(status string) bool {
+	return status == model.MessageStatusSent || status == model.MessageStatusStored
+}
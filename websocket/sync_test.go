@@ -0,0 +1,178 @@
+package websocket
+
+import (
+	"go_chat/model"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// syncRequest 构造同步请求消息
+func syncRequest(cursors ...SyncCursor) *Message {
+	message := NewMessage(MessageTypeSync, 0, 0, "")
+	message.Data = SyncRequestData{Conversations: cursors}
+	return message
+}
+
+// storedMessages 构造会话中指定序号的消息文档
+func storedMessages(mt *mtest.T, sessionID string, seqs ...int64) []bson.D {
+	docs := make([]bson.D, 0, len(seqs))
+	for _, seq := range seqs {
+		docs = append(docs, toBSON(mt, model.ChatMessage{
+			ID:         primitive.NewObjectID(),
+			SessionID:  sessionID,
+			Seq:        seq,
+			Type:       "private",
+			FromUserID: 2,
+			ToUserID:   1,
+			Timestamp:  time.Now(),
+		}))
+	}
+	return docs
+}
+
+// syncResult 解析最后一条同步结果，返回补发的消息和各会话的同步状态
+func syncResult(mt *mtest.T, client *Client) ([]*Message, map[string]SyncState) {
+	mt.Helper()
+	received := receivedMessages(mt, client)
+	results := messagesOfType(received, MessageTypeSync)
+	if len(results) != 1 {
+		mt.Fatalf("同步结果 = %v, want 1", results)
+	}
+	var result SyncResultData
+	if err := results[0].DecodeData(&result); err != nil {
+		mt.Fatal(err)
+	}
+	states := make(map[string]SyncState, len(result.Conversations))
+	for _, state := range result.Conversations {
+		states[state.SessionID] = state
+	}
+	return messagesOfType(received, MessageTypePrivate), states
+}
+
+func TestHandleSyncMessage(t *testing.T) {
+	mt := newMockMongo(t)
+	lastSeqs := func(mt *mtest.T, conversations ...model.Conversation) bson.D {
+		docs := make([]bson.D, 0, len(conversations))
+		for _, conversation := range conversations {
+			docs = append(docs, toBSON(mt, conversation))
+		}
+		return mtest.CreateCursorResponse(0, "go_chat_test.conversations", mtest.FirstBatch, docs...)
+	}
+
+	mt.Run("跳过空号同步到最新序号", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(
+			lastSeqs(mt, model.Conversation{SessionID: "private_1_2", LastSeq: 6}),
+			// 序号3和6写入失败留下空号
+			mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch, storedMessages(mt, "private_1_2", 2, 4, 5)...),
+		)
+
+		hub := NewHub()
+		client := newTestClient(hub, 1)
+		hub.HandleSyncMessage(client, syncRequest(SyncCursor{SessionID: "private_1_2", LastSeq: 1}))
+
+		replayed, states := syncResult(mt, client)
+		if len(replayed) != 3 || replayed[0].Seq != 2 || replayed[2].Seq != 5 {
+			mt.Errorf("补发的消息 = %v, want seq 2, 4, 5", replayed)
+		}
+		if state := states["private_1_2"]; state.SyncedSeq != 5 || state.LastSeq != 6 || state.HasMore {
+			mt.Errorf("同步状态 = %+v, want synced 5, last 6, 没有更多", state)
+		}
+
+		nextCommand(mt, "find")
+		query := nextCommand(mt, "find")
+		if after := query.Lookup("filter", "seq", "$gt").AsInt64(); after != 1 {
+			mt.Errorf("查询起点 = %d, want 1", after)
+		}
+		if viewer := query.Lookup("filter", "hidden_for", "$ne").AsInt64(); viewer != 1 {
+			mt.Errorf("同步未排除查看者删除的消息: hidden_for $ne %d", viewer)
+		}
+	})
+
+	mt.Run("占位记录只推进同步位置", func(mt *mtest.T) {
+		useMockMongo(mt)
+		docs := storedMessages(mt, "private_1_2", 2, 4)
+		skipped := toBSON(mt, model.ChatMessage{ID: primitive.NewObjectID(), SessionID: "private_1_2", Seq: 5, Skipped: true, Timestamp: time.Now()})
+		mt.AddMockResponses(
+			lastSeqs(mt, model.Conversation{SessionID: "private_1_2", LastSeq: 5}),
+			mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch, docs[0], docs[1], skipped),
+		)
+
+		hub := NewHub()
+		client := newTestClient(hub, 1)
+		hub.HandleSyncMessage(client, syncRequest(SyncCursor{SessionID: "private_1_2", LastSeq: 1}))
+
+		replayed, states := syncResult(mt, client)
+		if len(replayed) != 2 || replayed[1].Seq != 4 {
+			mt.Errorf("补发的消息 = %v, want seq 2, 4", replayed)
+		}
+		if state := states["private_1_2"]; state.SyncedSeq != 5 || state.HasMore {
+			mt.Errorf("同步状态 = %+v, want synced 5 且没有更多", state)
+		}
+	})
+
+	mt.Run("取满上限时分页", func(mt *mtest.T) {
+		useMockMongo(mt)
+		seqs := make([]int64, syncBatchLimit)
+		for i := range seqs {
+			seqs[i] = int64(i + 1)
+		}
+		mt.AddMockResponses(
+			lastSeqs(mt, model.Conversation{SessionID: "private_1_2", LastSeq: syncBatchLimit + 50}),
+			mtest.CreateCursorResponse(0, "go_chat_test.messages", mtest.FirstBatch, storedMessages(mt, "private_1_2", seqs...)...),
+		)
+
+		hub := NewHub()
+		client := newTestClient(hub, 1)
+		hub.HandleSyncMessage(client, syncRequest(SyncCursor{SessionID: "private_1_2"}))
+
+		replayed, states := syncResult(mt, client)
+		if len(replayed) != syncBatchLimit {
+			mt.Errorf("补发的消息 = %d, want %d", len(replayed), syncBatchLimit)
+		}
+		if state := states["private_1_2"]; state.SyncedSeq != syncBatchLimit || !state.HasMore {
+			mt.Errorf("同步状态 = %+v, want synced %d 且还有更多", state, syncBatchLimit)
+		}
+	})
+
+	mt.Run("未上报和不属于自己的会话", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(lastSeqs(mt, model.Conversation{SessionID: "private_1_2", LastSeq: 4}))
+
+		hub := NewHub()
+		client := newTestClient(hub, 1)
+		hub.HandleSyncMessage(client, syncRequest(SyncCursor{SessionID: "private_8_9"}))
+
+		replayed, states := syncResult(mt, client)
+		if len(replayed) != 0 {
+			mt.Errorf("补发的消息 = %v, want none", replayed)
+		}
+		if _, ok := states["private_8_9"]; ok || len(states) != 1 {
+			mt.Errorf("同步状态 = %v, want 只包含自己的会话", states)
+		}
+		if state := states["private_1_2"]; state.SyncedSeq != 0 || state.LastSeq != 4 || state.HasMore {
+			mt.Errorf("未上报的会话状态 = %+v, want 只返回最新序号", state)
+		}
+		mt.GetStartedEvent()
+		if event := mt.GetStartedEvent(); event != nil {
+			mt.Errorf("未上报的会话不应查询消息, got %s", event.CommandName)
+		}
+	})
+
+	mt.Run("已是最新", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(lastSeqs(mt, model.Conversation{SessionID: "private_1_2", LastSeq: 4}))
+
+		hub := NewHub()
+		client := newTestClient(hub, 1)
+		hub.HandleSyncMessage(client, syncRequest(SyncCursor{SessionID: "private_1_2", LastSeq: 4}))
+
+		if _, states := syncResult(mt, client); states["private_1_2"].SyncedSeq != 4 || states["private_1_2"].HasMore {
+			mt.Errorf("同步状态 = %+v, want synced 4", states["private_1_2"])
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package websocket
+
+import (
+	"encoding/json"
+	"go_chat/config"
+	"go_chat/global"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// newMockMongo 创建模拟MongoDB部署，每个子测试通过mt.Run获得独立的模拟客户端
+func newMockMongo(t *testing.T) *mtest.T {
+	t.Helper()
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	t.Cleanup(mt.Close)
+	return mt
+}
+
+// useMockMongo 将全局MongoDB客户端替换为子测试的模拟客户端，测试结束后恢复
+func useMockMongo(mt *mtest.T) {
+	previous, database := global.MongoDBClient, config.MongoDBName
+	mt.Cleanup(func() {
+		global.MongoDBClient, config.MongoDBName = previous, database
+	})
+	global.MongoDBClient = mt.Client
+	config.MongoDBName = "go_chat_test"
+}
+
+// toBSON 将文档编码为模拟响应中使用的bson.D
+func toBSON(mt *mtest.T, value interface{}) bson.D {
+	mt.Helper()
+	data, err := bson.Marshal(value)
+	if err != nil {
+		mt.Fatal(err)
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		mt.Fatal(err)
+	}
+	return doc
+}
+
+// updatedResponse 模拟更新命令的结果
+func updatedResponse(modified int) bson.D {
+	return mtest.CreateSuccessResponse(bson.E{Key: "n", Value: modified}, bson.E{Key: "nModified", Value: modified})
+}
+
+// nextCommand 跳过其他命令，返回下一条指定名称的命令
+func nextCommand(mt *mtest.T, name string) bson.Raw {
+	mt.Helper()
+	for event := mt.GetStartedEvent(); event != nil; event = mt.GetStartedEvent() {
+		if event.CommandName == name {
+			return event.Command
+		}
+	}
+	mt.Fatalf("没有执行 %s 命令", name)
+	return nil
+}
+
+// setupRedis 启动内存Redis并替换全局客户端，测试结束后恢复
+func setupRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+
+	previous := global.RedisClient
+	t.Cleanup(func() {
+		global.RedisClient = previous
+		client.Close()
+	})
+	global.RedisClient = client
+	return server
+}
+
+// setupMySQL 使用内存SQLite替代MySQL并迁移指定的表，测试结束后恢复
+func setupMySQL(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+	// 每个测试使用独立的共享缓存内存库，连接池中的连接看到同一份数据
+	db, err := gorm.Open("sqlite3", "file:"+strings.ReplaceAll(t.Name(), "/", "_")+"?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	db.SingularTable(true)
+	// SQLite不支持行锁语法，事务本身已串行执行，去掉FOR UPDATE即可
+	db.Callback().Query().Before("gorm:query").Register("test:strip_for_update", func(scope *gorm.Scope) {
+		if option, ok := scope.Get("gorm:query_option"); ok && option == "FOR UPDATE" {
+			scope.Set("gorm:query_option", "")
+		}
+	})
+	if err := db.AutoMigrate(models...).Error; err != nil {
+		t.Fatalf("测试数据库迁移失败: %v", err)
+	}
+
+	previous := global.MySQLClient
+	t.Cleanup(func() {
+		global.MySQLClient = previous
+		db.Close()
+	})
+	global.MySQLClient = db
+	return db
+}
+
+// setupMessageConfig 设置测试用的消息确认配置，测试结束后恢复
+func setupMessageConfig(t testing.TB) {
+	t.Helper()
+	ackTimeout, window, retransmits := config.MessageAckTimeout, config.MessageInflightWindow, config.MessageMaxRetransmits
+	t.Cleanup(func() {
+		config.MessageAckTimeout, config.MessageInflightWindow, config.MessageMaxRetransmits = ackTimeout, window, retransmits
+	})
+	config.MessageAckTimeout = 15 * time.Second
+	config.MessageInflightWindow = 100
+	config.MessageMaxRetransmits = 3
+}
+
+// trackedOutbound 筛选需要客户端确认的待发送消息
+func trackedOutbound(queued []*outbound) []*outbound {
+	var tracked []*outbound
+	for _, item := range queued {
+		if item.messageID != "" {
+			tracked = append(tracked, item)
+		}
+	}
+	return tracked
+}
+
+// newTestClient 创建没有底层连接的客户端，发送的消息留在Send通道中供测试读取
+func newTestClient(hub *Hub, userID uint) *Client {
+	return NewClient(hub, nil, userID, "user"+strconv.FormatUint(uint64(userID), 10), "")
+}
+
+// queuedOutbound 取出客户端发送通道中的全部待发送消息
+func queuedOutbound(client *Client) []*outbound {
+	var queued []*outbound
+	for {
+		select {
+		case message := <-client.Send:
+			queued = append(queued, message)
+		default:
+			return queued
+		}
+	}
+}
+
+// decodeOutbound 解析待发送消息
+func decodeOutbound(t testing.TB, queued []*outbound) []*Message {
+	t.Helper()
+	messages := make([]*Message, 0, len(queued))
+	for _, item := range queued {
+		var message Message
+		if err := json.Unmarshal(item.data, &message); err != nil {
+			t.Fatalf("消息解析失败: %v", err)
+		}
+		messages = append(messages, &message)
+	}
+	return messages
+}
+
+// receivedMessages 取出并解析客户端发送通道中的全部消息
+func receivedMessages(t testing.TB, client *Client) []*Message {
+	t.Helper()
+	return decodeOutbound(t, queuedOutbound(client))
+}
+
+// messagesOfType 筛选指定类型的消息
+func messagesOfType(messages []*Message, msgType MessageType) []*Message {
+	var matched []*Message
+	for _, message := range messages {
+		if message.Type == msgType {
+			matched = append(matched, message)
+		}
+	}
+	return matched
+}
+
+// messageData 将消息附加数据解析为map
+func messageData(message *Message) map[string]interface{} {
+	data, _ := message.Data.(map[string]interface{})
+	return data
+}